@@ -0,0 +1,7 @@
+module arpthrift
+
+go 1.15
+
+require git.apache.org/thrift.git v0.10.0
+
+replace git.apache.org/thrift.git v0.10.0 => github.com/apache/thrift v0.10.0
@@ -0,0 +1,12 @@
+module arpthrifttest
+
+go 1.15
+
+require (
+	arpthrift v0.0.0
+	git.apache.org/thrift.git v0.10.0
+)
+
+replace arpthrift v0.0.0 => ../arpthrift
+
+replace git.apache.org/thrift.git v0.10.0 => github.com/apache/thrift v0.10.0
@@ -1,13 +1,68 @@
 package main
 
 import (
-    "fmt"
     "arpthrift"
+    "encoding/binary"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "sort"
+    "sync"
     "git.apache.org/thrift.git/lib/go/thrift"
 )
 
+// The mock keeps the state a real ARP responder would: the interfaces it
+// listens on and the IP it answers with on each stag/ctag of them. It
+// resolves the neighbors of its fixture, but only over an interface and tag
+// pair that has an IP, as the responder needs one to send ARP requests from.
+// Calls the responder would refuse, e.g. adding an IP to an unknown
+// interface, return false.
+//
+// Its state comes from a JSON fixture, see Fixture, and every call is logged.
+// An HTTP endpoint lets tests look at and reset both:
+//
+//   GET    /calls    calls since the last reset
+//   DELETE /calls    clear the call log
+//   GET    /state    current state, in fixture format
+//   PUT    /fixture  load the fixture in the body and clear the call log
+//   POST   /reset    reload the startup fixture and clear the call log
+
+var addrFlag = flag.String("addr", "localhost:9091", "Thrift listen address")
+var httpAddrFlag = flag.String("http", ":9191", "Listen address of the call log and fixture endpoint")
+var fixtureFlag = flag.String("fixture", "", "JSON fixture to start from, the built-in one if empty")
+
 func main() {
-    transport, err := thrift.NewTServerSocket("localhost:9091")
+    flag.Parse()
+
+    fixture := defaultFixture()
+    if *fixtureFlag != "" {
+        data, err := ioutil.ReadFile(*fixtureFlag)
+        if err == nil {
+            fixture, err = parseFixture(data)
+        }
+        if err != nil {
+            fmt.Println("Error loading fixture:", err)
+            return
+        }
+    }
+
+    handler := NewArpHandler(fixture)
+    if err := handler.load(fixture); err != nil {
+        fmt.Println("Error loading fixture:", err)
+        return
+    }
+
+    go func() {
+        fmt.Println("Serving call log on", *httpAddrFlag)
+        if err := http.ListenAndServe(*httpAddrFlag, handler); err != nil {
+            fmt.Println("Error serving call log:", err)
+        }
+    }()
+
+    transport, err := thrift.NewTServerSocket(*addrFlag)
 
     if err != nil {
         fmt.Println("Error opening socket:", err)
@@ -20,7 +75,6 @@ func main() {
         return
     }
 
-    handler := NewArpHandler()
     processor := arp.NewArpResponderProcessor(handler)
     server := thrift.NewTSimpleServer2(processor, transport)
 
@@ -28,57 +82,334 @@ func main() {
     server.Serve()
 }
 
+// Fixture is the mock state in JSON. A neighbor without an interface is
+// reachable over any interface with an IP. Results scripts the next results
+// of a method by its Thrift name; they are returned in order, without
+// touching the state, before the mock answers on its own again. E.g.
+//
+//   {"interfaces": ["Ethernet0"],
+//    "ips": [{"iface_name": "Ethernet0", "stag": 100, "ctag": 200, "ip": "10.1.1.1"}],
+//    "neighbors": [{"ip": "10.1.1.2", "mac": "00:11:22:33:44:55"}],
+//    "results": {"add_ip": [false]}}
+type Fixture struct {
+    Interfaces []string          `json:"interfaces"`
+    IPs        []FixtureIP       `json:"ips"`
+    Neighbors  []FixtureNeighbor `json:"neighbors"`
+    Results    map[string][]bool `json:"results,omitempty"`
+}
+
+type FixtureIP struct {
+    IfaceName string `json:"iface_name"`
+    Stag      int16  `json:"stag"`
+    Ctag      int16  `json:"ctag"`
+    IP        string `json:"ip"`
+}
+
+type FixtureTag struct {
+    IfaceName string `json:"iface_name"`
+    Stag      int16  `json:"stag"`
+    Ctag      int16  `json:"ctag"`
+}
+
+type FixtureNeighbor struct {
+    IP        string      `json:"ip"`
+    Mac       string      `json:"mac"`
+    Interface *FixtureTag `json:"interface,omitempty"`
+}
+
+// defaultFixture has two neighbors and no interfaces
+func defaultFixture() *Fixture {
+    return &Fixture{
+        Neighbors: []FixtureNeighbor{
+            {IP: "12.34.56.78", Mac: "12:34:56:78:90:12"},
+            {IP: "90.12.34.56", Mac: "34:56:78:90:12:34"},
+        },
+    }
+}
+
+func parseFixture(data []byte) (*Fixture, error) {
+    var fixture Fixture
+    if err := json.Unmarshal(data, &fixture); err != nil {
+        return nil, err
+    }
+    return &fixture, nil
+}
+
+// Call is one entry of the call log
+type Call struct {
+    Method string                 `json:"method"`
+    Args   map[string]interface{} `json:"args"`
+    Result interface{}            `json:"result"`
+}
+
+type tagKey struct {
+    stag int16
+    ctag int16
+}
+
+type neighbor struct {
+    mac   net.HardwareAddr
+    iface *FixtureTag
+}
+
 type ArpHandler struct {
+    mu         sync.Mutex
+    startup    *Fixture
+    interfaces map[string]map[tagKey]string
+    neighbors  map[string]neighbor
+    results    map[string][]bool
+    calls      []Call
 }
 
-func NewArpHandler() *ArpHandler {
-    return &ArpHandler{}
+func NewArpHandler(startup *Fixture) *ArpHandler {
+    return &ArpHandler{startup: startup}
+}
+
+// load replaces the state with the fixture and clears the call log
+func (p *ArpHandler) load(fixture *Fixture) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    interfaces := make(map[string]map[tagKey]string)
+    for _, iface := range fixture.Interfaces {
+        interfaces[iface] = make(map[tagKey]string)
+    }
+    for _, ip := range fixture.IPs {
+        ips, ok := interfaces[ip.IfaceName]
+        if !ok {
+            return fmt.Errorf("IP %s on unknown interface %s", ip.IP, ip.IfaceName)
+        }
+        if net.ParseIP(ip.IP).To4() == nil {
+            return fmt.Errorf("invalid IPv4 address %q", ip.IP)
+        }
+        ips[tagKey{ip.Stag, ip.Ctag}] = ip.IP
+    }
+    neighbors := make(map[string]neighbor)
+    for _, n := range fixture.Neighbors {
+        mac, err := net.ParseMAC(n.Mac)
+        if err != nil {
+            return err
+        }
+        ip := net.ParseIP(n.IP).To4()
+        if ip == nil {
+            return fmt.Errorf("invalid IPv4 address %q", n.IP)
+        }
+        neighbors[ip.String()] = neighbor{mac: mac, iface: n.Interface}
+    }
+
+    p.interfaces = interfaces
+    p.neighbors = neighbors
+    p.results = make(map[string][]bool)
+    for method, results := range fixture.Results {
+        p.results[method] = append([]bool{}, results...)
+    }
+    p.calls = nil
+    return nil
+}
+
+// record logs a call. A scripted result, if any, replaces the one the state
+// gives; apply then does not run.
+func (p *ArpHandler) record(method string, args map[string]interface{}, apply func() bool) bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    var r bool
+    if scripted := p.results[method]; len(scripted) > 0 {
+        r = scripted[0]
+        p.results[method] = scripted[1:]
+    } else {
+        r = apply()
+    }
+    fmt.Printf("%s(%v) = %v\n", method, args, r)
+    p.calls = append(p.calls, Call{Method: method, Args: args, Result: r})
+    return r
 }
 
 func (p *ArpHandler) AddInterface(iface_name string) (r bool, err error) {
-    fmt.Printf("add_interface(%v)\n", iface_name)
-    r = true
+    args := map[string]interface{}{"iface_name": iface_name}
+    r = p.record("add_interface", args, func() bool {
+        if _, ok := p.interfaces[iface_name]; ok {
+            return false
+        }
+        p.interfaces[iface_name] = make(map[tagKey]string)
+        return true
+    })
     return
 }
 
+// DelInterface also drops the IPs of the interface
 func (p *ArpHandler) DelInterface(iface_name string) (r bool, err error) {
-    fmt.Printf("del_interface(%v)\n", iface_name)
-    r = true
+    args := map[string]interface{}{"iface_name": iface_name}
+    r = p.record("del_interface", args, func() bool {
+        if _, ok := p.interfaces[iface_name]; !ok {
+            return false
+        }
+        delete(p.interfaces, iface_name)
+        return true
+    })
     return
 }
 
+// AddIP replaces the IP already set on the tags, if any
 func (p *ArpHandler) AddIP(iface_name string, stag arp.VlanTagT, ctag arp.VlanTagT, ip arp.Ip4T) (r bool, err error) {
-    fmt.Printf("add_ip(%v, %+v, %v, %v)\n", iface_name, stag, ctag, ip)
-    r = true
+    ip_str := ipString(ip)
+    args := map[string]interface{}{"iface_name": iface_name, "stag": stag, "ctag": ctag, "ip": ip_str}
+    r = p.record("add_ip", args, func() bool {
+        ips, ok := p.interfaces[iface_name]
+        if !ok {
+            return false
+        }
+        ips[tagKey{int16(stag), int16(ctag)}] = ip_str
+        return true
+    })
     return
 }
 
 func (p *ArpHandler) DelIP(iface_name string, stag arp.VlanTagT, ctag arp.VlanTagT) (r bool, err error) {
-    fmt.Printf("del_ip(%v, %+v, %+v)\n", iface_name, stag, ctag)
-    r = true
+    args := map[string]interface{}{"iface_name": iface_name, "stag": stag, "ctag": ctag}
+    r = p.record("del_ip", args, func() bool {
+        key := tagKey{int16(stag), int16(ctag)}
+        if _, ok := p.interfaces[iface_name][key]; !ok {
+            return false
+        }
+        delete(p.interfaces[iface_name], key)
+        return true
+    })
     return
 }
 
+// RequestMac answers every request, with the first of its tuples the
+// neighbor is reachable over
 func (p *ArpHandler) RequestMac(requests []*arp.ReqTuplesT) (r []*arp.RepTupleT, err error) {
-    fmt.Printf("request_mac(%v)\n", requests)
+    p.mu.Lock()
+    defer p.mu.Unlock()
 
+    logged := make([]map[string]interface{}, 0, len(requests))
+    results := make([]map[string]interface{}, 0, len(requests))
     for _, req := range requests {
-        if req.IP == 203569230 {
-            r = append(r, &arp.RepTupleT{
-                Request: req.Tuples[int(req.Index) % len(req.Tuples)],
-                Index: req.Index,
-                Mac: arp.MacT([]byte{0x12, 0x34, 0x56, 0x78, 0x90, 0x12}),
-                IsFound: true,
-            })
-        } else if req.IP == 1510744632 {
-            r = append(r, &arp.RepTupleT{
-                Request: req.Tuples[int(req.Index) % len(req.Tuples)],
-                Index: req.Index,
-                Mac: arp.MacT([]byte{0x34, 0x56, 0x78, 0x90, 0x12, 0x34}),
-                IsFound: true,
-            })
+        ip_str := ipString(req.IP)
+        tuples := make([]FixtureTag, 0, len(req.Tuples))
+        reply := &arp.RepTupleT{Index: req.Index}
+        for _, t := range req.Tuples {
+            tag := FixtureTag{IfaceName: t.IfaceName, Stag: int16(t.Stag), Ctag: int16(t.Ctag)}
+            tuples = append(tuples, tag)
+            if !reply.IsFound && p.reachable(ip_str, tag) {
+                reply.Request = t
+                reply.Mac = arp.MacT(p.neighbors[ip_str].mac)
+                reply.IsFound = true
+            }
+        }
+        if !reply.IsFound && len(req.Tuples) > 0 {
+            reply.Request = req.Tuples[0]
+        }
+        r = append(r, reply)
+
+        logged = append(logged, map[string]interface{}{"index": req.Index, "ip": ip_str, "tuples": tuples})
+        result := map[string]interface{}{"index": reply.Index, "is_found": reply.IsFound}
+        if reply.IsFound {
+            result["mac"] = net.HardwareAddr(reply.Mac).String()
         }
+        results = append(results, result)
     }
 
+    args := map[string]interface{}{"requests": logged}
+    fmt.Printf("request_mac(%v) = %v\n", args, results)
+    p.calls = append(p.calls, Call{Method: "request_mac", Args: args, Result: results})
     return
 }
+
+func (p *ArpHandler) reachable(ip_str string, tag FixtureTag) bool {
+    n, ok := p.neighbors[ip_str]
+    if !ok {
+        return false
+    }
+    if _, ok = p.interfaces[tag.IfaceName][tagKey{tag.Stag, tag.Ctag}]; !ok {
+        return false
+    }
+    return n.iface == nil || *n.iface == tag
+}
+
+// state gives the current state in fixture format, sorted so it compares
+// stably
+func (p *ArpHandler) state() *Fixture {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    s := &Fixture{
+        Interfaces: []string{},
+        IPs:        []FixtureIP{},
+        Neighbors:  []FixtureNeighbor{},
+    }
+    for iface, ips := range p.interfaces {
+        s.Interfaces = append(s.Interfaces, iface)
+        for key, ip := range ips {
+            s.IPs = append(s.IPs, FixtureIP{IfaceName: iface, Stag: key.stag, Ctag: key.ctag, IP: ip})
+        }
+    }
+    sort.Strings(s.Interfaces)
+    sort.Slice(s.IPs, func(i, j int) bool {
+        a, b := s.IPs[i], s.IPs[j]
+        if a.IfaceName != b.IfaceName {
+            return a.IfaceName < b.IfaceName
+        }
+        if a.Stag != b.Stag {
+            return a.Stag < b.Stag
+        }
+        return a.Ctag < b.Ctag
+    })
+    for ip, n := range p.neighbors {
+        s.Neighbors = append(s.Neighbors, FixtureNeighbor{IP: ip, Mac: n.mac.String(), Interface: n.iface})
+    }
+    sort.Slice(s.Neighbors, func(i, j int) bool { return s.Neighbors[i].IP < s.Neighbors[j].IP })
+    return s
+}
+
+func (p *ArpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    switch {
+    case r.URL.Path == "/calls" && r.Method == http.MethodGet:
+        p.mu.Lock()
+        calls := append([]Call{}, p.calls...)
+        p.mu.Unlock()
+        writeJSON(w, calls)
+    case r.URL.Path == "/calls" && r.Method == http.MethodDelete:
+        p.mu.Lock()
+        p.calls = nil
+        p.mu.Unlock()
+        w.WriteHeader(http.StatusNoContent)
+    case r.URL.Path == "/state" && r.Method == http.MethodGet:
+        writeJSON(w, p.state())
+    case r.URL.Path == "/fixture" && r.Method == http.MethodPut:
+        data, err := ioutil.ReadAll(r.Body)
+        var fixture *Fixture
+        if err == nil {
+            fixture, err = parseFixture(data)
+        }
+        if err == nil {
+            err = p.load(fixture)
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    case r.URL.Path == "/reset" && r.Method == http.MethodPost:
+        if err := p.load(p.startup); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    json.NewEncoder(w).Encode(v)
+}
+
+func ipString(ip arp.Ip4T) string {
+    b := make(net.IP, net.IPv4len)
+    binary.BigEndian.PutUint32(b, uint32(ip))
+    return b.String()
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+    "go-server-server/models"
+    "net/url"
+    "strconv"
+)
+
+// plainRoute decodes the routes the server lists, which carry no cmd for
+// the validation of models.RouteModel to accept
+type plainRoute models.RouteModel
+
+func plainRoutes(routes []plainRoute) []models.RouteModel {
+    out := make([]models.RouteModel, len(routes))
+    for i, r := range routes {
+        out[i] = models.RouteModel(r)
+    }
+    return out
+}
+
+// RouteFilter narrows down a route listing, zero values match everything
+type RouteFilter struct {
+    IPPrefix string
+    Vnid     int
+}
+
+func (f RouteFilter) query() url.Values {
+    q := url.Values{}
+    if f.IPPrefix != "" {
+        q.Set("ip_prefix", f.IPPrefix)
+    }
+    if f.Vnid != 0 {
+        q.Set("vnid", strconv.Itoa(f.Vnid))
+    }
+    return q
+}
+
+func (c *Client) Heartbeat() (*models.HeartbeatReturnModel, error) {
+    var out models.HeartbeatReturnModel
+    if err := c.Do("GET", "/state/heartbeat", nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+func (c *Client) Ping(req models.PingRequestModel) (*models.PingReturnModel, error) {
+    var out models.PingReturnModel
+    if err := c.Do("POST", "/operations/ping", nil, req, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+func (c *Client) Snapshot() (*models.SnapshotModel, error) {
+    var out models.SnapshotModel
+    if err := c.Do("GET", "/config/snapshot", nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+// VNETs
+
+func (c *Client) CreateVnet(name string, attr models.VnetModel) error {
+    return c.Do("POST", "/config/vrouter/" + url.PathEscape(name), nil, attr, nil)
+}
+
+func (c *Client) GetVnet(name string) (*models.VnetReturnModel, error) {
+    var out models.VnetReturnModel
+    if err := c.Do("GET", "/config/vrouter/" + url.PathEscape(name), nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+func (c *Client) DeleteVnet(name string) error {
+    return c.Do("DELETE", "/config/vrouter/" + url.PathEscape(name), nil, nil, nil)
+}
+
+// ListVnets gives every VNET, there is no listing of its own so they come
+// from the configuration snapshot. Only its VNETs are decoded, the models
+// of its routes would not take every route the server holds.
+func (c *Client) ListVnets() ([]models.VnetReturnModel, error) {
+    var out struct {
+        Vnets []models.VnetReturnModel `json:"vnets"`
+    }
+    err := c.Do("GET", "/config/snapshot", nil, nil, &out)
+    return out.Vnets, err
+}
+
+// Routes
+
+func (c *Client) ListRoutes(vnet string, filter RouteFilter) ([]models.RouteModel, error) {
+    var out []plainRoute
+    err := c.Do("GET", "/config/vrouter/" + url.PathEscape(vnet) + "/routes", filter.query(), nil, &out)
+    return plainRoutes(out), err
+}
+
+// PatchRoutes applies the add, append, delete and remove commands of routes
+// to the routes of a VNET, and gives back those that failed
+func (c *Client) PatchRoutes(vnet string, routes []models.RouteModel) ([]models.RouteModel, error) {
+    var out models.RouteReturnModel
+    err := c.Do("PATCH", "/config/vrouter/" + url.PathEscape(vnet) + "/routes", nil, routes, &out)
+    return out.Failed, err
+}
+
+// DeleteRoutes removes every route of a VNET, or those towards vnid if it
+// is not 0, and gives back those that could not be removed
+func (c *Client) DeleteRoutes(vnet string, vnid int) ([]models.RouteModel, error) {
+    var out models.RouteReturnModel
+    err := c.Do("DELETE", "/config/vrouter/" + url.PathEscape(vnet) + "/routes", RouteFilter{Vnid: vnid}.query(), nil, &out)
+    return out.Failed, err
+}
+
+func (c *Client) ListVrfRoutes(vrf string, filter RouteFilter) ([]models.RouteModel, error) {
+    var out []plainRoute
+    err := c.Do("GET", "/config/vrf/" + url.PathEscape(vrf) + "/routes", filter.query(), nil, &out)
+    return plainRoutes(out), err
+}
+
+func (c *Client) PatchVrfRoutes(vrf string, routes []models.RouteModel) ([]models.RouteModel, error) {
+    var out models.RouteReturnModel
+    err := c.Do("PATCH", "/config/vrf/" + url.PathEscape(vrf) + "/routes", nil, routes, &out)
+    return out.Failed, err
+}
+
+// VLANs
+
+func vlanPath(vlan_id int) string {
+    return "/config/interface/vlan/" + strconv.Itoa(vlan_id)
+}
+
+func (c *Client) CreateVlan(vlan_id int, attr models.VlanModel) error {
+    return c.Do("POST", vlanPath(vlan_id), nil, attr, nil)
+}
+
+func (c *Client) GetVlan(vlan_id int) (*models.VlanReturnModel, error) {
+    var out models.VlanReturnModel
+    if err := c.Do("GET", vlanPath(vlan_id), nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+func (c *Client) DeleteVlan(vlan_id int) error {
+    return c.Do("DELETE", vlanPath(vlan_id), nil, nil, nil)
+}
+
+func (c *Client) ListVlans() ([]models.VlansModel, error) {
+    var out models.VlansReturnModel
+    err := c.Do("GET", "/config/interface/vlans/all", nil, nil, &out)
+    return out.Attr, err
+}
+
+func (c *Client) ListVnetVlans(vnet string) ([]models.VlansPerVnetModel, error) {
+    var out models.VlansPerVnetReturnModel
+    err := c.Do("GET", "/config/interface/vlans", url.Values{"vnet_id": {vnet}}, nil, &out)
+    return out.Attr, err
+}
+
+func (c *Client) AddVlanMember(vlan_id int, if_name string, attr models.VlanMemberModel) error {
+    return c.Do("POST", vlanPath(vlan_id) + "/member/" + url.PathEscape(if_name), nil, attr, nil)
+}
+
+func (c *Client) DeleteVlanMember(vlan_id int, if_name string) error {
+    return c.Do("DELETE", vlanPath(vlan_id) + "/member/" + url.PathEscape(if_name), nil, nil, nil)
+}
+
+func (c *Client) ListVlanMembers(vlan_id int) ([]models.VlanMembersModel, error) {
+    var out models.VlanMembersReturnModel
+    err := c.Do("GET", vlanPath(vlan_id) + "/members", nil, nil, &out)
+    return out.Attr, err
+}
+
+func (c *Client) ListAllVlanMembers() ([]models.VlanMembersReturnModel, error) {
+    var out models.VlanMembersAllReturnModel
+    err := c.Do("GET", "/config/interface/vlans/members/all", nil, nil, &out)
+    return out.Attr, err
+}
+
+func (c *Client) AddVlanNeighbor(vlan_id int, ip_addr string) error {
+    return c.Do("POST", vlanPath(vlan_id) + "/neighbor/" + url.PathEscape(ip_addr), nil, nil, nil)
+}
+
+func (c *Client) DeleteVlanNeighbor(vlan_id int, ip_addr string) error {
+    return c.Do("DELETE", vlanPath(vlan_id) + "/neighbor/" + url.PathEscape(ip_addr), nil, nil, nil)
+}
+
+func (c *Client) ListVlanNeighbors(vlan_id int) ([]models.VlanNeighborsModel, error) {
+    var out models.VlanNeighborsReturnModel
+    err := c.Do("GET", vlanPath(vlan_id) + "/neighbors", nil, nil, &out)
+    return out.Attr, err
+}
+
+// Tunnels
+
+func (c *Client) CreateDecapTunnel(tunnel_type string, attr models.TunnelDecapModel) error {
+    return c.Do("POST", "/config/tunnel/decap/" + url.PathEscape(tunnel_type), nil, attr, nil)
+}
+
+func (c *Client) GetDecapTunnel(tunnel_type string) (*models.TunnelDecapReturnModel, error) {
+    var out models.TunnelDecapReturnModel
+    if err := c.Do("GET", "/config/tunnel/decap/" + url.PathEscape(tunnel_type), nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+func (c *Client) DeleteDecapTunnel(tunnel_type string) error {
+    return c.Do("DELETE", "/config/tunnel/decap/" + url.PathEscape(tunnel_type), nil, nil, nil)
+}
+
+func (c *Client) CreateEncapTunnel(vnid int) error {
+    return c.Do("POST", "/config/tunnel/encap/vxlan/" + strconv.Itoa(vnid), nil, nil, nil)
+}
+
+func (c *Client) DeleteEncapTunnel(vnid int) error {
+    return c.Do("DELETE", "/config/tunnel/encap/vxlan/" + strconv.Itoa(vnid), nil, nil, nil)
+}
+
+// BGP profiles
+
+func (c *Client) SetBgpProfile(name string, attr models.BgpProfileModel) error {
+    return c.Do("POST", "/config/bgp/profile/" + url.PathEscape(name), nil, attr, nil)
+}
+
+func (c *Client) GetBgpProfile(name string) (*models.BgpProfileModel, error) {
+    var out models.BgpProfileModel
+    if err := c.Do("GET", "/config/bgp/profile/" + url.PathEscape(name), nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+func (c *Client) DeleteBgpProfile(name string) error {
+    return c.Do("DELETE", "/config/bgp/profile/" + url.PathEscape(name), nil, nil, nil)
+}
+
+// Maintenance mode
+
+func (c *Client) GetMaintenance() (*models.MaintenanceModel, error) {
+    var out models.MaintenanceModel
+    if err := c.Do("GET", "/config/maintenance", nil, nil, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
+
+// SetMaintenance gives the mode the server is in afterwards, which stays
+// maintenance while the maintenance file of the server exists
+func (c *Client) SetMaintenance(attr models.MaintenanceModel) (*models.MaintenanceModel, error) {
+    var out models.MaintenanceModel
+    if err := c.Do("POST", "/config/maintenance", nil, attr, &out); err != nil {
+        return nil, err
+    }
+    return &out, nil
+}
@@ -0,0 +1,268 @@
+// Package client is a Go client of the SONiC REST API, built on the request
+// and response models the server shares in package models.
+package client
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/json"
+    "fmt"
+    "github.com/satori/go.uuid"
+    "go-server-server/models"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const DEFAULT_URL string = "http://localhost:8090"
+const DEFAULT_TIMEOUT time.Duration = 30 * time.Second
+const DEFAULT_RETRIES int = 3
+const DEFAULT_RETRY_BACKOFF time.Duration = 200 * time.Millisecond
+
+// Longest wait between two attempts, whatever Retry-After asks for
+const MAX_RETRY_WAIT time.Duration = 10 * time.Second
+
+// Config describes how to reach the server. ClientCert and ClientKey enable
+// mutual TLS against the https endpoint, CACert is the PEM file the server
+// certificate is checked against, the system pool without one.
+type Config struct {
+    URL                string
+    ClientCert         string
+    ClientKey          string
+    CACert             string
+    InsecureSkipVerify bool
+    Timeout            time.Duration
+    // Attempts after the first one of a request that failed on the way or
+    // got 429, 502, 503 or 504
+    Retries            int
+    RetryBackoff       time.Duration
+    // Namespace every request is sent to, the default one if empty
+    Namespace          string
+    // Unix socket endpoint every request is sent to instead of the host of
+    // URL, e.g. /var/run/rest-api/rest-api.sock
+    UnixSocket         string
+}
+
+type Client struct {
+    base      *url.URL
+    http      *http.Client
+    retries   int
+    backoff   time.Duration
+    namespace string
+}
+
+// Error is an error answer of the server, decoded from its ErrorModel body
+type Error struct {
+    StatusCode int
+    models.ErrorInner
+}
+
+func (e *Error) Error() string {
+    msg := fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+    if e.SubCode != nil {
+        msg += fmt.Sprintf(" (sub-code %d)", *e.SubCode)
+    }
+    if len(e.Fields) > 0 {
+        msg += ", fields: " + strings.Join(e.Fields, ", ")
+    }
+    if e.Details != "" {
+        msg += ", " + e.Details
+    }
+    return msg
+}
+
+func statusOf(err error) int {
+    if e, ok := err.(*Error); ok {
+        return e.StatusCode
+    }
+    return 0
+}
+
+func IsNotFound(err error) bool {
+    return statusOf(err) == http.StatusNotFound
+}
+
+func IsConflict(err error) bool {
+    return statusOf(err) == http.StatusConflict
+}
+
+func IsBadRequest(err error) bool {
+    return statusOf(err) == http.StatusBadRequest
+}
+
+func New(config Config) (*Client, error) {
+    if config.URL == "" {
+        config.URL = DEFAULT_URL
+    }
+    base, err := url.Parse(strings.TrimSuffix(config.URL, "/"))
+    if err != nil {
+        return nil, fmt.Errorf("invalid URL %s: %v", config.URL, err)
+    }
+    if config.Timeout == 0 {
+        config.Timeout = DEFAULT_TIMEOUT
+    }
+    if config.RetryBackoff == 0 {
+        config.RetryBackoff = DEFAULT_RETRY_BACKOFF
+    }
+
+    tlsConfig := &tls.Config{
+        InsecureSkipVerify: config.InsecureSkipVerify,
+        MinVersion:         tls.VersionTLS12,
+    }
+    if config.ClientCert != "" || config.ClientKey != "" {
+        cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+        if err != nil {
+            return nil, fmt.Errorf("could not load the client cert: %v", err)
+        }
+        tlsConfig.Certificates = []tls.Certificate{cert}
+    }
+    if config.CACert != "" {
+        pem, err := ioutil.ReadFile(config.CACert)
+        if err != nil {
+            return nil, fmt.Errorf("could not read the CA cert: %v", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("no certificate in %s", config.CACert)
+        }
+        tlsConfig.RootCAs = pool
+    }
+
+    transport := &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}
+    if config.UnixSocket != "" {
+        transport.Proxy = nil
+        transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+            var d net.Dialer
+            return d.DialContext(ctx, "unix", config.UnixSocket)
+        }
+    }
+
+    return &Client{
+        base: base,
+        http: &http.Client{
+            Timeout:   config.Timeout,
+            Transport: transport,
+        },
+        retries:   config.Retries,
+        backoff:   config.RetryBackoff,
+        namespace: config.Namespace,
+    }, nil
+}
+
+func retryable(code int) bool {
+    return code == http.StatusTooManyRequests || code == http.StatusBadGateway ||
+        code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// retryWait is how long to wait before attempt n, the Retry-After of the
+// last answer if it gave one
+func (c *Client) retryWait(n int, resp *http.Response) time.Duration {
+    wait := c.backoff << uint(n - 1)
+    if resp != nil {
+        if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+            wait = time.Duration(secs) * time.Second
+        }
+    }
+    if wait > MAX_RETRY_WAIT {
+        wait = MAX_RETRY_WAIT
+    }
+    return wait
+}
+
+// Do sends a request to path, below /v1, and decodes the JSON answer into
+// out unless it is nil. Error answers are returned as *Error. Retried POSTs
+// and PATCHes carry an Idempotency-Key, so that the server does not apply
+// them twice.
+func (c *Client) Do(method string, path string, query url.Values, in interface{}, out interface{}) error {
+    var body []byte
+    if in != nil {
+        var err error
+        if body, err = json.Marshal(in); err != nil {
+            return err
+        }
+    }
+
+    u := *c.base
+    u.Path += "/v1" + path
+    q := url.Values{}
+    for k, v := range query {
+        q[k] = v
+    }
+    if c.namespace != "" {
+        q.Set(models.NAMESPACE_PARAM, c.namespace)
+    }
+    u.RawQuery = q.Encode()
+
+    idempotencyKey := ""
+    if c.retries > 0 && (method == "POST" || method == "PATCH") {
+        key, err := uuid.NewV4()
+        if err != nil {
+            return err
+        }
+        idempotencyKey = key.String()
+    }
+
+    var resp *http.Response
+    var err error
+    for attempt := 0; ; attempt++ {
+        if attempt > 0 {
+            time.Sleep(c.retryWait(attempt, resp))
+        }
+        var req *http.Request
+        req, err = http.NewRequest(method, u.String(), bytes.NewReader(body))
+        if err != nil {
+            return err
+        }
+        if body != nil {
+            req.Header.Set("Content-Type", "application/json")
+        }
+        if idempotencyKey != "" {
+            req.Header.Set(models.IDEMPOTENCY_KEY_HEADER, idempotencyKey)
+        }
+
+        resp, err = c.http.Do(req)
+        if err == nil && !retryable(resp.StatusCode) {
+            break
+        }
+        if attempt >= c.retries {
+            break
+        }
+        if err == nil {
+            io.Copy(ioutil.Discard, resp.Body)
+            resp.Body.Close()
+        }
+    }
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    data, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return err
+    }
+    if resp.StatusCode >= http.StatusBadRequest {
+        e := &Error{StatusCode: resp.StatusCode}
+        var model models.ErrorModel
+        if json.Unmarshal(data, &model) == nil && model.Error.Message != "" {
+            e.ErrorInner = model.Error
+        } else {
+            e.Message = http.StatusText(resp.StatusCode)
+        }
+        return e
+    }
+    if out == nil || len(data) == 0 {
+        return nil
+    }
+    if err = json.Unmarshal(data, out); err != nil {
+        return fmt.Errorf("could not decode the answer to %s %s: %v", method, path, err)
+    }
+    return nil
+}
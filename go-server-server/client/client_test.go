@@ -0,0 +1,147 @@
+package client
+
+import (
+    "go-server-server/models"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+    server := httptest.NewServer(handler)
+    t.Cleanup(server.Close)
+    c, err := New(Config{URL: server.URL, Retries: 2, RetryBackoff: time.Millisecond})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return c
+}
+
+func TestErrorDecoding(t *testing.T) {
+    c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusConflict)
+        w.Write([]byte(`{"error": {"code": 409, "sub-code": 1, "message": "Object already exists: Vnet1", "fields": ["vnet_name"]}}`))
+    })
+
+    err := c.CreateVnet("Vnet1", models.VnetModel{Vnid: 2001})
+    e, ok := err.(*Error)
+    if !ok {
+        t.Fatalf("got %v, want an *Error", err)
+    }
+    if !IsConflict(err) || IsNotFound(err) {
+        t.Errorf("got status %d, want 409", e.StatusCode)
+    }
+    if e.Message != "Object already exists: Vnet1" || e.SubCode == nil || *e.SubCode != 1 {
+        t.Errorf("got %+v", e.ErrorInner)
+    }
+    if len(e.Fields) != 1 || e.Fields[0] != "vnet_name" {
+        t.Errorf("got fields %v", e.Fields)
+    }
+}
+
+func TestErrorWithoutBody(t *testing.T) {
+    c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNotFound)
+    })
+
+    if _, err := c.GetVnet("Vnet1"); !IsNotFound(err) {
+        t.Errorf("got %v, want a 404 error", err)
+    }
+}
+
+func TestUnixSocket(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "rest-api.sock")
+    ln, err := net.Listen("unix", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    server.Listener = ln
+    server.Start()
+    t.Cleanup(server.Close)
+
+    c, err := New(Config{UnixSocket: path})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := c.DeleteVnet("Vnet1"); err != nil {
+        t.Errorf("got %v over the unix socket", err)
+    }
+}
+
+func TestRetryKeepsIdempotencyKey(t *testing.T) {
+    var keys []string
+    c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+        keys = append(keys, r.Header.Get("Idempotency-Key"))
+        if len(keys) < 3 {
+            w.Header().Set("Retry-After", "0")
+            w.WriteHeader(http.StatusServiceUnavailable)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    })
+
+    if err := c.CreateVnet("Vnet1", models.VnetModel{Vnid: 2001}); err != nil {
+        t.Fatal(err)
+    }
+    if len(keys) != 3 {
+        t.Fatalf("got %d attempts, want 3", len(keys))
+    }
+    if keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+        t.Errorf("got idempotency keys %v, want one key", keys)
+    }
+}
+
+func TestRetriesRunOut(t *testing.T) {
+    attempts := 0
+    c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+        attempts++
+        w.WriteHeader(http.StatusTooManyRequests)
+    })
+
+    err := c.DeleteVnet("Vnet1")
+    if statusOf(err) != http.StatusTooManyRequests {
+        t.Errorf("got %v, want a 429 error", err)
+    }
+    if attempts != 3 {
+        t.Errorf("got %d attempts, want 3", attempts)
+    }
+}
+
+func TestRetryWait(t *testing.T) {
+    c := &Client{backoff: 100 * time.Millisecond}
+    if w := c.retryWait(3, nil); w != 400*time.Millisecond {
+        t.Errorf("got %v, want 400ms", w)
+    }
+    resp := &http.Response{Header: http.Header{"Retry-After": {"2"}}}
+    if w := c.retryWait(1, resp); w != 2*time.Second {
+        t.Errorf("got %v, want 2s", w)
+    }
+    resp.Header.Set("Retry-After", "3600")
+    if w := c.retryWait(1, resp); w != MAX_RETRY_WAIT {
+        t.Errorf("got %v, want %v", w, MAX_RETRY_WAIT)
+    }
+}
+
+func TestListRoutes(t *testing.T) {
+    c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v1/config/vrouter/Vnet1/routes" || r.URL.Query().Get("vnid") != "2001" || r.URL.Query().Get("namespace") != "" {
+            t.Errorf("got %s", r.URL)
+        }
+        w.Write([]byte(`[{"ip_prefix": "10.1.1.0/24", "nexthop": "192.168.1.1", "vnid": 2001}]`))
+    })
+
+    routes, err := c.ListRoutes("Vnet1", RouteFilter{Vnid: 2001})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(routes) != 1 || routes[0].IPPrefix != "10.1.1.0/24" || routes[0].NextHop != "192.168.1.1" || routes[0].Vnid != 2001 {
+        t.Errorf("got %+v", routes)
+    }
+}
@@ -0,0 +1,628 @@
+// sonic-restapi-cli talks to the SONiC REST API from the command line.
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "go-server-server/client"
+    "go-server-server/models"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+)
+
+const USAGE string = `usage: sonic-restapi-cli [options] <command> [arguments]
+
+commands:
+  heartbeat
+  ping <ip_addr> [vnet [count]]
+  vnet list
+  vnet show <vnet>
+  vnet create <vnet> <vnid> [key=value...]     advertise_prefix, overlay_dmac
+  vnet delete <vnet>
+  vlan list [vnet]
+  vlan show <vlan_id>
+  vlan create <vlan_id> [key=value...]         vnet_id, ip_prefix
+  vlan delete <vlan_id>
+  vlan members [vlan_id]
+  vlan member add <vlan_id> <if_name> [tagged|untagged]
+  vlan member delete <vlan_id> <if_name>
+  vlan neighbors <vlan_id>
+  vlan neighbor add <vlan_id> <ip_addr>
+  vlan neighbor delete <vlan_id> <ip_addr>
+  route list <vnet> [ip_prefix]
+  route add|append|delete|remove <vnet> <ip_prefix> [key=value...]
+                                               nexthop, ifname, vnid, mac_address, ...
+  route flush <vnet> [vnid]
+  vrf route list <vrf> [ip_prefix]
+  vrf route add|delete <vrf> <ip_prefix> [key=value...]
+  tunnel decap show [type]
+  tunnel decap create <ip_addr> [type]
+  tunnel decap delete [type]
+  tunnel encap create <vnid>
+  tunnel encap delete <vnid>
+  bgp-profile show <name>
+  bgp-profile set <name> <community_id>
+  bgp-profile delete <name>
+  maintenance show
+  maintenance on [reason]
+  maintenance off
+
+options:
+`
+
+// result is what a command prints, either as a table or as the JSON of value
+type result struct {
+    value  interface{}
+    header []string
+    rows   [][]string
+    // Commands whose request partly failed exit with 1 after printing
+    failed bool
+}
+
+type command struct {
+    words []string
+    // Arguments after words, at least min and at most max, -1 for any
+    min   int
+    max   int
+    run   func(c *client.Client, args []string) (*result, error)
+}
+
+type usageError string
+
+func (e usageError) Error() string {
+    return string(e)
+}
+
+func atoi(name string, s string) (int, error) {
+    n, err := strconv.Atoi(s)
+    if err != nil {
+        return 0, usageError(name + " must be a number")
+    }
+    return n, nil
+}
+
+// keyValues turns key=value arguments into a JSON object decoded into out,
+// so that the request goes through the validation of the server models
+func keyValues(args []string, ints map[string]bool, base map[string]interface{}, out interface{}) error {
+    attr := base
+    for _, arg := range args {
+        kv := strings.SplitN(arg, "=", 2)
+        if len(kv) != 2 || kv[0] == "" {
+            return usageError("expected key=value, got " + arg)
+        }
+        if ints[kv[0]] {
+            n, err := atoi(kv[0], kv[1])
+            if err != nil {
+                return err
+            }
+            attr[kv[0]] = n
+        } else {
+            attr[kv[0]] = kv[1]
+        }
+    }
+    b, err := json.Marshal(attr)
+    if err != nil {
+        return err
+    }
+    if err = json.Unmarshal(b, out); err != nil {
+        return usageError(err.Error())
+    }
+    return nil
+}
+
+func routeRows(routes []models.RouteModel, withError bool) ([]string, [][]string) {
+    header := []string{"IP_PREFIX", "NEXTHOP", "IFNAME", "VNID", "MAC_ADDRESS", "PERSISTENT"}
+    if withError {
+        header = append([]string{"CMD"}, append(header, "ERROR_CODE", "ERROR_MSG")...)
+    }
+    rows := make([][]string, 0, len(routes))
+    for _, r := range routes {
+        vnid := ""
+        if r.Vnid != 0 {
+            vnid = strconv.Itoa(r.Vnid)
+        }
+        row := []string{r.IPPrefix, r.NextHop, r.IfName, vnid, r.MACAddress, r.Persistent}
+        if withError {
+            row = append([]string{r.Cmd}, append(row, strconv.Itoa(r.Error_code), r.Error_msg)...)
+        }
+        rows = append(rows, row)
+    }
+    return header, rows
+}
+
+func routesResult(routes []models.RouteModel) *result {
+    header, rows := routeRows(routes, false)
+    return &result{value: routes, header: header, rows: rows}
+}
+
+// failedResult reports the routes a bulk request could not apply
+func failedResult(failed []models.RouteModel, err error) (*result, error) {
+    if err != nil || len(failed) == 0 {
+        return nil, err
+    }
+    header, rows := routeRows(failed, true)
+    return &result{value: failed, header: header, rows: rows, failed: true}, nil
+}
+
+func patchRoute(patch func(string, []models.RouteModel) ([]models.RouteModel, error), cmd string) func(*client.Client, []string) (*result, error) {
+    return func(c *client.Client, args []string) (*result, error) {
+        var route models.RouteModel
+        base := map[string]interface{}{"cmd": cmd, "ip_prefix": args[1]}
+        if err := keyValues(args[2:], map[string]bool{"vnid": true}, base, &route); err != nil {
+            return nil, err
+        }
+        return failedResult(patch(args[0], []models.RouteModel{route}))
+    }
+}
+
+func done(err error) (*result, error) {
+    return nil, err
+}
+
+func maintenance(m *models.MaintenanceModel, err error) (*result, error) {
+    if err != nil {
+        return nil, err
+    }
+    retry_after := ""
+    if m.RetryAfter != 0 {
+        retry_after = strconv.Itoa(m.RetryAfter)
+    }
+    return &result{
+        value:  m,
+        header: []string{"MODE", "TRIGGER", "RETRY_AFTER", "REASON"},
+        rows:   [][]string{{m.Mode, m.Trigger, retry_after, m.Reason}},
+    }, nil
+}
+
+var commands = []command{
+    {[]string{"heartbeat"}, 0, 0, func(c *client.Client, args []string) (*result, error) {
+        hb, err := c.Heartbeat()
+        if err != nil {
+            return nil, err
+        }
+        rows := [][]string{
+            {"server_version", hb.ServerVersion},
+            {"reset_GUID", hb.ResetGUID},
+            {"reset_time", hb.ResetTime},
+            {"routes_available", strconv.Itoa(hb.RoutesAvailable)},
+        }
+        names := make([]string, 0, len(hb.Backends))
+        for name := range hb.Backends {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            b := hb.Backends[name]
+            rows = append(rows, []string{"backend " + name, fmt.Sprintf("%s reachable=%v circuit=%s", b.Address, b.Reachable, b.Circuit)})
+        }
+        if len(hb.Namespaces) > 0 {
+            rows = append(rows, []string{"namespaces", strings.Join(hb.Namespaces, ",")})
+        }
+        rows = append(rows, []string{"maintenance", hb.Maintenance.Mode})
+        return &result{value: hb, header: []string{"FIELD", "VALUE"}, rows: rows}, nil
+    }},
+    {[]string{"ping"}, 1, 3, func(c *client.Client, args []string) (*result, error) {
+        req := models.PingRequestModel{IpAddress: args[0]}
+        if len(args) > 1 {
+            req.VnetId = args[1]
+        }
+        if len(args) > 2 {
+            req.Count = args[2]
+        }
+        out, err := c.Ping(req)
+        if err != nil {
+            return nil, err
+        }
+        return &result{
+            value:  out,
+            header: []string{"TRANSMITTED", "RECEIVED", "MIN_RTT", "AVG_RTT", "MAX_RTT"},
+            rows:   [][]string{{out.PacketsTransmitted, out.PacketsReceived, out.MinRTT, out.AvgRTT, out.MaxRTT}},
+        }, nil
+    }},
+
+    {[]string{"vnet", "list"}, 0, 0, func(c *client.Client, args []string) (*result, error) {
+        vnets, err := c.ListVnets()
+        if err != nil {
+            return nil, err
+        }
+        rows := make([][]string, 0, len(vnets))
+        for _, v := range vnets {
+            rows = append(rows, []string{v.VnetName, strconv.Itoa(v.Attr.Vnid), v.Attr.AdvPrefix, v.Attr.OverlayDmac})
+        }
+        return &result{value: vnets, header: []string{"VNET", "VNID", "ADVERTISE_PREFIX", "OVERLAY_DMAC"}, rows: rows}, nil
+    }},
+    {[]string{"vnet", "show"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        v, err := c.GetVnet(args[0])
+        if err != nil {
+            return nil, err
+        }
+        return &result{
+            value:  v,
+            header: []string{"VNET", "VNID", "ADVERTISE_PREFIX", "OVERLAY_DMAC"},
+            rows:   [][]string{{v.VnetName, strconv.Itoa(v.Attr.Vnid), v.Attr.AdvPrefix, v.Attr.OverlayDmac}},
+        }, nil
+    }},
+    {[]string{"vnet", "create"}, 2, -1, func(c *client.Client, args []string) (*result, error) {
+        vnid, err := atoi("vnid", args[1])
+        if err != nil {
+            return nil, err
+        }
+        var attr models.VnetModel
+        if err = keyValues(args[2:], nil, map[string]interface{}{"vnid": vnid}, &attr); err != nil {
+            return nil, err
+        }
+        return done(c.CreateVnet(args[0], attr))
+    }},
+    {[]string{"vnet", "delete"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        return done(c.DeleteVnet(args[0]))
+    }},
+
+    {[]string{"vlan", "list"}, 0, 1, func(c *client.Client, args []string) (*result, error) {
+        header := []string{"VLAN_ID", "VNET", "IP_PREFIX"}
+        if len(args) == 1 {
+            vlans, err := c.ListVnetVlans(args[0])
+            if err != nil {
+                return nil, err
+            }
+            rows := make([][]string, 0, len(vlans))
+            for _, v := range vlans {
+                rows = append(rows, []string{strconv.Itoa(v.VlanID), args[0], v.IPPrefix})
+            }
+            return &result{value: vlans, header: header, rows: rows}, nil
+        }
+        vlans, err := c.ListVlans()
+        if err != nil {
+            return nil, err
+        }
+        rows := make([][]string, 0, len(vlans))
+        for _, v := range vlans {
+            rows = append(rows, []string{strconv.Itoa(v.VlanID), v.Vnet_id, v.IPPrefix})
+        }
+        return &result{value: vlans, header: header, rows: rows}, nil
+    }},
+    {[]string{"vlan", "show"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        v, err := c.GetVlan(vlan_id)
+        if err != nil {
+            return nil, err
+        }
+        return &result{
+            value:  v,
+            header: []string{"VLAN_ID", "VNET", "IP_PREFIX"},
+            rows:   [][]string{{strconv.Itoa(v.VlanID), v.Attr.Vnet_id, v.Attr.IPPrefix}},
+        }, nil
+    }},
+    {[]string{"vlan", "create"}, 1, -1, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        var attr models.VlanModel
+        if err = keyValues(args[1:], nil, map[string]interface{}{}, &attr); err != nil {
+            return nil, err
+        }
+        return done(c.CreateVlan(vlan_id, attr))
+    }},
+    {[]string{"vlan", "delete"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        return done(c.DeleteVlan(vlan_id))
+    }},
+    {[]string{"vlan", "members"}, 0, 1, func(c *client.Client, args []string) (*result, error) {
+        header := []string{"VLAN_ID", "IF_NAME", "TAGGING_MODE"}
+        if len(args) == 1 {
+            vlan_id, err := atoi("vlan_id", args[0])
+            if err != nil {
+                return nil, err
+            }
+            members, err := c.ListVlanMembers(vlan_id)
+            if err != nil {
+                return nil, err
+            }
+            rows := make([][]string, 0, len(members))
+            for _, m := range members {
+                rows = append(rows, []string{args[0], m.If_name, m.Tagging})
+            }
+            return &result{value: members, header: header, rows: rows}, nil
+        }
+        vlans, err := c.ListAllVlanMembers()
+        if err != nil {
+            return nil, err
+        }
+        rows := [][]string{}
+        for _, v := range vlans {
+            for _, m := range v.Attr {
+                rows = append(rows, []string{strconv.Itoa(v.VlanID), m.If_name, m.Tagging})
+            }
+        }
+        return &result{value: vlans, header: header, rows: rows}, nil
+    }},
+    {[]string{"vlan", "member", "add"}, 2, 3, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        var attr models.VlanMemberModel
+        base := map[string]interface{}{}
+        if len(args) == 3 {
+            base["tagging_mode"] = args[2]
+        }
+        if err = keyValues(nil, nil, base, &attr); err != nil {
+            return nil, err
+        }
+        return done(c.AddVlanMember(vlan_id, args[1], attr))
+    }},
+    {[]string{"vlan", "member", "delete"}, 2, 2, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        return done(c.DeleteVlanMember(vlan_id, args[1]))
+    }},
+    {[]string{"vlan", "neighbors"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        neighbors, err := c.ListVlanNeighbors(vlan_id)
+        if err != nil {
+            return nil, err
+        }
+        rows := make([][]string, 0, len(neighbors))
+        for _, n := range neighbors {
+            rows = append(rows, []string{args[0], n.Ip_addr})
+        }
+        return &result{value: neighbors, header: []string{"VLAN_ID", "IP_ADDR"}, rows: rows}, nil
+    }},
+    {[]string{"vlan", "neighbor", "add"}, 2, 2, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        return done(c.AddVlanNeighbor(vlan_id, args[1]))
+    }},
+    {[]string{"vlan", "neighbor", "delete"}, 2, 2, func(c *client.Client, args []string) (*result, error) {
+        vlan_id, err := atoi("vlan_id", args[0])
+        if err != nil {
+            return nil, err
+        }
+        return done(c.DeleteVlanNeighbor(vlan_id, args[1]))
+    }},
+
+    {[]string{"route", "list"}, 1, 2, func(c *client.Client, args []string) (*result, error) {
+        filter := client.RouteFilter{}
+        if len(args) == 2 {
+            filter.IPPrefix = args[1]
+        }
+        routes, err := c.ListRoutes(args[0], filter)
+        if err != nil {
+            return nil, err
+        }
+        return routesResult(routes), nil
+    }},
+    {[]string{"route", "flush"}, 1, 2, func(c *client.Client, args []string) (*result, error) {
+        vnid := 0
+        if len(args) == 2 {
+            var err error
+            if vnid, err = atoi("vnid", args[1]); err != nil {
+                return nil, err
+            }
+        }
+        return failedResult(c.DeleteRoutes(args[0], vnid))
+    }},
+    {[]string{"vrf", "route", "list"}, 1, 2, func(c *client.Client, args []string) (*result, error) {
+        filter := client.RouteFilter{}
+        if len(args) == 2 {
+            filter.IPPrefix = args[1]
+        }
+        routes, err := c.ListVrfRoutes(args[0], filter)
+        if err != nil {
+            return nil, err
+        }
+        return routesResult(routes), nil
+    }},
+
+    {[]string{"tunnel", "decap", "show"}, 0, 1, func(c *client.Client, args []string) (*result, error) {
+        t, err := c.GetDecapTunnel(tunnelType(args, 0))
+        if err != nil {
+            return nil, err
+        }
+        return &result{value: t, header: []string{"TUNNEL_TYPE", "IP_ADDR"}, rows: [][]string{{t.TunnelType, t.Attr.IPAddr}}}, nil
+    }},
+    {[]string{"tunnel", "decap", "create"}, 1, 2, func(c *client.Client, args []string) (*result, error) {
+        var attr models.TunnelDecapModel
+        if err := keyValues(nil, nil, map[string]interface{}{"ip_addr": args[0]}, &attr); err != nil {
+            return nil, err
+        }
+        return done(c.CreateDecapTunnel(tunnelType(args, 1), attr))
+    }},
+    {[]string{"tunnel", "decap", "delete"}, 0, 1, func(c *client.Client, args []string) (*result, error) {
+        return done(c.DeleteDecapTunnel(tunnelType(args, 0)))
+    }},
+    {[]string{"tunnel", "encap", "create"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        vnid, err := atoi("vnid", args[0])
+        if err != nil {
+            return nil, err
+        }
+        return done(c.CreateEncapTunnel(vnid))
+    }},
+    {[]string{"tunnel", "encap", "delete"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        vnid, err := atoi("vnid", args[0])
+        if err != nil {
+            return nil, err
+        }
+        return done(c.DeleteEncapTunnel(vnid))
+    }},
+
+    {[]string{"bgp-profile", "show"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        p, err := c.GetBgpProfile(args[0])
+        if err != nil {
+            return nil, err
+        }
+        return &result{value: p, header: []string{"PROFILE", "COMMUNITY_ID"}, rows: [][]string{{args[0], p.CommunityId}}}, nil
+    }},
+    {[]string{"bgp-profile", "set"}, 2, 2, func(c *client.Client, args []string) (*result, error) {
+        return done(c.SetBgpProfile(args[0], models.BgpProfileModel{CommunityId: args[1]}))
+    }},
+    {[]string{"bgp-profile", "delete"}, 1, 1, func(c *client.Client, args []string) (*result, error) {
+        return done(c.DeleteBgpProfile(args[0]))
+    }},
+
+    {[]string{"maintenance", "show"}, 0, 0, func(c *client.Client, args []string) (*result, error) {
+        return maintenance(c.GetMaintenance())
+    }},
+    {[]string{"maintenance", "on"}, 0, -1, func(c *client.Client, args []string) (*result, error) {
+        return maintenance(c.SetMaintenance(models.MaintenanceModel{Mode: models.MAINTENANCE_MODE_ON, Reason: strings.Join(args, " ")}))
+    }},
+    {[]string{"maintenance", "off"}, 0, 0, func(c *client.Client, args []string) (*result, error) {
+        return maintenance(c.SetMaintenance(models.MaintenanceModel{Mode: models.MAINTENANCE_MODE_OFF}))
+    }},
+}
+
+func init() {
+    for _, cmd := range []string{"add", "append", "delete", "remove"} {
+        commands = append(commands, command{[]string{"route", cmd}, 2, -1, patchRoute(patchVnetRoutes, cmd)})
+    }
+    for _, cmd := range []string{"add", "delete"} {
+        commands = append(commands, command{[]string{"vrf", "route", cmd}, 2, -1, patchRoute(patchVrfRoutes, cmd)})
+    }
+}
+
+// The client the route commands patch with, set once it is created
+var cli *client.Client
+
+func patchVnetRoutes(vnet string, routes []models.RouteModel) ([]models.RouteModel, error) {
+    return cli.PatchRoutes(vnet, routes)
+}
+
+func patchVrfRoutes(vrf string, routes []models.RouteModel) ([]models.RouteModel, error) {
+    return cli.PatchVrfRoutes(vrf, routes)
+}
+
+func tunnelType(args []string, i int) string {
+    if len(args) > i {
+        return args[i]
+    }
+    return "vxlan"
+}
+
+// findCommand picks the command with the longest run of words args starts with
+func findCommand(args []string) (*command, []string) {
+    var found *command
+    for i := range commands {
+        cmd := &commands[i]
+        if len(args) < len(cmd.words) || (found != nil && len(found.words) >= len(cmd.words)) {
+            continue
+        }
+        match := true
+        for j, w := range cmd.words {
+            if args[j] != w {
+                match = false
+                break
+            }
+        }
+        if match {
+            found = cmd
+        }
+    }
+    if found == nil {
+        return nil, nil
+    }
+    return found, args[len(found.words):]
+}
+
+func printResult(res *result, format string) error {
+    if format == "json" {
+        b, err := json.MarshalIndent(res.value, "", "  ")
+        if err != nil {
+            return err
+        }
+        fmt.Println(string(b))
+        return nil
+    }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, strings.Join(res.header, "\t"))
+    for _, row := range res.rows {
+        fmt.Fprintln(tw, strings.Join(row, "\t"))
+    }
+    return tw.Flush()
+}
+
+func main() {
+    fs := flag.NewFlagSet("sonic-restapi-cli", flag.ExitOnError)
+    url := fs.String("url", client.DEFAULT_URL, "Server URL, https://<host>:8081 for the https endpoint")
+    cert := fs.String("cert", "", "Client cert file, for the https endpoint")
+    key := fs.String("key", "", "Client key file, for the https endpoint")
+    cacert := fs.String("cacert", "", "CA cert file the server cert is checked against")
+    insecure := fs.Bool("insecure", false, "Do not check the server cert")
+    timeout := fs.Duration("timeout", client.DEFAULT_TIMEOUT, "Timeout of a request")
+    retries := fs.Int("retries", client.DEFAULT_RETRIES, "Retries of a request the server could not take")
+    unixSocket := fs.String("unixsocket", "", "Unix socket endpoint of the server, e.g. /var/run/rest-api/rest-api.sock, the host of -url is then ignored")
+    namespace := fs.String("namespace", "", "Namespace of the DBs on multi ASIC platforms, e.g. asic0")
+    output := fs.String("o", "table", "Output format, valid values are: table, json")
+    fs.Usage = func() {
+        fmt.Fprint(os.Stderr, USAGE)
+        fs.PrintDefaults()
+    }
+    fs.Parse(os.Args[1:])
+
+    if *output != "table" && *output != "json" {
+        fmt.Fprintln(os.Stderr, "error: -o must be table or json")
+        os.Exit(2)
+    }
+    cmd, args := findCommand(fs.Args())
+    if cmd == nil {
+        fs.Usage()
+        os.Exit(2)
+    }
+    if len(args) < cmd.min || (cmd.max >= 0 && len(args) > cmd.max) {
+        fmt.Fprintf(os.Stderr, "error: wrong number of arguments to %s\n", strings.Join(cmd.words, " "))
+        fs.Usage()
+        os.Exit(2)
+    }
+
+    var err error
+    cli, err = client.New(client.Config{
+        URL:                *url,
+        ClientCert:         *cert,
+        ClientKey:          *key,
+        CACert:             *cacert,
+        InsecureSkipVerify: *insecure,
+        Timeout:            *timeout,
+        Retries:            *retries,
+        Namespace:          *namespace,
+        UnixSocket:         *unixSocket,
+    })
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error:", err)
+        os.Exit(1)
+    }
+
+    res, err := cmd.run(cli, args)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error:", err)
+        if _, ok := err.(usageError); ok {
+            os.Exit(2)
+        }
+        os.Exit(1)
+    }
+    if res == nil {
+        return
+    }
+    if err = printResult(res, *output); err != nil {
+        fmt.Fprintln(os.Stderr, "error:", err)
+        os.Exit(1)
+    }
+    if res.failed {
+        os.Exit(1)
+    }
+}
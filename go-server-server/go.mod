@@ -1,14 +1,25 @@
 module go-server-server
 
-go 1.15
+go 1.20
 
 require (
+	arpthrift v0.0.0
+	git.apache.org/thrift.git v0.10.0
 	github.com/comail/colog v0.0.0-20160416085026-fba8e7b1f46c
 	github.com/go-redis/redis/v7 v7.3.0
 	github.com/gorilla/mux v1.7.4
 	github.com/satori/go.uuid v1.2.1-0.20180404165556-75cca531ea76
 	github.com/vharitonsky/iniflags v0.0.0-20180513140207-a33cd0b5f3de
+	gopkg.in/yaml.v2 v2.4.0
+	mseethrift v0.0.0
 	swsscommon v0.0.0
 )
 
 replace swsscommon v0.0.0 => ../swsscommon
+
+replace mseethrift v0.0.0 => ../mseethrift
+
+replace arpthrift v0.0.0 => ../arpthrift
+
+// mseethrift and arpthrift are generated by thrift 0.10, whose library is no longer served from git.apache.org
+replace git.apache.org/thrift.git v0.10.0 => github.com/apache/thrift v0.10.0
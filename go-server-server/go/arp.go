@@ -0,0 +1,287 @@
+package restapi
+
+import (
+    "arpthrift"
+    "encoding/binary"
+    "git.apache.org/thrift.git/lib/go/thrift"
+    "github.com/gorilla/mux"
+    "go-server-server/models"
+    "log"
+    "net"
+    "net/http"
+    "strconv"
+    "sync"
+)
+
+// Dry runs record ARP responder calls as writes to these pseudo tables
+const ARP_RESPONDER_DB   string = "ARP_RESPONDER"
+const ARP_INTERFACE_TB   string = "INTERFACE"
+const ARP_IP_TB          string = "IP"
+
+const MAX_VLAN_TAG int = models.MAX_VLAN_TAG
+
+// arpClient calls the ARP responder over the shared connection pool
+type arpClient struct {
+    pool *thriftPool
+}
+
+func (c *arpClient) do(method string, fn func(client *arp.ArpResponderClient) error) error {
+    return c.pool.do(method, func(client interface{}) error {
+        return fn(client.(*arp.ArpResponderClient))
+    })
+}
+
+// call runs an RPC answering whether it succeeded
+func (c *arpClient) call(method string, fn func(client *arp.ArpResponderClient) (bool, error)) error {
+    var ok bool
+    err := c.do(method, func(client *arp.ArpResponderClient) (err error) {
+        ok, err = fn(client)
+        return
+    })
+    if err != nil {
+        return err
+    }
+    log.Printf("trace: ARP responder %s returned %v", method, ok)
+    if !ok {
+        return NewBackendError(http.StatusInternalServerError, "ARP responder error: " + method + " failed")
+    }
+    return nil
+}
+
+var arpConnection *arpClient
+var arpConnectionOnce sync.Once
+
+func ArpConnection() *arpClient {
+    arpConnectionOnce.Do(func() {
+        arpConnection = &arpClient{newThriftPool("arp_responder", "ARP responder", *ArpAddrFlag, *ArpTimeoutFlag, "ARP responder unreachable",
+            func(transport thrift.TTransport, factory thrift.TProtocolFactory) interface{} {
+                return arp.NewArpResponderClientFactory(transport, factory)
+            })}
+    })
+    return arpConnection
+}
+
+func arpIP(ip net.IP) arp.Ip4T {
+    return arp.Ip4T(binary.BigEndian.Uint32(ip.To4()))
+}
+
+// ArpAddInterface registers an interface with the ARP responder. In a dry run
+// the call is only recorded.
+func ArpAddInterface(r *http.Request, if_name string) error {
+    if RecordDryRunCall(r, ARP_RESPONDER_DB, ARP_INTERFACE_TB, if_name, "SET", nil) {
+        return nil
+    }
+    return ArpConnection().call("add_interface", func(client *arp.ArpResponderClient) (bool, error) {
+        return client.AddInterface(if_name)
+    })
+}
+
+func ArpDelInterface(r *http.Request, if_name string) error {
+    if RecordDryRunCall(r, ARP_RESPONDER_DB, ARP_INTERFACE_TB, if_name, "DEL", nil) {
+        return nil
+    }
+    return ArpConnection().call("del_interface", func(client *arp.ArpResponderClient) (bool, error) {
+        return client.DelInterface(if_name)
+    })
+}
+
+func ArpAddIP(r *http.Request, if_name string, stag int, ctag int, ip net.IP) error {
+    key := arpIPKey(if_name, stag, ctag)
+    if RecordDryRunCall(r, ARP_RESPONDER_DB, ARP_IP_TB, key, "SET", map[string]string{"ip_addr": ip.String()}) {
+        return nil
+    }
+    return ArpConnection().call("add_ip", func(client *arp.ArpResponderClient) (bool, error) {
+        return client.AddIP(if_name, arp.VlanTagT(stag), arp.VlanTagT(ctag), arpIP(ip))
+    })
+}
+
+func ArpDelIP(r *http.Request, if_name string, stag int, ctag int) error {
+    if RecordDryRunCall(r, ARP_RESPONDER_DB, ARP_IP_TB, arpIPKey(if_name, stag, ctag), "DEL", nil) {
+        return nil
+    }
+    return ArpConnection().call("del_ip", func(client *arp.ArpResponderClient) (bool, error) {
+        return client.DelIP(if_name, arp.VlanTagT(stag), arp.VlanTagT(ctag))
+    })
+}
+
+func arpIPKey(if_name string, stag int, ctag int) string {
+    return generateDBTableKey(conf_db_ops.separator, if_name, strconv.Itoa(stag), strconv.Itoa(ctag))
+}
+
+// ArpVlanHookAdd registers the monitor interface of a new VLAN, and its
+// address when it has an IPv4 one, if -arphooks is set
+func ArpVlanHookAdd(r *http.Request, vlan_name string, ip_prefix string) error {
+    if !*ArpHooksFlag {
+        return nil
+    }
+    host_ifname := "Mon" + vlan_name
+    if err := ArpAddInterface(r, host_ifname); err != nil {
+        return err
+    }
+    if ip_prefix == "" {
+        return nil
+    }
+    ip, _, err := net.ParseCIDR(ip_prefix)
+    if err != nil || ip.To4() == nil {
+        log.Printf("info: not registering %s of %s with ARP responder, IPv4 only", ip_prefix, vlan_name)
+        return nil
+    }
+    return ArpAddIP(r, host_ifname, 0, 0, ip)
+}
+
+func ArpVlanHookDel(r *http.Request, vlan_name string, ip_prefix string) error {
+    if !*ArpHooksFlag {
+        return nil
+    }
+    host_ifname := "Mon" + vlan_name
+    if ip, _, err := net.ParseCIDR(ip_prefix); err == nil && ip.To4() != nil {
+        if err = ArpDelIP(r, host_ifname, 0, 0); err != nil {
+            return err
+        }
+    }
+    return ArpDelInterface(r, host_ifname)
+}
+
+// parseVlanTag reads a stag or ctag, 0 meaning untagged
+func parseVlanTag(w http.ResponseWriter, field string, tag_str string) (int, bool) {
+    tag, err := strconv.Atoi(tag_str)
+    if err != nil || tag < 0 || tag > MAX_VLAN_TAG {
+        WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{field}, field + " must be between 0 and 4095")
+        return 0, false
+    }
+    return tag, true
+}
+
+func ConfigArpInterfacePost(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    vars := mux.Vars(r)
+
+    if err := ArpAddInterface(r, vars["if_name"]); err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func ConfigArpInterfaceDelete(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    vars := mux.Vars(r)
+
+    if err := ArpDelInterface(r, vars["if_name"]); err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func ConfigArpInterfaceIPPost(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    vars := mux.Vars(r)
+
+    stag, ok := parseVlanTag(w, "stag", vars["stag"])
+    if !ok {
+        return
+    }
+    ctag, ok := parseVlanTag(w, "ctag", vars["ctag"])
+    if !ok {
+        return
+    }
+
+    var attr ArpIPModel
+    err := ReadJSONBody(w, r, &attr)
+    if err != nil {
+        // The error is already handled in this case
+        return
+    }
+
+    if err = ArpAddIP(r, vars["if_name"], stag, ctag, net.ParseIP(attr.IPAddr)); err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func ConfigArpInterfaceIPDelete(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    vars := mux.Vars(r)
+
+    stag, ok := parseVlanTag(w, "stag", vars["stag"])
+    if !ok {
+        return
+    }
+    ctag, ok := parseVlanTag(w, "ctag", vars["ctag"])
+    if !ok {
+        return
+    }
+
+    if err := ArpDelIP(r, vars["if_name"], stag, ctag); err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// StateArpResolvePost asks the ARP responder for the MAC of every address in
+// one request_mac call. Each address is looked up on its interfaces in turn
+// and answered with the first one it was found on.
+func StateArpResolvePost(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+    var attr []ArpResolveRequestModel
+    err := ReadJSONBody(w, r, &attr)
+    if err != nil {
+        // The error is already handled in this case
+        return
+    }
+
+    requests := make([]*arp.ReqTuplesT, len(attr))
+    for i, a := range attr {
+        tuples := make([]*arp.ReqTupleT, len(a.Interfaces))
+        for j, intf := range a.Interfaces {
+            tuples[j] = &arp.ReqTupleT{
+                IfaceName: intf.IfName,
+                Stag:      arp.VlanTagT(intf.Stag),
+                Ctag:      arp.VlanTagT(intf.Ctag),
+            }
+        }
+        requests[i] = &arp.ReqTuplesT{
+            Tuples: tuples,
+            Index:  int32(i),
+            IP:     arpIP(net.ParseIP(a.IPAddr)),
+        }
+    }
+
+    var replies []*arp.RepTupleT
+    if len(requests) > 0 {
+        err = ArpConnection().do("request_mac", func(client *arp.ArpResponderClient) (err error) {
+            replies, err = client.RequestMac(requests)
+            return
+        })
+        if err != nil {
+            WriteBackendError(w, err)
+            return
+        }
+    }
+
+    output := make([]ArpResolveReturnModel, len(attr))
+    for i, a := range attr {
+        output[i].IPAddr = a.IPAddr
+    }
+    for _, reply := range replies {
+        if !reply.IsFound || reply.Index < 0 || int(reply.Index) >= len(output) {
+            continue
+        }
+        o := &output[reply.Index]
+        o.Found = true
+        o.MACAddress = net.HardwareAddr(reply.Mac).String()
+        if reply.Request != nil {
+            o.Interface = &ArpInterfaceTagModel{
+                IfName: reply.Request.IfaceName,
+                Stag:   int(reply.Request.Stag),
+                Ctag:   int(reply.Request.Ctag),
+            }
+        }
+    }
+
+    WriteRequestResponse(w, output, http.StatusOK)
+}
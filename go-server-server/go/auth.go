@@ -4,14 +4,31 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
+// The trusted common names of -clientcertcommonname, a []string replaced as
+// a whole when the config is reloaded
+var trustedCertCommonNames atomic.Value
+
+func LoadTrustedCommonNames() {
+	trustedCertCommonNames.Store(strings.Split(*ClientCertCommonNameFlag, ","))
+}
+
 func CommonNameMatch(r *http.Request) bool {
+	_, ok := MatchedCommonName(r)
+	return ok
+}
+
+// MatchedCommonName gives the common name of the client cert that matched
+// one of the trusted common names
+func MatchedCommonName(r *http.Request) (string, bool) {
 	// During client cert authentication, after the certificate chain is validated by
 	// TLS, here we will further check if at least one of the common names in the end-entity certificate
 	// matches one of the trusted common names in the server config.
 
-	for _, name := range trustedCertCommonNames {
+	names, _ := trustedCertCommonNames.Load().([]string)
+	for _, name := range names {
 		is_wildcard := false
 		domain := name
 		if strings.HasPrefix(name, "*.") {
@@ -31,17 +48,17 @@ func CommonNameMatch(r *http.Request) bool {
 				// wildcard common name matching
 				if len(commonName) > len(domain) && strings.HasSuffix(commonName, domain) {
 					log.Printf("info: Wildcard match between common name %s in the client cert and trusted common name %s", commonName, name)
-					return true;
+					return commonName, true;
 				}
 			} else {
 				if commonName == name {
 					log.Printf("info: Exact match with trusted common name %s", name)
-					return true;
+					return commonName, true;
 				}
 			}
 		}
 	}
 
 	log.Printf("error: Authentication Fail! None of the common names in the client cert match any of the trusted common names")
-	return false;
+	return "", false;
 }
\ No newline at end of file
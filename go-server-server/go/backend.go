@@ -0,0 +1,96 @@
+package restapi
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "swsscommon"
+)
+
+const BACKEND_REDIS string = "redis"
+const BACKEND_MSEE  string = "msee"
+
+// Backend is what the handlers program the data plane through. The redis
+// backend writes the SONiC tables orchagent consumes; the msee backend also
+// drives an MSEE data plane over Thrift.
+type Backend interface {
+    Name() string
+    NewTable(db *db_ops, tableName string) TableWriter
+    NewProducerStateTable(db *db_ops, tableName string) TableWriter
+}
+
+var backend Backend
+
+// BackendError is a write the backend refused. It carries the status the
+// handler answers with.
+type BackendError struct {
+    Code    int
+    SubCode *int
+    Message string
+}
+
+func (e *BackendError) Error() string {
+    return e.Message
+}
+
+func NewBackendError(code int, message string) *BackendError {
+    return &BackendError{Code: code, Message: message}
+}
+
+func NewBackendErrorWithSubCode(code int, sub_code int, message string) *BackendError {
+    return &BackendError{Code: code, SubCode: &sub_code, Message: message}
+}
+
+// BackendErrorStatus gives the status code and message to report for err
+func BackendErrorStatus(err error) (int, string) {
+    if berr, ok := err.(*BackendError); ok {
+        return berr.Code, berr.Message
+    }
+    return http.StatusInternalServerError, "Internal service error"
+}
+
+func WriteBackendError(w http.ResponseWriter, err error) {
+    log.Printf("error: backend write failed: %v", err)
+    if berr, ok := err.(*BackendError); ok {
+        if berr.SubCode != nil {
+            WriteRequestErrorWithSubCode(w, berr.Code, *berr.SubCode, berr.Message, []string{}, "")
+        } else {
+            WriteRequestError(w, berr.Code, berr.Message, []string{}, "")
+        }
+        return
+    }
+    WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+}
+
+type redisBackend struct{}
+
+func (b *redisBackend) Name() string {
+    return BACKEND_REDIS
+}
+
+func (b *redisBackend) NewTable(db *db_ops, tableName string) TableWriter {
+    return swsscommon.NewTable(db.swss_db, tableName)
+}
+
+func (b *redisBackend) NewProducerStateTable(db *db_ops, tableName string) TableWriter {
+    return swsscommon.NewProducerStateTable(db.swss_db, tableName)
+}
+
+func NewBackend(name string) (Backend, error) {
+    switch name {
+    case BACKEND_REDIS:
+        return &redisBackend{}, nil
+    case BACKEND_MSEE:
+        return NewMseeBackend(&redisBackend{}, MseeConnection()), nil
+    }
+    return nil, fmt.Errorf("unknown backend %q, valid values are: %s, %s", name, BACKEND_REDIS, BACKEND_MSEE)
+}
+
+func InitialiseBackend() {
+    var err error
+    backend, err = NewBackend(*BackendFlag)
+    if err != nil {
+        log.Fatalf("error: %v", err)
+    }
+    log.Printf("info: using %s backend", backend.Name())
+}
@@ -0,0 +1,623 @@
+package restapi
+
+import (
+    "encoding/binary"
+    "fmt"
+    "git.apache.org/thrift.git/lib/go/thrift"
+    "log"
+    "mseethrift"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+const MSEE_VXLAN_UDP_PORT msee.MseeUDPPortT = 4789
+const ETHERNET_NAME_PREF string = "Ethernet"
+
+// mseeClient calls the MSEE over the shared connection pool
+type mseeClient struct {
+    pool *thriftPool
+}
+
+func (c *mseeClient) do(method string, fn func(client *msee.MSEEClient) error) error {
+    return c.pool.do(method, func(client interface{}) error {
+        return fn(client.(*msee.MSEEClient))
+    })
+}
+
+// call runs an RPC answering a ResultT and turns error results into a
+// BackendError too
+func (c *mseeClient) call(method string, fn func(client *msee.MSEEClient) (msee.ResultT, error)) error {
+    var result msee.ResultT
+    err := c.do(method, func(client *msee.MSEEClient) (err error) {
+        result, err = fn(client)
+        return
+    })
+    if err != nil {
+        return err
+    }
+    log.Printf("trace: MSEE %s returned %v", method, result)
+    return mseeResultError(method, result)
+}
+
+var mseeConnection *mseeClient
+var mseeConnectionOnce sync.Once
+
+// MseeConnection is the connection to the MSEE shared by the msee backend and
+// the MSEE state endpoints
+func MseeConnection() *mseeClient {
+    mseeConnectionOnce.Do(func() {
+        mseeConnection = &mseeClient{newThriftPool(BACKEND_MSEE, "MSEE", *MseeAddrFlag, *MseeTimeoutFlag, "Data plane unreachable",
+            func(transport thrift.TTransport, factory thrift.TProtocolFactory) interface{} {
+                return msee.NewMSEEClientFactory(transport, factory)
+            })}
+    })
+    return mseeConnection
+}
+
+func mseeResultError(method string, result msee.ResultT) error {
+    switch result {
+    case msee.ResultT_OK, msee.ResultT_ADDED, msee.ResultT_UPDATED, msee.ResultT_REMOVED:
+        return nil
+    case msee.ResultT_NOT_FOUND:
+        return NewBackendError(http.StatusNotFound, "Object not found in data plane: " + method)
+    case msee.ResultT_ALREADY_EXISTS:
+        return NewBackendErrorWithSubCode(http.StatusConflict, RESRC_EXISTS, "Object already exists in data plane: " + method)
+    case msee.ResultT_INVALID_PARAMETERS:
+        return NewBackendError(http.StatusBadRequest, "Data plane rejected arguments: " + method)
+    }
+    return NewBackendError(http.StatusInternalServerError, fmt.Sprintf("Data plane error: %s returned %v", method, result))
+}
+
+// mseeBackend programs the MSEE over Thrift and then writes the same entries
+// to the SONiC tables, which stay the source of truth for reads. Only the
+// tables the MSEE has an equivalent for are translated:
+//
+//   CONFIG_DB VNET                  -> MapVniToVrf, UnmapVniToVrf
+//   CONFIG_DB VLAN_INTERFACE        -> AddPortToVrf, DeletePortFromVrf for every member
+//   CONFIG_DB VLAN_MEMBER           -> AddPortToVrf, DeletePortFromVrf
+//   APPL_DB VNET_ROUTE_TUNNEL_TABLE -> AddEncapRoute, DeleteEncapRoute
+//   APPL_DB VNET_ROUTE_TABLE        -> AddDecapRoute, DeleteDecapRoute for Ethernet routes
+type mseeBackend struct {
+    redis  Backend
+    client *mseeClient
+}
+
+func NewMseeBackend(redis Backend, client *mseeClient) Backend {
+    return &mseeBackend{
+        redis:  redis,
+        client: client,
+    }
+}
+
+func (b *mseeBackend) Name() string {
+    return BACKEND_MSEE
+}
+
+func (b *mseeBackend) NewTable(db *db_ops, tableName string) TableWriter {
+    t := b.redis.NewTable(db, tableName)
+    if db.db_num == CONFIG_DB && (tableName == VNET_TB || tableName == VLAN_INTF_TB || tableName == VLAN_MEMB_TB) {
+        return &mseeTable{backend: b, db: db, table: tableName, next: t}
+    }
+    return t
+}
+
+func (b *mseeBackend) NewProducerStateTable(db *db_ops, tableName string) TableWriter {
+    t := b.redis.NewProducerStateTable(db, tableName)
+    if db.db_num == APPL_DB && (tableName == ROUTE_TUN_TB || tableName == LOCAL_ROUTE_TB) {
+        return &mseeTable{backend: b, db: db, table: tableName, next: t}
+    }
+    return t
+}
+
+// mseeTable programs the MSEE before passing the write on. A write the MSEE
+// refuses never reaches the DB. When the DB write fails, or the MSEE did not
+// answer in time and may have applied the change anyway, the MSEE change is
+// taken back so that the MSEE keeps matching the DB.
+type mseeTable struct {
+    backend *mseeBackend
+    db      *db_ops
+    table   string
+    next    TableWriter
+}
+
+func (t *mseeTable) Set(key string, values map[string]string, op string, prefix string) error {
+    prev, err := t.current(key)
+    if err != nil {
+        return err
+    }
+    err = t.program(key, values)
+    if err != nil && !mseeMayHaveApplied(err) {
+        return err
+    }
+    if err == nil {
+        err = t.next.Set(key, values, op, prefix)
+    }
+    if err != nil {
+        return t.rollback(key, err, func() error {
+            if prev != nil {
+                return ignoreBackendStatus(t.program(key, prev), http.StatusConflict)
+            }
+            return ignoreBackendStatus(t.unprogram(key, values), http.StatusNotFound)
+        })
+    }
+    return nil
+}
+
+func (t *mseeTable) Del(key string, op string, prefix string) error {
+    prev, err := t.current(key)
+    if err != nil {
+        return err
+    }
+    if prev == nil {
+        return t.next.Del(key, op, prefix)
+    }
+    err = t.unprogram(key, prev)
+    if err != nil && !mseeMayHaveApplied(err) {
+        return err
+    }
+    if err == nil {
+        err = t.next.Del(key, op, prefix)
+    }
+    if err != nil {
+        return t.rollback(key, err, func() error {
+            return ignoreBackendStatus(t.program(key, prev), http.StatusConflict)
+        })
+    }
+    return nil
+}
+
+// program applies the entry key of the table holds values to the MSEE
+func (t *mseeTable) program(key string, values map[string]string) error {
+    switch t.table {
+    case VNET_TB:
+        return t.setVnet(key, values)
+    case VLAN_INTF_TB:
+        return t.setVlanInterface(key, values)
+    case VLAN_MEMB_TB:
+        return t.setVlanMember(key, values)
+    case ROUTE_TUN_TB:
+        return t.setEncapRoute(key, values)
+    case LOCAL_ROUTE_TB:
+        return t.setDecapRoute(key, values)
+    }
+    return nil
+}
+
+// unprogram takes the entry key of the table holds values out of the MSEE
+func (t *mseeTable) unprogram(key string, values map[string]string) error {
+    switch t.table {
+    case VNET_TB:
+        return t.delVnet(key, values)
+    case VLAN_INTF_TB:
+        return t.delVlanInterface(key, values)
+    case VLAN_MEMB_TB:
+        return t.delVlanMember(key, values)
+    case ROUTE_TUN_TB:
+        return t.delEncapRoute(key, values)
+    case LOCAL_ROUTE_TB:
+        return t.delDecapRoute(key, values)
+    }
+    return nil
+}
+
+// rollback takes back the MSEE change of a write that failed with err. When
+// undo fails too the MSEE and the DB disagree on key, which is logged and
+// reported as such.
+func (t *mseeTable) rollback(key string, err error, undo func() error) error {
+    uerr := undo()
+    if uerr == nil {
+        log.Printf("info: MSEE change of %s %s taken back, error: %v", t.table, key, err)
+        return err
+    }
+    log.Printf("error: MSEE and DB inconsistent on %s %s, write failed with %v and taking back the MSEE change with %v", t.table, key, err, uerr)
+    return NewBackendError(http.StatusInternalServerError, fmt.Sprintf("Data plane and DB inconsistent on %s %s: %v", t.table, key, err))
+}
+
+// mseeMayHaveApplied tells whether the MSEE may have made a change it
+// reported an error for, it did when it did not answer in time
+func mseeMayHaveApplied(err error) bool {
+    berr, ok := err.(*BackendError)
+    return ok && berr.Code == http.StatusGatewayTimeout
+}
+
+// ignoreBackendStatus drops an error with code, which undoing a change
+// takes for the MSEE being in the state wanted already
+func ignoreBackendStatus(err error, code int) error {
+    if berr, ok := err.(*BackendError); ok && berr.Code == code {
+        return nil
+    }
+    return err
+}
+
+// SetBuffered leaves the table unbuffered: a buffered write that failed to
+// flush would already be programmed in the MSEE, and current has to find the
+// writes before it in the DB.
+func (t *mseeTable) SetBuffered(buffered bool) {
+}
+
+func (t *mseeTable) Flush() error {
+    return t.next.Flush()
+}
+
+func (t *mseeTable) Delete() {
+    t.next.Delete()
+}
+
+func (t *mseeTable) call(method string, fn func(client *msee.MSEEClient) (msee.ResultT, error)) error {
+    return t.backend.client.call(method, fn)
+}
+
+func (t *mseeTable) setVnet(key string, values map[string]string) error {
+    vrf, err := mseeVrfID(key)
+    if err != nil {
+        return err
+    }
+    vni, err := mseeVni(values["vni"])
+    if err != nil {
+        return err
+    }
+    return t.call("map_vni_to_vrf", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.MapVniToVrf(vni, vrf)
+    })
+}
+
+func (t *mseeTable) delVnet(key string, values map[string]string) error {
+    vni, err := mseeVni(values["vni"])
+    if err != nil {
+        return err
+    }
+    return t.call("unmap_vni_to_vrf", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.UnmapVniToVrf(vni)
+    })
+}
+
+// vlanVrf gives the VRF the VLAN interface is bound to, if any
+func vlanVrf(vlan_name string) (vrf msee.MseeVrfIDT, bound bool, err error) {
+    vnet_name, found, err := conf_db_ops.GetField(VLAN_INTF_TB, vlan_name, "vnet_name")
+    if err != nil || !found || vnet_name == "" {
+        return
+    }
+    vrf, err = mseeVrfID(vnet_name)
+    bound = err == nil
+    return
+}
+
+// vlanMembers gives the member ports of the VLAN with their tagging mode
+func vlanMembers(vlan_name string) (map[string]string, error) {
+    keys, err := conf_db_ops.GetTableKeys(VLAN_MEMB_TB)
+    if err != nil {
+        return nil, err
+    }
+    prefix := vlan_name + conf_db_ops.separator
+    members := make(map[string]string)
+    for _, k := range keys {
+        if !strings.HasPrefix(k, prefix) {
+            continue
+        }
+        tagging_mode, _, err := conf_db_ops.GetField(VLAN_MEMB_TB, k, "tagging_mode")
+        if err != nil {
+            return nil, err
+        }
+        members[strings.TrimPrefix(k, prefix)] = tagging_mode
+    }
+    return members, nil
+}
+
+func (t *mseeTable) addPortToVrf(vrf msee.MseeVrfIDT, vlan_name string, if_name string, tagging_mode string) error {
+    port, outer, err := mseePort(vlan_name, if_name, tagging_mode)
+    if err != nil {
+        return err
+    }
+    return t.call("add_port_to_vrf", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.AddPortToVrf(vrf, port, outer, 0)
+    })
+}
+
+func (t *mseeTable) deletePortFromVrf(vlan_name string, if_name string, tagging_mode string) error {
+    port, outer, err := mseePort(vlan_name, if_name, tagging_mode)
+    if err != nil {
+        return err
+    }
+    return t.call("delete_port_from_vrf", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.DeletePortFromVrf(port, outer, 0)
+    })
+}
+
+func (t *mseeTable) setVlanInterface(key string, values map[string]string) error {
+    // Entries keyed by VLAN and IP prefix have no MSEE equivalent
+    if strings.Contains(key, t.db.separator) || values["vnet_name"] == "" {
+        return nil
+    }
+    vrf, err := mseeVrfID(values["vnet_name"])
+    if err != nil {
+        return err
+    }
+    members, err := vlanMembers(key)
+    if err != nil {
+        return err
+    }
+    // The members done before one fails, and that one if the MSEE may have
+    // applied it, are taken back: the VLAN gets bound with all or none
+    var done []string
+    for if_name, tagging_mode := range members {
+        if err = t.addPortToVrf(vrf, key, if_name, tagging_mode); err != nil {
+            if mseeMayHaveApplied(err) {
+                done = append(done, if_name)
+            }
+            if len(done) == 0 {
+                return err
+            }
+            return t.rollback(key, err, func() error {
+                for _, if_name := range done {
+                    err := t.deletePortFromVrf(key, if_name, members[if_name])
+                    if err = ignoreBackendStatus(err, http.StatusNotFound); err != nil {
+                        return err
+                    }
+                }
+                return nil
+            })
+        }
+        done = append(done, if_name)
+    }
+    return nil
+}
+
+func (t *mseeTable) delVlanInterface(key string, values map[string]string) error {
+    if strings.Contains(key, t.db.separator) || values["vnet_name"] == "" {
+        return nil
+    }
+    vrf, err := mseeVrfID(values["vnet_name"])
+    if err != nil {
+        return err
+    }
+    members, err := vlanMembers(key)
+    if err != nil {
+        return err
+    }
+    var done []string
+    for if_name, tagging_mode := range members {
+        if err = t.deletePortFromVrf(key, if_name, tagging_mode); err != nil {
+            if mseeMayHaveApplied(err) {
+                done = append(done, if_name)
+            }
+            if len(done) == 0 {
+                return err
+            }
+            return t.rollback(key, err, func() error {
+                for _, if_name := range done {
+                    err := t.addPortToVrf(vrf, key, if_name, members[if_name])
+                    if err = ignoreBackendStatus(err, http.StatusConflict); err != nil {
+                        return err
+                    }
+                }
+                return nil
+            })
+        }
+        done = append(done, if_name)
+    }
+    return nil
+}
+
+func (t *mseeTable) setVlanMember(key string, values map[string]string) error {
+    vlan_name, if_name := splitDBKey(key, t.db.separator)
+    vrf, bound, err := vlanVrf(vlan_name)
+    if err != nil || !bound {
+        return err
+    }
+    return t.addPortToVrf(vrf, vlan_name, if_name, values["tagging_mode"])
+}
+
+func (t *mseeTable) delVlanMember(key string, values map[string]string) error {
+    vlan_name, if_name := splitDBKey(key, t.db.separator)
+    _, bound, err := vlanVrf(vlan_name)
+    if err != nil || !bound {
+        return err
+    }
+    return t.deletePortFromVrf(vlan_name, if_name, values["tagging_mode"])
+}
+
+func (t *mseeTable) setEncapRoute(key string, values map[string]string) error {
+    vnet_name, prefix_str := splitDBKey(key, t.db.separator)
+    vrf, err := mseeVrfID(vnet_name)
+    if err != nil {
+        return err
+    }
+    prefix, err := mseeIPPrefix(prefix_str)
+    if err != nil {
+        return err
+    }
+    if strings.Contains(values["endpoint"], ",") {
+        return NewBackendError(http.StatusBadRequest, "Data plane does not support multiple nexthops")
+    }
+    host_ip, err := mseeIPAddress(values["endpoint"])
+    if err != nil {
+        return err
+    }
+    var mac msee.MseeMacT
+    if values["mac_address"] != "" {
+        if mac, err = mseeMac(values["mac_address"]); err != nil {
+            return err
+        }
+    }
+    vni_str := values["vni"]
+    if vni_str == "" {
+        vni_str, _, err = conf_db_ops.GetField(VNET_TB, vnet_name, "vni")
+        if err != nil {
+            return err
+        }
+    }
+    vni, err := mseeVni(vni_str)
+    if err != nil {
+        return err
+    }
+    return t.call("add_encap_route", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.AddEncapRoute(vrf, prefix, host_ip, mac, vni, MSEE_VXLAN_UDP_PORT)
+    })
+}
+
+func (t *mseeTable) delEncapRoute(key string, values map[string]string) error {
+    vnet_name, prefix_str := splitDBKey(key, t.db.separator)
+    vrf, err := mseeVrfID(vnet_name)
+    if err != nil {
+        return err
+    }
+    prefix, err := mseeIPPrefix(prefix_str)
+    if err != nil {
+        return err
+    }
+    return t.call("delete_encap_route", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.DeleteEncapRoute(vrf, prefix)
+    })
+}
+
+// Local routes through a VLAN are reached over the member ports bound to the
+// VRF, so only routes out of an Ethernet port become decap routes.
+func (t *mseeTable) setDecapRoute(key string, values map[string]string) error {
+    if !strings.HasPrefix(values["ifname"], ETHERNET_NAME_PREF) {
+        return nil
+    }
+    vnet_name, prefix_str := splitDBKey(key, t.db.separator)
+    vrf, err := mseeVrfID(vnet_name)
+    if err != nil {
+        return err
+    }
+    prefix, err := mseeIPPrefix(prefix_str)
+    if err != nil {
+        return err
+    }
+    port, _, err := mseePort("", values["ifname"], "")
+    if err != nil {
+        return err
+    }
+    return t.call("add_decap_route", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.AddDecapRoute(vrf, prefix, 0, port, 0, 0)
+    })
+}
+
+func (t *mseeTable) delDecapRoute(key string, values map[string]string) error {
+    if !strings.HasPrefix(values["ifname"], ETHERNET_NAME_PREF) {
+        return nil
+    }
+    vnet_name, prefix_str := splitDBKey(key, t.db.separator)
+    vrf, err := mseeVrfID(vnet_name)
+    if err != nil {
+        return err
+    }
+    prefix, err := mseeIPPrefix(prefix_str)
+    if err != nil {
+        return err
+    }
+    return t.call("delete_decap_route", func(client *msee.MSEEClient) (msee.ResultT, error) {
+        return client.DeleteDecapRoute(vrf, prefix)
+    })
+}
+
+// current reads the entry the table holds under key, nil if none. The
+// handlers delete routes from both route tables, so this also tells which
+// one a route is in.
+func (t *mseeTable) current(key string) (map[string]string, error) {
+    tableName := t.table
+    if t.db.db_num == APPL_DB && *RunApiAsLocalTestDocker {
+        tableName = "_" + tableName
+    }
+    kv, err := GetKVs(t.db.db_num, generateDBTableKey(t.db.separator, tableName, key))
+    if err != nil || len(kv) == 0 {
+        return nil, err
+    }
+    return kv, nil
+}
+
+// splitDBKey splits a two part key. Only the first separator counts, since
+// IPv6 prefixes contain the APPL_DB one.
+func splitDBKey(key string, separator string) (string, string) {
+    parts := strings.SplitN(key, separator, 2)
+    if len(parts) < 2 {
+        return parts[0], ""
+    }
+    return parts[0], parts[1]
+}
+
+func mseeVrfID(vnet_name string) (msee.MseeVrfIDT, error) {
+    id, err := strconv.ParseInt(strings.TrimPrefix(vnet_name, VNET_NAME_PREF), 10, 32)
+    if err != nil {
+        return 0, NewBackendError(http.StatusInternalServerError, "Invalid VNET name " + vnet_name)
+    }
+    return msee.MseeVrfIDT(id), nil
+}
+
+func mseeVni(vni_str string) (msee.MseeVniT, error) {
+    vni, err := strconv.ParseInt(vni_str, 10, 32)
+    if err != nil {
+        return 0, NewBackendError(http.StatusInternalServerError, "Invalid VNI " + vni_str)
+    }
+    return msee.MseeVniT(vni), nil
+}
+
+// mseePort gives the MSEE port of an EthernetN interface, which is N, and
+// the outer VLAN of a tagged member
+func mseePort(vlan_name string, if_name string, tagging_mode string) (port msee.MseePortT, outer msee.MseeVlanT, err error) {
+    index, perr := strconv.ParseInt(strings.TrimPrefix(if_name, ETHERNET_NAME_PREF), 10, 8)
+    if !strings.HasPrefix(if_name, ETHERNET_NAME_PREF) || perr != nil {
+        err = NewBackendError(http.StatusBadRequest, "Interface not supported by data plane: " + if_name)
+        return
+    }
+    port = msee.MseePortT(index)
+    if tagging_mode == "tagged" {
+        vlan_id, _ := strconv.ParseInt(strings.TrimPrefix(vlan_name, VLAN_NAME_PREF), 10, 16)
+        outer = msee.MseeVlanT(vlan_id)
+    }
+    return
+}
+
+func mseeIP(ip net.IP) (*msee.MseeIPAddressT) {
+    if ip4 := ip.To4(); ip4 != nil {
+        v4 := msee.MseeIp4T(binary.BigEndian.Uint32(ip4))
+        return &msee.MseeIPAddressT{
+            IP:   &msee.MseeIPT{Ip4: &v4},
+            Type: msee.IPTypeT_v4,
+        }
+    }
+    ip6 := ip.To16()
+    return &msee.MseeIPAddressT{
+        IP: &msee.MseeIPT{Ip6: &msee.MseeIp6T{
+            High: int64(binary.BigEndian.Uint64(ip6[:8])),
+            Low:  int64(binary.BigEndian.Uint64(ip6[8:])),
+        }},
+        Type: msee.IPTypeT_v6,
+    }
+}
+
+func mseeIPAddress(ip_str string) (*msee.MseeIPAddressT, error) {
+    ip := net.ParseIP(ip_str)
+    if ip == nil {
+        return nil, NewBackendError(http.StatusBadRequest, "Invalid IP address " + ip_str)
+    }
+    return mseeIP(ip), nil
+}
+
+func mseeIPPrefix(prefix_str string) (*msee.MseeIPPrefixT, error) {
+    _, network, err := net.ParseCIDR(prefix_str)
+    if err != nil {
+        return nil, NewBackendError(http.StatusBadRequest, "Invalid IP prefix " + prefix_str)
+    }
+    mask_len, _ := network.Mask.Size()
+    return &msee.MseeIPPrefixT{
+        IP:         mseeIP(network.IP),
+        MaskLength: msee.MseePrefixLenT(mask_len),
+    }, nil
+}
+
+func mseeMac(mac_str string) (msee.MseeMacT, error) {
+    hw, err := net.ParseMAC(mac_str)
+    if err != nil || len(hw) != 6 {
+        return 0, NewBackendError(http.StatusBadRequest, "Invalid MAC address " + mac_str)
+    }
+    var mac int64
+    for _, b := range hw {
+        mac = mac << 8 | int64(b)
+    }
+    return msee.MseeMacT(mac), nil
+}
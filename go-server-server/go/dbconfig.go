@@ -0,0 +1,332 @@
+package restapi
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/go-redis/redis/v7"
+    "go-server-server/models"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "swsscommon"
+)
+
+// The namespace of the host, or of the only ASIC of a single ASIC platform
+const DEFAULT_NAMESPACE string = ""
+
+// Names of the DBs in database_config.json
+const (
+    APPL_DB_NAME    string = "APPL_DB"
+    COUNTER_DB_NAME string = "COUNTER_DB"
+    CONFIG_DB_NAME  string = "CONFIG_DB"
+    RESTAPI_DB_NAME string = "RESTAPI_DB"
+)
+
+// Query parameters that pick the namespace of a request, asic=<n> being
+// short for namespace=asic<n>
+const NAMESPACE_PARAM string = models.NAMESPACE_PARAM
+const ASIC_PARAM string = "asic"
+
+type dbInstanceConfig struct {
+    Hostname       string `json:"hostname"`
+    Port           int    `json:"port"`
+    UnixSocketPath string `json:"unix_socket_path"`
+}
+
+type dbConfig struct {
+    Id        int    `json:"id"`
+    Separator string `json:"separator"`
+    Instance  string `json:"instance"`
+}
+
+// databaseConfig is the content of database_config.json, the Redis
+// instances of one namespace and the DBs they hold
+type databaseConfig struct {
+    Instances map[string]dbInstanceConfig `json:"INSTANCES"`
+    Databases map[string]dbConfig         `json:"DATABASES"`
+}
+
+// databaseGlobalConfig is the content of database_global.json on multi ASIC
+// platforms, the database_config.json of every namespace
+type databaseGlobalConfig struct {
+    Includes []struct {
+        Namespace string `json:"namespace"`
+        Include   string `json:"include"`
+    } `json:"INCLUDES"`
+}
+
+// defaultDatabaseConfig is used when there is no database_config.json, as
+// in the test docker
+func defaultDatabaseConfig() *databaseConfig {
+    return &databaseConfig{
+        Instances: map[string]dbInstanceConfig{
+            "redis": {Hostname: "127.0.0.1", Port: 6379, UnixSocketPath: REDIS_SOCK},
+        },
+        Databases: map[string]dbConfig{
+            APPL_DB_NAME:    {Id: 0, Separator: ":", Instance: "redis"},
+            COUNTER_DB_NAME: {Id: 2, Separator: ":", Instance: "redis"},
+            CONFIG_DB_NAME:  {Id: 4, Separator: "|", Instance: "redis"},
+            RESTAPI_DB_NAME: {Id: 8, Separator: "|", Instance: "redis"},
+        },
+    }
+}
+
+func readJSONFile(path string, v interface{}) error {
+    b, err := ioutil.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(b, v)
+}
+
+// LoadDatabaseConfigs gives the database_config.json of every namespace.
+// database_global.json lists them on multi ASIC platforms, otherwise the
+// default namespace is the only one.
+func LoadDatabaseConfigs() (map[string]*databaseConfig, error) {
+    configs := make(map[string]*databaseConfig)
+
+    if _, err := os.Stat(*DBGlobalConfigFlag); err == nil {
+        var global databaseGlobalConfig
+        if err = readJSONFile(*DBGlobalConfigFlag, &global); err != nil {
+            return nil, fmt.Errorf("%s: %v", *DBGlobalConfigFlag, err)
+        }
+        dir := filepath.Dir(*DBGlobalConfigFlag)
+        for _, inc := range global.Includes {
+            path := inc.Include
+            if !filepath.IsAbs(path) {
+                path = filepath.Join(dir, path)
+            }
+            config := &databaseConfig{}
+            if err = readJSONFile(path, config); err != nil {
+                return nil, fmt.Errorf("%s: %v", path, err)
+            }
+            configs[inc.Namespace] = config
+        }
+        if _, ok := configs[DEFAULT_NAMESPACE]; ok {
+            return configs, nil
+        }
+    }
+
+    if _, err := os.Stat(*DBConfigFlag); err == nil {
+        config := &databaseConfig{}
+        if err = readJSONFile(*DBConfigFlag, config); err != nil {
+            return nil, fmt.Errorf("%s: %v", *DBConfigFlag, err)
+        }
+        configs[DEFAULT_NAMESPACE] = config
+    } else {
+        log.Printf("info: no %s, using the default DB layout", *DBConfigFlag)
+        configs[DEFAULT_NAMESPACE] = defaultDatabaseConfig()
+    }
+    return configs, nil
+}
+
+// namespaceState is the package state that belongs to one namespace
+type namespaceState struct {
+    serverResetGuid    string
+    serverResetTime    string
+    configResetStatus  bool
+    vnetGuidMap        map[string]uint32
+    vniVnetMap         map[uint32]string
+    vnetGuidIdUsed     []bool
+    nextGuidId         uint32
+    localTunnelLpbkIps []string
+    vnetAdvPrefixMap   map[string]string
+}
+
+// dbNamespace holds the connections to the Redis instances of a namespace.
+//
+// Requests are served one at a time under writeMutex and each picks its
+// namespace first, so the DB globals (app_db_ops, APPL_DB, ...) and the
+// caches kept next to them are those of the namespace of the running
+// request. useNamespace swaps them.
+type dbNamespace struct {
+    name    string
+    clients map[int]*redis.Client
+    swss    map[int]swsscommon.DBConnector
+
+    appl_db    int
+    counter_db int
+    config_db  int
+    cache_db   int
+
+    app_db_ops  db_ops
+    conf_db_ops db_ops
+    ctr_db_ops  db_ops
+
+    state namespaceState
+}
+
+var namespaces = make(map[string]*dbNamespace)
+var currentNamespace *dbNamespace
+
+func newDBNamespace(name string, config *databaseConfig) (*dbNamespace, error) {
+    ns := &dbNamespace{
+        name:    name,
+        clients: make(map[int]*redis.Client),
+        swss:    make(map[int]swsscommon.DBConnector),
+    }
+
+    instances := make(map[string]*redis.Client)
+    db := func(db_name string) (int, string, error) {
+        dbc, ok := config.Databases[db_name]
+        if !ok {
+            return 0, "", fmt.Errorf("namespace %q has no %s", name, db_name)
+        }
+        inst, ok := config.Instances[dbc.Instance]
+        if !ok {
+            return 0, "", fmt.Errorf("namespace %q has no Redis instance %s for %s", name, dbc.Instance, db_name)
+        }
+
+        local := *RunApiAsLocalTestDocker || inst.UnixSocketPath == ""
+        client, ok := instances[dbc.Instance]
+        if !ok {
+            if local {
+                client = redis.NewClient(&redis.Options{
+                    Addr:     inst.Hostname + ":" + strconv.Itoa(inst.Port),
+                    Password: "",
+                })
+            } else {
+                client = redis.NewClient(&redis.Options{
+                    Network:  "unix",
+                    Addr:     inst.UnixSocketPath,
+                    Password: "",
+                })
+            }
+            instances[dbc.Instance] = client
+            log.Printf("info: Redis connection established for namespace %q (%+v)", name, client)
+        }
+        ns.clients[dbc.Id] = client
+
+        if local {
+            ns.swss[dbc.Id] = swsscommon.NewDBConnector(dbc.Id, inst.Hostname, inst.Port, SWSS_TIMEOUT).WithSeparator(dbc.Separator)
+        } else {
+            ns.swss[dbc.Id] = swsscommon.NewDBConnector2(dbc.Id, inst.UnixSocketPath, SWSS_TIMEOUT).WithSeparator(dbc.Separator)
+        }
+        return dbc.Id, dbc.Separator, nil
+    }
+
+    var err error
+    var sep string
+    if ns.appl_db, sep, err = db(APPL_DB_NAME); err != nil {
+        return nil, err
+    }
+    ns.app_db_ops = db_ops{separator: sep, swss_db: ns.swss[ns.appl_db], db_num: ns.appl_db}
+    if ns.config_db, sep, err = db(CONFIG_DB_NAME); err != nil {
+        return nil, err
+    }
+    ns.conf_db_ops = db_ops{separator: sep, swss_db: ns.swss[ns.config_db], db_num: ns.config_db}
+    if ns.counter_db, sep, err = db(COUNTER_DB_NAME); err != nil {
+        return nil, err
+    }
+    ns.ctr_db_ops = db_ops{separator: sep, swss_db: ns.swss[ns.counter_db], db_num: ns.counter_db}
+    if ns.cache_db, _, err = db(RESTAPI_DB_NAME); err != nil {
+        return nil, err
+    }
+    return ns, nil
+}
+
+// save keeps the package state of the namespace while another one is in use
+func (ns *dbNamespace) save() {
+    ns.state = namespaceState{
+        serverResetGuid:    ServerResetGuid,
+        serverResetTime:    ServerResetTime,
+        configResetStatus:  ConfigResetStatus,
+        vnetGuidMap:        vnetGuidMap,
+        vniVnetMap:         vniVnetMap,
+        vnetGuidIdUsed:     vnetGuidIdUsed,
+        nextGuidId:         nextGuidId,
+        localTunnelLpbkIps: localTunnelLpbkIps,
+        vnetAdvPrefixMap:   vnetAdvPrefixMap,
+    }
+}
+
+func (ns *dbNamespace) load() {
+    APPL_DB = ns.appl_db
+    COUNTER_DB = ns.counter_db
+    CONFIG_DB = ns.config_db
+    APPL_CACHE_DB = ns.cache_db
+    swssDB = ns.app_db_ops.swss_db
+    swss_conf_DB = ns.conf_db_ops.swss_db
+    swss_ctr_DB = ns.ctr_db_ops.swss_db
+    app_db_ops = ns.app_db_ops
+    conf_db_ops = ns.conf_db_ops
+    ctr_db_ops = ns.ctr_db_ops
+
+    ServerResetGuid = ns.state.serverResetGuid
+    ServerResetTime = ns.state.serverResetTime
+    ConfigResetStatus = ns.state.configResetStatus
+    vnetGuidMap = ns.state.vnetGuidMap
+    vniVnetMap = ns.state.vniVnetMap
+    vnetGuidIdUsed = ns.state.vnetGuidIdUsed
+    nextGuidId = ns.state.nextGuidId
+    localTunnelLpbkIps = ns.state.localTunnelLpbkIps
+    vnetAdvPrefixMap = ns.state.vnetAdvPrefixMap
+}
+
+// useNamespace makes ns the namespace the DB globals point to. The caller
+// holds writeMutex, or is still initialising.
+func useNamespace(ns *dbNamespace) {
+    if currentNamespace == ns {
+        return
+    }
+    if currentNamespace != nil {
+        currentNamespace.save()
+    }
+    ns.load()
+    currentNamespace = ns
+}
+
+// redisClient gives the connection to the Redis instance holding DB in the
+// current namespace
+func redisClient(DB int) *redis.Client {
+    if client, ok := currentNamespace.clients[DB]; ok {
+        return client
+    }
+    return currentNamespace.clients[currentNamespace.appl_db]
+}
+
+// Namespaces lists the namespaces the server serves, the default one first
+func Namespaces() []string {
+    names := make([]string, 0, len(namespaces))
+    for name := range namespaces {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// RequestNamespace gives the namespace the request asks for with the
+// namespace or asic query parameter, the default one without either
+func RequestNamespace(r *http.Request) (*dbNamespace, error) {
+    query := r.URL.Query()
+    name := query.Get(NAMESPACE_PARAM)
+    if asic := query.Get(ASIC_PARAM); asic != "" {
+        if _, err := strconv.Atoi(asic); err != nil || name != "" {
+            return nil, fmt.Errorf("%s must be a number and not be given with %s", ASIC_PARAM, NAMESPACE_PARAM)
+        }
+        name = "asic" + asic
+    }
+    ns, ok := namespaces[name]
+    if !ok {
+        return nil, fmt.Errorf("Unknown namespace %q", name)
+    }
+    return ns, nil
+}
+
+// NamespaceMiddleware points the DB globals to the namespace of the
+// request. It runs under writeMutex.
+func NamespaceMiddleware(inner http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ns, err := RequestNamespace(r)
+        if err != nil {
+            WriteRequestError(w, http.StatusBadRequest, err.Error(), []string{NAMESPACE_PARAM}, "")
+            return
+        }
+        useNamespace(ns)
+        inner.ServeHTTP(w, r)
+    })
+}
@@ -6,7 +6,6 @@ import (
     "net/http"
     "strconv"
     "strings"
-    "swsscommon"
     "time"
     "github.com/gorilla/mux"
     "os/exec"
@@ -26,7 +25,9 @@ func StateHeartbeatGet(w http.ResponseWriter, r *http.Request) {
     if err != nil {
         log.Printf("Fetching CRM:STATS key from Counters DB failed")
     } else {
-        availableRoutes, _ = strconv.Atoi(crm_stats_kv["crm_stats_ipv4_route_available"])
+        if n, err := strconv.Atoi(crm_stats_kv["crm_stats_ipv4_route_available"]); err == nil {
+            availableRoutes = n
+        }
     }
 
     output := HeartbeatReturnModel{
@@ -34,6 +35,12 @@ func StateHeartbeatGet(w http.ResponseWriter, r *http.Request) {
         ResetGUID: ServerResetGuid,
         ResetTime: ServerResetTime,
         RoutesAvailable: availableRoutes,
+        Backends: ThriftBackendsStatus(),
+        Maintenance: Maintenance(),
+    }
+    // Only multi ASIC platforms have namespaces to pick from
+    if len(namespaces) > 1 {
+        output.Namespaces = Namespaces()
     }
 
     WriteRequestResponse(w, output, http.StatusOK)
@@ -55,14 +62,16 @@ func ConfigResetStatusPost(w http.ResponseWriter, r *http.Request) {
     
     ReadJSONBody(w, r, &attr)
     switch attr.ResetStatus {
-    case "true":
-        ConfigResetStatus = true
-    case "false":
-        ConfigResetStatus = false
+    case "true", "false":
     default:
         WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"reset_status"}, "only true/false values accepted")
         return
     }
+    if RecordDryRunWrite(r, APPL_CACHE_DB, "RESET_INFO", "", map[string]string{"reset_status": attr.ResetStatus}) {
+        WriteRequestResponse(w, attr, http.StatusOK)
+        return
+    }
+    ConfigResetStatus = attr.ResetStatus == "true"
     CacheSetResetStatusInfo(ConfigResetStatus)
     ConfigResetStatusGet(w, r)    
 }
@@ -72,14 +81,28 @@ func ConfigBgpProfilePost(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     db := &app_db_ops
 
-    ReadJSONBody(w, r, &attr)
+    err := ReadJSONBody(w, r, &attr)
+    if err != nil {
+        // The error is already handled in this case
+        return
+    }
+
+    etagFn := func() (string, error) { return BgpProfileETag(vars["profile_name"]) }
+    if !CheckETagPreconditions(w, r, etagFn) {
+        return
+    }
 
-    bgp_profile_t := swsscommon.NewTable(db.swss_db, BGP_PROFILE_TABLE)
+    bgp_profile_t := NewTableWriter(r, db, BGP_PROFILE_TABLE)
     defer bgp_profile_t.Delete()
 
-    bgp_profile_t.Set(vars["profile_name"], map[string]string {
+    err = bgp_profile_t.Set(vars["profile_name"], map[string]string {
                         "community_id": attr.CommunityId,
         }, "SET", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    SetETag(w, etagFn)
     w.WriteHeader(http.StatusNoContent)   
 }
 
@@ -94,14 +117,27 @@ func ConfigBgpProfileDelete(w http.ResponseWriter, r *http.Request) {
     }
 
     if kv["community_id"] == "" {
+        if IsV2(r) {
+            WriteRequestError(w, http.StatusNotFound, "Object not found", []string{"profile_name"}, "")
+            return
+        }
         WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"profile_name"}, "Invalid profile_name")
         return
     }
 
-    bgp_profile_t := swsscommon.NewTable(db.swss_db, BGP_PROFILE_TABLE)
+    if !CheckPreconditions(w, r, GenerateETag(map[string]map[string]string{
+            generateDBTableKey(db.separator, BGP_PROFILE_TABLE, vars["profile_name"]): kv})) {
+        return
+    }
+
+    bgp_profile_t := NewTableWriter(r, db, BGP_PROFILE_TABLE)
     defer bgp_profile_t.Delete()
 
-    bgp_profile_t.Del(vars["profile_name"], "DEL", "")
+    err = bgp_profile_t.Del(vars["profile_name"], "DEL", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
     w.WriteHeader(http.StatusNoContent)   
 }
 
@@ -116,6 +152,10 @@ func ConfigBgpProfileGet(w http.ResponseWriter, r *http.Request) {
     }
 
     if kv["community_id"] == "" {
+        if IsV2(r) {
+            WriteRequestError(w, http.StatusNotFound, "Object not found", []string{"profile_name"}, "")
+            return
+        }
         WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"profile_name"}, "Invalid profile_name")
         return
     }
@@ -123,7 +163,7 @@ func ConfigBgpProfileGet(w http.ResponseWriter, r *http.Request) {
     output := BgpProfileModel {
         CommunityId: kv["community_id"],
     }
-    WriteRequestResponse(w, output, http.StatusOK)
+    WriteRequestResponseWithETag(w, r, output, func() (string, error) { return BgpProfileETag(vars["profile_name"]) })
 }
 
 func ConfigInterfaceVlanGet(w http.ResponseWriter, r *http.Request) {
@@ -173,7 +213,7 @@ func ConfigInterfaceVlanGet(w http.ResponseWriter, r *http.Request) {
         Attr: attr,
     }
 
-    WriteRequestResponse(w, output, http.StatusOK)
+    WriteRequestResponseWithETag(w, r, output, func() (string, error) { return VlanETag(vlan_name) })
 }
 
 func ConfigInterfaceVlanDelete(w http.ResponseWriter, r *http.Request) {
@@ -188,7 +228,11 @@ func ConfigInterfaceVlanDelete(w http.ResponseWriter, r *http.Request) {
     }
     vlan_name := VLAN_NAME_PREF + vars["vlan_id"]
 
-    vlan_if_pt := swsscommon.NewTable(db.swss_db, VLAN_INTF_TB)
+    if !CheckETagPreconditions(w, r, func() (string, error) { return VlanETag(vlan_name) }) {
+        return
+    }
+
+    vlan_if_pt := NewTableWriter(r, db, VLAN_INTF_TB)
     defer vlan_if_pt.Delete()
 
     vlan_dep, err := vlan_dependencies_exist(vlan_name)
@@ -212,6 +256,16 @@ func ConfigInterfaceVlanDelete(w http.ResponseWriter, r *http.Request) {
         WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
         return
     }
+    var ip_prefix string
+    for k,_ := range vlan_pref_kv {
+        ip_prefix = k[(len(generateDBTableKey(db.separator,VLAN_INTF_TB, vlan_name)) + 1):]
+    }
+    err = ArpVlanHookDel(r, vlan_name, ip_prefix)
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+
     /* Delete sequence: 1. local subnet route(1s delay) 2. Vlan Interface IP prefix table(1s delay), 3. Vlan Interface table, 4. Vlan */
     /* Delete 1 */
     if len(vlan_pref_kv) == 1 && vlan_if_kv != nil {
@@ -219,19 +273,27 @@ func ConfigInterfaceVlanDelete(w http.ResponseWriter, r *http.Request) {
             ip_pref := k[(len(generateDBTableKey(db.separator,VLAN_INTF_TB, vlan_name)) + 1):]
              _, vlan_netw, _ := net.ParseCIDR(ip_pref)
             vnet_id_str := vlan_if_kv["vnet_name"]
-            local_subnet_route_pt := swsscommon.NewProducerStateTable(app_db_ops.swss_db, LOCAL_ROUTE_TB)
+            local_subnet_route_pt := NewProducerStateTableWriter(r, &app_db_ops, LOCAL_ROUTE_TB)
             defer local_subnet_route_pt.Delete()
-            local_subnet_route_pt.Del(generateDBTableKey(app_db_ops.separator, vnet_id_str, vlan_netw.String()), "DEL", "")
+            err = local_subnet_route_pt.Del(generateDBTableKey(app_db_ops.separator, vnet_id_str, vlan_netw.String()), "DEL", "")
+            if err != nil {
+                WriteBackendError(w, err)
+                return
+            }
         }
     }
 
     /* Delete 2 */
     if len(vlan_pref_kv) == 1 {
         /* Sleep as we deleted local subnet route */
-        time.Sleep(time.Second)
+        SleepUnlessDryRun(r, time.Second)
         for k,_ := range vlan_pref_kv {
             table_key := k[len(VLAN_INTF_TB)+ 1:]
-            vlan_if_pt.Del(table_key, "DEL", "")
+            err = vlan_if_pt.Del(table_key, "DEL", "")
+            if err != nil {
+                WriteBackendError(w, err)
+                return
+            }
         }
     }
 
@@ -239,15 +301,23 @@ func ConfigInterfaceVlanDelete(w http.ResponseWriter, r *http.Request) {
     if vlan_if_kv != nil {
         if len(vlan_pref_kv) == 1 {
             /* Sleep only if we previously deleted the VLAN_INTERFACE ip_prefix table */
-            time.Sleep(time.Second)
+            SleepUnlessDryRun(r, time.Second)
+        }
+        err = vlan_if_pt.Del(vlan_name, "DEL", "")
+        if err != nil {
+            WriteBackendError(w, err)
+            return
         }
-        vlan_if_pt.Del(vlan_name, "DEL", "")
     }
 
     /* Delete 4 */
-    pt := swsscommon.NewTable(db.swss_db, VLAN_TB)
+    pt := NewTableWriter(r, db, VLAN_TB)
     defer pt.Delete()
-    pt.Del(vlan_name, "DEL", "")
+    err = pt.Del(vlan_name, "DEL", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
 
     w.WriteHeader(http.StatusNoContent)
 }
@@ -274,6 +344,11 @@ func ConfigInterfaceVlanPost(w http.ResponseWriter, r *http.Request) {
 
     /* Config validation and failure reporting */
     vlan_name := VLAN_NAME_PREF + vars["vlan_id"]
+    etagFn := func() (string, error) { return VlanETag(vlan_name) }
+    if !CheckETagPreconditions(w, r, etagFn) {
+        return
+    }
+
     vlan_kv, err := GetKVs(db.db_num, generateDBTableKey(db.separator, VLAN_TB, vlan_name))
     if err != nil {
         WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
@@ -304,45 +379,68 @@ func ConfigInterfaceVlanPost(w http.ResponseWriter, r *http.Request) {
         }
     }
 
+    err = ArpVlanHookAdd(r, vlan_name, attr.IPPrefix)
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+
      /* Creation sequence:  1. Vlan, 2. Vlan Interface table, 3. Vlan Interface IP prefix table 4. Add local subnet route */
      /* Create 1 */
-     vlan_pt := swsscommon.NewTable(db.swss_db, VLAN_TB)
+     vlan_pt := NewTableWriter(r, db, VLAN_TB)
      defer vlan_pt.Delete()
-     vlan_pt.Set(vlan_name, map[string]string{
+     err = vlan_pt.Set(vlan_name, map[string]string{
           "vlanid": vars["vlan_id"],
           "host_ifname": "Mon"+vlan_name,
      }, "SET", "")
+     if err != nil {
+         WriteBackendError(w, err)
+         return
+     }
 
-    vlan_if_pt := swsscommon.NewTable(db.swss_db, VLAN_INTF_TB)
+    vlan_if_pt := NewTableWriter(r, db, VLAN_INTF_TB)
     defer vlan_if_pt.Delete()
 
     /* Create 2 */
     if attr.Vnet_id != "" {
         vnet_id_str = VNET_NAME_PREF + strconv.FormatUint(uint64(vnet_id), 10)
-        vlan_if_pt.Set(vlan_name, map[string]string{
+        err = vlan_if_pt.Set(vlan_name, map[string]string{
             "vnet_name": vnet_id_str,
             "proxy_arp": "enabled",
         }, "SET", "")
+        if err != nil {
+            WriteBackendError(w, err)
+            return
+        }
     }
 
     /* Create 3 */
     if attr.IPPrefix != "" {
         if attr.Vnet_id != "" {
-            time.Sleep(time.Second)
+            SleepUnlessDryRun(r, time.Second)
+        }
+        err = vlan_if_pt.Set(generateDBTableKey(db.separator, vlan_name, attr.IPPrefix), map[string]string{"":""}, "SET", "")
+        if err != nil {
+            WriteBackendError(w, err)
+            return
         }
-        vlan_if_pt.Set(generateDBTableKey(db.separator, vlan_name, attr.IPPrefix), map[string]string{"":""}, "SET", "")
         if attr.Vnet_id != "" {
-             local_subnet_route_pt := swsscommon.NewProducerStateTable(app_db_ops.swss_db, LOCAL_ROUTE_TB)
+             local_subnet_route_pt := NewProducerStateTableWriter(r, &app_db_ops, LOCAL_ROUTE_TB)
              defer local_subnet_route_pt.Delete()
              // No error check for IPPrefix since it is already checked in unmarshal
              _, vlan_netw, _ := net.ParseCIDR(attr.IPPrefix)
-             local_subnet_route_pt.Set(
+             err = local_subnet_route_pt.Set(
                  generateDBTableKey(app_db_ops.separator, vnet_id_str, vlan_netw.String()),
                  map[string]string{"ifname": vlan_name},
              "SET", "")
+             if err != nil {
+                 WriteBackendError(w, err)
+                 return
+             }
         }
     }
 
+    SetETag(w, etagFn)
     w.WriteHeader(http.StatusNoContent)
 }
 
@@ -405,6 +503,7 @@ func ConfigInterfaceVlansGet(w http.ResponseWriter, r *http.Request) {
 
     }
     VlansPerVnetReturn.Attr = VlansPerVnet
+    ReleaseWriteLock(r)
     WriteRequestResponse(w, VlansPerVnetReturn, http.StatusOK)
 }
 
@@ -460,6 +559,7 @@ func ConfigInterfaceVlansAllGet(w http.ResponseWriter, r *http.Request) {
         Vlans = append(Vlans,output)
     }
     VlansReturn.Attr = Vlans
+    ReleaseWriteLock(r)
     WriteRequestResponse(w, VlansReturn, http.StatusOK)
 }
 
@@ -509,6 +609,7 @@ func ConfigInterfaceVlansMembersAllGet(w http.ResponseWriter, r *http.Request) {
         MembersReturn.Attr = Members
         MembersAllReturn.Attr = append(MembersAllReturn.Attr, MembersReturn)
     }    
+    ReleaseWriteLock(r)
     WriteRequestResponse(w, MembersAllReturn, http.StatusOK)
 }
 
@@ -543,7 +644,7 @@ func ConfigInterfaceVlanMemberGet(w http.ResponseWriter, r *http.Request) {
         Attr: attr,
     }
 
-    WriteRequestResponse(w, output, http.StatusOK)
+    WriteRequestResponseWithETag(w, r, output, func() (string, error) { return VlanMemberETag(vlan_name, vars["if_name"]) })
 }
 
 func ConfigInterfaceVlanMemberDelete(w http.ResponseWriter, r *http.Request) {
@@ -567,9 +668,18 @@ func ConfigInterfaceVlanMemberDelete(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    vlan_member_pt := swsscommon.NewTable(db.swss_db, VLAN_MEMB_TB)
+    if !CheckPreconditions(w, r, GenerateETag(map[string]map[string]string{
+            generateDBTableKey(db.separator, VLAN_MEMB_TB, vlan_name, vars["if_name"]): vlan_member_kv})) {
+        return
+    }
+
+    vlan_member_pt := NewTableWriter(r, db, VLAN_MEMB_TB)
     defer vlan_member_pt.Delete()
-    vlan_member_pt.Del(generateDBTableKey(db.separator, vlan_name, vars["if_name"]), "DEL", "")
+    err = vlan_member_pt.Del(generateDBTableKey(db.separator, vlan_name, vars["if_name"]), "DEL", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
     w.WriteHeader(http.StatusNoContent)
 }
 
@@ -593,6 +703,11 @@ func ConfigInterfaceVlanMemberPost(w http.ResponseWriter, r *http.Request) {
     }
 
     vlan_name := VLAN_NAME_PREF + vars["vlan_id"]
+    etagFn := func() (string, error) { return VlanMemberETag(vlan_name, vars["if_name"]) }
+    if !CheckETagPreconditions(w, r, etagFn) {
+        return
+    }
+
     vlan_members, err := GetKVsMulti(db.db_num, generateDBTableKey(db.separator, VLAN_MEMB_TB, "*"))
     if err != nil {
         WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
@@ -620,12 +735,17 @@ func ConfigInterfaceVlanMemberPost(w http.ResponseWriter, r *http.Request) {
     }
 
     /* Config update */
-    vlan_member_pt := swsscommon.NewTable(db.swss_db, VLAN_MEMB_TB)
+    vlan_member_pt := NewTableWriter(r, db, VLAN_MEMB_TB)
     defer vlan_member_pt.Delete()
 
-    vlan_member_pt.Set(generateDBTableKey(db.separator, vlan_name, vars["if_name"]),
+    err = vlan_member_pt.Set(generateDBTableKey(db.separator, vlan_name, vars["if_name"]),
                        map[string]string{"tagging_mode": attr.Tagging}, "SET", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
 
+    SetETag(w, etagFn)
     w.WriteHeader(http.StatusNoContent)
 }
 
@@ -664,6 +784,7 @@ func ConfigInterfaceVlanMembersGet(w http.ResponseWriter, r *http.Request) {
     }
     MembersReturn.VlanID = vlan_id
     MembersReturn.Attr = Members
+    ReleaseWriteLock(r)
     WriteRequestResponse(w, MembersReturn, http.StatusOK)
 }
 
@@ -698,7 +819,7 @@ func ConfigInterfaceVlanNeighborGet(w http.ResponseWriter, r *http.Request) {
         Ip_addr: vars["ip_addr"],
     }
 
-    WriteRequestResponse(w, output, http.StatusOK)
+    WriteRequestResponseWithETag(w, r, output, func() (string, error) { return VlanNeighborETag(vlan_name, vars["ip_addr"]) })
 }
 
 func ConfigInterfaceVlanNeighborDelete(w http.ResponseWriter, r *http.Request) {
@@ -728,9 +849,18 @@ func ConfigInterfaceVlanNeighborDelete(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    neigh_pt := swsscommon.NewTable(db.swss_db, VLAN_NEIGH_TB)
+    if !CheckPreconditions(w, r, GenerateETag(map[string]map[string]string{
+            generateDBTableKey(db.separator, VLAN_NEIGH_TB, vlan_name, vars["ip_addr"]): neigh_kv})) {
+        return
+    }
+
+    neigh_pt := NewTableWriter(r, db, VLAN_NEIGH_TB)
     defer neigh_pt.Delete()
-    neigh_pt.Del(generateDBTableKey(db.separator, vlan_name, vars["ip_addr"]),"DEL", "")
+    err = neigh_pt.Del(generateDBTableKey(db.separator, vlan_name, vars["ip_addr"]),"DEL", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
 
     w.WriteHeader(http.StatusNoContent)
 }
@@ -759,6 +889,11 @@ func ConfigInterfaceVlanNeighborPost(w http.ResponseWriter, r *http.Request) {
     }
     vlan_name := VLAN_NAME_PREF + vars["vlan_id"]
 
+    etagFn := func() (string, error) { return VlanNeighborETag(vlan_name, vars["ip_addr"]) }
+    if !CheckETagPreconditions(w, r, etagFn) {
+        return
+    }
+
     neigh_kv, err := GetKVs(db.db_num, generateDBTableKey(db.separator, VLAN_NEIGH_TB, vlan_name, vars["ip_addr"]))
     if err != nil {
         WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
@@ -771,11 +906,16 @@ func ConfigInterfaceVlanNeighborPost(w http.ResponseWriter, r *http.Request) {
     }
 
     /* Config update */
-    neigh_pt := swsscommon.NewTable(db.swss_db, VLAN_NEIGH_TB)
+    neigh_pt := NewTableWriter(r, db, VLAN_NEIGH_TB)
     defer neigh_pt.Delete()
 
-    neigh_pt.Set(generateDBTableKey(db.separator, vlan_name, vars["ip_addr"]),
+    err = neigh_pt.Set(generateDBTableKey(db.separator, vlan_name, vars["ip_addr"]),
                        map[string]string{"family": family}, "SET", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    SetETag(w, etagFn)
     w.WriteHeader(http.StatusNoContent)
 }
 
@@ -814,7 +954,7 @@ func ConfigInterfaceVlanNeighborsGet(w http.ResponseWriter, r *http.Request) {
     }
     NeighborsReturn.VlanID = vlan_id
     NeighborsReturn.Attr = Neighbors
-
+    ReleaseWriteLock(r)
     WriteRequestResponse(w, NeighborsReturn, http.StatusOK)
 }
 
@@ -842,7 +982,7 @@ func ConfigTunnelDecapTunnelTypeDelete(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    pt := swsscommon.NewTable(db.swss_db, VXLAN_TUNNEL_TB)
+    pt := NewTableWriter(r, db, VXLAN_TUNNEL_TB)
     defer pt.Delete()
     pt.Del("default_vxlan_tunnel", "DEL", "")
 */
@@ -879,7 +1019,7 @@ func ConfigTunnelDecapTunnelTypeGet(w http.ResponseWriter, r *http.Request) {
         },
     }
 
-    WriteRequestResponse(w, output, http.StatusOK)
+    WriteRequestResponseWithETag(w, r, output, TunnelDecapETag)
 }
 
 func ConfigTunnelDecapTunnelTypePost(w http.ResponseWriter, r *http.Request) {
@@ -901,6 +1041,10 @@ func ConfigTunnelDecapTunnelTypePost(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    if !CheckETagPreconditions(w, r, TunnelDecapETag) {
+        return
+    }
+
     tunnel_name := "default_vxlan_tunnel"
     // Check if IP address is V4.
     if IsValidIP(attr.IPAddr) {
@@ -922,15 +1066,22 @@ func ConfigTunnelDecapTunnelTypePost(w http.ResponseWriter, r *http.Request) {
         }
     }
 
-    pt := swsscommon.NewTable(db.swss_db, VXLAN_TUNNEL_TB)
+    pt := NewTableWriter(r, db, VXLAN_TUNNEL_TB)
     defer pt.Delete()
 
-    pt.Set(tunnel_name, map[string]string{
+    err = pt.Set(tunnel_name, map[string]string{
         "src_ip": attr.IPAddr,
     }, "SET", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
 
-    CacheTunnelLpbkIps(attr.IPAddr, true)
+    if !IsDryRun(r) {
+        CacheTunnelLpbkIps(attr.IPAddr, true)
+    }
 
+    SetETag(w, TunnelDecapETag)
     w.WriteHeader(http.StatusNoContent)
 }
 
@@ -954,12 +1105,17 @@ func ConfigVrouterVrfIdDelete(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     db := &conf_db_ops
 
-    vnet_id_str, _, err := get_and_validate_vnet_id(w, vars["vnet_name"])
+    vnet_id_str, kv, err := get_and_validate_vnet_id(w, vars["vnet_name"])
     if err != nil {
         // Error is already handled in this case
         return
     }
 
+    if !CheckPreconditions(w, r, GenerateETag(map[string]map[string]string{
+            generateDBTableKey(db.separator, VNET_TB, vnet_id_str): kv})) {
+        return
+    }
+
     vnet_dep, err := vnet_dependencies_exist(vnet_id_str)
     if err != nil {
         WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
@@ -971,12 +1127,18 @@ func ConfigVrouterVrfIdDelete(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    pt := swsscommon.NewTable(db.swss_db, VNET_TB)
+    pt := NewTableWriter(r, db, VNET_TB)
     defer pt.Delete()
 
-    pt.Del(vnet_id_str, "DEL", "")
-    CacheDeleteVnetGuidId(vars["vnet_name"])
-    CacheDeletePrefixAdv(vnet_id_str)
+    err = pt.Del(vnet_id_str, "DEL", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+    if !IsDryRun(r) {
+        CacheDeleteVnetGuidId(vars["vnet_name"])
+        CacheDeletePrefixAdv(vnet_id_str)
+    }
 
     w.WriteHeader(http.StatusNoContent)
 }
@@ -1014,7 +1176,7 @@ func ConfigVrouterVrfIdGet(w http.ResponseWriter, r *http.Request) {
         },
     }
 
-    WriteRequestResponse(w, output, http.StatusOK)
+    WriteRequestResponseWithETag(w, r, output, func() (string, error) { return VnetETag(vars["vnet_name"]) })
 }
 
 func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
@@ -1034,6 +1196,11 @@ func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    etagFn := func() (string, error) { return VnetETag(vars["vnet_name"]) }
+    if !CheckETagPreconditions(w, r, etagFn) {
+        return
+    }
+
     tunnel_name := "default_vxlan_tunnel_v4"
     kv_4, err := GetKVs(db.db_num, generateDBTableKey(db.separator, VXLAN_TUNNEL_TB, tunnel_name))
     if err != nil {
@@ -1081,7 +1248,11 @@ func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
         }
     }
 
-    vnet_id = CacheGenAndSetVnetGuidId(vars["vnet_name"], uint32(attr.Vnid))
+    if IsDryRun(r) {
+        vnet_id = CachePeekNextVnetGuidId()
+    } else {
+        vnet_id = CacheGenAndSetVnetGuidId(vars["vnet_name"], uint32(attr.Vnid))
+    }
     vnet_id_str := VNET_NAME_PREF + strconv.FormatUint(uint64(vnet_id), 10)
 
     kv, err = GetKVs(db.db_num, generateDBTableKey(db.separator, VNET_TB, vnet_id_str))
@@ -1095,7 +1266,7 @@ func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    pt := swsscommon.NewTable(db.swss_db, VNET_TB)
+    pt := NewTableWriter(r, db, VNET_TB)
     defer pt.Delete()
     
     log.Printf("debug: vnet_id_str: "+vnet_id_str)
@@ -1105,6 +1276,10 @@ func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
     vnetParams["guid"] = vars["vnet_name"]
     if strings.Compare(vars["vnet_name"], "Vnet-default") == 0 {
         if v6_tunnel == false {
+            if IsV2(r) {
+                WriteRequestErrorWithSubCode(w, http.StatusConflict, DEP_MISSING, "Vnet-default is for V6 Tunnels, please create Vnet-default-v4", []string{"vnet_name"}, "")
+                return
+            }
             WriteRequestError(w, http.StatusInternalServerError, "Vnet-default is for V6 Tunnels, please create Vnet-default-v4", []string{}, "")
             return
         }
@@ -1112,6 +1287,10 @@ func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
         vnetParams["vxlan_tunnel"] = "default_vxlan_tunnel"
     } else if strings.Compare(vars["vnet_name"], "Vnet-default-v4") == 0 {
         if v4_tunnel == false {
+            if IsV2(r) {
+                WriteRequestErrorWithSubCode(w, http.StatusConflict, DEP_MISSING, "V4 tunnel not created, please create V4 Vxlan Tunnel", []string{"vnet_name"}, "")
+                return
+            }
             WriteRequestError(w, http.StatusInternalServerError, "V4 tunnel not created, please create V4 Vxlan Tunnel", []string{}, "")
             return
         }
@@ -1120,13 +1299,20 @@ func ConfigVrouterVrfIdPost(w http.ResponseWriter, r *http.Request) {
     }
     if attr.AdvPrefix != "" {
         vnetParams["advertise_prefix"] = attr.AdvPrefix
-        CacheSetPrefixAdv(vnet_id_str, attr.AdvPrefix)
+        if !IsDryRun(r) {
+            CacheSetPrefixAdv(vnet_id_str, attr.AdvPrefix)
+        }
     }
     if attr.OverlayDmac != "" {
         vnetParams["overlay_dmac"] = attr.OverlayDmac
     }
-    pt.Set(vnet_id_str, vnetParams, "SET", "")        
+    err = pt.Set(vnet_id_str, vnetParams, "SET", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
 
+    SetETag(w, etagFn)
     w.WriteHeader(http.StatusNoContent)
 }
 
@@ -1160,16 +1346,23 @@ func ConfigVrouterVrfIdRoutesDelete(w http.ResponseWriter, r *http.Request) {
     }
 
     var failed []RouteModel
-    pt1 := swsscommon.NewProducerStateTable(db.swss_db, ROUTE_TUN_TB)
+    pt1 := NewProducerStateTableWriter(r, db, ROUTE_TUN_TB)
     defer pt1.Delete()
-    pt2 := swsscommon.NewProducerStateTable(db.swss_db, LOCAL_ROUTE_TB)
+    pt2 := NewProducerStateTableWriter(r, db, LOCAL_ROUTE_TB)
     defer pt2.Delete()
 
     for _, r := range routes {
         table1 := generateDBTableKey(db.separator, vnet_id_str, r.IPPrefix)
-        pt1.Del(table1, "DEL", "")
+        if err := pt1.Del(table1, "DEL", ""); err != nil {
+            r.Error_code, r.Error_msg = BackendErrorStatus(err)
+            failed = append(failed, r)
+            continue
+        }
         table2 := generateDBTableKey(db.separator, vnet_id_str, r.IPPrefix)
-        pt2.Del(table2, "DEL", "")
+        if err := pt2.Del(table2, "DEL", ""); err != nil {
+            r.Error_code, r.Error_msg = BackendErrorStatus(err)
+            failed = append(failed, r)
+        }
     }
 
     if len(failed) > 0 {
@@ -1217,13 +1410,17 @@ func ConfigVrouterVrfIdRoutesGet(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    routes, err := SwssGetVrouterRoutes(vnet_id_str, vnidMatch, ipprefix)
+    // Every SCAN batch goes out as it is read, writeMutex is held until the
+    // last one while the stream renews the write deadline
+    stream := NewJSONStreamWriter(w, r, http.StatusOK)
+    err = SwssScanVrouterRoutes(vnet_id_str, vnidMatch, ipprefix, func(route RouteModel) error {
+        return stream.Write(route)
+    })
     if err != nil {
-        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        stream.Abort(err)
         return
     }
-
-    WriteRequestResponse(w, routes, http.StatusOK)
+    stream.Close()
 }
 
 func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
@@ -1246,18 +1443,27 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    var pt swsscommon.ProducerStateTable
-    var rt_tb_name string
-    defer pt.Delete()
+    var pt TableWriter
 
     var failed []RouteModel
 
-    tunnel_pt := swsscommon.NewProducerStateTable(db.swss_db, ROUTE_TUN_TB)
+    tunnel_pt := NewProducerStateTableWriter(r, db, ROUTE_TUN_TB)
     defer tunnel_pt.Delete()
-    local_pt := swsscommon.NewProducerStateTable(db.swss_db, LOCAL_ROUTE_TB)
+    local_pt := NewProducerStateTableWriter(r, db, LOCAL_ROUTE_TB)
     defer local_pt.Delete()
 
-    for _, r := range attr {
+    rt_tb_keys := make([]string, len(attr))
+    for i, r := range attr {
+        rt_tb_keys[i] = generateDBTableKey(db.separator, vnetRouteTableName(r.IfName), vnet_id_str, r.IPPrefix)
+    }
+    batch, err := newRouteBatch(db, rt_tb_keys, tunnel_pt, local_pt)
+    if err != nil {
+        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return
+    }
+    adv_prefix, adv_prefix_found := CacheGetPrefixAdv(vnet_id_str)
+
+    for i, r := range attr {
 
         /*
         Reject incorrect CIDR address such as 10.20.30.4/24
@@ -1276,7 +1482,7 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
             failed = append(failed, r)
             continue
         }
-        if adv_prefix, ok := CacheGetPrefixAdv(vnet_id_str); ok {
+        if adv_prefix_found {
             if adv_prefix == "true" {
                 prefix_len, _ := network.Mask.Size()
                 if isV4orV6(ip.String()) == 4 {
@@ -1296,16 +1502,8 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
         }        
         if r.IfName == "" {
             pt = tunnel_pt
-            rt_tb_name = ROUTE_TUN_TB
-            if *RunApiAsLocalTestDocker {
-                rt_tb_name = "_"+ROUTE_TUN_TB
-            }            
         } else {
             pt = local_pt
-            rt_tb_name = LOCAL_ROUTE_TB
-            if *RunApiAsLocalTestDocker {
-                rt_tb_name = "_"+LOCAL_ROUTE_TB
-            }
         }
 
         bm_next_hop := isLocalTunnelNexthop(r.NextHop)
@@ -1314,21 +1512,21 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
             continue
         }
 
-        rt_tb_key = generateDBTableKey(db.separator, rt_tb_name, vnet_id_str, r.IPPrefix)
+        rt_tb_key = rt_tb_keys[i]
+        route_key := generateDBTableKey(db.separator, vnet_id_str, r.IPPrefix)
 
-        cur_route, err := GetKVs(db.db_num, rt_tb_key)/* generateDBTableKey(db.separator, ROUTE_TUN_TB, vnet_id_str, r.IPPrefix))*/
-        if err != nil {
-            r.Error_code = http.StatusInternalServerError
-            r.Error_msg = "Internal service error"
-            failed = append(failed, r)
-        }
+        cur_route := batch.route(rt_tb_key)
         if r.Cmd == "delete" {
             if cur_route == nil {
                 r.Error_code = http.StatusNotFound
                     r.Error_msg = "Not found"
                     failed = append(failed, r)
             } else {
-                    pt.Del(generateDBTableKey(db.separator,vnet_id_str, r.IPPrefix), "DEL", "")
+                    err = batch.del(i, r, pt, rt_tb_key, route_key)
+                    if err != nil {
+                        r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                        failed = append(failed, r)
+                    }
                 }
         } else {
             if cur_route != nil {
@@ -1343,7 +1541,12 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
                         cur_route["profile"] != r.Profile {
                             if r.Cmd == "add" {
                                 /* Delete and re-add the route as it is not identical */
-                                pt.Del(generateDBTableKey(db.separator,vnet_id_str, r.IPPrefix), "DEL", "")
+                                err = batch.del(i, r, pt, rt_tb_key, route_key)
+                                if err != nil {
+                                    r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                                    failed = append(failed, r)
+                                    continue
+                                }
                             } else {
                                 curr_endpoints := ExtractIPsFromString(cur_route["endpoint"])
                                 curr_endpoint_monitors := ExtractIPsFromString(cur_route["endpoint_monitor"])
@@ -1393,13 +1596,17 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
                                 }
                                 if success == true {
                                     if len(curr_endpoints) == 0 {
-                                        pt.Del(generateDBTableKey(db.separator,vnet_id_str, r.IPPrefix), "DEL", "")
+                                        err = batch.del(i, r, pt, rt_tb_key, route_key)
                                     } else {
                                         cur_route["endpoint"] = strings.Join(curr_endpoints, ",")
                                         if len(curr_endpoint_monitors) > 0 {
                                             cur_route["endpoint_monitor"] = strings.Join(curr_endpoint_monitors, ",")
                                         }                       
-                                        pt.Set(generateDBTableKey(db.separator,vnet_id_str, r.IPPrefix), cur_route, "SET", "")
+                                        err = batch.set(i, r, pt, rt_tb_key, route_key, cur_route)
+                                    }
+                                    if err != nil {
+                                        r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                                        failed = append(failed, r)
                                     }
                                 }
                                 continue
@@ -1411,7 +1618,12 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
                 } else {
                     if cur_route["ifname"] != r.IfName {
                         /* Delete and re-add the route as it is not identical */
-                        pt.Del(generateDBTableKey(db.separator,vnet_id_str, r.IPPrefix), "DEL", "")                        
+                        err = batch.del(i, r, pt, rt_tb_key, route_key)
+                        if err != nil {
+                            r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                            failed = append(failed, r)
+                            continue
+                        }
                     } else {
                         /* Identical route */
                         continue
@@ -1494,7 +1706,11 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
                 if r.Monitoring != "" {
                     route_map["monitoring"] = r.Monitoring
                 }
-                pt.Set(generateDBTableKey(db.separator,vnet_id_str, r.IPPrefix), route_map, "SET", "")
+                err = batch.set(i, r, pt, rt_tb_key, route_key, route_map)
+                if err != nil {
+                    r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                    failed = append(failed, r)
+                }
             } else {
                 /* Remove from non existing entry */
                 r.Error_msg = "Cannot remove from non-existing route. Please add the route first!"
@@ -1503,6 +1719,7 @@ func ConfigVrouterVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
             }
 		}
 	}
+    failed = append(failed, batch.finish()...)
 
     if len(failed) > 0 {
         output := RouteReturnModel {
@@ -1525,6 +1742,10 @@ func ConfigVrfVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
 
     if vrf_id_str != "default" {
         // Only default is supported
+        if IsV2(r) {
+            WriteRequestError(w, http.StatusNotFound, "Object not found", []string{"vrf_id"}, "Only the default VRF is supported")
+            return
+        }
         WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
         return
     }
@@ -1537,10 +1758,10 @@ func ConfigVrfVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    var pt swsscommon.Table
-    conf_pt := swsscommon.NewTable(conf_db.swss_db, STATIC_ROUTE_TB)
+    var pt TableWriter
+    conf_pt := NewTableWriter(r, conf_db, STATIC_ROUTE_TB)
     defer conf_pt.Delete()
-    app_pt := swsscommon.NewTable(app_db.swss_db, STATIC_ROUTE_TB)
+    app_pt := NewTableWriter(r, app_db, STATIC_ROUTE_TB)
     defer app_pt.Delete()
 
     var failed []RouteModel
@@ -1588,14 +1809,23 @@ func ConfigVrfVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
                 r.Error_msg = "Not found"
                 failed = append(failed, r)
             } else {
-                pt.Del(generateDBTableKey(db.separator, vrf_id_str, r.IPPrefix), "DEL", "")
+                err = pt.Del(generateDBTableKey(db.separator, vrf_id_str, r.IPPrefix), "DEL", "")
+                if err != nil {
+                    r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                    failed = append(failed, r)
+                }
             }
         } else {
             if cur_route != nil {
                 if cur_route["nexthop"] != r.NextHop ||
                    cur_route["ifname"] != r.IfName {
                     /* Delete and re-add the route as it is not identical */
-                    pt.Del(generateDBTableKey(db.separator,vrf_id_str, r.IPPrefix), "DEL", "")
+                    err = pt.Del(generateDBTableKey(db.separator,vrf_id_str, r.IPPrefix), "DEL", "")
+                    if err != nil {
+                        r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                        failed = append(failed, r)
+                        continue
+                    }
                 } else {
                     /* Identical route */
                     if r.Persistent == "true" {
@@ -1627,7 +1857,11 @@ func ConfigVrfVrfIdRoutesPatch(w http.ResponseWriter, r *http.Request) {
             if r.Persistent == "false" {
                 route_map["refresh"] = "true"
             }
-            pt.Set(generateDBTableKey(db.separator,vrf_id_str, r.IPPrefix), route_map, "SET", "")
+            err = pt.Set(generateDBTableKey(db.separator,vrf_id_str, r.IPPrefix), route_map, "SET", "")
+            if err != nil {
+                r.Error_code, r.Error_msg = BackendErrorStatus(err)
+                failed = append(failed, r)
+            }
         }
     }
 
@@ -1652,12 +1886,16 @@ func ConfigVrfRouteExpiryPost(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    static_rt_t := swsscommon.NewTable(db.swss_db, STATIC_ROUTE_EXP_TB)
+    static_rt_t := NewTableWriter(r, db, STATIC_ROUTE_EXP_TB)
     defer static_rt_t.Delete()
 
-    static_rt_t.Set("", map[string]string {
+    err = static_rt_t.Set("", map[string]string {
                         "time": strconv.Itoa(attr.Time),
         }, "SET", "")
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
     w.WriteHeader(http.StatusNoContent)    
 }
 
@@ -1671,6 +1909,10 @@ func ConfigVrfRouteExpiryGet(w http.ResponseWriter, r *http.Request) {
     }
 
     if len(kv) == 0 {
+        if IsV2(r) {
+            WriteRequestError(w, http.StatusNotFound, "Object not found", []string{}, "")
+            return
+        }
         WriteRequestError(w, http.StatusBadRequest, "Object does not exist!", []string{}, "")
         return
     }
@@ -1687,6 +1929,11 @@ func ConfigVrfVrfIdRoutesGet(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     vrf_id_str := vars["vrf_id"]
 
+    if vrf_id_str != "default" && IsV2(r) {
+        WriteRequestError(w, http.StatusNotFound, "Object not found", []string{"vrf_id"}, "Only the default VRF is supported")
+        return
+    }
+
     ipprefix := "*"
     if len(r.URL.Query()["ip_prefix"]) == 1 {
         ipprefix = r.URL.Query()["ip_prefix"][0]
@@ -1700,78 +1947,15 @@ func ConfigVrfVrfIdRoutesGet(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    app_db := &app_db_ops
-    var pattern string
-
-    pattern = generateDBTableKey(app_db.separator, STATIC_ROUTE_TB, vrf_id_str, ipprefix)
-    kv1, err := GetKVsMulti(app_db.db_num, pattern)
-    if err != nil {
-        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
-        return
-    }
-
-    routes := []RouteModel{}
-    for k, kvp := range kv1 {
-        ipprefix, _ := ExtractIPPrefixFromKey(k, app_db.separator)
-        routeModel := RouteModel{
-            IPPrefix:    ipprefix,
-            NextHop:     kvp["nexthop"],
-        }
-
-        if ifname, ok := kvp["ifname"]; ok {
-            routeModel.IfName = ifname
-        }
-
-        if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
-            routeModel.NextHopMonitor = nexthop_monitor
-        }
-        
-        if weight, ok := kvp["weight"]; ok {
-            routeModel.Weight = weight
-        }
-
-        if profile, ok := kvp["profile"]; ok {
-            routeModel.Profile = profile
-        }
-
-        routes = append(routes, routeModel)
-    }
-
-    conf_db := &conf_db_ops
-    pattern = generateDBTableKey(conf_db.separator, STATIC_ROUTE_TB, vrf_id_str, ipprefix)
-    kv2, err := GetKVsMulti(conf_db.db_num, pattern)
+    stream := NewJSONStreamWriter(w, r, http.StatusOK)
+    err := SwssScanStaticRoutes(vrf_id_str, ipprefix, func(route RouteModel) error {
+        return stream.Write(route)
+    })
     if err != nil {
-        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        stream.Abort(err)
         return
     }
-      
-    for k, kvp := range kv2 {
-        ipprefix, _ := ExtractIPPrefixFromKey(k, conf_db.separator)
-        routeModel := RouteModel{
-            IPPrefix:    ipprefix,
-            NextHop:     kvp["nexthop"],
-        }
-
-        if ifname, ok := kvp["ifname"]; ok {
-            routeModel.IfName = ifname
-        }
-
-        if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
-            routeModel.NextHopMonitor = nexthop_monitor
-        }
-        
-        if weight, ok := kvp["weight"]; ok {
-            routeModel.Weight = weight
-        }
-
-        if profile, ok := kvp["profile"]; ok {
-            routeModel.Profile = profile
-        }
-        routeModel.Persistent = "true"
-        routes = append(routes, routeModel)
-    }
-
-    WriteRequestResponse(w, routes, http.StatusOK)
+    stream.Close()
 }
 
 func StateInterfaceGet(w http.ResponseWriter, r *http.Request) {
@@ -1836,7 +2020,7 @@ func StateInterfacePortGet(w http.ResponseWriter, r *http.Request) {
 // Required to run Unit Tests
 func InMemConfigRestart(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-    if *RunApiAsLocalTestDocker {
+    if *RunApiAsLocalTestDocker && !IsDryRun(r) {
         genVnetGuidMap()
     }
     w.WriteHeader(http.StatusNoContent)
@@ -1878,6 +2062,11 @@ func Ping(w http.ResponseWriter, r *http.Request) {
     if vnet_id_match != "" {
 	args = append(args, "-I", vnet_id_match)
     }
+    if IsDryRun(r) {
+        // Nothing to write, validating the request is all a dry run can do
+        w.WriteHeader(http.StatusOK)
+        return
+    }
     out, err = exec.Command(PING_COMMAND_STR, args...).Output()
     if err != nil {
         log.Printf("Exec command Error is "+ err.Error())
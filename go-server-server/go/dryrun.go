@@ -0,0 +1,235 @@
+package restapi
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+const DRY_RUN_QUERY_PARAM string = "dry_run"
+
+type dryRunKey struct{}
+
+// dryRunLog collects the DB writes a handler would have done
+type dryRunLog struct {
+    writes []DryRunWriteModel
+}
+
+// TableWriter is the write side of a DB table. Handlers get one from
+// NewTableWriter or NewProducerStateTableWriter, which hand out the selected
+// backend's tables, or a recorder in dry runs.
+type TableWriter interface {
+    Set(key string, values map[string]string, op string, prefix string) error
+    Del(key string, op string, prefix string) error
+    // SetBuffered holds writes back until Flush, which sends them in one
+    // round trip
+    SetBuffered(buffered bool)
+    Flush() error
+    Delete()
+}
+
+type dryRunTable struct {
+    log   *dryRunLog
+    db    string
+    table string
+}
+
+func (t *dryRunTable) Set(key string, values map[string]string, op string, prefix string) error {
+    copied := make(map[string]string, len(values))
+    for k, v := range values {
+        copied[k] = v
+    }
+    t.log.writes = append(t.log.writes, DryRunWriteModel{
+        DB:     t.db,
+        Table:  t.table,
+        Key:    key,
+        Op:     op,
+        Values: copied,
+    })
+    return nil
+}
+
+func (t *dryRunTable) Del(key string, op string, prefix string) error {
+    t.log.writes = append(t.log.writes, DryRunWriteModel{
+        DB:    t.db,
+        Table: t.table,
+        Key:   key,
+        Op:    op,
+    })
+    return nil
+}
+
+func (t *dryRunTable) SetBuffered(buffered bool) {
+}
+
+func (t *dryRunTable) Flush() error {
+    return nil
+}
+
+func (t *dryRunTable) Delete() {
+}
+
+func dryRunLogFrom(r *http.Request) *dryRunLog {
+    log, _ := r.Context().Value(dryRunKey{}).(*dryRunLog)
+    return log
+}
+
+func IsDryRun(r *http.Request) bool {
+    return dryRunLogFrom(r) != nil
+}
+
+func dbName(db_num int) string {
+    switch db_num {
+    case APPL_DB:
+        return "APPL_DB"
+    case COUNTER_DB:
+        return "COUNTERS_DB"
+    case CONFIG_DB:
+        return "CONFIG_DB"
+    case APPL_CACHE_DB:
+        return "APPL_CACHE_DB"
+    }
+    return strconv.Itoa(db_num)
+}
+
+func NewTableWriter(r *http.Request, db *db_ops, tableName string) TableWriter {
+    if log := dryRunLogFrom(r); log != nil {
+        return &dryRunTable{log: log, db: dbName(db.db_num), table: tableName}
+    }
+    return backend.NewTable(db, tableName)
+}
+
+func NewProducerStateTableWriter(r *http.Request, db *db_ops, tableName string) TableWriter {
+    if log := dryRunLogFrom(r); log != nil {
+        return &dryRunTable{log: log, db: dbName(db.db_num), table: tableName}
+    }
+    return backend.NewProducerStateTable(db, tableName)
+}
+
+// RecordDryRunWrite notes a write done outside of swsscommon, such as to the
+// cache DB, and reports whether the caller must skip doing it for real.
+func RecordDryRunWrite(r *http.Request, db_num int, table string, key string, values map[string]string) bool {
+    log := dryRunLogFrom(r)
+    if log == nil {
+        return false
+    }
+    t := dryRunTable{log: log, db: dbName(db_num), table: table}
+    t.Set(key, values, "SET", "")
+    return true
+}
+
+// RecordDryRunCall notes a call to a service other than the DBs, such as the
+// ARP responder, as a write to one of its tables, and reports whether the
+// caller must skip doing it for real.
+func RecordDryRunCall(r *http.Request, service string, table string, key string, op string, values map[string]string) bool {
+    log := dryRunLogFrom(r)
+    if log == nil {
+        return false
+    }
+    t := dryRunTable{log: log, db: service, table: table}
+    if op == "DEL" {
+        t.Del(key, op, "")
+    } else {
+        t.Set(key, values, op, "")
+    }
+    return true
+}
+
+// SleepUnlessDryRun keeps the pauses orchagent needs between dependent
+// writes out of dry runs, where nothing is written.
+func SleepUnlessDryRun(r *http.Request, d time.Duration) {
+    if !IsDryRun(r) {
+        time.Sleep(d)
+    }
+}
+
+// bufferedResponseWriter holds the whole response back from the client
+type bufferedResponseWriter struct {
+    header http.Header
+    code   int
+    body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+    return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+    if w.code == 0 {
+        w.code = http.StatusOK
+    }
+    return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+    w.code = statusCode
+}
+
+// ParseDryRun reads the dry_run query parameter, answering 400 if it is
+// malformed.
+func ParseDryRun(w http.ResponseWriter, r *http.Request) (dryRun bool, err error) {
+    values := r.URL.Query()[DRY_RUN_QUERY_PARAM]
+    if len(values) == 0 {
+        return
+    }
+    if len(values) > 1 {
+        err = errors.New("multiple dry_run")
+        WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{DRY_RUN_QUERY_PARAM}, "May only specify one dry_run")
+        return
+    }
+    dryRun, err = strconv.ParseBool(values[0])
+    if err != nil {
+        WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{DRY_RUN_QUERY_PARAM}, "dry_run must be true or false")
+    }
+    return
+}
+
+// DryRunMiddleware runs a mutating handler with ?dry_run=true against
+// recording tables instead of the backend ones. Validation and dependency
+// checks still read the DBs, so a request that would fail gets the very same
+// error. A request that would succeed gets 200 with the status and body it
+// would have returned and the list of DB writes it would have done.
+func DryRunMiddleware(inner http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        dryRun, err := ParseDryRun(w, r)
+        if err != nil {
+            // The error is already handled in this case
+            return
+        }
+        if !dryRun {
+            inner.ServeHTTP(w, r)
+            return
+        }
+
+        log := &dryRunLog{writes: []DryRunWriteModel{}}
+        buf := &bufferedResponseWriter{header: make(http.Header)}
+        inner.ServeHTTP(buf, r.WithContext(context.WithValue(r.Context(), dryRunKey{}, log)))
+
+        if buf.code == 0 {
+            buf.code = http.StatusOK
+        }
+        if buf.code >= http.StatusMultipleChoices {
+            for k, v := range buf.header {
+                w.Header()[k] = v
+            }
+            w.WriteHeader(buf.code)
+            w.Write(buf.body.Bytes())
+            return
+        }
+
+        output := DryRunReturnModel{
+            DryRun: true,
+            Status: buf.code,
+            Writes: log.writes,
+        }
+        if buf.body.Len() > 0 && json.Valid(buf.body.Bytes()) {
+            output.Response = json.RawMessage(buf.body.Bytes())
+        }
+        w.Header().Set("Content-Type", JSON_CONTENT_TYPE)
+        WriteRequestResponse(w, output, http.StatusOK)
+    })
+}
@@ -0,0 +1,210 @@
+package restapi
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// GenerateETag hashes the DB hashes backing a resource into a strong ETag.
+// Every table key is folded in together with its fields in sorted order, so
+// the ETag only changes when the stored content does. A resource with no
+// backing hash has no ETag.
+func GenerateETag(kvs map[string]map[string]string) string {
+    if len(kvs) == 0 {
+        return ""
+    }
+
+    keys := make([]string, 0, len(kvs))
+    for k := range kvs {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+
+    h := sha1.New()
+    for _, k := range keys {
+        fields := make([]string, 0, len(kvs[k]))
+        for f := range kvs[k] {
+            fields = append(fields, f)
+        }
+        sort.Strings(fields)
+
+        h.Write([]byte(k))
+        h.Write([]byte{0})
+        for _, f := range fields {
+            h.Write([]byte(f))
+            h.Write([]byte{0})
+            h.Write([]byte(kvs[k][f]))
+            h.Write([]byte{0})
+        }
+    }
+    return "\"" + hex.EncodeToString(h.Sum(nil)) + "\""
+}
+
+func etagFromKeys(db *db_ops, keys ...string) (etag string, err error) {
+    kvs := make(map[string]map[string]string)
+    for _, key := range keys {
+        var kv map[string]string
+        kv, err = GetKVs(db.db_num, key)
+        if err != nil {
+            return
+        }
+        if kv != nil {
+            kvs[key] = kv
+        }
+    }
+    etag = GenerateETag(kvs)
+    return
+}
+
+func VnetETag(vnet_name string) (etag string, err error) {
+    vnet_id := CacheGetVnetGuidId(vnet_name)
+    if vnet_id == 0 {
+        return
+    }
+    db := &conf_db_ops
+    vnet_id_str := VNET_NAME_PREF + strconv.FormatUint(uint64(vnet_id), 10)
+    return etagFromKeys(db, generateDBTableKey(db.separator, VNET_TB, vnet_id_str))
+}
+
+// The VLAN ETag covers the VLAN itself, its interface and its IP prefix.
+// Members and neighbors are separate resources with their own ETags.
+func VlanETag(vlan_name string) (etag string, err error) {
+    db := &conf_db_ops
+    kvs, err := GetKVsMulti(db.db_num, generateDBTableKey(db.separator, VLAN_INTF_TB, vlan_name, "*"))
+    if err != nil {
+        return
+    }
+    for _, key := range []string{
+        generateDBTableKey(db.separator, VLAN_TB, vlan_name),
+        generateDBTableKey(db.separator, VLAN_INTF_TB, vlan_name),
+    } {
+        var kv map[string]string
+        kv, err = GetKVs(db.db_num, key)
+        if err != nil {
+            return
+        }
+        if kv != nil {
+            kvs[key] = kv
+        }
+    }
+    if _, ok := kvs[generateDBTableKey(db.separator, VLAN_TB, vlan_name)]; !ok {
+        return
+    }
+    etag = GenerateETag(kvs)
+    return
+}
+
+func VlanMemberETag(vlan_name string, if_name string) (etag string, err error) {
+    db := &conf_db_ops
+    return etagFromKeys(db, generateDBTableKey(db.separator, VLAN_MEMB_TB, vlan_name, if_name))
+}
+
+func VlanNeighborETag(vlan_name string, ip_addr string) (etag string, err error) {
+    db := &conf_db_ops
+    return etagFromKeys(db, generateDBTableKey(db.separator, VLAN_NEIGH_TB, vlan_name, ip_addr))
+}
+
+func BgpProfileETag(profile_name string) (etag string, err error) {
+    db := &app_db_ops
+    return etagFromKeys(db, generateDBTableKey(db.separator, BGP_PROFILE_TABLE, profile_name))
+}
+
+func TunnelDecapETag() (etag string, err error) {
+    db := &conf_db_ops
+    return etagFromKeys(db,
+        generateDBTableKey(db.separator, VXLAN_TUNNEL_TB, "default_vxlan_tunnel"),
+        generateDBTableKey(db.separator, VXLAN_TUNNEL_TB, "default_vxlan_tunnel_v4"))
+}
+
+func etagListMatches(header string, etag string) bool {
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" {
+            if etag != "" {
+                return true
+            }
+            continue
+        }
+        // Our ETags are strong, a weak validator still names the same content
+        candidate = strings.TrimPrefix(candidate, "W/")
+        if etag != "" && candidate == etag {
+            return true
+        }
+    }
+    return false
+}
+
+// CheckPreconditions evaluates If-Match and If-None-Match against the current
+// ETag of the target resource, "" meaning that it does not exist. A failed
+// condition gets 304 on reads and 412 on mutations. The response has already
+// been written when false is returned.
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string) bool {
+    readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+    if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+        if !etagListMatches(ifMatch, etag) {
+            WriteRequestError(w, http.StatusPreconditionFailed, "Precondition failed",
+                []string{"If-Match"}, "Resource has been modified or does not exist")
+            return false
+        }
+    }
+
+    if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+        if etagListMatches(ifNoneMatch, etag) {
+            if readOnly {
+                w.Header().Set("ETag", etag)
+                w.WriteHeader(http.StatusNotModified)
+            } else {
+                WriteRequestError(w, http.StatusPreconditionFailed, "Precondition failed",
+                    []string{"If-None-Match"}, "Resource already exists or is unchanged")
+            }
+            return false
+        }
+    }
+
+    return true
+}
+
+// CheckETagPreconditions computes the current ETag with etagFn and checks the
+// request preconditions against it, answering 500 if the DB cannot be read.
+func CheckETagPreconditions(w http.ResponseWriter, r *http.Request, etagFn func() (string, error)) bool {
+    if r.Header.Get("If-Match") == "" && r.Header.Get("If-None-Match") == "" {
+        return true
+    }
+    etag, err := etagFn()
+    if err != nil {
+        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return false
+    }
+    return CheckPreconditions(w, r, etag)
+}
+
+// SetETag advertises the current ETag of a resource, typically right after a
+// successful write so clients can chain conditional requests.
+func SetETag(w http.ResponseWriter, etagFn func() (string, error)) {
+    etag, err := etagFn()
+    if err == nil && etag != "" {
+        w.Header().Set("ETag", etag)
+    }
+}
+
+// WriteRequestResponseWithETag is WriteRequestResponse for single resource
+// GETs: it tags the response and honors If-None-Match.
+func WriteRequestResponseWithETag(w http.ResponseWriter, r *http.Request, jsonObj interface{}, etagFn func() (string, error)) {
+    etag, err := etagFn()
+    if err != nil {
+        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return
+    }
+    if !CheckPreconditions(w, r, etag) {
+        return
+    }
+    if etag != "" {
+        w.Header().Set("ETag", etag)
+    }
+    WriteRequestResponse(w, jsonObj, http.StatusOK)
+}
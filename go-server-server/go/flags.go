@@ -1,6 +1,9 @@
 package restapi
 
-import "flag"
+import (
+    "flag"
+    "time"
+)
 
 var LogLevelFlag = flag.String("loglevel", "info", "Set's minimum log level, valid values are: trace, debug, info, warning, error, alert")
 var LogFileFlag = flag.String("logfile", "/dev/stderr", "Set's the output for the log")
@@ -12,3 +15,42 @@ var ServerCertFlag = flag.String("servercert", "", "Server cert file")
 var ServerKeyFlag = flag.String("serverkey", "", "Server key file")
 var RunApiAsLocalTestDocker = flag.Bool("localapitestdocker", false, "Defines whether Rest API is to be run as an independent test docker or with other SONiC components")
 var SystemTestFlag = flag.Bool("systemtest", false, "Set this flag if running system test")
+var IdempotencyKeyTTLFlag = flag.Duration("idempotencykeyttl", 24 * time.Hour, "How long responses to requests carrying an Idempotency-Key are kept for replay")
+var BackendFlag = flag.String("backend", "redis", "Data plane backend the API programs, valid values are: redis, msee")
+var MseeAddrFlag = flag.String("mseeaddr", "localhost:9090", "Address of the MSEE Thrift server, used by the msee backend")
+var MseeTimeoutFlag = flag.Duration("mseetimeout", 5 * time.Second, "Timeout of calls to the MSEE Thrift server")
+var MseeStateCacheTTLFlag = flag.Duration("mseestatecachettl", 2 * time.Second, "How long MSEE counters, statistics and histograms are cached, 0 disables caching")
+var ArpAddrFlag = flag.String("arpaddr", "localhost:9091", "Address of the ARP responder Thrift server")
+var ArpTimeoutFlag = flag.Duration("arptimeout", 5 * time.Second, "Timeout of calls to the ARP responder Thrift server")
+var ArpHooksFlag = flag.Bool("arphooks", false, "Register VLAN interfaces and their IPv4 address with the ARP responder when they are created")
+var ThriftPoolSizeFlag = flag.Int("thriftpoolsize", 4, "Most connections open at once to each Thrift backend")
+var ThriftBreakerThresholdFlag = flag.Int("thriftbreakerthreshold", 5, "Failed calls in a row to a Thrift backend after which calls to it fail right away")
+var ThriftBreakerCooldownFlag = flag.Duration("thriftbreakercooldown", 5 * time.Second, "How long calls to a failing Thrift backend fail right away before it is tried again")
+var ThriftBreakerCooldownMaxFlag = flag.Duration("thriftbreakercooldownmax", time.Minute, "Longest cooldown of a Thrift backend that keeps failing, the cooldown doubles every time")
+var DBConfigFlag = flag.String("dbconfig", "/var/run/redis/sonic-db/database_config.json", "SONiC database_config.json, the Redis instances and DBs of the default namespace")
+var DBGlobalConfigFlag = flag.String("dbglobalconfig", "/var/run/redis/sonic-db/database_global.json", "SONiC database_global.json, the database_config.json of every namespace on multi ASIC platforms")
+var RouteFlushSizeFlag = flag.Int("routeflushsize", 512, "Route writes of a bulk route PATCH sent to the DB in one round trip, 0 sends every write on its own")
+var OpenAPIFlag = flag.String("openapi", "/usr/share/sonic-rest-api/sonic_api.yaml", "Swagger spec of the API, served at /v1/openapi.yaml")
+var OpenAPIStrictFlag = flag.Bool("openapistrict", false, "Check request and response bodies against the schemas of the spec, answering 400 to requests and 500 instead of responses that do not match")
+var StrictJSONFlag = flag.Bool("strictjson", false, "Reject request bodies with fields the API does not know")
+var HttpReadHeaderTimeoutFlag = flag.Duration("httpreadheadertimeout", 10 * time.Second, "How long a client may take to send the headers of a request, 0 waits forever")
+var HttpReadTimeoutFlag = flag.Duration("httpreadtimeout", time.Minute, "How long a client may take to send a whole request, 0 waits forever")
+var HttpWriteTimeoutFlag = flag.Duration("httpwritetimeout", 2 * time.Minute, "How long serving a request and sending its response may take, 0 waits forever")
+var HttpIdleTimeoutFlag = flag.Duration("httpidletimeout", 2 * time.Minute, "How long an idle keep-alive connection is kept open, 0 uses -httpreadtimeout")
+var MaxBodyBytesFlag = flag.Int64("maxbodybytes", 1 << 20, "Largest request body, 0 sets no limit")
+var MaxBulkBodyBytesFlag = flag.Int64("maxbulkbodybytes", 64 << 20, "Largest request body of bulk route PATCHes and snapshot POSTs, 0 sets no limit")
+var MaxConcurrentRequestsFlag = flag.Int("maxconcurrentrequests", 64, "Most requests served or waiting at once, others get 503, 0 sets no limit")
+var RetryAfterFlag = flag.Duration("retryafter", time.Second, "Retry-After sent along with 503 when requests are shed")
+var RateLimitsFlag = flag.String("ratelimits", "", "Requests a second each client may send to a route, as Name=rate[/burst] separated by commas, * for the routes not listed")
+var MaintenanceFileFlag = flag.String("maintenancefile", "", "File whose presence puts the server in maintenance mode, what it holds being the reason")
+var HttpAddrFlag = flag.String("httpaddr", ":8090", "Address and port of the http endpoint")
+var HttpsAddrFlag = flag.String("httpsaddr", ":8081", "Address and port of the https endpoint")
+var ShutdownTimeoutFlag = flag.Duration("shutdowntimeout", 30 * time.Second, "How long the endpoints wait for the requests they serve when stopped on SIGTERM or restarted on SIGHUP")
+var UnixFlag = flag.Bool("enableunix", false, "Enable unix socket endpoint")
+var UnixSocketFlag = flag.String("unixsocket", "/var/run/rest-api/rest-api.sock", "Path of the unix socket endpoint")
+var UnixSocketUidsFlag = flag.String("unixsocketuids", "0", "Comma separated list of uids allowed on the unix socket endpoint")
+var UnixSocketGidsFlag = flag.String("unixsocketgids", "", "Comma separated list of gids allowed on the unix socket endpoint")
+var AdminClientCertCommonNameFlag = flag.String("adminclientcertcommonname", "", "Comma separated list of common names in the client cert allowed to change maintenance mode, they must be trusted too")
+var AdminUnixSocketUidsFlag = flag.String("adminunixsocketuids", "0", "Comma separated list of uids allowed to change maintenance mode on the unix socket endpoint")
+var ReadyzTimeoutFlag = flag.Duration("readyztimeout", 2 * time.Second, "How long /readyz waits for the request in flight before it reports the checks of the swsscommon connectors and caches as busy")
+var CertExpiryWarningFlag = flag.Duration("certexpirywarning", 30 * 24 * time.Hour, "How long before the server or client cert expires /readyz starts warning about it")
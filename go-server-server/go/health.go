@@ -0,0 +1,356 @@
+package restapi
+
+import (
+    "crypto/x509"
+    "encoding/pem"
+    "fmt"
+    "github.com/go-redis/redis/v7"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "swsscommon"
+    "sync"
+    "time"
+)
+
+// /healthz tells supervisord and container orchestration the process is
+// alive, /readyz whether it can serve the API: it checks every DB of every
+// namespace through Redis and through the swsscommon connectors, the caches
+// against the DBs, the certs of the https endpoint and the Thrift backends,
+// and answers 503 when one of them fails. Both are served outside writeMutex
+// and the rate limits, so a long mutation does not fail them; only the
+// checks of the connectors and caches, which must not run next to a request,
+// wait for their turn up to -readyztimeout.
+
+const HEALTH_PASS string = "pass"
+const HEALTH_WARN string = "warn"
+const HEALTH_FAIL string = "fail"
+
+// Hash the swsscommon connectors read to tell they work, it never exists
+const READYZ_PROBE_TB string  = "RESTAPI_READYZ"
+const READYZ_PROBE_KEY string = "probe"
+
+var probeRoutes = Routes{
+    Route{
+        "Healthz",
+        "GET",
+        "/healthz",
+        Healthz,
+    },
+
+    Route{
+        "Readyz",
+        "GET",
+        "/readyz",
+        Readyz,
+    },
+}
+
+// ProbeMiddleware serves a probe to any client the API would serve, it logs
+// at trace level as probes come every few seconds
+func ProbeMiddleware(inner http.Handler, name string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        log.Printf("trace: request: %s %s %s", r.Method, r.RequestURI, name)
+        if _, ok := RequestIdentity(r); !ok {
+            writeUnauthenticated(NewLoggingResponseWriter(w), r)
+            return
+        }
+        inner.ServeHTTP(w, r)
+    })
+}
+
+func Healthz(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    WriteRequestResponse(w, HealthModel{Status: HEALTH_PASS}, http.StatusOK)
+}
+
+func Readyz(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+    output := HealthModel{Status: HEALTH_PASS, Checks: ReadinessChecks()}
+    var failed []string
+    for name, check := range output.Checks {
+        if check.Status == HEALTH_FAIL {
+            failed = append(failed, name)
+        } else if check.Status == HEALTH_WARN && output.Status == HEALTH_PASS {
+            output.Status = HEALTH_WARN
+        }
+    }
+    if len(failed) > 0 {
+        sort.Strings(failed)
+        log.Printf("warning: not ready, failed checks: %s", strings.Join(failed, ", "))
+        output.Status = HEALTH_FAIL
+        WriteRequestResponse(w, output, http.StatusServiceUnavailable)
+        return
+    }
+    WriteRequestResponse(w, output, http.StatusOK)
+}
+
+// ReadinessChecks runs every check of /readyz at once, by name
+func ReadinessChecks() map[string]HealthCheckModel {
+    checks := make(map[string]HealthCheckModel)
+    var mutex sync.Mutex
+    var wgroup sync.WaitGroup
+    run := func(name string, check func() HealthCheckModel) {
+        wgroup.Add(1)
+        go func() {
+            defer wgroup.Done()
+            result := check()
+            mutex.Lock()
+            checks[name] = result
+            mutex.Unlock()
+        }()
+    }
+
+    for _, ns := range namespaces {
+        for _, db := range ns.databases() {
+            client, id := ns.clients[db.id], db.id
+            run(checkName("redis", ns.name, db.name), func() HealthCheckModel {
+                return checkRedis(client, id)
+            })
+        }
+    }
+    if *HttpsFlag {
+        run("cert:server", func() HealthCheckModel { return checkCertFile(*ServerCertFlag) })
+        run("cert:client", func() HealthCheckModel { return checkCertFile(*ClientCertFlag) })
+    }
+    backends := thriftBackends()
+    for key, p := range backends {
+        key, p := key, p
+        run("thrift:" + key, func() HealthCheckModel { return checkThriftBackend(key, p.status()) })
+    }
+    if _, ok := backends["arp_responder"]; *ArpHooksFlag && !ok {
+        // The ARP responder is only dialled once the hooks call it
+        run("thrift:arp_responder", func() HealthCheckModel {
+            return HealthCheckModel{Status: HEALTH_WARN, Message: *ArpAddrFlag + " not used yet"}
+        })
+    }
+
+    locked, ok := lockedReadinessChecks(*ReadyzTimeoutFlag)
+    wgroup.Wait()
+
+    if !ok {
+        // The server is busy, not broken: the checks are reported as they
+        // were last time, or as unknown
+        message := fmt.Sprintf("busy, waited %s for the request in flight", *ReadyzTimeoutFlag)
+        for name, check := range locked {
+            check.Message = message + ", last result: " + check.Status
+            if check.Status != HEALTH_FAIL {
+                check.Status = HEALTH_WARN
+            }
+            checks[name] = check
+        }
+        if locked == nil {
+            checks["swss"] = HealthCheckModel{Status: HEALTH_WARN, Message: message}
+        }
+        return checks
+    }
+    for name, check := range locked {
+        checks[name] = check
+    }
+    return checks
+}
+
+// checkName names a check of one namespace, those of the default namespace
+// go without it
+func checkName(kind string, namespace string, what string) string {
+    if namespace == DEFAULT_NAMESPACE {
+        return kind + ":" + what
+    }
+    return kind + ":" + namespace + ":" + what
+}
+
+type namedDB struct {
+    name string
+    id   int
+}
+
+func (ns *dbNamespace) databases() []namedDB {
+    return []namedDB{
+        {APPL_DB_NAME, ns.appl_db},
+        {COUNTER_DB_NAME, ns.counter_db},
+        {CONFIG_DB_NAME, ns.config_db},
+        {RESTAPI_DB_NAME, ns.cache_db},
+    }
+}
+
+func checkRedis(client *redis.Client, DB int) HealthCheckModel {
+    pipe := client.TxPipeline()
+    pipe.Select(DB)
+    pipe.Ping()
+    if _, err := pipe.Exec(); err != nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: err.Error()}
+    }
+    return HealthCheckModel{Status: HEALTH_PASS}
+}
+
+// checkCertFile fails once a cert of file has expired, or is not valid yet,
+// and warns -certexpirywarning before
+func checkCertFile(file string) HealthCheckModel {
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: err.Error()}
+    }
+    var first *x509.Certificate
+    for {
+        var block *pem.Block
+        block, data = pem.Decode(data)
+        if block == nil {
+            break
+        }
+        if block.Type != "CERTIFICATE" {
+            continue
+        }
+        cert, err := x509.ParseCertificate(block.Bytes)
+        if err != nil {
+            return HealthCheckModel{Status: HEALTH_FAIL, Message: fmt.Sprintf("%s: %v", file, err)}
+        }
+        if first == nil || cert.NotAfter.Before(first.NotAfter) {
+            first = cert
+        }
+        if time.Now().Before(cert.NotBefore) {
+            return HealthCheckModel{Status: HEALTH_FAIL, Message: fmt.Sprintf("%s of %s is not valid before %s", cert.Subject.CommonName, file, cert.NotBefore.UTC().Format(time.RFC3339))}
+        }
+    }
+    if first == nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: "no certificate in " + file}
+    }
+
+    left := time.Until(first.NotAfter)
+    message := fmt.Sprintf("%s of %s expires %s", first.Subject.CommonName, file, first.NotAfter.UTC().Format(time.RFC3339))
+    if left <= 0 {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: fmt.Sprintf("%s of %s expired %s", first.Subject.CommonName, file, first.NotAfter.UTC().Format(time.RFC3339))}
+    }
+    if left < *CertExpiryWarningFlag {
+        return HealthCheckModel{Status: HEALTH_WARN, Message: message}
+    }
+    return HealthCheckModel{Status: HEALTH_PASS, Message: message}
+}
+
+// checkThriftBackend fails when the backend of -backend, or the ARP
+// responder with -arphooks, cannot be reached, the others only warn
+func checkThriftBackend(key string, status ThriftBackendStatusModel) HealthCheckModel {
+    if status.Reachable {
+        return HealthCheckModel{Status: HEALTH_PASS, Message: status.Address + ", circuit " + status.Circuit}
+    }
+    check := HealthCheckModel{Status: HEALTH_WARN, Message: status.Address + " unreachable, circuit " + status.Circuit}
+    if status.Error != "" {
+        check.Message += ", " + status.Error
+    }
+    if key == *BackendFlag || (key == "arp_responder" && *ArpHooksFlag) {
+        check.Status = HEALTH_FAIL
+    }
+    return check
+}
+
+// The checks run under writeMutex, one at a time: a probe that gives up
+// waiting leaves them queued and the next probe waits for the same ones
+var lockedChecks struct {
+    mutex   sync.Mutex
+    done    chan struct{}
+    results map[string]HealthCheckModel
+}
+
+// lockedReadinessChecks runs the checks that must not run next to a request,
+// false with the results of the last ones if it waited for longer than
+// timeout
+func lockedReadinessChecks(timeout time.Duration) (map[string]HealthCheckModel, bool) {
+    lockedChecks.mutex.Lock()
+    done := lockedChecks.done
+    if done == nil {
+        done = make(chan struct{})
+        lockedChecks.done = done
+        go func() {
+            writeMutex.Lock("readyz")
+            results := make(map[string]HealthCheckModel)
+            previous := currentNamespace
+            for _, ns := range namespaces {
+                for _, db := range ns.databases() {
+                    results[checkName("swss", ns.name, db.name)] = checkSwss(ns.swss[db.id])
+                }
+                useNamespace(ns)
+                results[checkName("cache", ns.name, "vnet")] = checkVnetCache()
+            }
+            results["maintenance"] = checkMaintenance()
+            useNamespace(previous)
+            writeMutex.Unlock()
+
+            lockedChecks.mutex.Lock()
+            lockedChecks.results = results
+            lockedChecks.done = nil
+            lockedChecks.mutex.Unlock()
+            close(done)
+        }()
+    }
+    lockedChecks.mutex.Unlock()
+
+    ok := true
+    select {
+    case <-done:
+    case <-time.After(timeout):
+        ok = false
+    }
+    lockedChecks.mutex.Lock()
+    defer lockedChecks.mutex.Unlock()
+    return lockedChecks.results, ok
+}
+
+func checkSwss(db swsscommon.DBConnector) HealthCheckModel {
+    t := swsscommon.NewTable(db, READYZ_PROBE_TB)
+    defer t.Delete()
+
+    if _, _, err := t.HGet(READYZ_PROBE_KEY, "status"); err != nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: err.Error()}
+    }
+    return HealthCheckModel{Status: HEALTH_PASS}
+}
+
+// checkVnetCache compares the reset info and the VNETs the server keeps with
+// those of the DBs of the current namespace. They part when the DBs are
+// flushed or written by someone else, the server has to be restarted then.
+func checkVnetCache() HealthCheckModel {
+    guid, _, _, err := CacheGetConfigResetInfo()
+    if err == redis.Nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: "the reset info is gone from " + RESTAPI_DB_NAME}
+    } else if err != nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: err.Error()}
+    }
+    if guid != ServerResetGuid {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: fmt.Sprintf("reset GUID %s of %s is not %s of the server", guid, RESTAPI_DB_NAME, ServerResetGuid)}
+    }
+
+    db := &conf_db_ops
+    kv, err := GetKVsMulti(db.db_num, generateDBTableKey(db.separator, VNET_TB, "*"))
+    if err != nil {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: err.Error()}
+    }
+    prefix := generateDBTableKey(db.separator, VNET_TB, VNET_NAME_PREF)
+    vnets := 0
+    for k, v := range kv {
+        vnet_id, err := strconv.ParseUint(strings.TrimPrefix(k, prefix), 10, 32)
+        if err != nil || v["guid"] == "" || v["vni"] == "" {
+            // Skipped when the cache is built as well
+            continue
+        }
+        if cached, ok := vnetGuidMap[v["guid"]]; !ok || cached != uint32(vnet_id) {
+            return HealthCheckModel{Status: HEALTH_FAIL, Message: fmt.Sprintf("VNET %s of %s is not cached as %s%d", v["guid"], CONFIG_DB_NAME, VNET_NAME_PREF, vnet_id)}
+        }
+        vnets++
+    }
+    if vnets != len(vnetGuidMap) {
+        return HealthCheckModel{Status: HEALTH_FAIL, Message: fmt.Sprintf("%d VNETs cached, %d in %s", len(vnetGuidMap), vnets, CONFIG_DB_NAME)}
+    }
+    return HealthCheckModel{Status: HEALTH_PASS, Message: fmt.Sprintf("%d VNETs", vnets)}
+}
+
+// checkMaintenance warns in maintenance mode, reads are still served
+func checkMaintenance() HealthCheckModel {
+    m := Maintenance()
+    if m.Mode == MAINTENANCE_MODE_ON {
+        return HealthCheckModel{Status: HEALTH_WARN, Message: "maintenance mode, " + m.Reason}
+    }
+    return HealthCheckModel{Status: HEALTH_PASS}
+}
@@ -0,0 +1,122 @@
+package restapi
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/json"
+    "encoding/pem"
+    "io/ioutil"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func writeTestCert(t *testing.T, notBefore time.Time, notAfter time.Time) string {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    template := &x509.Certificate{
+        SerialNumber: big.NewInt(1),
+        Subject:      pkix.Name{CommonName: "test.restapi.sonic"},
+        NotBefore:    notBefore,
+        NotAfter:     notAfter,
+    }
+    der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    file := filepath.Join(t.TempDir(), "cert.pem")
+    if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+        t.Fatal(err)
+    }
+    return file
+}
+
+func TestCheckCertFile(t *testing.T) {
+    now := time.Now()
+    tests := []struct {
+        notBefore time.Time
+        notAfter  time.Time
+        status    string
+    }{
+        {now.Add(-time.Hour), now.Add(365 * 24 * time.Hour), HEALTH_PASS},
+        {now.Add(-time.Hour), now.Add(24 * time.Hour), HEALTH_WARN},
+        {now.Add(-2 * time.Hour), now.Add(-time.Hour), HEALTH_FAIL},
+        {now.Add(time.Hour), now.Add(365 * 24 * time.Hour), HEALTH_FAIL},
+    }
+    for _, test := range tests {
+        check := checkCertFile(writeTestCert(t, test.notBefore, test.notAfter))
+        if check.Status != test.status {
+            t.Errorf("valid from %s to %s: got %+v, want %s", test.notBefore, test.notAfter, check, test.status)
+        }
+    }
+    if check := checkCertFile(filepath.Join(t.TempDir(), "none.pem")); check.Status != HEALTH_FAIL {
+        t.Errorf("got %+v for a missing file, want %s", check, HEALTH_FAIL)
+    }
+}
+
+func TestCheckThriftBackend(t *testing.T) {
+    down := ThriftBackendStatusModel{Address: "localhost:9090", Reachable: false, Circuit: CIRCUIT_OPEN}
+    if check := checkThriftBackend(*BackendFlag, down); check.Status != HEALTH_FAIL {
+        t.Errorf("got %+v for the backend in use, want %s", check, HEALTH_FAIL)
+    }
+    if check := checkThriftBackend("arp_responder", down); check.Status != HEALTH_WARN {
+        t.Errorf("got %+v for the ARP responder without hooks, want %s", check, HEALTH_WARN)
+    }
+    down.Reachable = true
+    if check := checkThriftBackend(*BackendFlag, down); check.Status != HEALTH_PASS {
+        t.Errorf("got %+v, want %s", check, HEALTH_PASS)
+    }
+}
+
+func TestReadyzWhileBusy(t *testing.T) {
+    defer func(timeout time.Duration) { *ReadyzTimeoutFlag = timeout }(*ReadyzTimeoutFlag)
+    *ReadyzTimeoutFlag = 10 * time.Millisecond
+
+    readyz := func() HealthModel {
+        w := httptest.NewRecorder()
+        Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+        var output HealthModel
+        if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+            t.Fatal(err)
+        }
+        if w.Code != http.StatusOK {
+            t.Errorf("got %d, want 200", w.Code)
+        }
+        return output
+    }
+    if output := readyz(); output.Status != HEALTH_PASS || output.Checks["maintenance"].Status != HEALTH_PASS {
+        t.Fatalf("got %+v, want all checks to pass", output)
+    }
+
+    // A request in flight holds the checks that need writeMutex back, the
+    // others are not held back and the server stays ready
+    writeMutex.Lock("test")
+    output := readyz()
+    writeMutex.Unlock()
+    if output.Status != HEALTH_WARN || output.Checks["maintenance"].Status != HEALTH_WARN {
+        t.Errorf("got %+v, want the checks under writeMutex to warn", output)
+    }
+}
+
+func TestReadyzLeavesArpResponderAlone(t *testing.T) {
+    defer func(hooks bool) { *ArpHooksFlag = hooks }(*ArpHooksFlag)
+    *ArpHooksFlag = true
+
+    // The probe must not set up the pool of a backend nothing has used yet
+    check := ReadinessChecks()["thrift:arp_responder"]
+    if _, ok := thriftBackends()["arp_responder"]; ok {
+        t.Fatal("the probe created the pool of the ARP responder")
+    }
+    if check.Status != HEALTH_WARN || !strings.Contains(check.Message, "not used yet") {
+        t.Errorf("got %+v, want the ARP responder not used yet", check)
+    }
+}
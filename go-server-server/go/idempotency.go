@@ -0,0 +1,133 @@
+package restapi
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "go-server-server/models"
+    "io/ioutil"
+    "log"
+    "net/http"
+)
+
+const IDEMPOTENCY_KEY_HEADER string = models.IDEMPOTENCY_KEY_HEADER
+const IDEMPOTENCY_REPLAY_HEADER string = "Idempotent-Replayed"
+const IDEMPOTENCY_KEY_MAX_LEN int = 255
+
+// recordingResponseWriter passes the response through while keeping a copy
+// of the status and body so that it can be replayed later.
+type recordingResponseWriter struct {
+    inner http.ResponseWriter
+    code  int
+    body  bytes.Buffer
+}
+
+func (w *recordingResponseWriter) Header() http.Header {
+    return w.inner.Header()
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+    if w.code == 0 {
+        w.code = http.StatusOK
+    }
+    w.body.Write(b)
+    return w.inner.Write(b)
+}
+
+func (w *recordingResponseWriter) WriteHeader(statusCode int) {
+    w.code = statusCode
+    w.inner.WriteHeader(statusCode)
+}
+
+func (w *recordingResponseWriter) Unwrap() http.ResponseWriter {
+    return w.inner
+}
+
+func idempotencyRequestHash(r *http.Request, identity string, body []byte) string {
+    h := sha256.New()
+    h.Write([]byte(identity))
+    h.Write([]byte{0})
+    h.Write([]byte(r.Method))
+    h.Write([]byte{0})
+    h.Write([]byte(r.URL.RequestURI()))
+    h.Write([]byte{0})
+    h.Write(body)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// IdempotencyMiddleware makes a POST or PATCH carrying an Idempotency-Key
+// safe to retry. The first response for a key is stored in the cache DB
+// together with a hash of the request, and any later request with the same
+// key gets that response back without running the handler again. Keys are
+// scoped to the identity of the client, one never gets the response stored
+// for another. Reusing a key for a different request is rejected. Server
+// errors are not stored so that they can be retried for real.
+//
+// This relies on Middleware serializing requests: the lookup, the handler
+// and the store all happen under the server write lock.
+func IdempotencyMiddleware(inner http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        key := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+        // A dry run neither replays nor claims a key
+        if key == "" || IsDryRun(r) {
+            inner.ServeHTTP(w, r)
+            return
+        }
+        if len(key) > IDEMPOTENCY_KEY_MAX_LEN {
+            WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call",
+                []string{IDEMPOTENCY_KEY_HEADER}, "Idempotency-Key is too long")
+            return
+        }
+
+        body, err := ReadBody(w, r)
+        if err != nil {
+            return
+        }
+        r.Body = ioutil.NopCloser(bytes.NewReader(body))
+        // Middleware has already turned away the requests without one
+        identity, _ := RequestIdentity(r)
+        requestHash := idempotencyRequestHash(r, identity, body)
+
+        stored, err := CacheGetIdempotentResponse(identity, key)
+        if err != nil {
+            log.Printf("error: could not look up idempotency key %s, error: %+v", key, err)
+            WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+            return
+        }
+        if stored != nil {
+            if stored.RequestHash != requestHash {
+                WriteRequestError(w, http.StatusUnprocessableEntity, "Idempotency-Key already used for a different request",
+                    []string{IDEMPOTENCY_KEY_HEADER}, "")
+                return
+            }
+            log.Printf("info: replaying stored response for idempotency key %s", key)
+            if stored.ContentType != "" {
+                w.Header().Set("Content-Type", stored.ContentType)
+            }
+            if stored.ETag != "" {
+                w.Header().Set("ETag", stored.ETag)
+            }
+            w.Header().Set(IDEMPOTENCY_REPLAY_HEADER, "true")
+            w.WriteHeader(stored.Code)
+            w.Write(stored.Body)
+            return
+        }
+
+        rec := &recordingResponseWriter{inner: w}
+        inner.ServeHTTP(rec, r)
+
+        if rec.code == 0 || rec.code >= http.StatusInternalServerError {
+            return
+        }
+        err = CacheSetIdempotentResponse(identity, key, &IdempotentResponse{
+            RequestHash: requestHash,
+            Code:        rec.code,
+            ContentType: w.Header().Get("Content-Type"),
+            ETag:        w.Header().Get("ETag"),
+            Body:        rec.body.Bytes(),
+        }, *IdempotencyKeyTTLFlag)
+        if err != nil {
+            log.Printf("error: could not store response for idempotency key %s, error: %+v", key, err)
+        }
+    })
+}
@@ -0,0 +1,167 @@
+package restapi
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "time"
+)
+
+// Limits that keep one misbehaving client from pinning memory or
+// connections: the timeouts hold for whole connections, the body limits for
+// the class of the route and the cap on concurrent requests for all routes
+// together, since they all queue on writeMutex.
+
+const RETRY_AFTER_HEADER string = "Retry-After"
+
+// BodyTooLargeError is what reading a body past the limit of its route
+// fails with
+type BodyTooLargeError struct {
+    Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+    return fmt.Sprintf("request body is larger than %d bytes", e.Limit)
+}
+
+// Routes taking bulk bodies, limited by -maxbulkbodybytes instead of
+// -maxbodybytes
+var bulkBodyRoutes = map[string]bool{
+    "ConfigVrouterVrfIdRoutesPatch": true,
+    "ConfigVrfVrfIdRoutesPatch": true,
+    "ConfigSnapshotPost": true,
+}
+
+func bodyLimit(name string) int64 {
+    if bulkBodyRoutes[name] {
+        return *MaxBulkBodyBytesFlag
+    }
+    return *MaxBodyBytesFlag
+}
+
+// ConfigureServer sets the timeouts of a listener
+func ConfigureServer(server *http.Server) {
+    server.ReadHeaderTimeout = *HttpReadHeaderTimeoutFlag
+    server.ReadTimeout = *HttpReadTimeoutFlag
+    server.WriteTimeout = *HttpWriteTimeoutFlag
+    server.IdleTimeout = *HttpIdleTimeoutFlag
+    server.BaseContext = serverBaseContext
+}
+
+// ExtendWriteDeadline gives the response written to w another
+// -httpwritetimeout to be sent in. Streamed lists call it as they go, so a
+// long one is only cut off when the client stops reading it.
+func ExtendWriteDeadline(w http.ResponseWriter) {
+    if *HttpWriteTimeoutFlag == 0 {
+        return
+    }
+    err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(*HttpWriteTimeoutFlag))
+    if err != nil && !errors.Is(err, http.ErrNotSupported) {
+        log.Printf("warning: could not extend the write deadline, error: %v", err)
+    }
+}
+
+// limitedBody fails with a *BodyTooLargeError once more than limit bytes
+// are read, n is what is left of it
+type limitedBody struct {
+    io.ReadCloser
+    limit int64
+    n     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+    if b.n < 0 {
+        return 0, &BodyTooLargeError{b.limit}
+    }
+    if int64(len(p)) > b.n + 1 {
+        p = p[:b.n + 1]
+    }
+    n, err := b.ReadCloser.Read(p)
+    if int64(n) > b.n {
+        n = int(b.n)
+        b.n = -1
+        return n, &BodyTooLargeError{b.limit}
+    }
+    b.n -= int64(n)
+    return n, err
+}
+
+// RequestLimiter caps the requests being served at once, 0 means no cap
+type RequestLimiter struct {
+    slots chan struct{}
+}
+
+func NewRequestLimiter(max int) *RequestLimiter {
+    l := &RequestLimiter{}
+    if max > 0 {
+        l.slots = make(chan struct{}, max)
+    }
+    return l
+}
+
+func (l *RequestLimiter) acquire() bool {
+    if l.slots == nil {
+        return true
+    }
+    select {
+    case l.slots <- struct{}{}:
+        return true
+    default:
+        return false
+    }
+}
+
+func (l *RequestLimiter) release() {
+    if l.slots != nil {
+        <-l.slots
+    }
+}
+
+// LimitMiddleware sheds requests over the cap of limiter with 503 and
+// bounds the body of the others by the limit of the route. It comes before
+// anything reading the body, v2 tells which error scheme to answer with.
+func LimitMiddleware(inner http.Handler, limiter *RequestLimiter, name string, v2 bool) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !limiter.acquire() {
+            log.Printf("warning: request: %s %s shed, too many requests in progress", r.Method, r.RequestURI)
+            w.Header().Set(RETRY_AFTER_HEADER, retryAfterSeconds(*RetryAfterFlag))
+            writeServiceError(w, v2, SERVER_BUSY, "Server busy", "Too many requests in progress")
+            return
+        }
+        defer limiter.release()
+
+        limit := bodyLimit(name)
+        if limit > 0 && r.Body != nil {
+            if r.ContentLength > limit {
+                writeBodyTooLarge(w, limit, v2)
+                return
+            }
+            r.Body = &limitedBody{ReadCloser: r.Body, limit: limit, n: limit}
+        }
+        inner.ServeHTTP(w, r)
+    })
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64, v2 bool) {
+    // What is left of the body is not read, the connection cannot be reused
+    w.Header().Set("Connection", "close")
+    writeServiceError(w, v2, PAYLOAD_TOO_LARGE, "Request body too large", (&BodyTooLargeError{limit}).Error())
+}
+
+// ReadBody reads the whole request body, answering 413 if it is over the
+// limit of the route and 500 if it cannot be read
+func ReadBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+    body, err := ioutil.ReadAll(r.Body)
+    if e, ok := err.(*BodyTooLargeError); ok {
+        writeBodyTooLarge(w, e.Limit, false)
+        return nil, err
+    }
+    if err != nil {
+        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return nil, err
+    }
+    return body, nil
+}
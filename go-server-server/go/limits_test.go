@@ -0,0 +1,223 @@
+package restapi
+
+import (
+    "encoding/json"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func readRouteHandler(w http.ResponseWriter, r *http.Request) {
+    var attr VnetModel
+    if ReadJSONBody(w, r, &attr) != nil {
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeTestError(t *testing.T, body io.Reader) ErrorInner {
+    var e ErrorModel
+    if err := json.NewDecoder(body).Decode(&e); err != nil {
+        t.Fatalf("decoding the error: %v", err)
+    }
+    return e.Error
+}
+
+func TestBodyLimit(t *testing.T) {
+    defer func(limit, bulk int64) {
+        *MaxBodyBytesFlag, *MaxBulkBodyBytesFlag = limit, bulk
+    }(*MaxBodyBytesFlag, *MaxBulkBodyBytesFlag)
+    *MaxBodyBytesFlag = 32
+    *MaxBulkBodyBytesFlag = 128
+
+    limiter := NewRequestLimiter(0)
+    small := `{"vnid": 1001}`
+    large := `{"vnid": 1001, "guid": "` + strings.Repeat("x", 40) + `"}`
+    tests := []struct {
+        name    string
+        v2      bool
+        body    string
+        chunked bool
+        status  int
+    }{
+        {"ConfigVrouterVrfIdPost", false, small, false, http.StatusNoContent},
+        {"ConfigVrouterVrfIdPost", false, large, false, http.StatusRequestEntityTooLarge},
+        // Without Content-Length the limit trips while the body is read
+        {"ConfigVrouterVrfIdPost", false, large, true, http.StatusRequestEntityTooLarge},
+        {"ConfigVrouterVrfIdRoutesPatch", false, large, true, http.StatusNoContent},
+        {"ConfigVrouterVrfIdPost", true, large, true, http.StatusRequestEntityTooLarge},
+    }
+    for _, test := range tests {
+        var h http.Handler = http.HandlerFunc(readRouteHandler)
+        if test.v2 {
+            h = V2Middleware(h, test.name)
+        }
+        h = LimitMiddleware(h, limiter, test.name, test.v2)
+
+        r := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+        if test.chunked {
+            r.ContentLength = -1
+        }
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, r)
+        if w.Code != test.status {
+            t.Errorf("%s %d bytes: got %d, want %d: %s", test.name, len(test.body), w.Code, test.status, w.Body)
+            continue
+        }
+        if w.Code != http.StatusRequestEntityTooLarge {
+            continue
+        }
+        e := decodeTestError(t, w.Body)
+        if e.Details != "request body is larger than 32 bytes" {
+            t.Errorf("%s: got details %q", test.name, e.Details)
+        }
+        if test.v2 && (e.SubCode == nil || *e.SubCode != PAYLOAD_TOO_LARGE) {
+            t.Errorf("%s: got %+v, want sub-code %d", test.name, e, PAYLOAD_TOO_LARGE)
+        }
+    }
+}
+
+func TestConcurrencyCap(t *testing.T) {
+    limiter := NewRequestLimiter(1)
+    started := make(chan bool)
+    release := make(chan bool)
+    blocking := LimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        started <- true
+        <-release
+    }), limiter, "HeartbeatGet", false)
+
+    done := make(chan bool)
+    go func() {
+        blocking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+        done <- true
+    }()
+    <-started
+
+    for _, v2 := range []bool{false, true} {
+        h := LimitMiddleware(http.HandlerFunc(readRouteHandler), limiter, "ConfigVrouterVrfIdPost", v2)
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"vnid": 1001}`)))
+        if w.Code != http.StatusServiceUnavailable {
+            t.Fatalf("v2 %t: got %d, want 503", v2, w.Code)
+        }
+        if w.Header().Get(RETRY_AFTER_HEADER) != "1" {
+            t.Errorf("v2 %t: got Retry-After %q, want 1", v2, w.Header().Get(RETRY_AFTER_HEADER))
+        }
+        e := decodeTestError(t, w.Body)
+        if v2 && (e.SubCode == nil || *e.SubCode != SERVER_BUSY) {
+            t.Errorf("got %+v, want sub-code %d", e, SERVER_BUSY)
+        }
+    }
+
+    close(release)
+    <-done
+    h := LimitMiddleware(http.HandlerFunc(readRouteHandler), limiter, "ConfigVrouterVrfIdPost", false)
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"vnid": 1001}`)))
+    if w.Code != http.StatusNoContent {
+        t.Errorf("got %d once the slot is free, want 204", w.Code)
+    }
+}
+
+func TestSlowClientTimeout(t *testing.T) {
+    defer func(timeout time.Duration) { *HttpReadHeaderTimeoutFlag = timeout }(*HttpReadHeaderTimeoutFlag)
+    *HttpReadHeaderTimeoutFlag = 100 * time.Millisecond
+
+    server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    ConfigureServer(server.Config)
+    server.Start()
+    defer server.Close()
+
+    conn, err := net.Dial("tcp", server.Listener.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    // Headers that never end
+    if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+        t.Fatal(err)
+    }
+
+    conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    start := time.Now()
+    ioutil.ReadAll(conn)
+    if time.Since(start) > 2 * time.Second {
+        t.Errorf("connection of a slow client still open after %s", time.Since(start))
+    }
+}
+
+// A list streamed for longer than -httpwritetimeout still arrives whole
+func TestStreamOutlivesWriteTimeout(t *testing.T) {
+    defer func(timeout time.Duration) { *HttpWriteTimeoutFlag = timeout }(*HttpWriteTimeoutFlag)
+    *HttpWriteTimeoutFlag = 200 * time.Millisecond
+
+    server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        stream := NewJSONStreamWriter(w, r, http.StatusOK)
+        for i := 0; i < 3 * STREAM_FLUSH_INTERVAL; i++ {
+            if i > 0 && i % STREAM_FLUSH_INTERVAL == 0 {
+                time.Sleep(150 * time.Millisecond)
+            }
+            stream.Write(i)
+        }
+        stream.Close()
+    }))
+    ConfigureServer(server.Config)
+    server.Start()
+    defer server.Close()
+
+    resp, err := http.Get(server.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    var list []int
+    if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+        t.Fatalf("decoding the list: %v", err)
+    }
+    if len(list) != 3 * STREAM_FLUSH_INTERVAL {
+        t.Errorf("got %d elements, want %d", len(list), 3 * STREAM_FLUSH_INTERVAL)
+    }
+}
+
+// A handler that let go of writeMutex holds up no one while it sends
+func TestReleaseWriteLock(t *testing.T) {
+    released := make(chan struct{})
+    sent := make(chan struct{})
+    streaming := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ReleaseWriteLock(r)
+        close(released)
+        <-sent
+    }), "ConfigVrouterVrfIdRoutesGet")
+    other := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    }), "ConfigVrouterVrfIdGet")
+
+    done := make(chan struct{})
+    go func() {
+        streaming.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+        close(done)
+    }()
+    <-released
+
+    w := httptest.NewRecorder()
+    finished := make(chan struct{})
+    go func() {
+        other.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+        close(finished)
+    }()
+    select {
+    case <-finished:
+    case <-time.After(5 * time.Second):
+        t.Fatal("request still waiting for writeMutex")
+    }
+    close(sent)
+    <-done
+    if w.Code != http.StatusNoContent {
+        t.Errorf("got %d, want 204", w.Code)
+    }
+}
@@ -24,10 +24,22 @@ func (w LoggingResponseWriter) WriteHeader(statusCode int) {
     w.inner.WriteHeader(statusCode)
 }
 
+func (w LoggingResponseWriter) Flush() {
+    if f, ok := w.inner.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+func (w LoggingResponseWriter) Unwrap() http.ResponseWriter {
+    return w.inner
+}
+
 func NewLoggingResponseWriter(w http.ResponseWriter) LoggingResponseWriter {
     return LoggingResponseWriter{inner: w}
 }
 
+var logFile *os.File
+
 func InitLogging() {
     colog.Register()
 
@@ -42,4 +54,24 @@ func InitLogging() {
         log.Fatalf("error: couldn't open log file %s, %s", *LogFileFlag, err)
     }
     colog.SetOutput(file)
+    logFile = file
+}
+
+// ReloadLogging applies -loglevel and reopens -logfile, keeping what is in
+// use if either is invalid
+func ReloadLogging() {
+    level, err := colog.ParseLevel(*LogLevelFlag)
+    if err != nil {
+        log.Printf("error: invalid minimum log level %s", *LogLevelFlag)
+        return
+    }
+    file, err := os.OpenFile(*LogFileFlag, os.O_RDWR | os.O_CREATE | os.O_APPEND, 0666)
+    if err != nil {
+        log.Printf("error: couldn't open log file %s, %s", *LogFileFlag, err)
+        return
+    }
+    colog.SetMinLevel(level)
+    colog.SetOutput(file)
+    logFile.Close()
+    logFile = file
 }
\ No newline at end of file
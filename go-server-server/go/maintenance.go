@@ -0,0 +1,199 @@
+package restapi
+
+import (
+    "go-server-server/models"
+    "io/ioutil"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+// Maintenance mode keeps controllers from writing config during upgrades and
+// warm reboots: mutating routes answer 503 while reads keep working. The
+// mode only changes under writeMutex, so a mutation in flight when it is
+// turned on finishes first and the ones waiting for their turn see it.
+
+const MAINTENANCE_MODE_ON string  = models.MAINTENANCE_MODE_ON
+const MAINTENANCE_MODE_OFF string = models.MAINTENANCE_MODE_OFF
+
+const MAINTENANCE_TRIGGER_API string  = "api"
+const MAINTENANCE_TRIGGER_FILE string = "file"
+
+// Retry-After of maintenance mode, in seconds, when none is given
+const MAINTENANCE_RETRY_AFTER_DEFAULT int = 60
+
+const MAINTENANCE_FILE_POLL_INTERVAL = time.Second
+
+// Routes served in maintenance mode whatever their method, they change no
+// config
+var maintenanceExempt = map[string]bool{
+    "ConfigMaintenancePost": true,
+    "StateArpResolvePost": true,
+    "Ping": true,
+}
+
+// Maintenance mode set through the API and through -maintenancefile, nil
+// when off. Guarded by writeMutex.
+var maintenanceAPI *MaintenanceModel
+var maintenanceFile *MaintenanceModel
+
+// Maintenance gives the mode the server is in, the API trigger wins over
+// the file. It must be called under writeMutex.
+func Maintenance() MaintenanceModel {
+    if maintenanceAPI != nil {
+        return *maintenanceAPI
+    }
+    if maintenanceFile != nil {
+        return *maintenanceFile
+    }
+    return MaintenanceModel{Mode: MAINTENANCE_MODE_OFF}
+}
+
+// The identities of -adminclientcertcommonname and -adminunixsocketuids, a
+// map[string]bool replaced as a whole when the config is reloaded
+var adminIdentities atomic.Value
+
+// LoadAdminIdentities sets up the clients allowed to change maintenance mode
+func LoadAdminIdentities() error {
+    uids, err := parseIds(*AdminUnixSocketUidsFlag)
+    if err != nil {
+        return err
+    }
+    identities := make(map[string]bool)
+    for _, name := range strings.Split(*AdminClientCertCommonNameFlag, ",") {
+        name = strings.TrimSpace(name)
+        if name != "" {
+            identities["cn:" + name] = true
+        }
+    }
+    for uid := range uids {
+        identities["uid:" + strconv.FormatUint(uint64(uid), 10)] = true
+    }
+    adminIdentities.Store(identities)
+    return nil
+}
+
+// IsAdmin tells whether the client of a request may change maintenance mode.
+// Plain HTTP tells clients apart by their IP only, so it trusts those on the
+// box itself.
+func IsAdmin(r *http.Request) bool {
+    identity, ok := RequestIdentity(r)
+    if !ok {
+        return false
+    }
+    var admin bool
+    if strings.HasPrefix(identity, "ip:") {
+        ip := net.ParseIP(strings.TrimPrefix(identity, "ip:"))
+        admin = ip != nil && ip.IsLoopback()
+    } else {
+        identities, _ := adminIdentities.Load().(map[string]bool)
+        admin = identities[identity]
+    }
+    if !admin {
+        log.Printf("error: %s is not allowed to change maintenance mode", identity)
+        return false
+    }
+    return true
+}
+
+func isMutation(route Route) bool {
+    return (route.Method == "POST" || route.Method == "PATCH" || route.Method == "DELETE") &&
+        !maintenanceExempt[route.Name]
+}
+
+// MaintenanceMiddleware answers 503 to a mutation in maintenance mode. It
+// runs under writeMutex.
+func MaintenanceMiddleware(inner http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        m := Maintenance()
+        if m.Mode != MAINTENANCE_MODE_ON {
+            inner.ServeHTTP(w, r)
+            return
+        }
+
+        w.Header().Set(RETRY_AFTER_HEADER, strconv.Itoa(m.RetryAfter))
+        writeServiceError(w, IsV2(r), MAINTENANCE_MODE, "Maintenance mode", m.Reason)
+    })
+}
+
+func ConfigMaintenanceGet(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    WriteRequestResponse(w, Maintenance(), http.StatusOK)
+}
+
+func ConfigMaintenancePost(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+    if !IsAdmin(r) {
+        writeServiceError(w, IsV2(r), FORBIDDEN, "Forbidden", "Only admin clients may change maintenance mode")
+        return
+    }
+
+    var attr MaintenanceModel
+    err := ReadJSONBody(w, r, &attr)
+    if err != nil {
+        // The error is already handled in this case
+        return
+    }
+    if attr.Mode != MAINTENANCE_MODE_ON && attr.Mode != MAINTENANCE_MODE_OFF {
+        WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"mode"},
+                          "mode must be either maintenance or normal")
+        return
+    }
+
+    if !IsDryRun(r) {
+        if attr.Mode == MAINTENANCE_MODE_ON {
+            if attr.RetryAfter == 0 {
+                attr.RetryAfter = MAINTENANCE_RETRY_AFTER_DEFAULT
+            }
+            attr.Trigger = MAINTENANCE_TRIGGER_API
+            maintenanceAPI = &attr
+            log.Printf("info: maintenance mode on, reason: %s", attr.Reason)
+        } else if maintenanceAPI != nil {
+            maintenanceAPI = nil
+            log.Printf("info: maintenance mode set through the API off")
+        }
+    }
+    // Turning the API trigger off leaves that of -maintenancefile
+    WriteRequestResponse(w, Maintenance(), http.StatusOK)
+}
+
+// WatchMaintenanceFile keeps the server in maintenance mode while
+// -maintenancefile exists, what it holds being the reason
+func WatchMaintenanceFile() {
+    var current *MaintenanceModel
+    for {
+        var m *MaintenanceModel
+        data, err := ioutil.ReadFile(*MaintenanceFileFlag)
+        if err == nil {
+            m = &MaintenanceModel{
+                Mode:       MAINTENANCE_MODE_ON,
+                Reason:     strings.TrimSpace(string(data)),
+                RetryAfter: MAINTENANCE_RETRY_AFTER_DEFAULT,
+                Trigger:    MAINTENANCE_TRIGGER_FILE,
+            }
+        } else if !os.IsNotExist(err) {
+            log.Printf("error: could not read maintenance file %s, error: %+v", *MaintenanceFileFlag, err)
+            m = current
+        }
+
+        if (m == nil) != (current == nil) || (m != nil && *m != *current) {
+            // Waits for the request in flight
+            writeMutex.Lock(MAINTENANCE_TRIGGER_FILE)
+            maintenanceFile = m
+            writeMutex.Unlock()
+            if m != nil {
+                log.Printf("info: maintenance mode on, %s exists, reason: %s", *MaintenanceFileFlag, m.Reason)
+            } else {
+                log.Printf("info: maintenance mode of %s off", *MaintenanceFileFlag)
+            }
+            current = m
+        }
+        time.Sleep(MAINTENANCE_FILE_POLL_INTERVAL)
+    }
+}
@@ -0,0 +1,195 @@
+package restapi
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestIsMutation(t *testing.T) {
+    tests := []struct {
+        route    Route
+        mutation bool
+    }{
+        {Route{Name: "ConfigVrouterVrfIdPost", Method: "POST"}, true},
+        {Route{Name: "ConfigVrouterVrfIdRoutesPatch", Method: "PATCH"}, true},
+        {Route{Name: "ConfigVrouterVrfIdDelete", Method: "DELETE"}, true},
+        {Route{Name: "ConfigVrouterVrfIdGet", Method: "GET"}, false},
+        {Route{Name: "Ping", Method: "POST"}, false},
+        {Route{Name: "ConfigMaintenancePost", Method: "POST"}, false},
+    }
+    for _, test := range tests {
+        if isMutation(test.route) != test.mutation {
+            t.Errorf("%s: got mutation %t", test.route.Name, !test.mutation)
+        }
+    }
+}
+
+// adminRequest posts body from the box itself, which plain HTTP trusts
+func adminRequest(body string) *http.Request {
+    r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+    r.RemoteAddr = "127.0.0.1:1234"
+    return r
+}
+
+func TestMaintenanceDrainsMutations(t *testing.T) {
+    defer func() { maintenanceAPI = nil }()
+
+    started := make(chan bool)
+    release := make(chan bool)
+    mutation := Middleware(MaintenanceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("X-Block") != "" {
+            started <- true
+            <-release
+        }
+        w.WriteHeader(http.StatusNoContent)
+    })), "ConfigVrouterVrfIdPost")
+    maintenance := Middleware(http.HandlerFunc(ConfigMaintenancePost), "ConfigMaintenancePost")
+
+    // A mutation in flight when maintenance mode is asked for
+    inFlight := httptest.NewRecorder()
+    done := make(chan bool)
+    go func() {
+        r := httptest.NewRequest("POST", "/", nil)
+        r.Header.Set("X-Block", "1")
+        mutation.ServeHTTP(inFlight, r)
+        done <- true
+    }()
+    <-started
+
+    on := httptest.NewRecorder()
+    go func() {
+        maintenance.ServeHTTP(on, adminRequest(`{"mode": "maintenance", "reason": "upgrade", "retry_after": 30}`))
+        done <- true
+    }()
+    close(release)
+    <-done
+    <-done
+    if inFlight.Code != http.StatusNoContent || on.Code != http.StatusOK {
+        t.Fatalf("got %d for the mutation in flight and %d for maintenance mode, want 204 and 200", inFlight.Code, on.Code)
+    }
+
+    for _, v2 := range []bool{false, true} {
+        r := httptest.NewRequest("POST", "/", nil)
+        var h http.Handler = mutation
+        if v2 {
+            h = V2Middleware(h, "ConfigVrouterVrfIdPost")
+        }
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, r)
+        if w.Code != http.StatusServiceUnavailable || w.Header().Get(RETRY_AFTER_HEADER) != "30" {
+            t.Fatalf("v2 %t: got %d with Retry-After %q, want 503 with 30", v2, w.Code, w.Header().Get(RETRY_AFTER_HEADER))
+        }
+        e := decodeTestError(t, w.Body)
+        if e.Details != "upgrade" {
+            t.Errorf("v2 %t: got details %q, want the reason", v2, e.Details)
+        }
+        if v2 && (e.SubCode == nil || *e.SubCode != MAINTENANCE_MODE) {
+            t.Errorf("got %+v, want sub-code %d", e, MAINTENANCE_MODE)
+        }
+    }
+
+    off := httptest.NewRecorder()
+    maintenance.ServeHTTP(off, adminRequest(`{"mode": "normal"}`))
+    w := httptest.NewRecorder()
+    mutation.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+    if off.Code != http.StatusOK || w.Code != http.StatusNoContent {
+        t.Errorf("got %d and %d once maintenance mode is off, want 200 and 204", off.Code, w.Code)
+    }
+}
+
+func TestMaintenanceFileWins(t *testing.T) {
+    defer func() { maintenanceAPI, maintenanceFile = nil, nil }()
+
+    maintenanceFile = &MaintenanceModel{Mode: MAINTENANCE_MODE_ON, Reason: "warm reboot", RetryAfter: MAINTENANCE_RETRY_AFTER_DEFAULT, Trigger: MAINTENANCE_TRIGGER_FILE}
+    if m := Maintenance(); m.Mode != MAINTENANCE_MODE_ON || m.Trigger != MAINTENANCE_TRIGGER_FILE {
+        t.Errorf("got %+v, want the mode of the file", m)
+    }
+    // Turning it off through the API leaves that of the file, and says so
+    w := httptest.NewRecorder()
+    ConfigMaintenancePost(w, adminRequest(`{"mode": "normal"}`))
+    var m MaintenanceModel
+    if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil || w.Code != http.StatusOK {
+        t.Fatalf("got %d with %s, want 200 with the mode", w.Code, w.Body)
+    }
+    if m != Maintenance() || m.Mode != MAINTENANCE_MODE_ON || m.Trigger != MAINTENANCE_TRIGGER_FILE {
+        t.Errorf("got %+v, want maintenance mode of the file to stay on", m)
+    }
+}
+
+func TestConfigMaintenanceAdmin(t *testing.T) {
+    defer func(uids, admins string) {
+        *UnixSocketUidsFlag, *AdminUnixSocketUidsFlag = uids, admins
+        LoadUnixSocketPeers()
+        LoadAdminIdentities()
+        maintenanceAPI = nil
+    }(*UnixSocketUidsFlag, *AdminUnixSocketUidsFlag)
+    *UnixSocketUidsFlag, *AdminUnixSocketUidsFlag = "0,1000", "0"
+    if err := LoadUnixSocketPeers(); err != nil {
+        t.Fatal(err)
+    }
+    if err := LoadAdminIdentities(); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name   string
+        remote string
+        cred   *PeerCredentials
+        code   int
+    }{
+        {"plain http from loopback", "127.0.0.1:1234", nil, http.StatusOK},
+        {"plain http from ipv6 loopback", "[::1]:1234", nil, http.StatusOK},
+        {"plain http from elsewhere", "192.0.2.1:1234", nil, http.StatusForbidden},
+        {"admin uid", "", &PeerCredentials{Uid: 0, Gid: 0}, http.StatusOK},
+        {"uid allowed on the socket only", "", &PeerCredentials{Uid: 1000, Gid: 1000}, http.StatusForbidden},
+    }
+    for _, test := range tests {
+        for _, v2 := range []bool{false, true} {
+            maintenanceAPI = nil
+            r := httptest.NewRequest("POST", "/", strings.NewReader(`{"mode": "maintenance"}`))
+            if test.remote != "" {
+                r.RemoteAddr = test.remote
+            }
+            if test.cred != nil {
+                r = r.WithContext(context.WithValue(r.Context(), peerCredentialsKey{}, test.cred))
+            }
+            var h http.Handler = http.HandlerFunc(ConfigMaintenancePost)
+            if v2 {
+                h = V2Middleware(h, "ConfigMaintenancePost")
+            }
+            w := httptest.NewRecorder()
+            h.ServeHTTP(w, r)
+            if w.Code != test.code {
+                t.Fatalf("%s, v2 %t: got %d, want %d", test.name, v2, w.Code, test.code)
+            }
+            if test.code != http.StatusOK {
+                if maintenanceAPI != nil {
+                    t.Errorf("%s: maintenance mode turned on", test.name)
+                }
+                if e := decodeTestError(t, w.Body); v2 && (e.SubCode == nil || *e.SubCode != FORBIDDEN) {
+                    t.Errorf("%s: got %+v, want sub-code %d", test.name, e, FORBIDDEN)
+                }
+            }
+        }
+    }
+
+    // A mode other than maintenance and normal leaves maintenance mode on
+    maintenanceAPI = &MaintenanceModel{Mode: MAINTENANCE_MODE_ON, Trigger: MAINTENANCE_TRIGGER_API}
+    w := httptest.NewRecorder()
+    ConfigMaintenancePost(w, adminRequest(`{"mode": "off"}`))
+    if w.Code != http.StatusBadRequest || maintenanceAPI == nil {
+        t.Errorf("got %d and %+v, want 400 with maintenance mode on", w.Code, maintenanceAPI)
+    }
+
+    // So does a negative retry_after
+    maintenanceAPI = nil
+    w = httptest.NewRecorder()
+    ConfigMaintenancePost(w, adminRequest(`{"mode": "maintenance", "retry_after": -5}`))
+    if w.Code != http.StatusBadRequest || maintenanceAPI != nil {
+        t.Errorf("got %d and %+v, want 400 with maintenance mode off", w.Code, maintenanceAPI)
+    }
+}
@@ -0,0 +1,180 @@
+package restapi
+
+import (
+    "github.com/gorilla/mux"
+    "mseethrift"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// stateCache keeps data plane readouts for a short while, so that many
+// pollers cost the MSEE one RPC per TTL. A fetch runs outside the lock, the
+// misses that come while it is in flight wait for it instead of sending an
+// RPC of their own.
+type stateCache struct {
+    mu      sync.Mutex
+    entries map[string]*stateCacheEntry
+}
+
+type stateCacheEntry struct {
+    // Closed once the fetch is done and the fields below are set
+    done    chan struct{}
+    expires time.Time
+    value   interface{}
+    err     error
+}
+
+func (c *stateCache) get(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+    c.mu.Lock()
+    now := time.Now()
+    if e, ok := c.entries[key]; ok {
+        select {
+        case <-e.done:
+            if now.Before(e.expires) {
+                c.mu.Unlock()
+                return e.value, nil
+            }
+        default:
+            c.mu.Unlock()
+            <-e.done
+            return e.value, e.err
+        }
+    }
+
+    if c.entries == nil {
+        c.entries = make(map[string]*stateCacheEntry)
+    }
+    for k, e := range c.entries {
+        select {
+        case <-e.done:
+            if !now.Before(e.expires) {
+                delete(c.entries, k)
+            }
+        default:
+        }
+    }
+    e := &stateCacheEntry{done: make(chan struct{})}
+    c.entries[key] = e
+    c.mu.Unlock()
+
+    value, err := fetch()
+
+    c.mu.Lock()
+    e.value, e.err = value, err
+    if err == nil && ttl > 0 {
+        e.expires = now.Add(ttl)
+    }
+    close(e.done)
+    c.mu.Unlock()
+    return value, err
+}
+
+var mseeStateCache stateCache
+
+func StateMseeCountersGroupGet(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    vars := mux.Vars(r)
+    group := vars["group"]
+
+    value, err := mseeStateCache.get("counters/" + group, *MseeStateCacheTTLFlag, func() (interface{}, error) {
+        var counters msee.CountersT
+        err := MseeConnection().do("get_counters", func(client *msee.MSEEClient) (err error) {
+            counters, err = client.GetCounters(msee.MseeGroupT(group))
+            return
+        })
+        return counters, err
+    })
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+
+    counters := value.(msee.CountersT)
+    if len(counters) == 0 {
+        WriteRequestError(w, http.StatusNotFound, "Object not found", []string{"group"}, "")
+        return
+    }
+
+    output := MseeCountersReturnModel{
+        Group:    group,
+        Counters: make(map[string]map[string]int64, len(counters)),
+    }
+    for g, values := range counters {
+        m := make(map[string]int64, len(values))
+        for name, v := range values {
+            m[string(name)] = v
+        }
+        output.Counters[string(g)] = m
+    }
+    WriteRequestResponse(w, output, http.StatusOK)
+}
+
+func StateMseeStatisticsGroupGet(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    vars := mux.Vars(r)
+    group := vars["group"]
+
+    value, err := mseeStateCache.get("statistics/" + group, *MseeStateCacheTTLFlag, func() (interface{}, error) {
+        var statistics msee.StatisticsT
+        err := MseeConnection().do("get_statistics", func(client *msee.MSEEClient) (err error) {
+            statistics, err = client.GetStatistics(msee.MseeGroupT(group))
+            return
+        })
+        return statistics, err
+    })
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+
+    statistics := value.(msee.StatisticsT)
+    if len(statistics) == 0 {
+        WriteRequestError(w, http.StatusNotFound, "Object not found", []string{"group"}, "")
+        return
+    }
+
+    output := MseeStatisticsReturnModel{
+        Group:      group,
+        Statistics: make(map[string]map[string]int64, len(statistics)),
+    }
+    for g, values := range statistics {
+        m := make(map[string]int64, len(values))
+        for name, v := range values {
+            m[string(name)] = v
+        }
+        output.Statistics[string(g)] = m
+    }
+    WriteRequestResponse(w, output, http.StatusOK)
+}
+
+func StateMseeHistogramGet(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+    value, err := mseeStateCache.get("histogram", *MseeStateCacheTTLFlag, func() (interface{}, error) {
+        var hist msee.HistT
+        err := MseeConnection().do("get_hist", func(client *msee.MSEEClient) (err error) {
+            hist, err = client.GetHist()
+            return
+        })
+        return hist, err
+    })
+    if err != nil {
+        WriteBackendError(w, err)
+        return
+    }
+
+    hist := value.(msee.HistT)
+    output := MseeHistogramReturnModel{
+        Histogram: make(map[string]map[string]float64, len(hist)),
+    }
+    for queue, buckets := range hist {
+        m := make(map[string]float64, len(buckets))
+        for bucket, v := range buckets {
+            m[strconv.Itoa(int(bucket))] = v
+        }
+        output.Histogram[strconv.Itoa(int(queue))] = m
+    }
+    WriteRequestResponse(w, output, http.StatusOK)
+}
@@ -0,0 +1,78 @@
+package restapi
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestStateCacheFetchesOnce(t *testing.T) {
+    var c stateCache
+    var fetches int
+    release := make(chan bool)
+    fetch := func() (interface{}, error) {
+        fetches++
+        <-release
+        return fetches, nil
+    }
+
+    var wg sync.WaitGroup
+    values := make(chan interface{}, 3)
+    for i := 0; i < 3; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            value, _ := c.get("counters/a", time.Minute, fetch)
+            values <- value
+        }()
+    }
+
+    // Another key is fetched while the first one is in flight
+    other, err := c.get("counters/b", time.Minute, func() (interface{}, error) { return "b", nil })
+    if other != "b" || err != nil {
+        t.Errorf("got %v, %v for another key", other, err)
+    }
+
+    close(release)
+    wg.Wait()
+    close(values)
+    for value := range values {
+        if value != 1 {
+            t.Errorf("got %v, want the value of the one fetch", value)
+        }
+    }
+    if value, _ := c.get("counters/a", time.Minute, fetch); value != 1 || fetches != 1 {
+        t.Errorf("got %v after %d fetches, want the cached value", value, fetches)
+    }
+}
+
+func TestStateCacheKeepsNoErrors(t *testing.T) {
+    var c stateCache
+    failed := errors.New("unreachable")
+    if _, err := c.get("histogram", time.Minute, func() (interface{}, error) { return nil, failed }); err != failed {
+        t.Fatalf("got %v, want the error of the fetch", err)
+    }
+    value, err := c.get("histogram", time.Minute, func() (interface{}, error) { return "h", nil })
+    if value != "h" || err != nil {
+        t.Errorf("got %v, %v, want the fetch retried", value, err)
+    }
+}
+
+func TestUnlockedRoutesSkipWriteLock(t *testing.T) {
+    served := make(chan bool, 1)
+    h := routeHandler(Route{"StateMseeHistogramGet", "GET", "/", func(w http.ResponseWriter, r *http.Request) {
+        served <- true
+    }}, "StateMseeHistogramGet")
+
+    writeMutex.Lock("test")
+    defer writeMutex.Unlock()
+    go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+    select {
+    case <-served:
+    case <-time.After(time.Second):
+        t.Fatal("waited for the write lock")
+    }
+}
@@ -0,0 +1,481 @@
+package restapi
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "gopkg.in/yaml.v2"
+    "io/ioutil"
+    "log"
+    "math"
+    "net"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+const OPENAPI_CONTENT_TYPE string = "application/yaml"
+const OPENAPI_DEFINITIONS_REF string = "#/definitions/"
+
+// openAPIOperation is an operation of the spec, keyed by its operationId,
+// which is the Name of the Route serving it
+type openAPIOperation struct {
+    Id         string
+    Method     string
+    Path       string
+    PathParams []string
+    // Schema of the body parameter, nil if the operation takes no body
+    Body       interface{}
+    // Schemas of the responses by status code or "default", nil for a
+    // response without a body
+    Responses  map[string]interface{}
+    // Documented for clients but not served yet, x-unimplemented in the spec
+    Unimplemented bool
+}
+
+// openAPISpec is the Swagger 2.0 document of the API, sonic_api.yaml
+type openAPISpec struct {
+    raw         []byte
+    definitions map[string]interface{}
+    operations  map[string]*openAPIOperation
+}
+
+// openAPIViolation is a part of a body that does not match its schema
+type openAPIViolation struct {
+    Field   string
+    Message string
+}
+
+func (v openAPIViolation) String() string {
+    if v.Field == "" {
+        return v.Message
+    }
+    return v.Field + ": " + v.Message
+}
+
+var openAPI *openAPISpec
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// yamlToJSON turns the maps yaml.v2 decodes into the map[string]interface{}
+// encoding/json would have given
+func yamlToJSON(v interface{}) interface{} {
+    switch t := v.(type) {
+    case map[interface{}]interface{}:
+        m := make(map[string]interface{}, len(t))
+        for k, e := range t {
+            m[fmt.Sprint(k)] = yamlToJSON(e)
+        }
+        return m
+    case []interface{}:
+        for i, e := range t {
+            t[i] = yamlToJSON(e)
+        }
+        return t
+    }
+    return v
+}
+
+func yamlMap(v interface{}) map[string]interface{} {
+    m, _ := v.(map[string]interface{})
+    return m
+}
+
+func yamlList(v interface{}) []interface{} {
+    l, _ := v.([]interface{})
+    return l
+}
+
+func yamlString(v interface{}) string {
+    s, _ := v.(string)
+    return s
+}
+
+// LoadOpenAPISpec reads the spec at path and indexes its operations
+func LoadOpenAPISpec(path string) (*openAPISpec, error) {
+    raw, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var doc interface{}
+    if err = yaml.Unmarshal(raw, &doc); err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    root := yamlMap(yamlToJSON(doc))
+    if yamlString(root["swagger"]) != "2.0" {
+        return nil, fmt.Errorf("%s: not a Swagger 2.0 document", path)
+    }
+
+    spec := &openAPISpec{
+        raw:         raw,
+        definitions: yamlMap(root["definitions"]),
+        operations:  make(map[string]*openAPIOperation),
+    }
+    basePath := strings.TrimSuffix(yamlString(root["basePath"]), "/")
+    for pattern, item := range yamlMap(root["paths"]) {
+        pathItem := yamlMap(item)
+        for _, method := range openAPIMethods {
+            operation := yamlMap(pathItem[method])
+            if operation == nil {
+                continue
+            }
+            op := &openAPIOperation{
+                Id:        yamlString(operation["operationId"]),
+                Method:    strings.ToUpper(method),
+                Path:      basePath + pattern,
+                Responses: make(map[string]interface{}),
+            }
+            op.Unimplemented, _ = operation["x-unimplemented"].(bool)
+            if op.Id == "" {
+                return nil, fmt.Errorf("%s: %s %s has no operationId", path, op.Method, pattern)
+            }
+            if _, ok := spec.operations[op.Id]; ok {
+                return nil, fmt.Errorf("%s: operationId %s is used twice", path, op.Id)
+            }
+
+            params := append(yamlList(pathItem["parameters"]), yamlList(operation["parameters"])...)
+            for _, p := range params {
+                param := yamlMap(p)
+                if ref := yamlString(param["$ref"]); ref != "" {
+                    param = yamlMap(yamlMap(root["parameters"])[strings.TrimPrefix(ref, "#/parameters/")])
+                }
+                switch yamlString(param["in"]) {
+                case "path":
+                    op.PathParams = append(op.PathParams, yamlString(param["name"]))
+                case "body":
+                    op.Body = param["schema"]
+                }
+            }
+            for code, response := range yamlMap(operation["responses"]) {
+                op.Responses[code] = yamlMap(response)["schema"]
+            }
+            spec.operations[op.Id] = op
+        }
+    }
+    return spec, nil
+}
+
+// InitialiseOpenAPI loads the spec served at /v1/openapi.yaml. The server
+// runs without one unless it is strict about it.
+func InitialiseOpenAPI() {
+    spec, err := LoadOpenAPISpec(*OpenAPIFlag)
+    if err != nil {
+        if *OpenAPIStrictFlag {
+            log.Fatalf("error: could not load the API specification, error: %+v", err)
+        }
+        log.Printf("warning: no API specification, error: %+v", err)
+        return
+    }
+    openAPI = spec
+    log.Printf("info: loaded the API specification %s, %d operations", *OpenAPIFlag, len(spec.operations))
+}
+
+// Operation gives the operation served by the Route named id
+func (s *openAPISpec) Operation(id string) *openAPIOperation {
+    if s == nil {
+        return nil
+    }
+    return s.operations[id]
+}
+
+// Validate checks a JSON body against schema
+func (s *openAPISpec) Validate(schema interface{}, body []byte) []openAPIViolation {
+    dec := json.NewDecoder(bytes.NewReader(body))
+    dec.UseNumber()
+    var value interface{}
+    if err := dec.Decode(&value); err != nil {
+        return []openAPIViolation{{Message: "body is not JSON"}}
+    }
+    return s.validate(schema, value, "", nil)
+}
+
+func joinField(field string, name string) string {
+    if field == "" {
+        return name
+    }
+    return field + "." + name
+}
+
+func (s *openAPISpec) validate(schema interface{}, value interface{}, field string, violations []openAPIViolation) []openAPIViolation {
+    sch := yamlMap(schema)
+    for depth := 0; sch["$ref"] != nil; depth++ {
+        ref := yamlString(sch["$ref"])
+        def, ok := s.definitions[strings.TrimPrefix(ref, OPENAPI_DEFINITIONS_REF)]
+        if !ok || depth > len(s.definitions) {
+            return append(violations, openAPIViolation{field, "unresolvable schema " + ref})
+        }
+        sch = yamlMap(def)
+    }
+    if sch == nil {
+        return violations
+    }
+
+    typ := yamlString(sch["type"])
+    if typ == "" && sch["properties"] != nil {
+        typ = "object"
+    }
+    if value == nil {
+        if typ == "" {
+            return violations
+        }
+        return append(violations, openAPIViolation{field, "must not be null"})
+    }
+
+    switch typ {
+    case "object":
+        obj, ok := value.(map[string]interface{})
+        if !ok {
+            return append(violations, openAPIViolation{field, "must be an object"})
+        }
+        for _, r := range yamlList(sch["required"]) {
+            if _, ok := obj[yamlString(r)]; !ok {
+                violations = append(violations, openAPIViolation{joinField(field, yamlString(r)), "is required"})
+            }
+        }
+        properties := yamlMap(sch["properties"])
+        names := make([]string, 0, len(obj))
+        for name := range obj {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            if property, ok := properties[name]; ok {
+                violations = s.validate(property, obj[name], joinField(field, name), violations)
+                continue
+            }
+            switch extra := sch["additionalProperties"].(type) {
+            case bool:
+                if !extra {
+                    violations = append(violations, openAPIViolation{joinField(field, name), "is not a known field"})
+                }
+            case map[string]interface{}:
+                violations = s.validate(extra, obj[name], joinField(field, name), violations)
+            }
+        }
+    case "array":
+        list, ok := value.([]interface{})
+        if !ok {
+            return append(violations, openAPIViolation{field, "must be an array"})
+        }
+        for i, item := range list {
+            violations = s.validate(sch["items"], item, field + "[" + strconv.Itoa(i) + "]", violations)
+        }
+    case "string":
+        str, ok := value.(string)
+        if !ok {
+            return append(violations, openAPIViolation{field, "must be a string"})
+        }
+        if yamlString(sch["format"]) == "ipv4" {
+            if ip := net.ParseIP(str); ip == nil || ip.To4() == nil {
+                violations = append(violations, openAPIViolation{field, "must be an IPv4 address"})
+            }
+        }
+    case "integer", "number":
+        what := "a number"
+        if typ == "integer" {
+            what = "an integer"
+        }
+        num, ok := value.(json.Number)
+        if !ok {
+            return append(violations, openAPIViolation{field, "must be " + what})
+        }
+        f, err := num.Float64()
+        if err != nil || (typ == "integer" && f != math.Trunc(f)) {
+            return append(violations, openAPIViolation{field, "must be " + what})
+        }
+        if typ == "integer" {
+            if yamlString(sch["format"]) == "int32" && (f < math.MinInt32 || f > math.MaxInt32) {
+                violations = append(violations, openAPIViolation{field, "must fit in 32 bits"})
+            }
+        }
+        if min, ok := sch["minimum"].(int); ok && f < float64(min) {
+            violations = append(violations, openAPIViolation{field, fmt.Sprintf("must be at least %d", min)})
+        }
+        if max, ok := sch["maximum"].(int); ok && f > float64(max) {
+            violations = append(violations, openAPIViolation{field, fmt.Sprintf("must be at most %d", max)})
+        }
+    case "boolean":
+        if _, ok := value.(bool); !ok {
+            return append(violations, openAPIViolation{field, "must be a boolean"})
+        }
+    }
+
+    if enum := yamlList(sch["enum"]); enum != nil {
+        for _, e := range enum {
+            if fmt.Sprint(e) == fmt.Sprint(value) {
+                return violations
+            }
+        }
+        allowed := make([]string, len(enum))
+        for i, e := range enum {
+            allowed[i] = fmt.Sprint(e)
+        }
+        violations = append(violations, openAPIViolation{field, "must be one of " + strings.Join(allowed, ", ")})
+    }
+    return violations
+}
+
+func openAPIFields(violations []openAPIViolation) (fields []string, details string) {
+    fields = []string{}
+    msgs := make([]string, len(violations))
+    for i, v := range violations {
+        if v.Field != "" {
+            fields = append(fields, v.Field)
+        }
+        msgs[i] = v.String()
+    }
+    return fields, strings.Join(msgs, "; ")
+}
+
+// OpenAPIMiddleware holds the body of a request and of its response to the
+// schemas of the spec operation of the route. A request that does not match
+// is answered 400 without running the handler, a response that does not
+// match is logged, so that divergences between the server and the spec
+// cannot go unnoticed. The response of a GET is held back and replaced by a
+// 500; that of a mutation is sent as it is, its writes have been done.
+// Responses are checked against the schema of their status code, or the
+// default one; statuses the spec does not list, dry runs and streamed lists
+// are not checked.
+func OpenAPIMiddleware(inner http.Handler, name string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        op := openAPI.Operation(name)
+        if op == nil {
+            inner.ServeHTTP(w, r)
+            return
+        }
+
+        if op.Body != nil && r.Body != nil {
+            body, err := ReadBody(w, r)
+            if err != nil {
+                return
+            }
+            r.Body = ioutil.NopCloser(bytes.NewReader(body))
+            // Bodies that are no JSON at all get the error of the handler
+            if json.Valid(body) {
+                if violations := openAPI.Validate(op.Body, body); len(violations) > 0 {
+                    fields, details := openAPIFields(violations)
+                    WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", fields, details)
+                    return
+                }
+            }
+        }
+
+        if dryRun, _ := strconv.ParseBool(r.URL.Query().Get(DRY_RUN_QUERY_PARAM)); dryRun {
+            inner.ServeHTTP(w, r)
+            return
+        }
+
+        out := &openAPIResponseWriter{w: w, header: make(http.Header), hold: r.Method == "GET"}
+        inner.ServeHTTP(out, r)
+        if out.streamed {
+            return
+        }
+        if out.code == 0 {
+            out.code = http.StatusOK
+        }
+
+        schema, ok := op.Responses[strconv.Itoa(out.code)]
+        if !ok {
+            schema = op.Responses["default"]
+        }
+        if schema != nil && out.body.Len() > 0 && out.Header().Get("Content-Type") != NDJSON_CONTENT_TYPE {
+            if violations := openAPI.Validate(schema, out.body.Bytes()); len(violations) > 0 {
+                _, details := openAPIFields(violations)
+                log.Printf("error: %s response %d does not match the API specification: %s", name, out.code, details)
+                if out.hold {
+                    WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{},
+                                      "Response does not match the API specification: " + details)
+                    return
+                }
+            }
+        }
+        out.release()
+    })
+}
+
+// openAPIResponseWriter holds the response of a GET back until it has been
+// checked. Other responses, and lists a GET streams, go straight through,
+// the former with a copy kept to check once they are sent.
+type openAPIResponseWriter struct {
+    w        http.ResponseWriter
+    header   http.Header
+    code     int
+    body     bytes.Buffer
+    hold     bool
+    streamed bool
+}
+
+func (o *openAPIResponseWriter) Header() http.Header {
+    if o.hold {
+        return o.header
+    }
+    return o.w.Header()
+}
+
+func (o *openAPIResponseWriter) WriteHeader(statusCode int) {
+    if o.code != 0 {
+        return
+    }
+    o.code = statusCode
+    if !o.hold {
+        o.w.WriteHeader(statusCode)
+    }
+}
+
+func (o *openAPIResponseWriter) Write(b []byte) (int, error) {
+    if o.code == 0 {
+        o.WriteHeader(http.StatusOK)
+    }
+    if o.hold {
+        return o.body.Write(b)
+    }
+    if !o.streamed {
+        o.body.Write(b)
+    }
+    return o.w.Write(b)
+}
+
+// Stream lets a streamed list through as it is written, it is not checked
+func (o *openAPIResponseWriter) Stream() {
+    o.release()
+    o.streamed = true
+}
+
+func (o *openAPIResponseWriter) Flush() {
+    if f, ok := o.w.(http.Flusher); ok && !o.hold {
+        f.Flush()
+    }
+}
+
+func (o *openAPIResponseWriter) Unwrap() http.ResponseWriter {
+    return o.w
+}
+
+// release sends what was held back
+func (o *openAPIResponseWriter) release() {
+    if !o.hold {
+        return
+    }
+    o.hold = false
+    for k, v := range o.header {
+        o.w.Header()[k] = v
+    }
+    if o.code != 0 {
+        o.w.WriteHeader(o.code)
+    }
+    if o.body.Len() > 0 {
+        o.w.Write(o.body.Bytes())
+    }
+    o.body.Reset()
+}
+
+func OpenAPIGet(w http.ResponseWriter, r *http.Request) {
+    if openAPI == nil {
+        WriteRequestError(w, http.StatusNotFound, "Object not found", []string{}, "The server has no API specification")
+        return
+    }
+    w.Header().Set("Content-Type", OPENAPI_CONTENT_TYPE)
+    w.WriteHeader(http.StatusOK)
+    w.Write(openAPI.raw)
+}
@@ -5,7 +5,6 @@ import (
     "github.com/go-redis/redis/v7"
     "log"
     "strconv"
-    "strings"
     "swsscommon"
     "time"
     "bytes"
@@ -18,11 +17,9 @@ var ServerResetTime string
 
 var ConfigResetStatus bool
 
-var redisDB *redis.Client
 var swssDB swsscommon.DBConnector
 var swss_conf_DB swsscommon.DBConnector
 var swss_ctr_DB swsscommon.DBConnector
-var trustedCertCommonNames []string
 
 var vnetGuidMap map[string]uint32
 var vniVnetMap map[uint32]string
@@ -33,15 +30,22 @@ var vnetAdvPrefixMap map[string]string
 
 const REDIS_SOCK string = "/var/run/redis/redis.sock"
 
-const APPL_DB int = 0
-const COUNTER_DB int = 2
-const CONFIG_DB int = 4
+// DB numbers of the namespace in use, from its database_config.json
+var APPL_DB int = 0
+var COUNTER_DB int = 2
+var CONFIG_DB int = 4
 
 // Use RESTAPI_DB for cache
-const APPL_CACHE_DB int = 8
+var APPL_CACHE_DB int = 8
 
 const SWSS_TIMEOUT uint = 0
 
+// Number of keys asked from redis per SCAN round trip
+const SCAN_BATCH_SIZE int64 = 100
+
+// Number of hashes read per round trip by GetKVsBulk
+const BULK_READ_BATCH_SIZE int = 1000
+
 // DB Table names
 const VXLAN_TUNNEL_TB       string = "VXLAN_TUNNEL"
 const VNET_TB               string = "VNET"
@@ -74,11 +78,17 @@ var ctr_db_ops db_ops
 
 func Initialise() {
     DBConnect()
-    InitialiseVariables()
+    InitialiseBackend()
+    InitialiseOpenAPI()
+    for _, name := range Namespaces() {
+        useNamespace(namespaces[name])
+        InitialiseVariables()
+    }
+    useNamespace(namespaces[DEFAULT_NAMESPACE])
 }
 
 func InitialiseVariables() {
-    trustedCertCommonNames = strings.Split(*ClientCertCommonNameFlag, ",")
+    LoadTrustedCommonNames()
     var err error
     var resetStatus string
     ServerResetGuid, ServerResetTime, resetStatus, err = CacheGetConfigResetInfo()
@@ -184,37 +194,52 @@ func genVxlanTunnelInfo() {
     log.Printf("info: Existing loopback ips %v", localTunnelLpbkIps)
 }
 
+// DBConnect connects to the Redis instances of every namespace, as
+// database_config.json and database_global.json describe them
 func DBConnect() {
-    if *RunApiAsLocalTestDocker {
-        redisDB = redis.NewClient(&redis.Options{
-            Addr:     "localhost:6379",
-            Password: "",
-        })
-    } else {
-        redisDB = redis.NewClient(&redis.Options{
-            Network:  "unix",
-            Addr:     REDIS_SOCK,
-            Password: "",
-        })
+    configs, err := LoadDatabaseConfigs()
+    if err != nil {
+        log.Fatalf("error: could not load the DB config, error: %v", err)
+    }
+    for name, config := range configs {
+        ns, err := newDBNamespace(name, config)
+        if err != nil {
+            log.Fatalf("error: %v", err)
+        }
+        namespaces[name] = ns
     }
+    useNamespace(namespaces[DEFAULT_NAMESPACE])
+}
 
-    log.Printf("info: Redis connection established (%+v)", redisDB)
+// GetEntry reads key of table through swsscommon. kv is nil when the key does
+// not exist.
+func (db *db_ops) GetEntry(table string, key string) (kv map[string]string, err error) {
+    t := swsscommon.NewTable(db.swss_db, table)
+    defer t.Delete()
 
-    if *RunApiAsLocalTestDocker {
-	     swssDB = swsscommon.NewDBConnector(APPL_DB, "localhost", 6379, SWSS_TIMEOUT)
-	     swss_conf_DB = swsscommon.NewDBConnector(CONFIG_DB, "localhost", 6379, SWSS_TIMEOUT)
-    } else {
-        swssDB = swsscommon.NewDBConnector2(APPL_DB, REDIS_SOCK, SWSS_TIMEOUT)
-        swss_conf_DB = swsscommon.NewDBConnector2(CONFIG_DB, REDIS_SOCK, SWSS_TIMEOUT)
-        swss_ctr_DB = swsscommon.NewDBConnector2(COUNTER_DB, REDIS_SOCK, SWSS_TIMEOUT)
-    }
-    app_db_ops = db_ops{separator: ":", swss_db: swssDB, db_num: APPL_DB}
-    conf_db_ops = db_ops{separator: "|", swss_db: swss_conf_DB, db_num: CONFIG_DB}
-    ctr_db_ops = db_ops{separator: ":", swss_db: swss_ctr_DB, db_num: COUNTER_DB}
+    kv, _, err = t.Get(key)
+    return
+}
+
+// GetField reads one field of key of table through swsscommon
+func (db *db_ops) GetField(table string, key string, field string) (value string, found bool, err error) {
+    t := swsscommon.NewTable(db.swss_db, table)
+    defer t.Delete()
+
+    return t.HGet(key, field)
+}
+
+// GetTableKeys lists the keys of table through swsscommon, without the table
+// name
+func (db *db_ops) GetTableKeys(table string) (keys []string, err error) {
+    t := swsscommon.NewTable(db.swss_db, table)
+    defer t.Delete()
+
+    return t.GetKeys()
 }
 
 func GetKVs(DB int, key string) (kv map[string]string, err error) {
-    pipe := redisDB.TxPipeline()
+    pipe := redisClient(DB).TxPipeline()
     pipe.Select(DB)
     kvRes := pipe.HGetAll(key)
     _, err = pipe.Exec()
@@ -230,15 +255,61 @@ func GetKVs(DB int, key string) (kv map[string]string, err error) {
     return
 }
 
-func GetKVsMulti(DB int, pattern string) (kv map[string]map[string]string, err error) {
-    var cursor uint64
+// GetKVsBulk reads the hashes of keys, BULK_READ_BATCH_SIZE of them per
+// round trip. Keys that do not exist are left out.
+func GetKVsBulk(DB int, keys []string) (kvs map[string]map[string]string, err error) {
+    kvs = make(map[string]map[string]string)
+
+    for start := 0; start < len(keys); start += BULK_READ_BATCH_SIZE {
+        end := start + BULK_READ_BATCH_SIZE
+        if end > len(keys) {
+            end = len(keys)
+        }
+
+        pipe := redisClient(DB).TxPipeline()
+        pipe.Select(DB)
+        cmds := make([]*redis.StringStringMapCmd, 0, end - start)
+        for _, key := range keys[start:end] {
+            cmds = append(cmds, pipe.HGetAll(key))
+        }
+        _, err = pipe.Exec()
+        if err != nil {
+            return nil, err
+        }
+
+        for i, cmd := range cmds {
+            if kv := cmd.Val(); len(kv) > 0 {
+                kvs[keys[start + i]] = kv
+            }
+        }
+    }
 
+    return
+}
+
+func GetKVsMulti(DB int, pattern string) (kv map[string]map[string]string, err error) {
     kv = make(map[string]map[string]string)
 
+    err = ScanKVs(DB, pattern, func(key string, kvp map[string]string) error {
+        kv[key] = kvp
+        return nil
+    })
+
+    return
+}
+
+// ScanKVs walks the keys matching pattern and hands every hash to fn as soon
+// as it is read, so callers never have to hold the whole table in memory.
+// The hashes of a SCAN batch are read in one pipeline, keys deleted in
+// between are skipped. SCAN may report a key more than once if redis
+// rehashes while we iterate.
+func ScanKVs(DB int, pattern string, fn func(key string, kv map[string]string) error) (err error) {
+    var cursor uint64
+
     for {
-        pipe := redisDB.TxPipeline()
+        pipe := redisClient(DB).TxPipeline()
         pipe.Select(DB)
-        ret := pipe.Scan(cursor, pattern, 1)
+        ret := pipe.Scan(cursor, pattern, SCAN_BATCH_SIZE)
 
         _, err = pipe.Exec()
         if err != nil {
@@ -248,8 +319,18 @@ func GetKVsMulti(DB int, pattern string) (kv map[string]map[string]string, err e
         var keys []string
         keys, cursor = ret.Val()
 
+        var kvs map[string]map[string]string
+        kvs, err = GetKVsBulk(DB, keys)
+        if err != nil {
+            return
+        }
         for _, key := range keys {
-            kv[key], err = GetKVs(DB, key)
+            kv, ok := kvs[key]
+            if !ok {
+                // Deleted since SCAN reported it
+                continue
+            }
+            err = fn(key, kv)
             if err != nil {
                 return
             }
@@ -264,6 +345,17 @@ func GetKVsMulti(DB int, pattern string) (kv map[string]map[string]string, err e
 }
 
 func SwssGetVrouterRoutes(vnet_id_str string, vnidMatch int, ipFilter string) (routes []RouteModel, err error) {
+    routes = []RouteModel{}
+    err = SwssScanVrouterRoutes(vnet_id_str, vnidMatch, ipFilter, func(route RouteModel) error {
+        routes = append(routes, route)
+        return nil
+    })
+    return
+}
+
+// SwssScanVrouterRoutes calls fn for every tunnel and local route of the vnet
+// matching ipFilter, in the order they come back from the DB.
+func SwssScanVrouterRoutes(vnet_id_str string, vnidMatch int, ipFilter string, fn func(route RouteModel) error) (err error) {
     db := &app_db_ops
     var pattern string
 
@@ -272,10 +364,15 @@ func SwssGetVrouterRoutes(vnet_id_str string, vnidMatch int, ipFilter string) (r
         rt_tb_name = "_"+ROUTE_TUN_TB
     }
     pattern = generateDBTableKey(db.separator, rt_tb_name, vnet_id_str, ipFilter)
-    routes = []RouteModel{}
 
-    kv1, err1 := GetKVsMulti(db.db_num,pattern)
-    if err1 != nil {
+    err = ScanKVs(db.db_num, pattern, func(k string, kvp map[string]string) error {
+        routeModel := tunnelRouteFromKVs(k, kvp, db.separator)
+        if vnidMatch >= 0 && vnidMatch != routeModel.Vnid {
+            return nil
+        }
+        return fn(routeModel)
+    })
+    if err != nil {
         return
     }
 
@@ -285,94 +382,135 @@ func SwssGetVrouterRoutes(vnet_id_str string, vnidMatch int, ipFilter string) (r
     }
     pattern = generateDBTableKey(db.separator, rt_tb_name, vnet_id_str, ipFilter)
 
-    kv2, err2 := GetKVsMulti(db.db_num,pattern)
-    if err2 != nil {
+    err = ScanKVs(db.db_num, pattern, func(k string, kvp map[string]string) error {
+        return fn(localRouteFromKVs(k, kvp, db.separator))
+    })
+    return
+}
+
+// SwssScanStaticRoutes calls fn for every static route of the vrf, first the
+// non persistent ones in APPL_DB and then the persistent ones in CONFIG_DB.
+func SwssScanStaticRoutes(vrf_id_str string, ipFilter string, fn func(route RouteModel) error) (err error) {
+    app_db := &app_db_ops
+    conf_db := &conf_db_ops
+
+    pattern := generateDBTableKey(app_db.separator, STATIC_ROUTE_TB, vrf_id_str, ipFilter)
+    err = ScanKVs(app_db.db_num, pattern, func(k string, kvp map[string]string) error {
+        return fn(staticRouteFromKVs(k, kvp, app_db.separator))
+    })
+    if err != nil {
         return
-    }        
+    }
 
-    for k, kvp := range kv1 {
-        ipprefix, _ := ExtractIPPrefixFromKey(k, db.separator)
+    pattern = generateDBTableKey(conf_db.separator, STATIC_ROUTE_TB, vrf_id_str, ipFilter)
+    err = ScanKVs(conf_db.db_num, pattern, func(k string, kvp map[string]string) error {
+        routeModel := staticRouteFromKVs(k, kvp, conf_db.separator)
+        routeModel.Persistent = "true"
+        return fn(routeModel)
+    })
+    return
+}
 
-        routeModel := RouteModel{
-            IPPrefix:    ipprefix,
-            NextHop:     kvp["endpoint"],
-        }
+func tunnelRouteFromKVs(k string, kvp map[string]string, separator string) (routeModel RouteModel) {
+    ipprefix, _ := ExtractIPPrefixFromKey(k, separator)
 
-        if vnid, ok := kvp["vni"]; ok {
-            routeModel.Vnid, _ = strconv.Atoi(vnid)
-        }
+    routeModel = RouteModel{
+        IPPrefix:    ipprefix,
+        NextHop:     kvp["endpoint"],
+    }
 
-        if vnidMatch >= 0 {
-            if vnidMatch != routeModel.Vnid {
-                continue
-            }
-        }
+    if vnid, ok := kvp["vni"]; ok {
+        routeModel.Vnid, _ = strconv.Atoi(vnid)
+    }
 
-        if mac, ok := kvp["mac_address"]; ok {
-            routeModel.MACAddress = mac
-        }
+    if mac, ok := kvp["mac_address"]; ok {
+        routeModel.MACAddress = mac
+    }
 
-        if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
-            routeModel.NextHopMonitor = nexthop_monitor
-        }
+    if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
+        routeModel.NextHopMonitor = nexthop_monitor
+    }
 
-        if primary, ok := kvp["primary"]; ok {
-            routeModel.Primary = primary
-        }        
+    if primary, ok := kvp["primary"]; ok {
+        routeModel.Primary = primary
+    }
 
-        if weight, ok := kvp["weight"]; ok {
-            routeModel.Weight = weight
-        }
+    if weight, ok := kvp["weight"]; ok {
+        routeModel.Weight = weight
+    }
 
-        if profile, ok := kvp["profile"]; ok {
-            routeModel.Profile = profile
-        }
+    if profile, ok := kvp["profile"]; ok {
+        routeModel.Profile = profile
+    }
 
-        if adv_prefix, ok := kvp["adv_prefix"]; ok {
-            routeModel.AdvPrefix = adv_prefix
-        }
+    if adv_prefix, ok := kvp["adv_prefix"]; ok {
+        routeModel.AdvPrefix = adv_prefix
+    }
 
-        if monitoring, ok := kvp["monitoring"]; ok {
-            routeModel.Monitoring = monitoring
-        }
+    if monitoring, ok := kvp["monitoring"]; ok {
+        routeModel.Monitoring = monitoring
+    }
+    return
+}
+
+func localRouteFromKVs(k string, kvp map[string]string, separator string) (routeModel RouteModel) {
+    ipprefix, _ := ExtractIPPrefixFromKey(k, separator)
 
-        routes = append(routes, routeModel)
+    routeModel = RouteModel{
+        IPPrefix:    ipprefix,
+        NextHop:     kvp["nexthop"],
     }
 
-    for k, kvp := range kv2 {
-        ipprefix, _ := ExtractIPPrefixFromKey(k, db.separator)
+    if ifname, ok := kvp["ifname"]; ok {
+        routeModel.IfName = ifname
+    }
 
-        routeModel := RouteModel{
-            IPPrefix:    ipprefix,
-            NextHop:     kvp["nexthop"],
-        }
+    if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
+        routeModel.NextHopMonitor = nexthop_monitor
+    }
 
-        if ifname, ok := kvp["ifname"]; ok {
-            routeModel.IfName = ifname
-        }
+    if primary, ok := kvp["primary"]; ok {
+        routeModel.Primary = primary
+    }
 
-        if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
-            routeModel.NextHopMonitor = nexthop_monitor
-        }
+    if weight, ok := kvp["weight"]; ok {
+        routeModel.Weight = weight
+    }
 
-        if primary, ok := kvp["primary"]; ok {
-            routeModel.Primary = primary
-        }        
+    if profile, ok := kvp["profile"]; ok {
+        routeModel.Profile = profile
+    }
+    return
+}
 
-        if weight, ok := kvp["weight"]; ok {
-            routeModel.Weight = weight
-        }
+func staticRouteFromKVs(k string, kvp map[string]string, separator string) (routeModel RouteModel) {
+    ipprefix, _ := ExtractIPPrefixFromKey(k, separator)
 
-        if profile, ok := kvp["profile"]; ok {
-            routeModel.Profile = profile
-        }
-        routes = append(routes, routeModel)
+    routeModel = RouteModel{
+        IPPrefix:    ipprefix,
+        NextHop:     kvp["nexthop"],
+    }
+
+    if ifname, ok := kvp["ifname"]; ok {
+        routeModel.IfName = ifname
+    }
+
+    if nexthop_monitor, ok := kvp["endpoint_monitor"]; ok {
+        routeModel.NextHopMonitor = nexthop_monitor
+    }
+
+    if weight, ok := kvp["weight"]; ok {
+        routeModel.Weight = weight
+    }
+
+    if profile, ok := kvp["profile"]; ok {
+        routeModel.Profile = profile
     }
     return
 }
 
 func CacheGetConfigResetInfo() (GUID string, time string, resetStatus string, err error) {
-    pipe := redisDB.TxPipeline()
+    pipe := redisClient(APPL_CACHE_DB).TxPipeline()
     pipe.Select(APPL_CACHE_DB)
     getCmd_guid := pipe.HGet("RESET_INFO", "GUID")
     getCmd_time := pipe.HGet("RESET_INFO", "time")
@@ -402,7 +540,7 @@ func CacheGetConfigResetInfo() (GUID string, time string, resetStatus string, er
 }
 
 func CacheSetConfigResetInfo(GUID string, time string) error {
-    pipe := redisDB.TxPipeline()
+    pipe := redisClient(APPL_CACHE_DB).TxPipeline()
     pipe.Select(APPL_CACHE_DB)
     setCmd_guid := pipe.HSet("RESET_INFO", "GUID", GUID)
     setCmd_time := pipe.HSet("RESET_INFO", "time", time)
@@ -423,7 +561,7 @@ func CacheSetConfigResetInfo(GUID string, time string) error {
 }
 
 func CacheSetResetStatusInfo(resetStatus bool) error {
-    pipe := redisDB.TxPipeline()
+    pipe := redisClient(APPL_CACHE_DB).TxPipeline()
     pipe.Select(APPL_CACHE_DB)
 
     val := "false"
@@ -444,6 +582,62 @@ func CacheSetResetStatusInfo(resetStatus bool) error {
     return nil
 }
 
+// Idempotency-Key entries live in the cache DB so that they survive a
+// restart of the API server but expire on their own.
+const IDEMPOTENCY_KEY_TB string = "IDEMPOTENCY_KEY"
+
+type IdempotentResponse struct {
+    RequestHash string
+    Code        int
+    ContentType string
+    ETag        string
+    Body        []byte
+}
+
+func CacheGetIdempotentResponse(identity string, key string) (resp *IdempotentResponse, err error) {
+    pipe := redisClient(APPL_CACHE_DB).TxPipeline()
+    pipe.Select(APPL_CACHE_DB)
+    getCmd := pipe.HGetAll(generateDBTableKey(":", IDEMPOTENCY_KEY_TB, identity, key))
+    _, err = pipe.Exec()
+    if err != nil {
+        return
+    }
+
+    kv := getCmd.Val()
+    if len(kv) == 0 {
+        return
+    }
+
+    code, err := strconv.Atoi(kv["code"])
+    if err != nil {
+        return
+    }
+    resp = &IdempotentResponse{
+        RequestHash: kv["request_hash"],
+        Code:        code,
+        ContentType: kv["content_type"],
+        ETag:        kv["etag"],
+        Body:        []byte(kv["body"]),
+    }
+    return
+}
+
+func CacheSetIdempotentResponse(identity string, key string, resp *IdempotentResponse, ttl time.Duration) error {
+    table_key := generateDBTableKey(":", IDEMPOTENCY_KEY_TB, identity, key)
+    pipe := redisClient(APPL_CACHE_DB).TxPipeline()
+    pipe.Select(APPL_CACHE_DB)
+    pipe.HMSet(table_key, map[string]interface{}{
+        "request_hash": resp.RequestHash,
+        "code":         strconv.Itoa(resp.Code),
+        "content_type": resp.ContentType,
+        "etag":         resp.ETag,
+        "body":         string(resp.Body),
+    })
+    pipe.Expire(table_key, ttl)
+    _, err := pipe.Exec()
+    return err
+}
+
 func CacheTunnelLpbkIps(ipAddr string, add bool) {
 
     log.Printf("info: lbkp ip update %s, add: %v", ipAddr, add)
@@ -494,6 +688,12 @@ func CacheDeletePrefixAdv(vnet_id_str string) {
     return
 }
 
+// CachePeekNextVnetGuidId is the id CacheGenAndSetVnetGuidId would hand out
+func CachePeekNextVnetGuidId() (val uint32) {
+    val = nextGuidId
+    return
+}
+
 func CacheGenAndSetVnetGuidId(GUID string, VNI uint32) (val uint32) {
     vnetGuidMap[GUID] = nextGuidId
     vniVnetMap[VNI] = GUID
@@ -0,0 +1,251 @@
+package restapi
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Clients are told apart by the common name their cert matched with, by
+// their uid on the unix socket or, on plain HTTP, by their source IP. Each
+// gets a token bucket per route and they take turns at writeMutex, so that
+// one flooding the API neither uses up the rate of the others nor keeps
+// them waiting behind its requests.
+
+// The rate limits of -ratelimits that no route Name has
+const RATE_LIMIT_DEFAULT string = "*"
+
+// Buckets kept before full ones are dropped
+const RATE_LIMIT_MAX_BUCKETS int = 4096
+
+// RequestIdentity gives who sent a request, false if its client cert is not
+// trusted or its peer not allowed on the unix socket
+func RequestIdentity(r *http.Request) (string, bool) {
+    if r.TLS != nil {
+        name, ok := MatchedCommonName(r)
+        return "cn:" + name, ok
+    }
+    if cred, ok := RequestPeerCredentials(r); ok {
+        if !PeerAllowed(cred) {
+            return "", false
+        }
+        return "uid:" + strconv.FormatUint(uint64(cred.Uid), 10), true
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        host = r.RemoteAddr
+    }
+    return "ip:" + host, true
+}
+
+// RateLimit lets Burst requests through at once and Rate a second after
+type RateLimit struct {
+    Rate  float64
+    Burst float64
+}
+
+// ParseRateLimits reads limits as Name=rate[/burst] separated by commas,
+// rate in requests a second and burst, by default the rate rounded up, in
+// requests. The Name * sets the limit of all routes not listed.
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+    limits := make(map[string]RateLimit)
+    for _, item := range strings.Split(s, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        kv := strings.SplitN(item, "=", 2)
+        if len(kv) != 2 || kv[0] == "" {
+            return nil, fmt.Errorf("rate limit %q is not Name=rate[/burst]", item)
+        }
+        values := strings.SplitN(kv[1], "/", 2)
+        rate, err := strconv.ParseFloat(values[0], 64)
+        if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+            return nil, fmt.Errorf("rate limit %q has no positive rate", item)
+        }
+        limit := RateLimit{Rate: rate, Burst: math.Ceil(rate)}
+        if len(values) == 2 {
+            burst, err := strconv.ParseUint(values[1], 10, 32)
+            if err != nil || burst == 0 {
+                return nil, fmt.Errorf("rate limit %q has no positive burst", item)
+            }
+            limit.Burst = float64(burst)
+        }
+        limits[kv[0]] = limit
+    }
+    return limits, nil
+}
+
+type tokenBucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// RateLimiter keeps a token bucket per identity and route Name
+type RateLimiter struct {
+    limits  map[string]RateLimit
+    mutex   sync.Mutex
+    buckets map[string]*tokenBucket
+    now     func() time.Time
+}
+
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+    return &RateLimiter{
+        limits:  limits,
+        buckets: make(map[string]*tokenBucket),
+        now:     time.Now,
+    }
+}
+
+func (l *RateLimiter) limit(name string) (RateLimit, bool) {
+    if limit, ok := l.limits[name]; ok {
+        return limit, true
+    }
+    limit, ok := l.limits[RATE_LIMIT_DEFAULT]
+    return limit, ok
+}
+
+// Take takes a token from the bucket of identity for route name, if it is
+// empty it tells how long until it has one again
+func (l *RateLimiter) Take(identity string, name string) (time.Duration, bool) {
+    if l == nil {
+        return 0, true
+    }
+    limit, ok := l.limit(name)
+    if !ok {
+        return 0, true
+    }
+
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+    now := l.now()
+    key := identity + " " + name
+    b, ok := l.buckets[key]
+    if !ok {
+        if len(l.buckets) >= RATE_LIMIT_MAX_BUCKETS {
+            l.dropFull(now)
+        }
+        b = &tokenBucket{tokens: limit.Burst, last: now}
+        l.buckets[key] = b
+    }
+    b.tokens = math.Min(limit.Burst, b.tokens + now.Sub(b.last).Seconds() * limit.Rate)
+    b.last = now
+    if b.tokens < 1 {
+        return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+    }
+    b.tokens--
+    return 0, true
+}
+
+// dropFull drops the buckets that have refilled, new ones start full anyway
+func (l *RateLimiter) dropFull(now time.Time) {
+    for key, b := range l.buckets {
+        limit, _ := l.limit(key[strings.LastIndex(key, " ") + 1:])
+        if b.tokens + now.Sub(b.last).Seconds() * limit.Rate >= limit.Burst {
+            delete(l.buckets, key)
+        }
+    }
+}
+
+// The *RateLimiter of -ratelimits, nil without limits, replaced as a whole
+// when the config is reloaded
+var rateLimiter atomic.Value
+
+func currentRateLimiter() *RateLimiter {
+    l, _ := rateLimiter.Load().(*RateLimiter)
+    return l
+}
+
+// InitRateLimits sets up the limits of -ratelimits
+func InitRateLimits() error {
+    limits, err := ParseRateLimits(*RateLimitsFlag)
+    if err != nil {
+        return err
+    }
+    for name := range limits {
+        if name != RATE_LIMIT_DEFAULT && !isRouteName(name) {
+            return errors.New("rate limit for unknown route " + name)
+        }
+    }
+    var l *RateLimiter
+    if len(limits) > 0 {
+        l = NewRateLimiter(limits)
+    }
+    rateLimiter.Store(l)
+    return nil
+}
+
+func isRouteName(name string) bool {
+    for _, route := range routes {
+        if route.Name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// retryAfterSeconds gives a wait in whole seconds for Retry-After, at least
+// one
+func retryAfterSeconds(d time.Duration) string {
+    seconds := int64((d + time.Second - 1) / time.Second)
+    if seconds < 1 {
+        seconds = 1
+    }
+    return strconv.FormatInt(seconds, 10)
+}
+
+// fairMutex is a mutex its waiters get in turns by identity: whenever it is
+// released it goes to the next identity waiting, and to the requests of one
+// identity in the order they came in
+type fairMutex struct {
+    mutex   sync.Mutex
+    locked  bool
+    waiters map[string][]chan struct{}
+    turns   []string
+}
+
+func (m *fairMutex) Lock(identity string) {
+    m.mutex.Lock()
+    if !m.locked {
+        m.locked = true
+        m.mutex.Unlock()
+        return
+    }
+    if m.waiters == nil {
+        m.waiters = make(map[string][]chan struct{})
+    }
+    ready := make(chan struct{})
+    if len(m.waiters[identity]) == 0 {
+        m.turns = append(m.turns, identity)
+    }
+    m.waiters[identity] = append(m.waiters[identity], ready)
+    m.mutex.Unlock()
+    <-ready
+}
+
+// Unlock hands the mutex over to the next waiter, if any
+func (m *fairMutex) Unlock() {
+    m.mutex.Lock()
+    defer m.mutex.Unlock()
+    if len(m.turns) == 0 {
+        m.locked = false
+        return
+    }
+    identity := m.turns[0]
+    m.turns = m.turns[1:]
+    waiters := m.waiters[identity]
+    if len(waiters) > 1 {
+        m.waiters[identity] = waiters[1:]
+        m.turns = append(m.turns, identity)
+    } else {
+        delete(m.waiters, identity)
+    }
+    close(waiters[0])
+}
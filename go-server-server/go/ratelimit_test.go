@@ -0,0 +1,117 @@
+package restapi
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+    limits, err := ParseRateLimits("*=10, ConfigVrouterVrfIdRoutesPatch=0.5/4")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if limits["*"] != (RateLimit{10, 10}) || limits["ConfigVrouterVrfIdRoutesPatch"] != (RateLimit{0.5, 4}) {
+        t.Errorf("got %+v", limits)
+    }
+    if limits, err := ParseRateLimits(""); err != nil || len(limits) != 0 {
+        t.Errorf("got %+v, %v for no limits", limits, err)
+    }
+
+    for _, s := range []string{"10", "=10", "*=", "*=0", "*=-1", "*=x", "*=1/0", "*=1/x"} {
+        if _, err := ParseRateLimits(s); err == nil {
+            t.Errorf("%q: got no error", s)
+        }
+    }
+}
+
+func TestRateLimiterTake(t *testing.T) {
+    now := time.Unix(0, 0)
+    l := NewRateLimiter(map[string]RateLimit{"*": {2, 2}, "HeartbeatGet": {100, 100}})
+    l.now = func() time.Time { return now }
+
+    for i := 0; i < 2; i++ {
+        if _, ok := l.Take("ip:10.0.0.1", "ConfigVrouterVrfIdPost"); !ok {
+            t.Fatalf("request %d over the limit", i)
+        }
+    }
+    wait, ok := l.Take("ip:10.0.0.1", "ConfigVrouterVrfIdPost")
+    if ok || wait != 500 * time.Millisecond {
+        t.Errorf("got %v, %t, want a wait of 500ms", wait, ok)
+    }
+
+    // Other clients and routes have buckets of their own
+    if _, ok := l.Take("ip:10.0.0.2", "ConfigVrouterVrfIdPost"); !ok {
+        t.Errorf("another client is over the limit")
+    }
+    if _, ok := l.Take("ip:10.0.0.1", "HeartbeatGet"); !ok {
+        t.Errorf("another route is over the limit")
+    }
+
+    now = now.Add(500 * time.Millisecond)
+    if _, ok := l.Take("ip:10.0.0.1", "ConfigVrouterVrfIdPost"); !ok {
+        t.Errorf("bucket did not refill")
+    }
+
+    var none *RateLimiter
+    if _, ok := none.Take("ip:10.0.0.1", "ConfigVrouterVrfIdPost"); !ok {
+        t.Errorf("no limits set, got a limit")
+    }
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+    defer rateLimiter.Store(currentRateLimiter())
+    rateLimiter.Store(NewRateLimiter(map[string]RateLimit{"ConfigVrouterVrfIdPost": {1, 1}}))
+
+    h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    }), "ConfigVrouterVrfIdPost")
+    codes := []int{}
+    for i := 0; i < 2; i++ {
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+        codes = append(codes, w.Code)
+        if w.Code == http.StatusTooManyRequests && w.Header().Get(RETRY_AFTER_HEADER) != "1" {
+            t.Errorf("got Retry-After %q, want 1", w.Header().Get(RETRY_AFTER_HEADER))
+        }
+    }
+    if codes[0] != http.StatusNoContent || codes[1] != http.StatusTooManyRequests {
+        t.Errorf("got %v, want 204 then 429", codes)
+    }
+}
+
+func TestFairMutex(t *testing.T) {
+    var m fairMutex
+    m.Lock("a")
+
+    // a queues three requests before b queues one, b gets its turn second
+    order := make(chan string, 4)
+    queue := func(identity string) {
+        queued := make(chan bool)
+        go func() {
+            go func() {
+                // Lock blocks, give it the time to queue
+                time.Sleep(10 * time.Millisecond)
+                queued <- true
+            }()
+            m.Lock(identity)
+            order <- identity
+            m.Unlock()
+        }()
+        <-queued
+    }
+    queue("a")
+    queue("a")
+    queue("a")
+    queue("b")
+    m.Unlock()
+
+    got := ""
+    for i := 0; i < 4; i++ {
+        got += <-order
+    }
+    if got != "abaa" {
+        t.Errorf("got turns %s, want abaa", got)
+    }
+}
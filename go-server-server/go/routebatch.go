@@ -0,0 +1,116 @@
+package restapi
+
+import (
+    "log"
+)
+
+// routeBatch writes the routes of a bulk route PATCH. The tables are
+// buffered and flushed every -routeflushsize writes, so that a large batch
+// takes a few round trips instead of one per route.
+//
+// The current routes are read for the whole batch up front and kept in step
+// with the writes, so an entry sees what the entries before it did even
+// though their writes may not have reached the DB yet.
+type routeBatch struct {
+    tables     []TableWriter
+    flush_size int
+    current    map[string]map[string]string
+
+    // Entries written since the last flush, which fail with it
+    unflushed  []RouteModel
+    last_entry int
+    writes     int
+    failed     []RouteModel
+}
+
+// newRouteBatch reads the routes under keys, the keys of the route tables
+// the entries of the batch may be in
+func newRouteBatch(db *db_ops, keys []string, tables ...TableWriter) (*routeBatch, error) {
+    current, err := GetKVsBulk(db.db_num, keys)
+    if err != nil {
+        return nil, err
+    }
+
+    b := &routeBatch{
+        tables:     tables,
+        flush_size: *RouteFlushSizeFlag,
+        current:    current,
+        last_entry: -1,
+    }
+    if b.flush_size > 0 {
+        for _, t := range tables {
+            t.SetBuffered(true)
+        }
+    }
+    return b, nil
+}
+
+// route gives the current route under the route table key, nil if none
+func (b *routeBatch) route(rt_tb_key string) map[string]string {
+    return b.current[rt_tb_key]
+}
+
+func (b *routeBatch) set(entry int, route RouteModel, pt TableWriter, rt_tb_key string, key string, values map[string]string) error {
+    if err := pt.Set(key, values, "SET", ""); err != nil {
+        return err
+    }
+    b.current[rt_tb_key] = values
+    b.written(entry, route)
+    return nil
+}
+
+func (b *routeBatch) del(entry int, route RouteModel, pt TableWriter, rt_tb_key string, key string) error {
+    if err := pt.Del(key, "DEL", ""); err != nil {
+        return err
+    }
+    delete(b.current, rt_tb_key)
+    b.written(entry, route)
+    return nil
+}
+
+// written counts a write of the entry, flushing once there are enough
+func (b *routeBatch) written(entry int, route RouteModel) {
+    if b.flush_size <= 0 {
+        return
+    }
+    if entry != b.last_entry {
+        b.unflushed = append(b.unflushed, route)
+        b.last_entry = entry
+    }
+    b.writes++
+    if b.writes >= b.flush_size {
+        b.flush()
+    }
+}
+
+// flush sends the buffered writes. If that fails, the entries they came
+// from fail with it.
+func (b *routeBatch) flush() {
+    if b.writes == 0 {
+        return
+    }
+    var err error
+    for _, t := range b.tables {
+        if ferr := t.Flush(); ferr != nil && err == nil {
+            err = ferr
+        }
+    }
+    if err != nil {
+        log.Printf("error: flushing %d route writes failed, error: %v", b.writes, err)
+        code, msg := BackendErrorStatus(err)
+        for _, route := range b.unflushed {
+            route.Error_code = code
+            route.Error_msg = msg
+            b.failed = append(b.failed, route)
+        }
+    }
+    b.unflushed = nil
+    b.last_entry = -1
+    b.writes = 0
+}
+
+// finish flushes what is left and gives the entries that failed to flush
+func (b *routeBatch) finish() []RouteModel {
+    b.flush()
+    return b.failed
+}
@@ -1,11 +1,13 @@
 package restapi
 
 import (
+    "context"
     "net/http"
     "fmt"
     "log"
-    "time"
+    "strings"
     "sync"
+    "time"
     "github.com/gorilla/mux"
 )
 
@@ -18,7 +20,18 @@ type Route struct {
 
 type Routes []Route
 
-var writeMutex sync.Mutex
+var writeMutex fairMutex
+
+type writeLockKey struct{}
+
+// ReleaseWriteLock lets go of writeMutex before the handler of r returns, so
+// that a list read under it is sent to the client without holding up the
+// other requests. The handler must not touch the DB or the namespace after.
+func ReleaseWriteLock(r *http.Request) {
+    if release, ok := r.Context().Value(writeLockKey{}).(func()); ok {
+        release()
+    }
+}
 
 func Middleware(inner http.Handler, name string) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -30,15 +43,35 @@ func Middleware(inner http.Handler, name string) http.Handler {
             name,
         )
 
-        if r.TLS == nil || CommonNameMatch(r) {
-            log.Printf("trace: acquire server write lock")
-            writeMutex.Lock()
+        identity, ok := RequestIdentity(r)
+        if !ok {
+            writeUnauthenticated(NewLoggingResponseWriter(w), r)
+        } else if wait, ok := currentRateLimiter().Take(identity, name); !ok {
+            log.Printf("warning: request: %s over the rate limit of %s", identity, name)
+            w.Header().Set(RETRY_AFTER_HEADER, retryAfterSeconds(wait))
+            WriteRequestError(NewLoggingResponseWriter(w), http.StatusTooManyRequests,
+                        "Too many requests", []string{}, "Rate limit of " + name + " exceeded")
+        } else if unlockedRoutes[name] {
             inner.ServeHTTP(NewLoggingResponseWriter(w), r)
-            writeMutex.Unlock()
-            log.Printf("trace: release server write lock")
         } else {
-            WriteRequestError(NewLoggingResponseWriter(w), http.StatusUnauthorized,
-                        "Authentication Fail with untrusted client cert", []string{}, "")
+            log.Printf("trace: acquire server write lock")
+            writeMutex.Lock(identity)
+            var once sync.Once
+            release := func() {
+                once.Do(func() {
+                    writeMutex.Unlock()
+                    log.Printf("trace: release server write lock")
+                })
+            }
+            // Streaming handlers may abort with a panic, never leave the lock held
+            defer release()
+            r = r.WithContext(context.WithValue(r.Context(), writeLockKey{}, release))
+            if stopping() {
+                // The drain deadline has passed while it waited
+                writeStopping(NewLoggingResponseWriter(w), r)
+            } else {
+                inner.ServeHTTP(NewLoggingResponseWriter(w), r)
+            }
         }
 
         log.Printf(
@@ -48,16 +81,81 @@ func Middleware(inner http.Handler, name string) http.Handler {
     })
 }
 
+func writeUnauthenticated(w http.ResponseWriter, r *http.Request) {
+    message := "Authentication Fail with untrusted client cert"
+    if r.TLS == nil {
+        message = "Authentication Fail with unauthorized peer credentials"
+    }
+    WriteRequestError(w, http.StatusUnauthorized, message, []string{}, "")
+}
+
+// Read-only routes that touch neither the DB nor the namespace, served
+// outside writeMutex so that polling them never holds up config writes
+var unlockedRoutes = map[string]bool{
+    "StateMseeCountersGroupGet": true,
+    "StateMseeStatisticsGroupGet": true,
+    "StateMseeHistogramGet": true,
+}
+
+// Routes that answer dry_run themselves instead of through DryRunMiddleware,
+// or that write nothing for it to hold back
+var dryRunSelfHandled = map[string]bool{
+    "ConfigSnapshotPost": true,
+    "StateArpResolvePost": true,
+}
+
+// routeHandler chains the middlewares of a route, name is that of its v1
+// route and spec operation
+func routeHandler(route Route, name string) http.Handler {
+    var inner http.Handler = route.HandlerFunc
+    if route.Method == "POST" || route.Method == "PATCH" {
+        inner = IdempotencyMiddleware(inner)
+    }
+    if (route.Method == "POST" || route.Method == "PATCH" || route.Method == "DELETE") &&
+        !dryRunSelfHandled[name] {
+        inner = DryRunMiddleware(inner)
+    }
+    if *OpenAPIStrictFlag {
+        inner = OpenAPIMiddleware(inner, name)
+    }
+    if isMutation(route) {
+        inner = MaintenanceMiddleware(inner)
+    }
+    if unlockedRoutes[name] {
+        return Middleware(inner, name)
+    }
+    return Middleware(NamespaceMiddleware(inner), name)
+}
+
 func NewRouter() *mux.Router {
     router := mux.NewRouter().StrictSlash(true)
+    limiter := NewRequestLimiter(*MaxConcurrentRequestsFlag)
     for _, route := range routes {
-        handler := Middleware(route.HandlerFunc, route.Name)
+        router.
+            Methods(route.Method).
+            Path(route.Pattern).
+            Name(route.Name).
+            Handler(LimitMiddleware(routeHandler(route, route.Name), limiter, route.Name, false))
+    }
+
+    for _, route := range v2Routes() {
+        name := route.Name
+        if !strings.HasPrefix(name, "V2") {
+            name = "V2" + name
+        }
+        router.
+            Methods(route.Method).
+            Path(route.Pattern).
+            Name(name).
+            Handler(LimitMiddleware(V2Middleware(routeHandler(route, route.Name), route.Name), limiter, route.Name, true))
+    }
 
+    for _, route := range probeRoutes {
         router.
             Methods(route.Method).
             Path(route.Pattern).
             Name(route.Name).
-            Handler(handler)
+            Handler(ProbeMiddleware(route.HandlerFunc, route.Name))
     }
 
     return router
@@ -75,6 +173,13 @@ var routes = Routes{
         Index,
     },
 
+    Route{
+        "OpenAPIGet",
+        "GET",
+        "/v1/openapi.yaml",
+        OpenAPIGet,
+    },
+
     Route{
         "StateHeartbeatGet",
         "GET",
@@ -292,6 +397,20 @@ var routes = Routes{
         ConfigVrfRouteExpiryPost,
     },
 
+    Route{
+        "ConfigMaintenanceGet",
+        "GET",
+        "/v1/config/maintenance",
+        ConfigMaintenanceGet,
+    },
+
+    Route{
+        "ConfigMaintenancePost",
+        "POST",
+        "/v1/config/maintenance",
+        ConfigMaintenancePost,
+    },
+
     Route{
         "ConfigVrfVrfIdRoutesGet",
         "GET",
@@ -320,6 +439,62 @@ var routes = Routes{
         StateInterfaceGet,
     },
 
+    Route{
+        "ConfigArpInterfacePost",
+        "POST",
+        "/v1/config/arp/interface/{if_name}",
+        ConfigArpInterfacePost,
+    },
+
+    Route{
+        "ConfigArpInterfaceDelete",
+        "DELETE",
+        "/v1/config/arp/interface/{if_name}",
+        ConfigArpInterfaceDelete,
+    },
+
+    Route{
+        "ConfigArpInterfaceIPPost",
+        "POST",
+        "/v1/config/arp/interface/{if_name}/{stag}/{ctag}",
+        ConfigArpInterfaceIPPost,
+    },
+
+    Route{
+        "ConfigArpInterfaceIPDelete",
+        "DELETE",
+        "/v1/config/arp/interface/{if_name}/{stag}/{ctag}",
+        ConfigArpInterfaceIPDelete,
+    },
+
+    Route{
+        "StateArpResolvePost",
+        "POST",
+        "/v1/state/arp/resolve",
+        StateArpResolvePost,
+    },
+
+    Route{
+        "StateMseeCountersGroupGet",
+        "GET",
+        "/v1/state/msee/counters/{group}",
+        StateMseeCountersGroupGet,
+    },
+
+    Route{
+        "StateMseeStatisticsGroupGet",
+        "GET",
+        "/v1/state/msee/statistics/{group}",
+        StateMseeStatisticsGroupGet,
+    },
+
+    Route{
+        "StateMseeHistogramGet",
+        "GET",
+        "/v1/state/msee/histogram",
+        StateMseeHistogramGet,
+    },
+
     Route{
         "ConfigBgpProfilePost",
         "POST",
@@ -341,6 +516,20 @@ var routes = Routes{
         ConfigBgpProfileDelete,
     },
 
+    Route{
+        "ConfigSnapshotGet",
+        "GET",
+        "/v1/config/snapshot",
+        ConfigSnapshotGet,
+    },
+
+    Route{
+        "ConfigSnapshotPost",
+        "POST",
+        "/v1/config/snapshot",
+        ConfigSnapshotPost,
+    },
+
     // Required to run Unit tests
     Route{
         "InMemConfigRestart",
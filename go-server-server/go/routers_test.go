@@ -0,0 +1,175 @@
+package restapi
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "regexp"
+    "sort"
+    "strings"
+    "testing"
+)
+
+// The spec at the top of the repository
+const TEST_OPENAPI_SPEC string = "../../sonic_api.yaml"
+
+var patternParamRe = regexp.MustCompile(`\{([^}]+)\}`)
+
+func loadTestSpec(t *testing.T) *openAPISpec {
+    spec, err := LoadOpenAPISpec(TEST_OPENAPI_SPEC)
+    if err != nil {
+        t.Fatalf("loading the spec: %v", err)
+    }
+    return spec
+}
+
+// v2 routes without a v1 counterpart, the spec only describes /v1
+var v2OnlyRoutes = map[string]bool{
+    "V2Index": true,
+    "V2ErrorCodesGet": true,
+}
+
+// checkRouteOperation compares route with its spec operation, whose path is
+// under prefix instead of /v1
+func checkRouteOperation(t *testing.T, spec *openAPISpec, route Route, prefix string) {
+    op := spec.Operation(route.Name)
+    if op == nil {
+        t.Errorf("route %s %s %s has no spec operation", route.Name, route.Method, route.Pattern)
+        return
+    }
+    if op.Unimplemented {
+        t.Errorf("route %s is served, its spec operation is marked x-unimplemented", route.Name)
+    }
+    path := prefix + strings.TrimPrefix(op.Path, "/v1")
+    if op.Method != route.Method || path != route.Pattern {
+        t.Errorf("route %s is %s %s, the spec has %s %s", route.Name, route.Method, route.Pattern, op.Method, path)
+    }
+
+    var vars []string
+    for _, m := range patternParamRe.FindAllStringSubmatch(route.Pattern, -1) {
+        vars = append(vars, m[1])
+    }
+    params := append([]string{}, op.PathParams...)
+    sort.Strings(vars)
+    sort.Strings(params)
+    if len(vars) != len(params) {
+        t.Errorf("route %s has path parameters %v, the spec has %v", route.Name, vars, params)
+        return
+    }
+    for i := range vars {
+        if vars[i] != params[i] {
+            t.Errorf("route %s has path parameters %v, the spec has %v", route.Name, vars, params)
+            return
+        }
+    }
+}
+
+// Every Route, of /v1 and of /v2, has a spec operation with its Name as
+// operationId, the same method, path and path parameters, and every spec
+// operation has a Route unless it is marked x-unimplemented
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+    spec := loadTestSpec(t)
+
+    served := make(map[string]bool)
+    for _, route := range routes {
+        served[route.Name] = true
+        checkRouteOperation(t, spec, route, "/v1")
+    }
+    for _, route := range v2Routes() {
+        if v2OnlyRoutes[route.Name] {
+            continue
+        }
+        checkRouteOperation(t, spec, route, "/v2")
+    }
+
+    for id, op := range spec.operations {
+        if !served[id] && !op.Unimplemented {
+            t.Errorf("spec operation %s %s %s has no route", id, op.Method, op.Path)
+        }
+    }
+}
+
+func TestOpenAPIValidate(t *testing.T) {
+    spec := loadTestSpec(t)
+
+    tests := []struct {
+        op     string
+        body   string
+        fields []string
+    }{
+        {"ConfigVrouterVrfIdPost", `{"vnid": 2001}`, []string{}},
+        {"ConfigVrouterVrfIdPost", `{"vnid": "2001"}`, []string{"vnid"}},
+        {"ConfigVrouterVrfIdPost", `{}`, []string{"vnid"}},
+        {"ConfigVrouterVrfIdPost", `{"vnid": 1.5}`, []string{"vnid"}},
+        {"ConfigVrouterVrfIdPost", `{"vnid": 4294967296}`, []string{"vnid"}},
+        {"ConfigVrouterVrfIdPost", `[]`, []string{""}},
+        {"ConfigVrouterVrfIdRoutesPatch", `[{"cmd": "add", "ip_prefix": "10.1.1.0/24", "nexthop": "192.168.1.1", "persistent": "true"}]`, []string{}},
+        {"ConfigVrouterVrfIdRoutesPatch", `[{"cmd": "add", "nexthop": "192.168.1.1"}, {"ip_prefix": "10.1.2.0/24", "nexthop": "192.168.1.1", "persistent": true}]`, []string{"[0].ip_prefix", "[1].persistent"}},
+    }
+
+    for _, test := range tests {
+        op := spec.Operation(test.op)
+        if op == nil || op.Body == nil {
+            t.Fatalf("spec operation %s takes no body", test.op)
+        }
+        violations := spec.Validate(op.Body, []byte(test.body))
+        fields := make([]string, len(violations))
+        for i, v := range violations {
+            fields[i] = v.Field
+        }
+        _, details := openAPIFields(violations)
+        if len(fields) != len(test.fields) {
+            t.Errorf("%s %s: got %v (%s), want %v", test.op, test.body, fields, details, test.fields)
+            continue
+        }
+        for i := range fields {
+            if fields[i] != test.fields[i] {
+                t.Errorf("%s %s: got %v (%s), want %v", test.op, test.body, fields, details, test.fields)
+                break
+            }
+        }
+    }
+}
+
+// A GET whose response does not match the spec gets a 500, a mutation the
+// response it had and a streamed list goes through unchecked
+func TestOpenAPIMiddleware(t *testing.T) {
+    defer func(spec *openAPISpec) { openAPI = spec }(openAPI)
+    openAPI = loadTestSpec(t)
+
+    tests := []struct {
+        name   string
+        method string
+        code   int
+        body   string
+        stream bool
+        want   int
+    }{
+        {"ConfigVrouterVrfIdGet", "GET", http.StatusOK, `{"vnet_id": "vnet-guid-1", "attr": {"vnid": 1001}}`, false, http.StatusOK},
+        {"ConfigVrouterVrfIdGet", "GET", http.StatusOK, `{"vnet_id": 1}`, false, http.StatusInternalServerError},
+        {"ConfigVrouterVrfIdRoutesPatch", "PATCH", http.StatusMultiStatus, `{"failed": "all"}`, false, http.StatusMultiStatus},
+        {"ConfigVrouterVrfIdRoutesGet", "GET", http.StatusOK, `{"not": "a list"}`, true, http.StatusOK},
+    }
+    for _, test := range tests {
+        h := OpenAPIMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if test.stream {
+                stream := NewJSONStreamWriter(w, r, test.code)
+                stream.Write(map[string]string{"not": "a list"})
+                stream.Close()
+                return
+            }
+            w.WriteHeader(test.code)
+            w.Write([]byte(test.body))
+        }), test.name)
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, httptest.NewRequest(test.method, "/", strings.NewReader("[]")))
+        if w.Code != test.want {
+            t.Errorf("%s %s: got %d, want %d", test.name, test.body, w.Code, test.want)
+        }
+        if test.want != http.StatusInternalServerError && !test.stream && w.Body.String() != test.body {
+            t.Errorf("%s: got %s, want %s", test.name, w.Body.String(), test.body)
+        }
+        if test.stream && !w.Flushed {
+            t.Errorf("%s: streamed list was held back", test.name)
+        }
+    }
+}
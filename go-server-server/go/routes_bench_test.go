@@ -0,0 +1,103 @@
+package restapi
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "github.com/go-redis/redis/v7"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+)
+
+// BenchmarkRoutesPatch measures routes/s through PATCH
+// /v1/config/vrouter/{vnet_name}/routes. It needs the redis-server of the
+// test docker at localhost:6379 and is skipped without one:
+//
+//   go test -run XXX -bench RoutesPatch ./go
+//
+// flush_size=0 writes every route on its own, as before buffering.
+
+const BENCH_VNET string = "vnet-bench"
+const BENCH_ROUTES_PER_PATCH int = 1000
+
+var benchSetupOnce sync.Once
+var benchRouter http.Handler
+
+func benchSetup(b *testing.B) {
+    client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+    err := client.Ping().Err()
+    client.Close()
+    if err != nil {
+        b.Skipf("no redis-server at localhost:6379: %v", err)
+    }
+
+    benchSetupOnce.Do(func() {
+        log.SetOutput(ioutil.Discard)
+        *RunApiAsLocalTestDocker = true
+        Initialise()
+        benchRouter = NewRouter()
+
+        benchRequest(b, "POST", "/v1/config/tunnel/decap/vxlan", map[string]string{"ip_addr": "34.53.1.0"})
+        benchRequest(b, "POST", "/v1/config/vrouter/" + BENCH_VNET, map[string]int{"vnid": 9001})
+    })
+}
+
+func benchRequest(b *testing.B, method string, path string, body interface{}) int {
+    data, err := json.Marshal(body)
+    if err != nil {
+        b.Fatal(err)
+    }
+    req := httptest.NewRequest(method, path, bytes.NewReader(data))
+    rec := httptest.NewRecorder()
+    benchRouter.ServeHTTP(rec, req)
+    return rec.Code
+}
+
+func benchRoutes(cmd string) []RouteModel {
+    routes := make([]RouteModel, BENCH_ROUTES_PER_PATCH)
+    for i := range routes {
+        routes[i] = RouteModel{
+            Cmd:      cmd,
+            IPPrefix: fmt.Sprintf("10.%d.%d.0/24", i / 256, i % 256),
+            NextHop:  "192.168.1.1",
+            Vnid:     9001,
+        }
+    }
+    return routes
+}
+
+func BenchmarkRoutesPatch(b *testing.B) {
+    benchSetup(b)
+    add := benchRoutes("add")
+    del := benchRoutes("delete")
+
+    for _, flush_size := range []int{0, 64, 512} {
+        b.Run(fmt.Sprintf("flush_size=%d", flush_size), func(b *testing.B) {
+            *RouteFlushSizeFlag = flush_size
+            path := "/v1/config/vrouter/" + BENCH_VNET + "/routes"
+
+            var elapsed time.Duration
+            b.ResetTimer()
+            for n := 0; n < b.N; n++ {
+                start := time.Now()
+                if code := benchRequest(b, "PATCH", path, add); code != http.StatusNoContent {
+                    b.Fatalf("adding routes answered %d", code)
+                }
+                elapsed += time.Since(start)
+
+                // Deleting is not measured, it only makes room for the next round
+                b.StopTimer()
+                if code := benchRequest(b, "PATCH", path, del); code != http.StatusNoContent {
+                    b.Fatalf("deleting routes answered %d", code)
+                }
+                b.StartTimer()
+            }
+            b.ReportMetric(float64(b.N * BENCH_ROUTES_PER_PATCH) / elapsed.Seconds(), "routes/s")
+        })
+    }
+}
@@ -0,0 +1,42 @@
+package restapi
+
+import (
+    "context"
+    "log"
+    "net"
+    "net/http"
+)
+
+// The listeners drain on shutdown: they stop accepting connections and wait
+// for the requests they serve. Once the deadline for that has passed, the
+// requests still waiting for their turn at writeMutex are refused and those
+// running see their context cancelled. A mutation running is never cut
+// short, it would leave half of its writes behind, so the server only exits
+// once writeMutex is free.
+
+var serverContext, cancelServerContext = context.WithCancel(context.Background())
+
+// StopServing refuses the requests still waiting for their turn and cancels
+// the context of those running
+func StopServing() {
+    cancelServerContext()
+}
+
+func stopping() bool {
+    return serverContext.Err() != nil
+}
+
+// WaitForWrites returns once no request is running, none starts after
+func WaitForWrites() {
+    log.Printf("info: waiting for the request in flight")
+    writeMutex.Lock("shutdown")
+}
+
+func serverBaseContext(net.Listener) context.Context {
+    return serverContext
+}
+
+func writeStopping(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set(RETRY_AFTER_HEADER, retryAfterSeconds(*RetryAfterFlag))
+    writeServiceError(w, IsV2(r), SERVER_BUSY, "Server shutting down", "")
+}
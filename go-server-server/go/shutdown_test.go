@@ -0,0 +1,64 @@
+package restapi
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestShutdownWaitsForWrites(t *testing.T) {
+    defer func(ctx context.Context, cancel context.CancelFunc) {
+        serverContext, cancelServerContext = ctx, cancel
+    }(serverContext, cancelServerContext)
+    serverContext, cancelServerContext = context.WithCancel(context.Background())
+
+    started := make(chan bool)
+    release := make(chan bool)
+    h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("X-Block") != "" {
+            started <- true
+            <-release
+        }
+        w.WriteHeader(http.StatusNoContent)
+    }), "ConfigVrouterVrfIdPost")
+
+    inFlight := httptest.NewRecorder()
+    done := make(chan bool)
+    go func() {
+        r := httptest.NewRequest("POST", "/", nil)
+        r.Header.Set("X-Block", "1")
+        h.ServeHTTP(inFlight, r)
+        done <- true
+    }()
+    <-started
+
+    // A request queued behind the one in flight when the server stops
+    queued := httptest.NewRecorder()
+    go func() {
+        h.ServeHTTP(queued, httptest.NewRequest("POST", "/", nil))
+        done <- true
+    }()
+    // Lock blocks, give it the time to queue
+    time.Sleep(10 * time.Millisecond)
+
+    StopServing()
+    stopped := make(chan bool)
+    go func() {
+        WaitForWrites()
+        stopped <- true
+    }()
+    close(release)
+    <-done
+    <-done
+    <-stopped
+    defer writeMutex.Unlock()
+
+    if inFlight.Code != http.StatusNoContent {
+        t.Errorf("got %d for the request in flight, want 204", inFlight.Code)
+    }
+    if queued.Code != http.StatusServiceUnavailable || queued.Header().Get(RETRY_AFTER_HEADER) == "" {
+        t.Errorf("got %d with Retry-After %q for the queued request, want 503", queued.Code, queued.Header().Get(RETRY_AFTER_HEADER))
+    }
+}
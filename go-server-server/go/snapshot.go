@@ -0,0 +1,666 @@
+package restapi
+
+import (
+    "bytes"
+    "encoding/json"
+    "net"
+    "net/http"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+    "github.com/gorilla/mux"
+)
+
+// Bump whenever the layout of SnapshotModel changes incompatibly
+const SNAPSHOT_VERSION int = 1
+
+const SNAPSHOT_MODE_MERGE string = "merge"
+const SNAPSHOT_MODE_REPLACE string = "replace"
+
+// snapshotOp is one API call of a restore, run in-process against the
+// handler that would serve it so that the snapshot gets the exact same
+// validation as the equivalent REST calls.
+type snapshotOp struct {
+    SnapshotOpModel
+    vars    map[string]string
+    handler http.HandlerFunc
+}
+
+func newSnapshotOp(method string, path string, handler http.HandlerFunc, vars map[string]string, body interface{}) snapshotOp {
+    return snapshotOp{
+        SnapshotOpModel: SnapshotOpModel{
+            Method: method,
+            Path:   path,
+            Body:   body,
+        },
+        vars:    vars,
+        handler: handler,
+    }
+}
+
+func vnetIdFromKey(key string, separator string) uint64 {
+    id, _ := strconv.ParseUint(key[len(generateDBTableKey(separator, VNET_TB, VNET_NAME_PREF)):], 10, 32)
+    return id
+}
+
+// SnapshotGet reads everything the API manages into a snapshot document.
+// Lists are sorted so that two snapshots of the same state are identical,
+// and VNETs come in the order of their internal ids so that restoring into
+// an empty system hands out the same ids again.
+func SnapshotGet() (snap SnapshotModel, err error) {
+    conf_db := &conf_db_ops
+    app_db := &app_db_ops
+
+    snap = SnapshotModel{
+        Version:       SNAPSHOT_VERSION,
+        ServerVersion: ServerAPIVersion,
+        Tunnels:       []TunnelDecapReturnModel{},
+        Vnets:         []VnetReturnModel{},
+        BgpProfiles:   []BgpProfileReturnModel{},
+        Vlans:         []VlansModel{},
+        VlanMembers:   []VlanMemberReturnModel{},
+        VlanNeighbors: []VlanNeighborReturnModel{},
+        VnetRoutes:    []SnapshotVnetRoutesModel{},
+        StaticRoutes:  []SnapshotVrfRoutesModel{},
+    }
+
+    /* Tunnels */
+    for _, tunnel_name := range []string{"default_vxlan_tunnel_v4", "default_vxlan_tunnel"} {
+        var kv map[string]string
+        kv, err = GetKVs(conf_db.db_num, generateDBTableKey(conf_db.separator, VXLAN_TUNNEL_TB, tunnel_name))
+        if err != nil {
+            return
+        }
+        if kv != nil {
+            snap.Tunnels = append(snap.Tunnels, TunnelDecapReturnModel{
+                TunnelType: "vxlan",
+                Attr:       TunnelDecapModel{IPAddr: kv["src_ip"]},
+            })
+        }
+    }
+
+    /* VNETs */
+    vnet_kv, err := GetKVsMulti(conf_db.db_num, generateDBTableKey(conf_db.separator, VNET_TB, "*"))
+    if err != nil {
+        return
+    }
+    vnet_keys := make([]string, 0, len(vnet_kv))
+    for k := range vnet_kv {
+        vnet_keys = append(vnet_keys, k)
+    }
+    sort.Slice(vnet_keys, func(i, j int) bool {
+        return vnetIdFromKey(vnet_keys[i], conf_db.separator) < vnetIdFromKey(vnet_keys[j], conf_db.separator)
+    })
+    // vnet_id_str -> guid
+    vnet_guids := make(map[string]string)
+    for _, k := range vnet_keys {
+        kv := vnet_kv[k]
+        vnet_id_str := k[len(VNET_TB)+1:]
+        vnet_guids[vnet_id_str] = kv["guid"]
+        vnid, _ := strconv.Atoi(kv["vni"])
+        snap.Vnets = append(snap.Vnets, VnetReturnModel{
+            VnetName: kv["guid"],
+            Attr: VnetModel{
+                Vnid:        vnid,
+                AdvPrefix:   kv["advertise_prefix"],
+                OverlayDmac: kv["overlay_dmac"],
+            },
+        })
+    }
+
+    /* BGP profiles */
+    bgp_kv, err := GetKVsMulti(app_db.db_num, generateDBTableKey(app_db.separator, BGP_PROFILE_TABLE, "*"))
+    if err != nil {
+        return
+    }
+    for k, kv := range bgp_kv {
+        snap.BgpProfiles = append(snap.BgpProfiles, BgpProfileReturnModel{
+            ProfileName: k[len(BGP_PROFILE_TABLE)+1:],
+            Attr:        BgpProfileModel{CommunityId: kv["community_id"]},
+        })
+    }
+    sort.Slice(snap.BgpProfiles, func(i, j int) bool {
+        return snap.BgpProfiles[i].ProfileName < snap.BgpProfiles[j].ProfileName
+    })
+
+    /* VLANs, the local subnet routes they own are left out of the vnet routes */
+    vlan_kv, err := GetKVsMulti(conf_db.db_num, generateDBTableKey(conf_db.separator, VLAN_TB, "*"))
+    if err != nil {
+        return
+    }
+    subnet_routes := make(map[string]bool)
+    for _, v := range vlan_kv {
+        vlan_name := VLAN_NAME_PREF + v["vlanid"]
+        vlanInt, _ := strconv.Atoi(v["vlanid"])
+        vlan := VlansModel{VlanID: vlanInt}
+
+        var vlan_if_kv map[string]string
+        vlan_if_kv, err = GetKVs(conf_db.db_num, generateDBTableKey(conf_db.separator, VLAN_INTF_TB, vlan_name))
+        if err != nil {
+            return
+        }
+        var vlan_pref_kv map[string]map[string]string
+        vlan_pref_kv, err = GetKVsMulti(conf_db.db_num, generateDBTableKey(conf_db.separator, VLAN_INTF_TB, vlan_name, "*"))
+        if err != nil {
+            return
+        }
+        for k := range vlan_pref_kv {
+            vlan.IPPrefix = k[len(generateDBTableKey(conf_db.separator, VLAN_INTF_TB, vlan_name))+1:]
+        }
+        if vlan_if_kv != nil {
+            vlan.Vnet_id = vnet_guids[vlan_if_kv["vnet_name"]]
+            if _, vlan_netw, err_p := net.ParseCIDR(vlan.IPPrefix); err_p == nil {
+                subnet_routes[vlan_if_kv["vnet_name"] + "|" + vlan_netw.String() + "|" + vlan_name] = true
+            }
+        }
+        snap.Vlans = append(snap.Vlans, vlan)
+    }
+    sort.Slice(snap.Vlans, func(i, j int) bool { return snap.Vlans[i].VlanID < snap.Vlans[j].VlanID })
+
+    /* VLAN members */
+    member_kv, err := GetKVsMulti(conf_db.db_num, generateDBTableKey(conf_db.separator, VLAN_MEMB_TB, "*"))
+    if err != nil {
+        return
+    }
+    for k, kv := range member_kv {
+        parts := strings.SplitN(k, conf_db.separator, 3)
+        if len(parts) != 3 {
+            continue
+        }
+        vlanInt, _ := strconv.Atoi(strings.TrimPrefix(parts[1], VLAN_NAME_PREF))
+        snap.VlanMembers = append(snap.VlanMembers, VlanMemberReturnModel{
+            VlanID:  vlanInt,
+            If_name: parts[2],
+            Attr:    VlanMemberModel{Tagging: kv["tagging_mode"]},
+        })
+    }
+    sort.Slice(snap.VlanMembers, func(i, j int) bool {
+        if snap.VlanMembers[i].VlanID != snap.VlanMembers[j].VlanID {
+            return snap.VlanMembers[i].VlanID < snap.VlanMembers[j].VlanID
+        }
+        return snap.VlanMembers[i].If_name < snap.VlanMembers[j].If_name
+    })
+
+    /* VLAN neighbors */
+    neigh_kv, err := GetKVsMulti(conf_db.db_num, generateDBTableKey(conf_db.separator, VLAN_NEIGH_TB, "*"))
+    if err != nil {
+        return
+    }
+    for k := range neigh_kv {
+        parts := strings.SplitN(k, conf_db.separator, 3)
+        if len(parts) != 3 {
+            continue
+        }
+        vlanInt, _ := strconv.Atoi(strings.TrimPrefix(parts[1], VLAN_NAME_PREF))
+        snap.VlanNeighbors = append(snap.VlanNeighbors, VlanNeighborReturnModel{
+            VlanID:  vlanInt,
+            Ip_addr: parts[2],
+        })
+    }
+    sort.Slice(snap.VlanNeighbors, func(i, j int) bool {
+        if snap.VlanNeighbors[i].VlanID != snap.VlanNeighbors[j].VlanID {
+            return snap.VlanNeighbors[i].VlanID < snap.VlanNeighbors[j].VlanID
+        }
+        return snap.VlanNeighbors[i].Ip_addr < snap.VlanNeighbors[j].Ip_addr
+    })
+
+    /* VNET routes */
+    for _, k := range vnet_keys {
+        vnet_id_str := k[len(VNET_TB)+1:]
+        routes := []RouteModel{}
+        err = SwssScanVrouterRoutes(vnet_id_str, -1, "*", func(route RouteModel) error {
+            if subnet_routes[vnet_id_str + "|" + route.IPPrefix + "|" + route.IfName] {
+                return nil
+            }
+            route.Cmd = "add"
+            routes = append(routes, route)
+            return nil
+        })
+        if err != nil {
+            return
+        }
+        if len(routes) == 0 {
+            continue
+        }
+        sortRoutes(routes)
+        snap.VnetRoutes = append(snap.VnetRoutes, SnapshotVnetRoutesModel{
+            VnetName: vnet_guids[vnet_id_str],
+            Routes:   routes,
+        })
+    }
+
+    /* Static routes, persistent ones live in CONFIG_DB */
+    vrf_routes := make(map[string][]RouteModel)
+    for _, db := range []*db_ops{app_db, conf_db} {
+        prefix := generateDBTableKey(db.separator, STATIC_ROUTE_TB, "")
+        err = ScanKVs(db.db_num, prefix + "*", func(k string, kvp map[string]string) error {
+            vrf_id_str := strings.SplitN(k[len(prefix):], db.separator, 2)[0]
+            route := staticRouteFromKVs(k, kvp, db.separator)
+            route.Cmd = "add"
+            if db == conf_db {
+                route.Persistent = "true"
+            }
+            vrf_routes[vrf_id_str] = append(vrf_routes[vrf_id_str], route)
+            return nil
+        })
+        if err != nil {
+            return
+        }
+    }
+    for vrf_id_str, routes := range vrf_routes {
+        sortRoutes(routes)
+        snap.StaticRoutes = append(snap.StaticRoutes, SnapshotVrfRoutesModel{
+            VrfID:  vrf_id_str,
+            Routes: routes,
+        })
+    }
+    sort.Slice(snap.StaticRoutes, func(i, j int) bool { return snap.StaticRoutes[i].VrfID < snap.StaticRoutes[j].VrfID })
+
+    /* Route expiry */
+    expiry_kv, err := GetKVs(app_db.db_num, generateDBTableKey(app_db.separator, STATIC_ROUTE_EXP_TB))
+    if err != nil {
+        return
+    }
+    if len(expiry_kv) != 0 {
+        time, _ := strconv.Atoi(expiry_kv["time"])
+        snap.RouteExpiry = &RouteExpiryTimeModel{Time: time}
+    }
+
+    return
+}
+
+func sortRoutes(routes []RouteModel) {
+    sort.Slice(routes, func(i, j int) bool {
+        if routes[i].IPPrefix != routes[j].IPPrefix {
+            return routes[i].IPPrefix < routes[j].IPPrefix
+        }
+        return routes[i].IfName < routes[j].IfName
+    })
+}
+
+// A tunnel route and a local route may share a prefix, they live in
+// different tables. Static routes likewise in APPL_DB and CONFIG_DB.
+func vnetRouteKey(route RouteModel) string {
+    return route.IPPrefix + "|" + strconv.FormatBool(route.IfName != "")
+}
+
+func staticRouteKey(route RouteModel) string {
+    return route.IPPrefix + "|" + strconv.FormatBool(route.Persistent == "true")
+}
+
+func routesEqual(a RouteModel, b RouteModel) bool {
+    a.Cmd, b.Cmd = "", ""
+    a.Error_code, b.Error_code = 0, 0
+    a.Error_msg, b.Error_msg = "", ""
+    return reflect.DeepEqual(a, b)
+}
+
+// diffRoutes returns the routes of want missing or different in cur, and the
+// routes of cur missing from want turned into deletes.
+func diffRoutes(cur []RouteModel, want []RouteModel, keyFn func(RouteModel) string) (adds []RouteModel, dels []RouteModel) {
+    cur_map := make(map[string]RouteModel)
+    for _, route := range cur {
+        cur_map[keyFn(route)] = route
+    }
+    want_map := make(map[string]bool)
+    for _, route := range want {
+        want_map[keyFn(route)] = true
+        if c, ok := cur_map[keyFn(route)]; !ok || !routesEqual(c, route) {
+            route.Cmd = "add"
+            adds = append(adds, route)
+        }
+    }
+    for _, route := range cur {
+        if !want_map[keyFn(route)] {
+            route.Cmd = "delete"
+            dels = append(dels, route)
+        }
+    }
+    return
+}
+
+// SnapshotPlan lists the API calls that take the current state cur to want,
+// deletes first in reverse dependency order, then creates in dependency
+// order. In merge mode nothing is deleted. An object that exists in both
+// with different attributes is recreated in replace mode, while in merge
+// mode it is posted as is and its handler reports the conflict.
+// Decap tunnels cannot be deleted through the API and are never removed.
+func SnapshotPlan(cur SnapshotModel, want SnapshotModel, mode string) (ops []snapshotOp) {
+    replace := mode == SNAPSHOT_MODE_REPLACE
+
+    /* What is in want */
+    want_vnets := make(map[string]VnetReturnModel)
+    for _, v := range want.Vnets {
+        want_vnets[v.VnetName] = v
+    }
+    want_bgp := make(map[string]BgpProfileReturnModel)
+    for _, v := range want.BgpProfiles {
+        want_bgp[v.ProfileName] = v
+    }
+    want_vlans := make(map[int]VlansModel)
+    for _, v := range want.Vlans {
+        want_vlans[v.VlanID] = v
+    }
+    want_members := make(map[string]VlanMemberReturnModel)
+    for _, v := range want.VlanMembers {
+        want_members[strconv.Itoa(v.VlanID) + "|" + v.If_name] = v
+    }
+    want_neighs := make(map[string]bool)
+    for _, v := range want.VlanNeighbors {
+        want_neighs[strconv.Itoa(v.VlanID) + "|" + v.Ip_addr] = true
+    }
+    want_vnet_routes := make(map[string][]RouteModel)
+    for _, v := range want.VnetRoutes {
+        want_vnet_routes[v.VnetName] = append(want_vnet_routes[v.VnetName], v.Routes...)
+    }
+    want_static_routes := make(map[string][]RouteModel)
+    for _, v := range want.StaticRoutes {
+        want_static_routes[v.VrfID] = append(want_static_routes[v.VrfID], v.Routes...)
+    }
+
+    /* What is in cur */
+    cur_tunnels := make(map[int]TunnelDecapReturnModel)
+    for _, v := range cur.Tunnels {
+        cur_tunnels[isV4orV6(v.Attr.IPAddr)] = v
+    }
+    cur_vnets := make(map[string]VnetReturnModel)
+    for _, v := range cur.Vnets {
+        cur_vnets[v.VnetName] = v
+    }
+    cur_bgp := make(map[string]BgpProfileReturnModel)
+    for _, v := range cur.BgpProfiles {
+        cur_bgp[v.ProfileName] = v
+    }
+    cur_vlans := make(map[int]VlansModel)
+    for _, v := range cur.Vlans {
+        cur_vlans[v.VlanID] = v
+    }
+    cur_members := make(map[string]VlanMemberReturnModel)
+    for _, v := range cur.VlanMembers {
+        cur_members[strconv.Itoa(v.VlanID) + "|" + v.If_name] = v
+    }
+    cur_neighs := make(map[string]bool)
+    for _, v := range cur.VlanNeighbors {
+        cur_neighs[strconv.Itoa(v.VlanID) + "|" + v.Ip_addr] = true
+    }
+    cur_vnet_routes := make(map[string][]RouteModel)
+    for _, v := range cur.VnetRoutes {
+        cur_vnet_routes[v.VnetName] = append(cur_vnet_routes[v.VnetName], v.Routes...)
+    }
+    cur_static_routes := make(map[string][]RouteModel)
+    for _, v := range cur.StaticRoutes {
+        cur_static_routes[v.VrfID] = append(cur_static_routes[v.VrfID], v.Routes...)
+    }
+
+    /* Objects to recreate, only in replace mode */
+    stale_vnets := make(map[string]bool)
+    stale_vlans := make(map[int]bool)
+    stale_members := make(map[string]bool)
+    if replace {
+        for name, v := range cur_vnets {
+            if w, ok := want_vnets[name]; !ok || !reflect.DeepEqual(w, v) {
+                stale_vnets[name] = true
+            }
+        }
+        for id, v := range cur_vlans {
+            if w, ok := want_vlans[id]; !ok || !reflect.DeepEqual(w, v) || stale_vnets[v.Vnet_id] {
+                stale_vlans[id] = true
+            }
+        }
+        for k, v := range cur_members {
+            if w, ok := want_members[k]; !ok || !reflect.DeepEqual(w, v) || stale_vlans[v.VlanID] {
+                stale_members[k] = true
+            }
+        }
+    }
+
+    vrouterRoutesPath := func(vnet_name string) string { return "/v1/config/vrouter/" + vnet_name + "/routes" }
+    vrfRoutesPath := func(vrf_id string) string { return "/v1/config/vrf/" + vrf_id + "/routes" }
+    vlanPath := func(vlan_id int) string { return "/v1/config/interface/vlan/" + strconv.Itoa(vlan_id) }
+
+    /* Deletes */
+    if replace {
+        for _, s := range cur.StaticRoutes {
+            _, dels := diffRoutes(s.Routes, want_static_routes[s.VrfID], staticRouteKey)
+            if len(dels) > 0 {
+                ops = append(ops, newSnapshotOp("PATCH", vrfRoutesPath(s.VrfID), ConfigVrfVrfIdRoutesPatch,
+                    map[string]string{"vrf_id": s.VrfID}, dels))
+            }
+        }
+        for _, s := range cur.VnetRoutes {
+            want_routes := want_vnet_routes[s.VnetName]
+            if stale_vnets[s.VnetName] {
+                want_routes = nil
+            }
+            _, dels := diffRoutes(s.Routes, want_routes, vnetRouteKey)
+            if len(dels) > 0 {
+                ops = append(ops, newSnapshotOp("PATCH", vrouterRoutesPath(s.VnetName), ConfigVrouterVrfIdRoutesPatch,
+                    map[string]string{"vnet_name": s.VnetName}, dels))
+            }
+        }
+        for _, v := range cur.VlanNeighbors {
+            if !want_neighs[strconv.Itoa(v.VlanID) + "|" + v.Ip_addr] || stale_vlans[v.VlanID] {
+                ops = append(ops, newSnapshotOp("DELETE", vlanPath(v.VlanID) + "/neighbor/" + v.Ip_addr, ConfigInterfaceVlanNeighborDelete,
+                    map[string]string{"vlan_id": strconv.Itoa(v.VlanID), "ip_addr": v.Ip_addr}, nil))
+            }
+        }
+        for _, v := range cur.VlanMembers {
+            if stale_members[strconv.Itoa(v.VlanID) + "|" + v.If_name] {
+                ops = append(ops, newSnapshotOp("DELETE", vlanPath(v.VlanID) + "/member/" + v.If_name, ConfigInterfaceVlanMemberDelete,
+                    map[string]string{"vlan_id": strconv.Itoa(v.VlanID), "if_name": v.If_name}, nil))
+            }
+        }
+        for _, v := range cur.Vlans {
+            if stale_vlans[v.VlanID] {
+                ops = append(ops, newSnapshotOp("DELETE", vlanPath(v.VlanID), ConfigInterfaceVlanDelete,
+                    map[string]string{"vlan_id": strconv.Itoa(v.VlanID)}, nil))
+            }
+        }
+        for _, v := range cur.BgpProfiles {
+            if _, ok := want_bgp[v.ProfileName]; !ok {
+                ops = append(ops, newSnapshotOp("DELETE", "/v1/config/bgp/profile/" + v.ProfileName, ConfigBgpProfileDelete,
+                    map[string]string{"profile_name": v.ProfileName}, nil))
+            }
+        }
+        for i := len(cur.Vnets) - 1; i >= 0; i-- {
+            v := cur.Vnets[i]
+            if stale_vnets[v.VnetName] {
+                ops = append(ops, newSnapshotOp("DELETE", "/v1/config/vrouter/" + v.VnetName, ConfigVrouterVrfIdDelete,
+                    map[string]string{"vnet_name": v.VnetName}, nil))
+            }
+        }
+    }
+
+    /* Creates */
+    for _, v := range want.Tunnels {
+        if c, ok := cur_tunnels[isV4orV6(v.Attr.IPAddr)]; !ok || c.Attr.IPAddr != v.Attr.IPAddr {
+            ops = append(ops, newSnapshotOp("POST", "/v1/config/tunnel/decap/" + v.TunnelType, ConfigTunnelDecapTunnelTypePost,
+                map[string]string{"tunnel_type": v.TunnelType}, v.Attr))
+        }
+    }
+    for _, v := range want.Vnets {
+        if c, ok := cur_vnets[v.VnetName]; !ok || stale_vnets[v.VnetName] || !reflect.DeepEqual(c, v) {
+            ops = append(ops, newSnapshotOp("POST", "/v1/config/vrouter/" + v.VnetName, ConfigVrouterVrfIdPost,
+                map[string]string{"vnet_name": v.VnetName}, v.Attr))
+        }
+    }
+    for _, v := range want.BgpProfiles {
+        if c, ok := cur_bgp[v.ProfileName]; !ok || !reflect.DeepEqual(c, v) {
+            ops = append(ops, newSnapshotOp("POST", "/v1/config/bgp/profile/" + v.ProfileName, ConfigBgpProfilePost,
+                map[string]string{"profile_name": v.ProfileName}, v.Attr))
+        }
+    }
+    for _, v := range want.Vlans {
+        if c, ok := cur_vlans[v.VlanID]; !ok || stale_vlans[v.VlanID] || !reflect.DeepEqual(c, v) {
+            ops = append(ops, newSnapshotOp("POST", vlanPath(v.VlanID), ConfigInterfaceVlanPost,
+                map[string]string{"vlan_id": strconv.Itoa(v.VlanID)},
+                VlanModel{Vnet_id: v.Vnet_id, IPPrefix: v.IPPrefix}))
+        }
+    }
+    for _, v := range want.VlanMembers {
+        k := strconv.Itoa(v.VlanID) + "|" + v.If_name
+        if c, ok := cur_members[k]; !ok || stale_members[k] || !reflect.DeepEqual(c, v) {
+            ops = append(ops, newSnapshotOp("POST", vlanPath(v.VlanID) + "/member/" + v.If_name, ConfigInterfaceVlanMemberPost,
+                map[string]string{"vlan_id": strconv.Itoa(v.VlanID), "if_name": v.If_name}, v.Attr))
+        }
+    }
+    for _, v := range want.VlanNeighbors {
+        if !cur_neighs[strconv.Itoa(v.VlanID) + "|" + v.Ip_addr] || stale_vlans[v.VlanID] {
+            ops = append(ops, newSnapshotOp("POST", vlanPath(v.VlanID) + "/neighbor/" + v.Ip_addr, ConfigInterfaceVlanNeighborPost,
+                map[string]string{"vlan_id": strconv.Itoa(v.VlanID), "ip_addr": v.Ip_addr}, nil))
+        }
+    }
+    for _, s := range want.VnetRoutes {
+        cur_routes := cur_vnet_routes[s.VnetName]
+        if stale_vnets[s.VnetName] {
+            cur_routes = nil
+        }
+        adds, _ := diffRoutes(cur_routes, s.Routes, vnetRouteKey)
+        if len(adds) > 0 {
+            ops = append(ops, newSnapshotOp("PATCH", vrouterRoutesPath(s.VnetName), ConfigVrouterVrfIdRoutesPatch,
+                map[string]string{"vnet_name": s.VnetName}, adds))
+        }
+    }
+    for _, s := range want.StaticRoutes {
+        adds, _ := diffRoutes(cur_static_routes[s.VrfID], s.Routes, staticRouteKey)
+        if len(adds) > 0 {
+            ops = append(ops, newSnapshotOp("PATCH", vrfRoutesPath(s.VrfID), ConfigVrfVrfIdRoutesPatch,
+                map[string]string{"vrf_id": s.VrfID}, adds))
+        }
+    }
+    if want.RouteExpiry != nil && !reflect.DeepEqual(cur.RouteExpiry, want.RouteExpiry) {
+        ops = append(ops, newSnapshotOp("POST", "/v1/config/vrf/route_expiry", ConfigVrfRouteExpiryPost,
+            map[string]string{}, *want.RouteExpiry))
+    }
+
+    return
+}
+
+// run calls the handler of the op the way the router would, returning the
+// status it answered with and its body.
+func (op *snapshotOp) run(r *http.Request) (code int, body []byte, err error) {
+    var b []byte
+    if op.Body != nil {
+        b, err = json.Marshal(op.Body)
+        if err != nil {
+            return
+        }
+    }
+    req, err := http.NewRequest(op.Method, op.Path, bytes.NewReader(b))
+    if err != nil {
+        return
+    }
+    req = req.WithContext(r.Context())
+    req.Header.Set("Content-Type", "application/json")
+    req = mux.SetURLVars(req, op.vars)
+
+    rec := &bufferedResponseWriter{header: make(http.Header)}
+    op.handler(rec, req)
+
+    code = rec.code
+    if code == 0 {
+        code = http.StatusOK
+    }
+    body = rec.body.Bytes()
+    return
+}
+
+func ConfigSnapshotGet(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+    snap, err := SnapshotGet()
+    if err != nil {
+        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return
+    }
+
+    WriteRequestResponse(w, snap, http.StatusOK)
+}
+
+// ConfigSnapshotPost restores a snapshot by running the API calls planned by
+// SnapshotPlan one after the other. The first call that fails stops the
+// restore since later calls may depend on it; what was applied stays applied
+// and the answer is 207 listing the applied, failed and skipped calls.
+// With dry_run the plan is returned without running anything.
+func ConfigSnapshotPost(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+    dryRun, err := ParseDryRun(w, r)
+    if err != nil {
+        // The error is already handled in this case
+        return
+    }
+
+    mode := SNAPSHOT_MODE_MERGE
+    if len(r.URL.Query()["mode"]) == 1 {
+        mode = r.URL.Query()["mode"][0]
+        if mode != SNAPSHOT_MODE_MERGE && mode != SNAPSHOT_MODE_REPLACE {
+            WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"mode"}, "mode must be merge or replace")
+            return
+        }
+    } else if len(r.URL.Query()["mode"]) > 1 {
+        WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"mode"}, "May only specify one mode")
+        return
+    }
+
+    var want SnapshotModel
+    err = ReadJSONBody(w, r, &want)
+    if err != nil {
+        // The error is already handled in this case
+        return
+    }
+    if want.Version != SNAPSHOT_VERSION {
+        WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{"version"},
+            "Unsupported snapshot version, expected " + strconv.Itoa(SNAPSHOT_VERSION))
+        return
+    }
+
+    cur, err := SnapshotGet()
+    if err != nil {
+        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return
+    }
+
+    ops := SnapshotPlan(cur, want, mode)
+    output := SnapshotRestoreReturnModel{Mode: mode}
+
+    if dryRun {
+        output.DryRun = true
+        output.Planned = make([]SnapshotOpModel, 0, len(ops))
+        for _, op := range ops {
+            output.Planned = append(output.Planned, op.SnapshotOpModel)
+        }
+        WriteRequestResponse(w, output, http.StatusOK)
+        return
+    }
+
+    for i := range ops {
+        op := &ops[i]
+        if len(output.Failed) > 0 {
+            output.Skipped = append(output.Skipped, op.SnapshotOpModel)
+            continue
+        }
+        code, body, err := op.run(r)
+        if err != nil {
+            WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+            return
+        }
+        // 207 is a bulk route call with failed entries
+        if code >= http.StatusMultipleChoices || code == http.StatusMultiStatus {
+            op.Error_code = code
+            if json.Valid(body) {
+                op.Error = json.RawMessage(body)
+            }
+            output.Failed = append(output.Failed, op.SnapshotOpModel)
+            continue
+        }
+        output.Applied = append(output.Applied, op.SnapshotOpModel)
+    }
+
+    if len(output.Failed) > 0 {
+        WriteRequestResponse(w, output, http.StatusMultiStatus)
+        return
+    }
+    WriteRequestResponse(w, output, http.StatusOK)
+}
@@ -0,0 +1,300 @@
+package restapi
+
+import (
+    "errors"
+    "git.apache.org/thrift.git/lib/go/thrift"
+    "log"
+    "net/http"
+    "sync"
+    "time"
+)
+
+const (
+    CIRCUIT_CLOSED    string = "closed"
+    CIRCUIT_OPEN      string = "open"
+    CIRCUIT_HALF_OPEN string = "half_open"
+)
+
+// First wait between two connection attempts within a call, doubled after
+// every failed attempt
+const THRIFT_RECONNECT_BACKOFF time.Duration = 50 * time.Millisecond
+
+var errThriftCallTimeout = errors.New("call deadline exceeded")
+
+type thriftConn struct {
+    transport *thrift.TSocket
+    client    interface{}
+}
+
+func (c *thriftConn) close() {
+    c.transport.Close()
+}
+
+// thriftPool holds the connections to one Thrift backend. Every call gets a
+// connection of its own, up to -thriftpoolsize at once, and must finish
+// within the timeout of the pool or is abandoned. A call that finds no idle
+// connection dials with exponential backoff until its deadline.
+//
+// After -thriftbreakerthreshold failed calls in a row the circuit opens and
+// calls fail right away for -thriftbreakercooldown. Then a single call is let
+// through to test the backend; if it fails too, the circuit opens again for
+// twice as long, up to -thriftbreakercooldownmax.
+type thriftPool struct {
+    name        string
+    addr        string
+    timeout     time.Duration
+    newClient   func(transport thrift.TTransport, factory thrift.TProtocolFactory) interface{}
+    unreachable string
+
+    slots chan struct{}
+    idle  chan *thriftConn
+
+    mu         sync.Mutex
+    failures   int
+    cooldown   time.Duration
+    open_until time.Time
+    last_error string
+}
+
+var thriftPools = make(map[string]*thriftPool)
+var thriftPoolsMutex sync.Mutex
+
+// newThriftPool creates the pool of a backend and registers it under key,
+// which is the name the heartbeat reports it with. unreachable is the message
+// of the 503 calls fail with when the backend cannot be reached.
+func newThriftPool(key string, name string, addr string, timeout time.Duration, unreachable string,
+    newClient func(transport thrift.TTransport, factory thrift.TProtocolFactory) interface{}) *thriftPool {
+    size := *ThriftPoolSizeFlag
+    if size < 1 {
+        size = 1
+    }
+    p := &thriftPool{
+        name:        name,
+        addr:        addr,
+        timeout:     timeout,
+        newClient:   newClient,
+        unreachable: unreachable,
+        slots:       make(chan struct{}, size),
+        idle:        make(chan *thriftConn, size),
+    }
+
+    thriftPoolsMutex.Lock()
+    defer thriftPoolsMutex.Unlock()
+    thriftPools[key] = p
+    return p
+}
+
+func (p *thriftPool) dial(timeout time.Duration) (*thriftConn, error) {
+    transport, err := thrift.NewTSocketTimeout(p.addr, timeout)
+    if err != nil {
+        return nil, err
+    }
+    if err = transport.Open(); err != nil {
+        return nil, err
+    }
+    return &thriftConn{
+        transport: transport,
+        client:    p.newClient(transport, thrift.NewTBinaryProtocolFactoryDefault()),
+    }, nil
+}
+
+// get takes an idle connection or dials a new one, retrying with backoff
+// until the deadline
+func (p *thriftPool) get(deadline time.Time) (*thriftConn, error) {
+    select {
+    case conn := <-p.idle:
+        return conn, nil
+    default:
+    }
+
+    backoff := THRIFT_RECONNECT_BACKOFF
+    for {
+        conn, err := p.dial(p.timeout)
+        if err == nil {
+            return conn, nil
+        }
+        if time.Now().Add(backoff).After(deadline) {
+            return nil, err
+        }
+        log.Printf("debug: could not connect to %s at %s, retrying in %v, error: %v", p.name, p.addr, backoff, err)
+        time.Sleep(backoff)
+        backoff *= 2
+    }
+}
+
+// put keeps a healthy connection for the next call
+func (p *thriftPool) put(conn *thriftConn) {
+    select {
+    case p.idle <- conn:
+    default:
+        conn.close()
+    }
+}
+
+// allow tells whether the circuit lets a call through. In the half open
+// state it lets one through and holds the others off until it is done.
+func (p *thriftPool) allow() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if p.failures < *ThriftBreakerThresholdFlag {
+        return true
+    }
+    now := time.Now()
+    if now.Before(p.open_until) {
+        return false
+    }
+    p.open_until = now.Add(p.timeout)
+    return true
+}
+
+func (p *thriftPool) success() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if p.failures >= *ThriftBreakerThresholdFlag {
+        log.Printf("info: %s at %s is reachable again, closing circuit", p.name, p.addr)
+    }
+    p.failures = 0
+    p.cooldown = 0
+    p.last_error = ""
+}
+
+func (p *thriftPool) failure(err error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    p.failures++
+    p.last_error = err.Error()
+    if p.failures < *ThriftBreakerThresholdFlag {
+        return
+    }
+    if p.cooldown == 0 {
+        p.cooldown = *ThriftBreakerCooldownFlag
+    } else {
+        p.cooldown *= 2
+    }
+    if p.cooldown > *ThriftBreakerCooldownMaxFlag {
+        p.cooldown = *ThriftBreakerCooldownMaxFlag
+    }
+    p.open_until = time.Now().Add(p.cooldown)
+    log.Printf("error: %s at %s failed %d times in a row, opening circuit for %v", p.name, p.addr, p.failures, p.cooldown)
+}
+
+func (p *thriftPool) circuit() string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if p.failures < *ThriftBreakerThresholdFlag {
+        return CIRCUIT_CLOSED
+    }
+    if time.Now().Before(p.open_until) {
+        return CIRCUIT_OPEN
+    }
+    return CIRCUIT_HALF_OPEN
+}
+
+// do runs one RPC on a connection of the pool. Failing to connect, a
+// transport error or an open circuit become a 503 BackendError and running
+// past the deadline a 504 one. The connection of a failed call is dropped.
+func (p *thriftPool) do(method string, fn func(client interface{}) error) error {
+    deadline := time.Now().Add(p.timeout)
+
+    if !p.allow() {
+        log.Printf("error: %s %s not attempted, circuit is open", p.name, method)
+        return NewBackendError(http.StatusServiceUnavailable, p.unreachable)
+    }
+
+    select {
+    case p.slots <- struct{}{}:
+    case <-time.After(time.Until(deadline)):
+        log.Printf("error: %s %s timed out waiting for a connection", p.name, method)
+        return NewBackendError(http.StatusServiceUnavailable, p.unreachable)
+    }
+    defer func() { <-p.slots }()
+
+    conn, err := p.get(deadline)
+    if err != nil {
+        log.Printf("error: could not connect to %s at %s, error: %v", p.name, p.addr, err)
+        p.failure(err)
+        return NewBackendError(http.StatusServiceUnavailable, p.unreachable)
+    }
+
+    done := make(chan error, 1)
+    go func() {
+        done <- fn(conn.client)
+    }()
+
+    select {
+    case err = <-done:
+    case <-time.After(time.Until(deadline)):
+        err = errThriftCallTimeout
+    }
+    if err != nil {
+        log.Printf("error: %s %s failed, error: %v", p.name, method, err)
+        // Closing also unblocks a call still waiting for its reply
+        conn.close()
+        p.failure(err)
+        if err == errThriftCallTimeout {
+            return NewBackendError(http.StatusGatewayTimeout, p.name + " did not answer in time")
+        }
+        return NewBackendError(http.StatusServiceUnavailable, p.unreachable)
+    }
+    p.success()
+    p.put(conn)
+    return nil
+}
+
+// status tells whether the backend can be reached. An idle connection is
+// taken as proof; without one it dials, unless the circuit is open. The
+// dial is left out of the circuit breaker, which counts requests only.
+func (p *thriftPool) status() ThriftBackendStatusModel {
+    status := ThriftBackendStatusModel{Address: p.addr, Circuit: p.circuit()}
+
+    p.mu.Lock()
+    status.Error = p.last_error
+    p.mu.Unlock()
+
+    if status.Circuit != CIRCUIT_OPEN {
+        select {
+        case conn := <-p.idle:
+            p.put(conn)
+            status.Reachable = true
+        default:
+            if conn, err := p.dial(p.timeout); err == nil {
+                p.put(conn)
+                status.Reachable = true
+            } else {
+                status.Error = err.Error()
+            }
+        }
+    }
+    return status
+}
+
+// thriftBackends gives the pool of every Thrift backend the server has
+// talked to, by name
+func thriftBackends() map[string]*thriftPool {
+    thriftPoolsMutex.Lock()
+    defer thriftPoolsMutex.Unlock()
+
+    pools := make(map[string]*thriftPool, len(thriftPools))
+    for key, p := range thriftPools {
+        pools[key] = p
+    }
+    return pools
+}
+
+// ThriftBackendsStatus gives the status of every Thrift backend the server
+// has talked to, by name
+func ThriftBackendsStatus() map[string]ThriftBackendStatusModel {
+    pools := thriftBackends()
+    if len(pools) == 0 {
+        return nil
+    }
+    statuses := make(map[string]ThriftBackendStatusModel, len(pools))
+    for key, p := range pools {
+        statuses[key] = p.status()
+    }
+    return statuses
+}
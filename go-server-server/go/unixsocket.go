@@ -0,0 +1,148 @@
+package restapi
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "syscall"
+)
+
+// The unix socket endpoint serves the agents on the box. Anyone may connect
+// to it, the kernel tells who is at the other end of a connection and that
+// uid and gid are checked against -unixsocketuids and -unixsocketgids where
+// the https endpoint checks a client cert.
+
+// PeerCredentials is who connected to the unix socket endpoint, as of
+// SO_PEERCRED when it connected
+type PeerCredentials struct {
+    Pid int32
+    Uid uint32
+    Gid uint32
+}
+
+type peerCredentialsKey struct{}
+
+// The uids and gids allowed on the unix socket endpoint, a unixSocketPeers
+// replaced as a whole when the config is reloaded
+var allowedUnixSocketPeers atomic.Value
+
+type unixSocketPeers struct {
+    uids map[uint32]bool
+    gids map[uint32]bool
+}
+
+func parseIds(s string) (map[uint32]bool, error) {
+    ids := make(map[uint32]bool)
+    for _, item := range strings.Split(s, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        id, err := strconv.ParseUint(item, 10, 32)
+        if err != nil {
+            return nil, fmt.Errorf("%q is not a uid or gid", item)
+        }
+        ids[uint32(id)] = true
+    }
+    return ids, nil
+}
+
+// LoadUnixSocketPeers sets up the uids and gids of -unixsocketuids and
+// -unixsocketgids
+func LoadUnixSocketPeers() error {
+    uids, err := parseIds(*UnixSocketUidsFlag)
+    if err != nil {
+        return err
+    }
+    gids, err := parseIds(*UnixSocketGidsFlag)
+    if err != nil {
+        return err
+    }
+    allowedUnixSocketPeers.Store(unixSocketPeers{uids: uids, gids: gids})
+    return nil
+}
+
+// RequestPeerCredentials gives who sent a request to the unix socket
+// endpoint, false for the other endpoints. It is nil when the kernel could
+// not tell.
+func RequestPeerCredentials(r *http.Request) (*PeerCredentials, bool) {
+    cred, ok := r.Context().Value(peerCredentialsKey{}).(*PeerCredentials)
+    return cred, ok
+}
+
+// PeerAllowed tells whether the uid or the gid of a peer is allowed on the
+// unix socket endpoint
+func PeerAllowed(cred *PeerCredentials) bool {
+    if cred == nil {
+        log.Printf("error: Authentication Fail! No peer credentials for the unix socket connection")
+        return false
+    }
+    peers, _ := allowedUnixSocketPeers.Load().(unixSocketPeers)
+    if peers.uids[cred.Uid] || peers.gids[cred.Gid] {
+        return true
+    }
+    log.Printf("error: Authentication Fail! Neither uid %d nor gid %d of pid %d is allowed on the unix socket", cred.Uid, cred.Gid, cred.Pid)
+    return false
+}
+
+// ListenUnix listens on path, replacing a socket left behind by a server
+// that did not stop cleanly. A socket some server still accepts connections
+// on is left alone.
+func ListenUnix(path string) (net.Listener, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return nil, err
+    }
+    if info, err := os.Lstat(path); err == nil {
+        if info.Mode() & os.ModeSocket == 0 {
+            return nil, fmt.Errorf("%s exists and is not a socket", path)
+        }
+        conn, err := net.Dial("unix", path)
+        if err == nil {
+            conn.Close()
+            return nil, fmt.Errorf("%s is in use by another server", path)
+        }
+        if !errors.Is(err, syscall.ECONNREFUSED) {
+            return nil, err
+        }
+        if err := os.Remove(path); err != nil {
+            return nil, err
+        }
+    }
+    ln, err := net.Listen("unix", path)
+    if err != nil {
+        return nil, err
+    }
+    // Anyone may connect, the peer credentials decide what gets served
+    if err := os.Chmod(path, 0666); err != nil {
+        ln.Close()
+        return nil, err
+    }
+    return ln, nil
+}
+
+// ConfigureUnixServer sets up a server of the unix socket endpoint, its
+// requests carry the credentials of their peer
+func ConfigureUnixServer(server *http.Server) {
+    ConfigureServer(server)
+    server.ConnContext = unixConnContext
+}
+
+func unixConnContext(ctx context.Context, c net.Conn) context.Context {
+    var cred *PeerCredentials
+    if uc, ok := c.(*net.UnixConn); ok {
+        var err error
+        cred, err = peerCredentials(uc)
+        if err != nil {
+            log.Printf("error: could not read the peer credentials of a unix socket connection, error: %v", err)
+        }
+    }
+    return context.WithValue(ctx, peerCredentialsKey{}, cred)
+}
@@ -0,0 +1,28 @@
+//go:build linux
+// +build linux
+
+package restapi
+
+import (
+    "net"
+    "syscall"
+)
+
+func peerCredentials(c *net.UnixConn) (*PeerCredentials, error) {
+    raw, err := c.SyscallConn()
+    if err != nil {
+        return nil, err
+    }
+    var ucred *syscall.Ucred
+    var credErr error
+    err = raw.Control(func(fd uintptr) {
+        ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+    })
+    if err != nil {
+        return nil, err
+    }
+    if credErr != nil {
+        return nil, credErr
+    }
+    return &PeerCredentials{Pid: ucred.Pid, Uid: ucred.Uid, Gid: ucred.Gid}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package restapi
+
+import (
+    "errors"
+    "net"
+)
+
+// Only linux tells the credentials of a peer through SO_PEERCRED, every
+// request to the unix socket endpoint is refused elsewhere
+func peerCredentials(c *net.UnixConn) (*PeerCredentials, error) {
+    return nil, errors.New("peer credentials are only available on linux")
+}
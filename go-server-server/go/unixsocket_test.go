@@ -0,0 +1,99 @@
+package restapi
+
+import (
+    "context"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "testing"
+)
+
+func TestParseIds(t *testing.T) {
+    ids, err := parseIds("0, 1000,,")
+    if err != nil || len(ids) != 2 || !ids[0] || !ids[1000] {
+        t.Errorf("got %v, %v", ids, err)
+    }
+    for _, s := range []string{"root", "-1", "4294967296"} {
+        if _, err := parseIds(s); err == nil {
+            t.Errorf("%q: got no error", s)
+        }
+    }
+}
+
+func TestUnixSocketPeerCredentials(t *testing.T) {
+    defer func(uids, gids string) {
+        *UnixSocketUidsFlag, *UnixSocketGidsFlag = uids, gids
+        LoadUnixSocketPeers()
+    }(*UnixSocketUidsFlag, *UnixSocketGidsFlag)
+
+    path := filepath.Join(t.TempDir(), "run", "rest-api.sock")
+    ln, err := ListenUnix(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // A socket left behind by a server that did not stop cleanly is replaced
+    ln.(*net.UnixListener).SetUnlinkOnClose(false)
+    ln.Close()
+    if ln, err = ListenUnix(path); err != nil {
+        t.Fatal(err)
+    }
+    // One still served is not
+    if _, err := ListenUnix(path); err == nil {
+        t.Fatal("listened in place of a socket in use")
+    }
+    if _, err := ListenUnix(filepath.Join(filepath.Dir(path), "..")); err == nil {
+        t.Error("listened in place of a directory")
+    }
+    if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0666 {
+        t.Errorf("got %v, %v, want a socket anyone may connect to", info, err)
+    }
+
+    identities := make(chan string, 1)
+    server := &http.Server{Handler: Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        identity, _ := RequestIdentity(r)
+        identities <- identity
+        w.WriteHeader(http.StatusNoContent)
+    }), "StateHeartbeatGet")}
+    ConfigureUnixServer(server)
+    go server.Serve(ln)
+    defer server.Close()
+
+    c := &http.Client{Transport: &http.Transport{
+        DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+            return net.Dial("unix", path)
+        },
+        DisableKeepAlives: true,
+    }}
+    uid := strconv.Itoa(os.Getuid())
+    gid := strconv.Itoa(os.Getgid())
+    tests := []struct {
+        uids string
+        gids string
+        code int
+    }{
+        {uid, "", http.StatusNoContent},
+        {"", gid, http.StatusNoContent},
+        {"", "", http.StatusUnauthorized},
+    }
+    for _, test := range tests {
+        *UnixSocketUidsFlag, *UnixSocketGidsFlag = test.uids, test.gids
+        if err := LoadUnixSocketPeers(); err != nil {
+            t.Fatal(err)
+        }
+        resp, err := c.Get("http://localhost/v1/state/heartbeat")
+        if err != nil {
+            t.Fatal(err)
+        }
+        resp.Body.Close()
+        if resp.StatusCode != test.code {
+            t.Errorf("uids %q, gids %q: got %d, want %d", test.uids, test.gids, resp.StatusCode, test.code)
+        }
+        if resp.StatusCode == http.StatusNoContent {
+            if identity := <-identities; identity != "uid:" + uid {
+                t.Errorf("got identity %q, want uid:%s", identity, uid)
+            }
+        }
+    }
+}
@@ -3,7 +3,6 @@ package restapi
 import (
     "encoding/json"
     "errors"
-    "io/ioutil"
     "log"
     "net"
     "net/http"
@@ -13,46 +12,38 @@ import (
 )
 
 func WriteRequestError(w http.ResponseWriter, code int, message string, fields []string, details string) {
-    e := ErrorInner{
+    writeErrorModel(w, ErrorInner{
         Code:    code,
         Message: message,
         Fields:  fields,
         Details: details,
-    }
-
-    b, err := json.Marshal(ErrorModel{e})
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        b = []byte(`
-{
-  "error": {
-    "code": 500,
-    "message": "Internal service error"
-  }
-}`)
-    } else {
-        w.WriteHeader(code)
-    }
-
-    log.Printf(
-        "error: Request ends with error message %s",
-        b,
-    )
-
-    w.Write(b)
+    })
 }
 
 
 func WriteRequestErrorWithSubCode(w http.ResponseWriter, code int, sub_code int, message string, fields []string, details string) {
-    e := ErrorInner{
+    writeErrorModel(w, ErrorInner{
         Code:     code,
         SubCode: &sub_code,
         Message:  message,
         Fields:   fields,
         Details:  details,
-    }
+    })
+}
+
+// WriteValidationError answers 400 with every violation of a request body
+func WriteValidationError(w http.ResponseWriter, e *ValidationError) {
+    writeErrorModel(w, ErrorInner{
+        Code:       http.StatusBadRequest,
+        Message:    "Malformed arguments for API call",
+        Fields:     e.Fields(),
+        Details:    e.Error(),
+        Violations: e.Violations,
+    })
+}
 
-    b, err := json.Marshal(ErrorModel{e})
+func writeErrorModel(w http.ResponseWriter, e ErrorInner) {
+    b, err := json.Marshal(ErrorModel{Error: e})
     if err != nil {
         w.WriteHeader(http.StatusInternalServerError)
         b = []byte(`
@@ -63,7 +54,7 @@ func WriteRequestErrorWithSubCode(w http.ResponseWriter, code int, sub_code int,
   }
 }`)
     } else {
-        w.WriteHeader(code)
+        w.WriteHeader(e.Code)
     }
 
     log.Printf(
@@ -80,6 +71,8 @@ func WriteJsonError(w http.ResponseWriter, err error) {
         WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{}, "Invalid character in JSON")
     case *json.UnmarshalTypeError:
         WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{t.Field}, "JSON field does not match required type")
+    case *ValidationError:
+        WriteValidationError(w, t)
     case *MissingValueError:
         WriteRequestError(w, http.StatusBadRequest, "Malformed arguments for API call", []string{t.Field}, "Missing JSON field")
     case *InvalidFormatError:
@@ -99,10 +92,117 @@ func WriteRequestResponse(w http.ResponseWriter, jsonObj interface{}, code int)
     }
 }
 
+const JSON_CONTENT_TYPE string = "application/json; charset=UTF-8"
+const NDJSON_CONTENT_TYPE string = "application/x-ndjson"
+
+// Number of streamed elements written between two flushes to the client
+const STREAM_FLUSH_INTERVAL int = 256
+
+// JSONStreamWriter writes a list response one element at a time, either as a
+// JSON array or, when the client asks for it with Accept, as newline
+// delimited JSON. Nothing is buffered beyond the element being encoded.
+type JSONStreamWriter struct {
+    w       http.ResponseWriter
+    enc     *json.Encoder
+    code    int
+    ndjson  bool
+    started bool
+    count   int
+}
+
+// streamer is a ResponseWriter holding responses back that lets a streamed
+// one through once told
+type streamer interface {
+    Stream()
+}
+
+func NewJSONStreamWriter(w http.ResponseWriter, r *http.Request, code int) *JSONStreamWriter {
+    return &JSONStreamWriter{
+        w:      w,
+        enc:    json.NewEncoder(w),
+        code:   code,
+        ndjson: AcceptsNDJSON(r),
+    }
+}
+
+func AcceptsNDJSON(r *http.Request) bool {
+    for _, accept := range r.Header["Accept"] {
+        for _, mediaType := range strings.Split(accept, ",") {
+            mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+            if mediaType == NDJSON_CONTENT_TYPE {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func (s *JSONStreamWriter) start() {
+    if s.started {
+        return
+    }
+    s.started = true
+    ExtendWriteDeadline(s.w)
+    if st, ok := s.w.(streamer); ok {
+        st.Stream()
+    }
+    if s.ndjson {
+        s.w.Header().Set("Content-Type", NDJSON_CONTENT_TYPE)
+    } else {
+        s.w.Header().Set("Content-Type", JSON_CONTENT_TYPE)
+    }
+    s.w.WriteHeader(s.code)
+    if !s.ndjson {
+        s.w.Write([]byte("["))
+    }
+}
+
+func (s *JSONStreamWriter) Write(jsonObj interface{}) error {
+    s.start()
+    if !s.ndjson && s.count > 0 {
+        s.w.Write([]byte(","))
+    }
+    err := s.enc.Encode(jsonObj)
+    if err != nil {
+        return err
+    }
+    s.count++
+    if s.count % STREAM_FLUSH_INTERVAL == 0 {
+        s.flush()
+    }
+    return nil
+}
+
+func (s *JSONStreamWriter) Close() {
+    s.start()
+    if !s.ndjson {
+        s.w.Write([]byte("]"))
+    }
+    s.flush()
+}
+
+// Abort reports err to the client. Once the first element is out the status
+// line is gone, so the connection is dropped instead to make sure the client
+// cannot mistake a partial list for a complete one.
+func (s *JSONStreamWriter) Abort(err error) {
+    if !s.started {
+        WriteRequestError(s.w, http.StatusInternalServerError, "Internal service error", []string{}, "")
+        return
+    }
+    log.Printf("error: aborting streamed response after %d elements: %v", s.count, err)
+    panic(http.ErrAbortHandler)
+}
+
+func (s *JSONStreamWriter) flush() {
+    ExtendWriteDeadline(s.w)
+    if f, ok := s.w.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
 func ReadJSONBody(w http.ResponseWriter, r *http.Request, attr interface{}) error {
-    body, err := ioutil.ReadAll(r.Body)
+    body, err := ReadBody(w, r)
     if err != nil {
-        WriteRequestError(w, http.StatusInternalServerError, "Internal service error", []string{}, "")
         return err
     }
 
@@ -111,7 +211,7 @@ func ReadJSONBody(w http.ResponseWriter, r *http.Request, attr interface{}) erro
         body,
     )
 
-    err = json.Unmarshal(body, attr)
+    err = DecodeJSON(body, attr)
     if err != nil {
         WriteJsonError(w, err)
         return err
@@ -160,16 +260,6 @@ func RemoveFromSlice(slice_t []string, item string) (bool, []string) {
     return true, append(slice_t[:index], slice_t[index+1:]...)
 }
 
-func IsValidIP(ipstr string) bool {
-    ip := net.ParseIP(ipstr)
-    return (ip != nil) && (ip.To4() != nil)
-}
-
-func IsValidIPBoth(ipstr string) bool {
-    ip := net.ParseIP(ipstr)
-    return ip != nil
-}
-
 func isV4orV6(ipaddr string) (int) {
     ip := net.ParseIP(ipaddr)
     if ip.To4() != nil {
@@ -178,18 +268,6 @@ func isV4orV6(ipaddr string) (int) {
     return 6
 }
 
-func ParseIPBothPrefix(ipprefix string) (ipstr string, length int, err error) {
-    ip, net, err := net.ParseCIDR(ipprefix)
-    if err != nil {
-        return
-    }
-
-    ipstr = ip.String()
-    length, _ = net.Mask.Size()
-
-    return
-}
-
 func ParseIPPrefix(ipprefix string) (ipstr string, length int, err error) {
     ip, net, err := net.ParseCIDR(ipprefix)
     if err != nil {
@@ -267,6 +345,19 @@ func validateVlanID(vlan_id_str string) (vlanID int, err error) {
    return
 }
 
+// vnetRouteTableName gives the APPL_DB table routes of a VNET are read from,
+// the local route one for routes out of an interface
+func vnetRouteTableName(if_name string) string {
+    rt_tb_name := ROUTE_TUN_TB
+    if if_name != "" {
+        rt_tb_name = LOCAL_ROUTE_TB
+    }
+    if *RunApiAsLocalTestDocker {
+        rt_tb_name = "_" + rt_tb_name
+    }
+    return rt_tb_name
+}
+
 func generateVlanPrefixInVnet(vnet_id_str string) (vlanPrefixArr []string, err error) {
     db := &app_db_ops
     var rt_tb_key string
@@ -301,7 +392,7 @@ func isBMNextHop(ipprefix string, vlanPrefixArr []string) (bm_next_hop bool, err
 		       if err != nil {
 		           return bm_next_hop, err
 		       }
-				 if ((ip.To4 == nil && vlan_ip.To4 == nil) || (ip.To4 != nil && vlan_ip.To4 != nil)) && vlan_netw.Contains(ip) {
+				 if ((ip.To4() == nil && vlan_ip.To4() == nil) || (ip.To4() != nil && vlan_ip.To4() != nil)) && vlan_netw.Contains(ip) {
 				     bm_next_hop = true
                  return bm_next_hop, err
 			    }
@@ -0,0 +1,740 @@
+package restapi
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// The v2 API serves the v1 handlers under /v2. V2Middleware turns the typed
+// fields of v2 bodies into the strings of the v1 models and back, and maps
+// every error onto an entry of the error code catalog below.
+
+type apiVersionKey struct{}
+
+func IsV2(r *http.Request) bool {
+    v, _ := r.Context().Value(apiVersionKey{}).(int)
+    return v == 2
+}
+
+// Sub-codes of the error code catalog, RESRC_EXISTS, DEP_MISSING and
+// DELETE_DEP are the ones v1 has always used
+const NOT_FOUND int          = 3
+const INVALID_ARGUMENT int   = 4
+const MALFORMED_REQUEST int  = 5
+const PRECONDITION_FAIL int  = 6
+const IDEMPOTENCY_REUSE int  = 7
+const UNAUTHENTICATED int    = 8
+const BACKEND_UNAVAIL int    = 9
+const INTERNAL_ERROR int     = 10
+const PAYLOAD_TOO_LARGE int  = 11
+const SERVER_BUSY int        = 12
+const RATE_LIMITED int       = 13
+const MAINTENANCE_MODE int   = 14
+const FORBIDDEN int          = 15
+
+var ErrorCodeCatalog = []ErrorCodeModel{
+    {RESRC_EXISTS, "RESOURCE_EXISTS", http.StatusConflict, "The object to create already exists"},
+    {DEP_MISSING, "DEPENDENCY_MISSING", http.StatusConflict, "An object the request refers to does not exist yet"},
+    {DELETE_DEP, "DELETE_DEPENDENCY", http.StatusConflict, "The object to delete still has child objects"},
+    {NOT_FOUND, "NOT_FOUND", http.StatusNotFound, "The object does not exist, fields names the path or query parameter"},
+    {INVALID_ARGUMENT, "INVALID_ARGUMENT", http.StatusUnprocessableEntity, "A parameter or body field has an invalid value, fields names them"},
+    {MALFORMED_REQUEST, "MALFORMED_REQUEST", http.StatusBadRequest, "The body is not JSON"},
+    {PRECONDITION_FAIL, "PRECONDITION_FAILED", http.StatusPreconditionFailed, "If-Match or If-None-Match does not hold"},
+    {IDEMPOTENCY_REUSE, "IDEMPOTENCY_KEY_REUSED", http.StatusUnprocessableEntity, "The Idempotency-Key was used for a different request"},
+    {UNAUTHENTICATED, "UNAUTHENTICATED", http.StatusUnauthorized, "The client cert is not trusted"},
+    {BACKEND_UNAVAIL, "BACKEND_UNAVAILABLE", http.StatusServiceUnavailable, "The data plane backend did not answer, 503 or 504, retry later"},
+    {INTERNAL_ERROR, "INTERNAL_ERROR", http.StatusInternalServerError, "The server failed to handle the request"},
+    {PAYLOAD_TOO_LARGE, "PAYLOAD_TOO_LARGE", http.StatusRequestEntityTooLarge, "The body is over the limit of the route"},
+    {SERVER_BUSY, "SERVER_BUSY", http.StatusServiceUnavailable, "Too many requests are in progress, retry after Retry-After"},
+    {RATE_LIMITED, "RATE_LIMITED", http.StatusTooManyRequests, "The client is over the rate limit of the route, retry after Retry-After"},
+    {MAINTENANCE_MODE, "MAINTENANCE_MODE", http.StatusServiceUnavailable, "The server takes no config changes for now, retry after Retry-After"},
+    {FORBIDDEN, "FORBIDDEN", http.StatusForbidden, "The client may not use the route"},
+}
+
+func errorCode(sub_code int) ErrorCodeModel {
+    for _, e := range ErrorCodeCatalog {
+        if e.SubCode == sub_code {
+            return e
+        }
+    }
+    return ErrorCodeCatalog[len(ErrorCodeCatalog) - 1]
+}
+
+// Details WriteJsonError gives to bodies that could not be decoded at all
+var malformedBodyDetails = map[string]bool{
+    "Invalid character in JSON": true,
+    "Failed to decode JSON": true,
+}
+
+// v2ErrorCode picks the catalog entry of a v1 error
+func v2ErrorCode(e ErrorInner, code int) ErrorCodeModel {
+    // Sub-codes past those of v1 come from handlers answering in v2 already
+    if e.SubCode != nil && *e.SubCode > DELETE_DEP {
+        return errorCode(*e.SubCode)
+    }
+    switch code {
+    case http.StatusConflict:
+        if e.SubCode != nil {
+            return errorCode(*e.SubCode)
+        }
+        return errorCode(RESRC_EXISTS)
+    case http.StatusNotFound:
+        return errorCode(NOT_FOUND)
+    case http.StatusBadRequest:
+        if malformedBodyDetails[e.Details] {
+            return errorCode(MALFORMED_REQUEST)
+        }
+        return errorCode(INVALID_ARGUMENT)
+    case http.StatusPreconditionFailed:
+        return errorCode(PRECONDITION_FAIL)
+    case http.StatusUnprocessableEntity:
+        return errorCode(IDEMPOTENCY_REUSE)
+    case http.StatusUnauthorized:
+        return errorCode(UNAUTHENTICATED)
+    case http.StatusForbidden:
+        return errorCode(FORBIDDEN)
+    case http.StatusRequestEntityTooLarge:
+        return errorCode(PAYLOAD_TOO_LARGE)
+    case http.StatusTooManyRequests:
+        return errorCode(RATE_LIMITED)
+    case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+        c := errorCode(BACKEND_UNAVAIL)
+        c.Status = code
+        return c
+    }
+    return errorCode(INTERNAL_ERROR)
+}
+
+// writeServiceError answers with the status of sub_code, and the sub-code
+// itself in v2
+func writeServiceError(w http.ResponseWriter, v2 bool, sub_code int, message string, details string) {
+    if v2 {
+        writeV2Error(w, sub_code, message, []string{}, details)
+        return
+    }
+    WriteRequestError(w, errorCode(sub_code).Status, message, []string{}, details)
+}
+
+func writeV2Error(w http.ResponseWriter, sub_code int, message string, fields []string, details string) {
+    c := errorCode(sub_code)
+    w.Header().Set("Content-Type", JSON_CONTENT_TYPE)
+    WriteRequestResponse(w, ErrorModel{Error: ErrorInner{
+        Code:    c.Status,
+        SubCode: &sub_code,
+        Reason:  c.Reason,
+        Message: message,
+        Fields:  fields,
+        Details: details,
+    }}, c.Status)
+}
+
+// Typed encodings of v1 string fields
+const (
+    v2Bool = iota
+    v2Int
+    v2Number
+    v2List
+    v2IntList
+)
+
+// v2Model lists the fields of a v1 model that v2 types and the models nested
+// in it, by JSON key. It describes an object, or every object of a list.
+// pick, if set, gives the model of an object from the object itself.
+type v2Model struct {
+    fields map[string]int
+    nested map[string]*v2Model
+    pick   func(obj map[string]interface{}) *v2Model
+}
+
+var v2RouteModel = &v2Model{fields: map[string]int{
+    "nexthop":         v2List,
+    "nexthop_monitor": v2List,
+    "primary":         v2List,
+    "weight":          v2IntList,
+    "persistent":      v2Bool,
+}}
+
+var v2RouteReturnModel = &v2Model{nested: map[string]*v2Model{
+    "failed": v2RouteModel,
+}}
+
+var v2VnetModel = &v2Model{fields: map[string]int{
+    "advertise_prefix": v2Bool,
+}}
+
+var v2VnetReturnModel = &v2Model{nested: map[string]*v2Model{
+    "attr": v2VnetModel,
+}}
+
+var v2ConfigResetStatusModel = &v2Model{fields: map[string]int{
+    "reset_status": v2Bool,
+}}
+
+var v2PingRequestModel = &v2Model{fields: map[string]int{
+    "count": v2Int,
+}}
+
+var v2PingReturnModel = &v2Model{fields: map[string]int{
+    "packets_transmitted": v2Int,
+    "packets_received":    v2Int,
+    "min_rtt":             v2Number,
+    "max_rtt":             v2Number,
+    "avg_rtt":             v2Number,
+}}
+
+var v2SnapshotRoutesModel = &v2Model{nested: map[string]*v2Model{
+    "routes": v2RouteModel,
+}}
+
+var v2SnapshotModel = &v2Model{nested: map[string]*v2Model{
+    "vnets":         v2VnetReturnModel,
+    "vnet_routes":   v2SnapshotRoutesModel,
+    "static_routes": v2SnapshotRoutesModel,
+}}
+
+// The body and error of a snapshot op are those of the route it calls, its
+// pick is set by init since the routes refer back to it
+var v2SnapshotOpModel = &v2Model{}
+
+func v2SnapshotOpBodies(op map[string]interface{}) *v2Model {
+    method, _ := op["method"].(string)
+    path, _ := op["path"].(string)
+    models := v2RouteModels[v1RouteName(method, path)]
+    return &v2Model{nested: map[string]*v2Model{
+        "body":  models.request,
+        "error": models.response,
+    }}
+}
+
+func init() {
+    v2SnapshotOpModel.pick = v2SnapshotOpBodies
+}
+
+var v2SnapshotRestoreReturnModel = &v2Model{nested: map[string]*v2Model{
+    "planned": v2SnapshotOpModel,
+    "applied": v2SnapshotOpModel,
+    "failed":  v2SnapshotOpModel,
+    "skipped": v2SnapshotOpModel,
+}}
+
+// v2RouteBodies are the models of the request and response bodies of a route
+type v2RouteBodies struct {
+    request  *v2Model
+    response *v2Model
+}
+
+// Models of the v1 routes whose bodies have typed fields in v2, by name
+var v2RouteModels = map[string]v2RouteBodies{
+    "ConfigResetStatusGet":           {nil, v2ConfigResetStatusModel},
+    "ConfigResetStatusPost":          {v2ConfigResetStatusModel, v2ConfigResetStatusModel},
+    "ConfigVrouterVrfIdGet":          {nil, v2VnetReturnModel},
+    "ConfigVrouterVrfIdPost":         {v2VnetModel, nil},
+    "ConfigVrouterVrfIdRoutesDelete": {nil, v2RouteReturnModel},
+    "ConfigVrouterVrfIdRoutesGet":    {nil, v2RouteModel},
+    "ConfigVrouterVrfIdRoutesPatch":  {v2RouteModel, v2RouteReturnModel},
+    "ConfigVrfVrfIdRoutesGet":        {nil, v2RouteModel},
+    "ConfigVrfVrfIdRoutesPatch":      {v2RouteModel, v2RouteReturnModel},
+    "ConfigSnapshotGet":              {nil, v2SnapshotModel},
+    "ConfigSnapshotPost":             {v2SnapshotModel, v2SnapshotRestoreReturnModel},
+    "Ping":                           {v2PingRequestModel, v2PingReturnModel},
+}
+
+// v1RoutePatterns match the paths of the v1 routes, for v1RouteName
+var v1RoutePatterns = routePatterns(routes)
+
+type routePattern struct {
+    name   string
+    method string
+    re     *regexp.Regexp
+}
+
+func routePatterns(routes Routes) []routePattern {
+    patterns := make([]routePattern, 0, len(routes))
+    for _, route := range routes {
+        parts := strings.Split(route.Pattern, "/")
+        for i, part := range parts {
+            if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+                parts[i] = "[^/]+"
+            } else {
+                parts[i] = regexp.QuoteMeta(part)
+            }
+        }
+        patterns = append(patterns, routePattern{
+            name:   route.Name,
+            method: route.Method,
+            re:     regexp.MustCompile("^" + strings.Join(parts, "/") + "$"),
+        })
+    }
+    return patterns
+}
+
+// v1RouteName gives the name of the v1 route serving method and path, ""
+// if none does
+func v1RouteName(method string, path string) string {
+    for _, p := range v1RoutePatterns {
+        if p.method == method && p.re.MatchString(path) {
+            return p.name
+        }
+    }
+    return ""
+}
+
+func v2FieldPath(path string, key string) string {
+    if path == "" {
+        return key
+    }
+    return path + "." + key
+}
+
+// toV1 turns the typed fields of a decoded v2 body of model m into v1
+// strings
+func toV1(v interface{}, m *v2Model, path string, violations *[]openAPIViolation) interface{} {
+    if m == nil {
+        return v
+    }
+    switch t := v.(type) {
+    case map[string]interface{}:
+        if m.pick != nil {
+            return toV1(v, m.pick(t), path, violations)
+        }
+        // In key order, for the violations to come out the same every time
+        keys := make([]string, 0, len(t))
+        for key := range t {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+            value := t[key]
+            field := v2FieldPath(path, key)
+            if value == nil {
+                continue
+            }
+            kind, typed := m.fields[key]
+            if !typed {
+                if nested, ok := m.nested[key]; ok {
+                    t[key] = toV1(value, nested, field, violations)
+                }
+                continue
+            }
+            s, err := v1String(kind, value)
+            if err != "" {
+                *violations = append(*violations, openAPIViolation{Field: field, Message: err})
+                continue
+            }
+            t[key] = s
+        }
+    case []interface{}:
+        for i, value := range t {
+            t[i] = toV1(value, m, fmt.Sprintf("%s[%d]", path, i), violations)
+        }
+    }
+    return v
+}
+
+func v1String(kind int, v interface{}) (string, string) {
+    switch kind {
+    case v2Bool:
+        if b, ok := v.(bool); ok {
+            return strconv.FormatBool(b), ""
+        }
+        return "", "must be a boolean"
+    case v2Int:
+        if n, ok := v.(json.Number); ok {
+            if _, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+                return string(n), ""
+            }
+        }
+        return "", "must be an integer"
+    case v2Number:
+        if n, ok := v.(json.Number); ok {
+            return string(n), ""
+        }
+        return "", "must be a number"
+    }
+
+    list, ok := v.([]interface{})
+    if !ok {
+        return "", "must be an array"
+    }
+    items := make([]string, len(list))
+    for i, item := range list {
+        var err string
+        if kind == v2IntList {
+            items[i], err = v1String(v2Int, item)
+        } else if s, ok := item.(string); ok {
+            items[i] = s
+        } else {
+            err = "must be a string"
+        }
+        if err != "" {
+            return "", fmt.Sprintf("[%d] %s", i, err)
+        }
+    }
+    return strings.Join(items, ","), ""
+}
+
+// toV2 turns the string fields of a decoded v1 body of model m into typed
+// ones, values that do not parse are left alone
+func toV2(v interface{}, m *v2Model) interface{} {
+    if m == nil {
+        return v
+    }
+    switch t := v.(type) {
+    case map[string]interface{}:
+        if m.pick != nil {
+            return toV2(v, m.pick(t))
+        }
+        for key, value := range t {
+            kind, typed := m.fields[key]
+            s, isString := value.(string)
+            if !typed || !isString {
+                if nested, ok := m.nested[key]; ok {
+                    t[key] = toV2(value, nested)
+                }
+                continue
+            }
+            t[key] = v2Value(kind, s)
+        }
+    case []interface{}:
+        for i, value := range t {
+            t[i] = toV2(value, m)
+        }
+    }
+    return v
+}
+
+func v2Value(kind int, s string) interface{} {
+    switch kind {
+    case v2Bool:
+        if b, err := strconv.ParseBool(s); err == nil {
+            return b
+        }
+    case v2Int, v2Number:
+        if s == "" {
+            // e.g. the RTTs of a ping nothing answered
+            return nil
+        }
+        if _, err := strconv.ParseFloat(s, 64); err == nil {
+            return json.Number(s)
+        }
+    case v2List, v2IntList:
+        list := []interface{}{}
+        if s == "" {
+            return list
+        }
+        for _, item := range strings.Split(s, ",") {
+            if kind == v2List {
+                list = append(list, item)
+            } else if _, err := strconv.Atoi(item); err == nil {
+                list = append(list, json.Number(item))
+            } else {
+                return s
+            }
+        }
+        return list
+    }
+    return s
+}
+
+func decodeJSONNumbers(data []byte) (v interface{}, err error) {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    err = dec.Decode(&v)
+    return
+}
+
+func v2ResponseBody(data []byte, m *v2Model) []byte {
+    if m == nil {
+        return data
+    }
+    v, err := decodeJSONNumbers(data)
+    if err != nil {
+        return data
+    }
+    b, err := json.Marshal(toV2(v, m))
+    if err != nil {
+        return data
+    }
+    return b
+}
+
+// How v2ResponseWriter converts a response
+const (
+    v2Undecided = iota
+    v2Through
+    v2Lines
+    v2Elements
+    v2Whole
+    v2Error
+)
+
+// v2ResponseWriter converts what a v1 handler writes as it comes: NDJSON one
+// line at a time and JSON arrays one element at a time. Other JSON bodies are
+// converted once whole, errors mapped onto the catalog once whole, and bodies
+// without typed fields go straight through.
+type v2ResponseWriter struct {
+    w     http.ResponseWriter
+    model *v2Model
+    code  int
+    mode  int
+    body  bytes.Buffer
+
+    // Where the scan of a JSON array is at
+    scanned  int
+    depth    int
+    inString bool
+    escaped  bool
+}
+
+func (c *v2ResponseWriter) Header() http.Header {
+    return c.w.Header()
+}
+
+func (c *v2ResponseWriter) WriteHeader(statusCode int) {
+    if c.code != 0 {
+        return
+    }
+    c.code = statusCode
+    if statusCode >= http.StatusBadRequest {
+        c.mode = v2Error
+        return
+    }
+    contentType := c.w.Header().Get("Content-Type")
+    switch {
+    case c.model == nil:
+        c.mode = v2Through
+    case strings.HasPrefix(contentType, NDJSON_CONTENT_TYPE):
+        c.mode = v2Lines
+    case !strings.HasPrefix(contentType, "application/json"):
+        c.mode = v2Through
+    }
+    c.w.WriteHeader(statusCode)
+}
+
+func (c *v2ResponseWriter) Write(b []byte) (int, error) {
+    if c.code == 0 {
+        c.WriteHeader(http.StatusOK)
+    }
+    if c.mode == v2Through {
+        return c.w.Write(b)
+    }
+    c.body.Write(b)
+
+    if c.mode == v2Undecided {
+        // A body starting with [ is a list, its elements go out one by one
+        data := bytes.TrimLeft(c.body.Bytes(), " \t\r\n")
+        if len(data) == 0 {
+            return len(b), nil
+        }
+        c.body.Next(c.body.Len() - len(data))
+        if data[0] == '[' {
+            c.mode = v2Elements
+        } else {
+            c.mode = v2Whole
+        }
+    }
+
+    switch c.mode {
+    case v2Lines:
+        return len(b), c.writeLines(false)
+    case v2Elements:
+        return len(b), c.writeElements()
+    }
+    return len(b), nil
+}
+
+func (c *v2ResponseWriter) writeLines(last bool) error {
+    for {
+        data := c.body.Bytes()
+        n := bytes.IndexByte(data, '\n')
+        if n < 0 {
+            if !last || len(data) == 0 {
+                return nil
+            }
+            n = len(data)
+        }
+        line := v2ResponseBody(data[:n], c.model)
+        c.body.Next(n + 1)
+        if _, err := c.w.Write(append(line, '\n')); err != nil {
+            return err
+        }
+    }
+}
+
+// writeElements converts the elements of the array in the body that are
+// whole. The body starts with the [ or , in front of the next element.
+func (c *v2ResponseWriter) writeElements() error {
+    data := c.body.Bytes()
+    for ; c.scanned < len(data); c.scanned++ {
+        b := data[c.scanned]
+        if c.inString {
+            switch {
+            case c.escaped:
+                c.escaped = false
+            case b == '\\':
+                c.escaped = true
+            case b == '"':
+                c.inString = false
+            }
+            continue
+        }
+        switch b {
+        case '"':
+            c.inString = true
+        case '[', '{':
+            c.depth++
+        case ']', '}':
+            c.depth--
+        }
+        end := b == ']' && c.depth == 0
+        if !end && (b != ',' || c.depth != 1) {
+            continue
+        }
+
+        out := []byte{}
+        if element := bytes.TrimSpace(data[1:c.scanned]); len(element) > 0 || data[0] == '[' {
+            out = append(out, data[0])
+            out = append(out, v2ResponseBody(element, c.model)...)
+        }
+        if end {
+            // What follows the array, if anything, goes out as it is
+            out = append(out, data[c.scanned:]...)
+            c.body.Reset()
+            c.mode = v2Through
+            _, err := c.w.Write(out)
+            return err
+        }
+        if _, err := c.w.Write(out); err != nil {
+            return err
+        }
+        c.body.Next(c.scanned)
+        data = c.body.Bytes()
+        c.scanned = 0
+    }
+    return nil
+}
+
+func (c *v2ResponseWriter) Flush() {
+    if f, ok := c.w.(http.Flusher); ok && (c.mode == v2Through || c.mode == v2Lines || c.mode == v2Elements) {
+        f.Flush()
+    }
+}
+
+func (c *v2ResponseWriter) Unwrap() http.ResponseWriter {
+    return c.w
+}
+
+func (c *v2ResponseWriter) finish() {
+    if c.code == 0 {
+        c.WriteHeader(http.StatusOK)
+    }
+    switch c.mode {
+    case v2Lines:
+        c.writeLines(true)
+    case v2Whole:
+        c.w.Write(v2ResponseBody(c.body.Bytes(), c.model))
+    case v2Undecided, v2Elements:
+        // Nothing but blanks, or an array cut short
+        c.w.Write(c.body.Bytes())
+    case v2Error:
+        var e ErrorModel
+        if json.Unmarshal(c.body.Bytes(), &e) != nil {
+            e.Error.Message = http.StatusText(c.code)
+        }
+        code := v2ErrorCode(e.Error, c.code)
+        e.Error.Code = code.Status
+        e.Error.SubCode = &code.SubCode
+        e.Error.Reason = code.Reason
+        c.w.Header().Set("Content-Type", JSON_CONTENT_TYPE)
+        WriteRequestResponse(c.w, e, code.Status)
+    }
+}
+
+// V2Middleware serves a v1 handler as part of the v2 API, name is that of
+// its v1 route
+func V2Middleware(inner http.Handler, name string) http.Handler {
+    models := v2RouteModels[name]
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        r = r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, 2))
+        response := models.response
+        if dryRun, _ := strconv.ParseBool(r.URL.Query().Get(DRY_RUN_QUERY_PARAM)); dryRun && !dryRunSelfHandled[name] {
+            // DryRunMiddleware answers with what the handler would have
+            response = &v2Model{nested: map[string]*v2Model{"response": response}}
+        }
+
+        if r.Body != nil {
+            body, err := ioutil.ReadAll(r.Body)
+            if e, ok := err.(*BodyTooLargeError); ok {
+                writeBodyTooLarge(w, e.Limit, true)
+                return
+            }
+            if err != nil {
+                writeV2Error(w, MALFORMED_REQUEST, "Malformed arguments for API call", []string{}, "Could not read the body")
+                return
+            }
+            if len(bytes.TrimSpace(body)) > 0 {
+                v, err := decodeJSONNumbers(body)
+                if err != nil {
+                    writeV2Error(w, MALFORMED_REQUEST, "Malformed arguments for API call", []string{}, "Invalid character in JSON")
+                    return
+                }
+                violations := []openAPIViolation{}
+                v = toV1(v, models.request, "", &violations)
+                if len(violations) > 0 {
+                    fields, details := openAPIFields(violations)
+                    writeV2Error(w, INVALID_ARGUMENT, "Malformed arguments for API call", fields, details)
+                    return
+                }
+                if body, err = json.Marshal(v); err != nil {
+                    writeV2Error(w, INTERNAL_ERROR, "Internal service error", []string{}, "")
+                    return
+                }
+            }
+            r.Body = ioutil.NopCloser(bytes.NewReader(body))
+            r.ContentLength = int64(len(body))
+        }
+
+        c := &v2ResponseWriter{w: w, model: response}
+        inner.ServeHTTP(c, r)
+        c.finish()
+    })
+}
+
+func V2Index(w http.ResponseWriter, r *http.Request) {
+    fmt.Fprintf(w, "Sonic MSEE Restful API v2!")
+}
+
+func V2ErrorCodesGet(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", JSON_CONTENT_TYPE)
+    WriteRequestResponse(w, ErrorCodeCatalog, http.StatusOK)
+}
+
+// v1 routes the v2 API does not serve
+var v2Skipped = map[string]bool{
+    "Index": true,
+    "OpenAPIGet": true,
+}
+
+// v2Routes are the v1 routes moved to /v2 and the routes only v2 has
+func v2Routes() Routes {
+    v2 := Routes{
+        Route{"V2Index", "GET", "/v2/", V2Index},
+        Route{"V2ErrorCodesGet", "GET", "/v2/errors", V2ErrorCodesGet},
+    }
+    for _, route := range routes {
+        if v2Skipped[route.Name] {
+            continue
+        }
+        route.Pattern = "/v2" + strings.TrimPrefix(route.Pattern, "/v1")
+        v2 = append(v2, route)
+    }
+    return v2
+}
@@ -0,0 +1,123 @@
+package restapi
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestV2Fields(t *testing.T) {
+    tests := []struct {
+        model  *v2Model
+        v2     string
+        v1     string
+        fields []string
+    }{
+        {v2VnetModel, `{"vnid": 1, "advertise_prefix": true}`, `{"advertise_prefix":"true","vnid":1}`, nil},
+        {v2RouteModel, `[{"nexthop": ["1.1.1.1", "1.1.1.2"], "weight": [1, 2], "persistent": false}]`, `[{"nexthop":"1.1.1.1,1.1.1.2","persistent":"false","weight":"1,2"}]`, nil},
+        {v2PingRequestModel, `{"ip_addr": "1.1.1.1", "count": 5}`, `{"count":"5","ip_addr":"1.1.1.1"}`, nil},
+        // Only the fields of the model are typed
+        {v2VnetModel, `{"vnid": 1, "count": "5", "weight": "1"}`, `{"count":"5","vnid":1,"weight":"1"}`, nil},
+        {v2SnapshotModel, `{"vnets": [{"vnet_id": "vnet-1", "attr": {"advertise_prefix": true}}], "vnet_routes": [{"vnet_id": "vnet-1", "routes": [{"nexthop": ["1.1.1.1"]}]}]}`,
+            `{"vnet_routes":[{"routes":[{"nexthop":"1.1.1.1"}],"vnet_id":"vnet-1"}],"vnets":[{"attr":{"advertise_prefix":"true"},"vnet_id":"vnet-1"}]}`, nil},
+        {v2SnapshotRestoreReturnModel, `{"planned": [{"method": "PATCH", "path": "/v1/config/vrouter/vnet-1/routes", "body": [{"nexthop": ["1.1.1.1"]}]}, {"method": "POST", "path": "/v1/config/vrouter/vnet-1", "body": {"advertise_prefix": false}}]}`,
+            `{"planned":[{"body":[{"nexthop":"1.1.1.1"}],"method":"PATCH","path":"/v1/config/vrouter/vnet-1/routes"},{"body":{"advertise_prefix":"false"},"method":"POST","path":"/v1/config/vrouter/vnet-1"}]}`, nil},
+        {v2VnetModel, `{"advertise_prefix": "true"}`, ``, []string{"advertise_prefix"}},
+        {v2RouteModel, `[{"nexthop": "1.1.1.1", "weight": [1.5]}]`, ``, []string{"[0].nexthop", "[0].weight"}},
+        {v2PingRequestModel, `{"count": 1.5}`, ``, []string{"count"}},
+    }
+
+    for _, test := range tests {
+        v, err := decodeJSONNumbers([]byte(test.v2))
+        if err != nil {
+            t.Fatal(err)
+        }
+        violations := []openAPIViolation{}
+        v = toV1(v, test.model, "", &violations)
+        if len(violations) != len(test.fields) {
+            t.Errorf("%s: got violations %v, want %v", test.v2, violations, test.fields)
+            continue
+        }
+        for i := range violations {
+            if violations[i].Field != test.fields[i] {
+                t.Errorf("%s: got violations %v, want %v", test.v2, violations, test.fields)
+            }
+        }
+        if len(violations) > 0 {
+            continue
+        }
+        b, _ := json.Marshal(v)
+        if string(b) != test.v1 {
+            t.Errorf("%s: got %s, want %s", test.v2, b, test.v1)
+        }
+
+        // And back, the v1 answer reads as the v2 body
+        back := v2ResponseBody(b, test.model)
+        want, _ := decodeJSONNumbers([]byte(test.v2))
+        wantb, _ := json.Marshal(want)
+        if string(back) != string(wantb) {
+            t.Errorf("%s: got %s back, want %s", test.v1, back, wantb)
+        }
+    }
+}
+
+func TestV2ErrorCode(t *testing.T) {
+    exists := RESRC_EXISTS
+    delete_dep := DELETE_DEP
+    tests := []struct {
+        e        ErrorInner
+        code     int
+        sub_code int
+        status   int
+    }{
+        {ErrorInner{SubCode: &exists}, 409, RESRC_EXISTS, 409},
+        {ErrorInner{SubCode: &delete_dep}, 409, DELETE_DEP, 409},
+        {ErrorInner{Details: "Invalid character in JSON"}, 400, MALFORMED_REQUEST, 400},
+        {ErrorInner{Fields: []string{"vnid"}}, 400, INVALID_ARGUMENT, 422},
+        {ErrorInner{}, 404, NOT_FOUND, 404},
+        {ErrorInner{}, 504, BACKEND_UNAVAIL, 504},
+        {ErrorInner{}, 500, INTERNAL_ERROR, 500},
+    }
+    for _, test := range tests {
+        c := v2ErrorCode(test.e, test.code)
+        if c.SubCode != test.sub_code || c.Status != test.status {
+            t.Errorf("%d %+v: got %+v, want sub-code %d status %d", test.code, test.e, c, test.sub_code, test.status)
+        }
+    }
+}
+
+// The elements of a list are converted as they are written, split anywhere
+func TestV2ResponseWriterElements(t *testing.T) {
+    first := `{"ip_prefix": "10.1.1.0/24", "nexthop": "1.1.1.1,1.1.1.2", "description": "a \"[quoted]\", list"}`
+    second := `{"ip_prefix": "10.1.2.0/24", "weight": "1,2", "nexthop": ""}`
+
+    w := httptest.NewRecorder()
+    w.Header().Set("Content-Type", JSON_CONTENT_TYPE)
+    c := &v2ResponseWriter{w: w, model: v2RouteModel}
+    c.WriteHeader(http.StatusOK)
+    for _, chunk := range []string{" [", first[:20], first[20:], "\n,"} {
+        c.Write([]byte(chunk))
+    }
+    want := `[{"description":"a \"[quoted]\", list","ip_prefix":"10.1.1.0/24","nexthop":["1.1.1.1","1.1.1.2"]}`
+    if w.Body.String() != want {
+        t.Fatalf("got %s once the first element is written, want %s", w.Body.String(), want)
+    }
+    for i := range second {
+        c.Write([]byte{second[i]})
+    }
+    c.Write([]byte("\n]"))
+    c.finish()
+
+    var got []map[string]interface{}
+    if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+        t.Fatalf("decoding %s: %v", w.Body.String(), err)
+    }
+    if len(got) != 2 {
+        t.Fatalf("got %s, want 2 elements", w.Body.String())
+    }
+    b, _ := json.Marshal(got[1])
+    if string(b) != `{"ip_prefix":"10.1.2.0/24","nexthop":[],"weight":[1,2]}` {
+        t.Errorf("got %s for the second element", b)
+    }
+}
@@ -25,83 +25,235 @@ import (
 
 const CERT_MONITOR_FREQUENCY = 3600 * time.Second
 
-func StartHttpServer(handler http.Handler) {
-    log.Printf("info: http endpoint started")
-    log.Fatal(http.ListenAndServe(":8090", handler))
+// Messages to serve
+const (
+    SERVER_STOP = iota
+    // The certs rolled, the https endpoint restarts with them
+    SERVER_RESTART_HTTPS
+)
+
+// Flags re-read from -config on SIGHUP that the endpoints are set up with,
+// all of them restart when one of these changes
+var endpointFlags = []string{
+    "enablehttp",
+    "enablehttps",
+    "enableunix",
+    "httpaddr",
+    "httpsaddr",
+    "unixsocket",
+    "clientcert",
+    "servercert",
+    "serverkey",
+    "httpreadheadertimeout",
+    "httpreadtimeout",
+    "httpwritetimeout",
+    "httpidletimeout",
 }
 
-func StartHttpsServer(handler http.Handler, messenger <-chan int, wgroup *sync.WaitGroup) {
-    defer wgroup.Done()
-    for {
-        clientCert, err := ioutil.ReadFile(*sw.ClientCertFlag)
-        if err != nil {
-            log.Fatalf("error: couldn't open client cert file, %s", err)
-        }
-        clientCertPool := x509.NewCertPool()
-        clientCertPool.AppendCertsFromPEM(clientCert)
-
-        // Setup HTTPS client cert the server trust and validation policy
-        tlsConfig := &tls.Config{
-            ClientCAs: clientCertPool,
-            // NoClientCert
-            // RequestClientCert
-            // RequireAnyClientCert
-            // VerifyClientCertIfGiven
-            // RequireAndVerifyClientCert
-            ClientAuth: tls.RequireAndVerifyClientCert,
-            MinVersion: tls.VersionTLS12,
-        }
-        tlsConfig.BuildNameToCertificate()
+func NewHttpServer(handler http.Handler) *http.Server {
+    server := &http.Server{
+        Addr:    *sw.HttpAddrFlag,
+        Handler: handler,
+    }
+    sw.ConfigureServer(server)
+    return server
+}
 
-        server := &http.Server{
-            Addr:      ":8081",
-            Handler:   handler,
-            TLSConfig: tlsConfig,
-        }
+func StartHttpServer(server *http.Server) {
+    log.Printf("info: http endpoint started on %s", server.Addr)
+    if err := server.ListenAndServe(); err != http.ErrServerClosed {
+        log.Fatal(err)
+    }
+}
+
+func NewHttpsServer(handler http.Handler) *http.Server {
+    clientCert, err := ioutil.ReadFile(*sw.ClientCertFlag)
+    if err != nil {
+        log.Fatalf("error: couldn't open client cert file, %s", err)
+    }
+    clientCertPool := x509.NewCertPool()
+    clientCertPool.AppendCertsFromPEM(clientCert)
+
+    // Setup HTTPS client cert the server trust and validation policy
+    tlsConfig := &tls.Config{
+        ClientCAs: clientCertPool,
+        // NoClientCert
+        // RequestClientCert
+        // RequireAnyClientCert
+        // VerifyClientCertIfGiven
+        // RequireAndVerifyClientCert
+        ClientAuth: tls.RequireAndVerifyClientCert,
+        MinVersion: tls.VersionTLS12,
+    }
+    tlsConfig.BuildNameToCertificate()
+
+    server := &http.Server{
+        Addr:      *sw.HttpsAddrFlag,
+        Handler:   handler,
+        TLSConfig: tlsConfig,
+    }
+    sw.ConfigureServer(server)
+    return server
+}
+
+func StartHttpsServer(server *http.Server) {
+    log.Printf("info: https endpoint started on %s", server.Addr)
+    if err := server.ListenAndServeTLS(*sw.ServerCertFlag, *sw.ServerKeyFlag); err != http.ErrServerClosed {
+        log.Println(err)
+    }
+}
 
-        log.Printf("info: https endpoint started")
+func NewUnixServer(handler http.Handler) *http.Server {
+    server := &http.Server{
+        Addr:    *sw.UnixSocketFlag,
+        Handler: handler,
+    }
+    sw.ConfigureUnixServer(server)
+    return server
+}
 
-        // Listening should happen in a go-routine to prevent blocking
-        go func() {
-            if err := server.ListenAndServeTLS(*sw.ServerCertFlag, *sw.ServerKeyFlag); err != nil {
-                log.Println(err)
+func StartUnixServer(server *http.Server) {
+    ln, err := sw.ListenUnix(server.Addr)
+    if err != nil {
+        log.Fatalf("error: unix socket endpoint could not listen on %s, %s", server.Addr, err)
+    }
+    log.Printf("info: unix socket endpoint started on %s", server.Addr)
+    if err := server.Serve(ln); err != http.ErrServerClosed {
+        log.Fatal(err)
+    }
+}
+
+// StopServers stops the servers accepting connections and waits up to
+// -shutdowntimeout for the requests they serve
+func StopServers(servers ...*http.Server) {
+    ctx, cancel := context.WithTimeout(context.Background(), *sw.ShutdownTimeoutFlag)
+    defer cancel()
+
+    var wgroup sync.WaitGroup
+    for _, server := range servers {
+        if server == nil {
+            continue
+        }
+        wgroup.Add(1)
+        go func(server *http.Server) {
+            defer wgroup.Done()
+            if err := server.Shutdown(ctx); err != nil {
+                log.Printf("warning: endpoint %s did not drain within %s, %v", server.Addr, *sw.ShutdownTimeoutFlag, err)
+            } else {
+                log.Printf("info: endpoint %s shut down", server.Addr)
             }
-        }()
-
-        value := <-messenger
-        log.Printf("info: HTTPS Signal received. Shutting down...")
-        if err := server.Shutdown(context.Background()); err != nil {
-            log.Printf("trace: HTTPS server Shutdown: %v", err)
-        } else {
-            log.Printf("info: HTTPS Server shutdown successful!")
+        }(server)
+    }
+    wgroup.Wait()
+}
+
+// serve runs the endpoints until told to stop, restarting them when the
+// certs roll or the config is reloaded
+func serve(handler http.Handler, messenger chan int, reload <-chan bool) {
+    var httpServer, httpsServer, unixServer *http.Server
+    var monitoring sync.Once
+    start := func() {
+        httpServer, httpsServer, unixServer = nil, nil, nil
+        if (*sw.HttpFlag) {
+            httpServer = NewHttpServer(handler)
+            go StartHttpServer(httpServer)
         }
-        switch value {
-        case 0:
-            // Signal from signal_handler
-            log.Printf("info: Terminating...")
+        if (*sw.HttpsFlag) {
+            httpsServer = NewHttpsServer(handler)
+            go StartHttpsServer(httpsServer)
+            // Also when the https endpoint is only turned on by a reload
             if (!*sw.SystemTestFlag) {
-                os.Exit(0)
+                monitoring.Do(func() { go monitor_certs(messenger) })
             }
-            return
+        }
+        if (*sw.UnixFlag) {
+            unixServer = NewUnixServer(handler)
+            go StartUnixServer(unixServer)
+        }
+    }
+    start()
+
+    for {
+        select {
+        case value := <-messenger:
+            switch value {
+            case SERVER_STOP:
+                log.Printf("info: Signal received. Draining endpoints...")
+                StopServers(httpServer, httpsServer, unixServer)
+                // Requests still waiting are refused, the one running finishes
+                sw.StopServing()
+                sw.WaitForWrites()
+                return
+            case SERVER_RESTART_HTTPS:
+                if httpsServer == nil {
+                    // Turned off since
+                    continue
+                }
+                log.Printf("info: Restarting https endpoint...")
+                StopServers(httpsServer)
+                httpsServer = NewHttpsServer(handler)
+                go StartHttpsServer(httpsServer)
+            }
+        case <-reload:
+            if (!*sw.HttpFlag && !*sw.HttpsFlag && !*sw.UnixFlag) {
+                log.Printf("error: the http, https and unix socket endpoints are all disabled, keeping those running")
+                continue
+            }
+            log.Printf("info: Config reloaded. Restarting endpoints...")
+            StopServers(httpServer, httpsServer, unixServer)
+            start()
         }
     }
 }
 
-func signal_handler(messenger chan<- int, wgroup *sync.WaitGroup) {
-    defer wgroup.Done()
+// reloadOnChange applies the flags iniflags re-reads from -config on SIGHUP
+func reloadOnChange(reload chan<- bool) {
+    iniflags.OnFlagChange("loglevel", sw.ReloadLogging)
+    iniflags.OnFlagChange("logfile", sw.ReloadLogging)
+    iniflags.OnFlagChange("clientcertcommonname", sw.LoadTrustedCommonNames)
+    for _, name := range []string{"unixsocketuids", "unixsocketgids"} {
+        iniflags.OnFlagChange(name, func() {
+            if err := sw.LoadUnixSocketPeers(); err != nil {
+                log.Printf("error: -unixsocketuids, -unixsocketgids: %v, keeping the peers allowed", err)
+            }
+        })
+    }
+    for _, name := range []string{"adminclientcertcommonname", "adminunixsocketuids"} {
+        iniflags.OnFlagChange(name, func() {
+            if err := sw.LoadAdminIdentities(); err != nil {
+                log.Printf("error: -adminclientcertcommonname, -adminunixsocketuids: %v, keeping the admins allowed", err)
+            }
+        })
+    }
+    iniflags.OnFlagChange("ratelimits", func() {
+        if err := sw.InitRateLimits(); err != nil {
+            log.Printf("error: -ratelimits: %v, keeping the limits in use", err)
+        }
+    })
+    for _, name := range endpointFlags {
+        iniflags.OnFlagChange(name, func() {
+            // One restart for all the flags changed at once
+            select {
+            case reload <- true:
+            default:
+            }
+        })
+    }
+}
+
+func signal_handler(messenger chan<- int) {
     sigchannel := make(chan os.Signal, 1)
     signal.Notify(sigchannel,
         syscall.SIGTERM,
         syscall.SIGQUIT)
 
     <-sigchannel
-    messenger <- 0
+    messenger <- SERVER_STOP
     log.Printf("info: Signal Handler returning...")
     return
 }
 
-func monitor_certs(messenger chan<- int, wgroup *sync.WaitGroup) {
-    defer wgroup.Done()
+func monitor_certs(messenger chan<- int) {
     client_cert_finfo, _ := os.Lstat(*sw.ClientCertFlag)
     prev_client_cert_mtime := client_cert_finfo.ModTime()
     log.Printf("trace: Last modified time of %s is %d", client_cert_finfo.Name(), prev_client_cert_mtime.Unix())
@@ -147,7 +299,7 @@ func monitor_certs(messenger chan<- int, wgroup *sync.WaitGroup) {
 
         if reload == true {
             log.Printf("info: Certs have rolled! Reload needed!")
-            messenger <- 1
+            messenger <- SERVER_RESTART_HTTPS
         }
         time.Sleep(CERT_MONITOR_FREQUENCY)
     }
@@ -157,34 +309,34 @@ func main() {
     iniflags.Parse()
 
     sw.InitLogging()
-    var wgroup sync.WaitGroup
     var messenger = make(chan int, 1)
+    var reload = make(chan bool, 1)
 
     log.Printf("info: server started")
 
     sw.Initialise()
-    router := sw.NewRouter()
-
-    if (!*sw.HttpFlag && !*sw.HttpsFlag) {
-        log.Fatal("Both http and http endpoints are disabled.")
+    if err := sw.InitRateLimits(); err != nil {
+        log.Fatalf("error: -ratelimits: %v", err)
+    }
+    if err := sw.LoadUnixSocketPeers(); err != nil {
+        log.Fatalf("error: -unixsocketuids, -unixsocketgids: %v", err)
     }
+    if err := sw.LoadAdminIdentities(); err != nil {
+        log.Fatalf("error: -adminclientcertcommonname, -adminunixsocketuids: %v", err)
+    }
+    router := sw.NewRouter()
 
-    if (*sw.HttpFlag) {
-        go StartHttpServer(router)
+    if (!*sw.HttpFlag && !*sw.HttpsFlag && !*sw.UnixFlag) {
+        log.Fatal("The http, https and unix socket endpoints are all disabled.")
     }
 
-    if (*sw.HttpsFlag) {
-        wgroup.Add(1)
-        go StartHttpsServer(router, messenger, &wgroup)
-        
-        if (!*sw.SystemTestFlag) {
-            wgroup.Add(1)
-            go monitor_certs(messenger, &wgroup)
-        }
+    if (*sw.MaintenanceFileFlag != "") {
+        go sw.WatchMaintenanceFile()
     }
 
-    wgroup.Add(1)
-    go signal_handler(messenger, &wgroup)
+    reloadOnChange(reload)
+    go signal_handler(messenger)
 
-    wgroup.Wait()
+    serve(router, messenger, reload)
+    log.Printf("info: Terminating...")
 }
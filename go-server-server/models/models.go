@@ -0,0 +1,713 @@
+// Package models holds the request and response models of the REST API and
+// the checks run on request bodies. It depends on nothing but the standard
+// library, so that clients can share it with the server.
+package models
+
+import (
+    "encoding/json"
+    "net"
+    "strconv"
+    "strings"
+)
+
+// Query parameter that picks the namespace of a request
+const NAMESPACE_PARAM string = "namespace"
+
+const IDEMPOTENCY_KEY_HEADER string = "Idempotency-Key"
+
+const MAINTENANCE_MODE_ON string  = "maintenance"
+const MAINTENANCE_MODE_OFF string = "normal"
+
+const MAX_VLAN_TAG int = 4095
+
+type HeartbeatReturnModel struct {
+    ServerVersion   string                              `json:"server_version,omitempty"`
+    ResetGUID       string                              `json:"reset_GUID,omitempty"`
+    ResetTime       string                              `json:"reset_time,omitempty"`
+    RoutesAvailable int                                 `json:"routes_available"`
+    Backends        map[string]ThriftBackendStatusModel `json:"backends,omitempty"`
+    Namespaces      []string                            `json:"namespaces,omitempty"`
+    Maintenance     MaintenanceModel                    `json:"maintenance"`
+}
+
+type ThriftBackendStatusModel struct {
+    Address   string `json:"address"`
+    Reachable bool   `json:"reachable"`
+    Circuit   string `json:"circuit"`
+    Error     string `json:"error,omitempty"`
+}
+
+type HealthCheckModel struct {
+    Status  string `json:"status"`
+    Message string `json:"message,omitempty"`
+}
+
+type HealthModel struct {
+    Status string                      `json:"status"`
+    Checks map[string]HealthCheckModel `json:"checks,omitempty"`
+}
+
+type ConfigResetStatusModel struct {
+    ResetStatus      string `json:"reset_status,omitempty"`
+}
+
+type BgpProfileModel struct {
+    CommunityId  string `json:"community_id"`
+}
+
+type RouteExpiryTimeModel struct {
+    Time int `json:"time"`
+}
+
+type MaintenanceModel struct {
+    Mode       string `json:"mode"`
+    Reason     string `json:"reason,omitempty"`
+    RetryAfter int    `json:"retry_after,omitempty"`
+    Trigger    string `json:"trigger,omitempty"`
+}
+
+type RouteModel struct {
+    Cmd            string `json:"cmd,omitempty"`
+    IPPrefix       string `json:"ip_prefix"`
+    IfName         string `json:"ifname,omitempty"`
+    NextHopType    string `json:"nexthop_type,omitempty"`
+    NextHop        string `json:"nexthop"`
+    NextHopMonitor string `json:"nexthop_monitor,omitempty"`
+    Primary        string `json:"primary,omitempty"`
+    AdvPrefix      string `json:"adv_prefix,omitempty"`
+    Monitoring     string `json:"monitoring,omitempty"`
+    MACAddress     string `json:"mac_address,omitempty"`
+    Vnid           int    `json:"vnid,omitempty"`
+    Weight         string `json:"weight,omitempty"`
+    Profile        string `json:"profile,omitempty"`
+    Persistent     string `json:"persistent,omitempty"`
+    Error_code     int    `json:"error_code,omitempty"`
+    Error_msg      string `json:"error_msg,omitempty"`
+}
+
+type RouteReturnModel struct {
+    Failed  []RouteModel `json:"failed,omitempty"`
+}
+
+type InterfaceModel struct {
+    AdminState string `json:"admin-state"`
+}
+
+type InterfaceReturnModel struct {
+    Port string         `json:"port"`
+    Attr InterfaceModel `json:"attr"`
+}
+
+type MseeCountersReturnModel struct {
+    Group    string                      `json:"group"`
+    Counters map[string]map[string]int64 `json:"counters"`
+}
+
+type MseeStatisticsReturnModel struct {
+    Group      string                      `json:"group"`
+    Statistics map[string]map[string]int64 `json:"statistics"`
+}
+
+type MseeHistogramReturnModel struct {
+    Histogram map[string]map[string]float64 `json:"histogram"`
+}
+
+type ArpIPModel struct {
+    IPAddr string `json:"ip_addr"`
+}
+
+type ArpInterfaceTagModel struct {
+    IfName string `json:"if_name"`
+    Stag   int    `json:"stag"`
+    Ctag   int    `json:"ctag"`
+}
+
+type ArpResolveRequestModel struct {
+    IPAddr     string                 `json:"ip_addr"`
+    Interfaces []ArpInterfaceTagModel `json:"interfaces"`
+}
+
+type ArpResolveReturnModel struct {
+    IPAddr     string                `json:"ip_addr"`
+    Found      bool                  `json:"found"`
+    MACAddress string                `json:"mac_address,omitempty"`
+    Interface  *ArpInterfaceTagModel `json:"interface,omitempty"`
+}
+
+type VlanModel struct {
+    Vnet_id  string  `json:"vnet_id,omitempty"`
+    IPPrefix string  `json:"ip_prefix,omitempty"`
+}
+
+type VlanReturnModel struct {
+    VlanID    int         `json:"vlan_id"`
+    Attr      VlanModel   `json:"attr"`
+}
+
+type VlansModel struct {
+    VlanID    int     `json:"vlan_id"`
+    IPPrefix  string  `json:"ip_prefix,omitempty"`
+    Vnet_id   string  `json:"vnet_id,omitempty"`
+}
+
+type VlansReturnModel struct {
+    Attr      []VlansModel  `json:"attr"`
+}
+
+type VlanMemberModel struct {
+    Tagging   string      `json:"tagging_mode"`
+}
+
+type VlanMemberReturnModel struct {
+    VlanID    int              `json:"vlan_id"`
+    If_name   string           `json:"if_name"`
+    Attr      VlanMemberModel  `json:"attr"`
+}
+
+type VlanMembersModel struct {
+    If_name   string           `json:"if_name"`
+    Tagging   string           `json:"tagging_mode"`
+}
+
+type VlanMembersReturnModel struct {
+    VlanID    int                 `json:"vlan_id"`
+    Attr      []VlanMembersModel  `json:"attr"`
+}
+
+type VlanMembersAllReturnModel struct {
+    Attr      []VlanMembersReturnModel  `json:"attr"`
+}
+
+type VlanNeighborReturnModel struct {
+    VlanID    int              `json:"vlan_id"`
+    Ip_addr   string           `json:"ip_addr"`
+}
+
+type VlanNeighborsModel struct {
+    Ip_addr   string           `json:"ip_addr"`
+}
+
+type VlanNeighborsReturnModel struct {
+    VlanID    int                  `json:"vlan_id"`
+    Attr      []VlanNeighborsModel `json:"attr"`
+}
+
+type VlansPerVnetReturnModel struct {
+    Vnet_id   string               `json:"vnet_id,omitempty"`
+    Attr      []VlansPerVnetModel  `json:"attr"`
+}
+
+type VlansPerVnetModel struct {
+    VlanID    int              `json:"vlan_id"`
+    IPPrefix  string           `json:"ip_prefix,omitempty"`
+
+}
+
+type TunnelDecapModel struct {
+    IPAddr string `json:"ip_addr"`
+}
+
+type TunnelDecapReturnModel struct {
+    TunnelType string           `json:"tunnel_type"`
+    Attr       TunnelDecapModel `json:"attr"`
+}
+
+type VnetModel struct {
+    Vnid        int     `json:"vnid"`
+    AdvPrefix   string  `json:"advertise_prefix,omitempty"`
+    OverlayDmac string  `json:"overlay_dmac,omitempty"`
+}
+
+type VnetReturnModel struct {
+    VnetName string   `json:"vnet_id"`
+    Attr VnetModel    `json:"attr"`
+}
+
+type PingRequestModel struct {
+    IpAddress string   `json:"ip_addr"`
+    VnetId string   `json:"vnet_id"`
+    Count string   `json:"count"`
+}
+
+type PingReturnModel struct {
+    PacketsTransmitted string   `json:"packets_transmitted"`
+    PacketsReceived string   `json:"packets_received"`
+    MinRTT string   `json:"min_rtt"`
+    MaxRTT string   `json:"max_rtt"`
+    AvgRTT string   `json:"avg_rtt"`
+}
+
+type BgpProfileReturnModel struct {
+    ProfileName string          `json:"profile_name"`
+    Attr        BgpProfileModel `json:"attr"`
+}
+
+type SnapshotVnetRoutesModel struct {
+    VnetName string       `json:"vnet_id"`
+    Routes   []RouteModel `json:"routes"`
+}
+
+type SnapshotVrfRoutesModel struct {
+    VrfID  string       `json:"vrf_id"`
+    Routes []RouteModel `json:"routes"`
+}
+
+type SnapshotModel struct {
+    Version       int                       `json:"version"`
+    ServerVersion string                    `json:"server_version,omitempty"`
+    Tunnels       []TunnelDecapReturnModel  `json:"tunnels"`
+    Vnets         []VnetReturnModel         `json:"vnets"`
+    BgpProfiles   []BgpProfileReturnModel   `json:"bgp_profiles"`
+    Vlans         []VlansModel              `json:"vlans"`
+    VlanMembers   []VlanMemberReturnModel   `json:"vlan_members"`
+    VlanNeighbors []VlanNeighborReturnModel `json:"vlan_neighbors"`
+    VnetRoutes    []SnapshotVnetRoutesModel `json:"vnet_routes"`
+    StaticRoutes  []SnapshotVrfRoutesModel  `json:"static_routes"`
+    RouteExpiry   *RouteExpiryTimeModel     `json:"route_expiry,omitempty"`
+}
+
+type SnapshotOpModel struct {
+    Method     string          `json:"method"`
+    Path       string          `json:"path"`
+    Body       interface{}     `json:"body,omitempty"`
+    Error_code int             `json:"error_code,omitempty"`
+    Error      json.RawMessage `json:"error,omitempty"`
+}
+
+type SnapshotRestoreReturnModel struct {
+    Mode    string            `json:"mode"`
+    DryRun  bool              `json:"dry_run,omitempty"`
+    Planned []SnapshotOpModel `json:"planned,omitempty"`
+    Applied []SnapshotOpModel `json:"applied,omitempty"`
+    Failed  []SnapshotOpModel `json:"failed,omitempty"`
+    Skipped []SnapshotOpModel `json:"skipped,omitempty"`
+}
+
+type DryRunWriteModel struct {
+    DB     string            `json:"db"`
+    Table  string            `json:"table"`
+    Key    string            `json:"key"`
+    Op     string            `json:"op"`
+    Values map[string]string `json:"values,omitempty"`
+}
+
+type DryRunReturnModel struct {
+    DryRun   bool               `json:"dry_run"`
+    Status   int                `json:"status"`
+    Writes   []DryRunWriteModel `json:"writes"`
+    Response json.RawMessage    `json:"response,omitempty"`
+}
+
+type ErrorInner struct {
+    Code    int      `json:"code"`
+    SubCode *int     `json:"sub-code,omitempty"`
+    // Name of the sub-code in the error code catalog, v2 only
+    Reason  string   `json:"reason,omitempty"`
+    Message string   `json:"message"`
+    Fields  []string `json:"fields,omitempty"`
+    Details string   `json:"details,omitempty"`
+    // Every problem found in the request body
+    Violations []ViolationModel `json:"violations,omitempty"`
+}
+
+type ViolationModel struct {
+    // JSON pointer to the offending value, e.g. /0/ip_prefix
+    Path       string      `json:"path"`
+    // One of required, type, format, enum, range, unknown
+    Constraint string      `json:"constraint"`
+    Value      interface{} `json:"value,omitempty"`
+    Message    string      `json:"message"`
+}
+
+type ErrorModel struct {
+    Error ErrorInner `json:"error"`
+}
+
+type MissingValueError struct {
+    Field string
+}
+
+type InvalidFormatError struct {
+    Field   string
+    Message string
+}
+
+func (e *MissingValueError) Error() string {
+    return "JSON missing field: " + (*e).Field
+}
+
+func (e *InvalidFormatError) Error() string {
+    return (*e).Message
+}
+
+func (m *RouteModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *RouteModel) validate(d *objectDecoder) {
+    cmd := d.String("cmd")
+    ip_prefix := d.String("ip_prefix")
+    ifname := d.String("ifname")
+    nexthop_type := d.String("nexthop_type")
+    nexthop := d.String("nexthop")
+    nexthop_monitor := d.String("nexthop_monitor")
+    primary := d.String("primary")
+    adv_prefix := d.String("adv_prefix")
+    monitoring := d.String("monitoring")
+    mac_address := d.String("mac_address")
+    vnid := d.Int("vnid")
+    weight := d.String("weight")
+    profile := d.String("profile")
+    persistent := d.String("persistent")
+    d.String("error")
+
+    if !d.Has("cmd") {
+        d.Missing("cmd")
+    }
+    if !d.Has("ip_prefix") {
+        d.Missing("ip_prefix")
+    }
+    if !d.Has("ifname") && !d.Has("nexthop") {
+        d.Missing("nexthop")
+    }
+
+    if cmd != nil {
+        if *cmd != "add" && *cmd != "delete" && *cmd != "append" && *cmd != "remove" {
+            d.Invalid("cmd", CONSTRAINT_ENUM, "Must be add/delete/append/delete")
+        }
+        m.Cmd = *cmd
+    }
+
+    if ip_prefix != nil {
+        if _, _, err := ParseIPBothPrefix(*ip_prefix); err != nil {
+            d.Invalid("ip_prefix", CONSTRAINT_FORMAT, "Invalid IP prefix")
+        }
+        m.IPPrefix = *ip_prefix
+    }
+
+    nexthop_valid := true
+    if nexthop != nil {
+        if !strings.Contains(*nexthop, ",") && !IsValidIPBoth(*nexthop) {
+            d.Invalid("nexthop", CONSTRAINT_FORMAT, "Invalid IP address")
+            nexthop_valid = false
+        }
+        m.NextHop = *nexthop
+    }
+
+    if nexthop_monitor != nil {
+        if !strings.Contains(*nexthop_monitor, ",") && !IsValidIPBoth(*nexthop_monitor) {
+            d.Invalid("nexthop_monitor", CONSTRAINT_FORMAT, "Invalid IP address")
+        } else if nexthop_valid && m.Cmd == "add" &&
+            len(strings.Split(m.NextHop, ",")) != len(strings.Split(*nexthop_monitor, ",")) {
+            d.Invalid("nexthop_monitor", CONSTRAINT_FORMAT, "there must be equal number of nexthop(s) and nexthop_monitor(s)")
+        }
+        m.NextHopMonitor = *nexthop_monitor
+    }
+
+    if primary != nil {
+        if !strings.Contains(*primary, ",") && !IsValidIPBoth(*primary) {
+            d.Invalid("primary", CONSTRAINT_FORMAT, "Invalid IP address")
+        }
+        m.Primary = *primary
+    }
+
+    if adv_prefix != nil {
+        if _, _, err := ParseIPBothPrefix(*adv_prefix); err != nil {
+            d.Invalid("adv_prefix", CONSTRAINT_FORMAT, "Invalid advertisement prefix")
+        }
+        m.AdvPrefix = *adv_prefix
+    }
+
+    // The MAC address of a route to an interface is the interface's own
+    if ifname == nil && mac_address != nil {
+        if _, err := net.ParseMAC(*mac_address); err != nil {
+            d.Invalid("mac_address", CONSTRAINT_FORMAT, "Invalid MAC address")
+        }
+        m.MACAddress = *mac_address
+    }
+
+    if persistent == nil {
+        m.Persistent = "false"
+    } else {
+        if !strings.Contains(*persistent, "true") && !strings.Contains(*persistent, "false") {
+            d.Invalid("persistent", CONSTRAINT_ENUM, "must be either true or false")
+        }
+        m.Persistent = *persistent
+    }
+
+    if vnid != nil {
+        m.Vnid = *vnid
+    }
+    if ifname != nil {
+        m.IfName = *ifname
+    }
+    if nexthop_type != nil {
+        m.NextHopType = *nexthop_type
+    }
+    if weight != nil {
+        m.Weight = *weight
+    }
+    if profile != nil {
+        m.Profile = *profile
+    }
+    if monitoring != nil {
+        m.Monitoring = *monitoring
+    }
+}
+
+func (m *VlanMemberModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *VlanMemberModel) validate(d *objectDecoder) {
+    tagging := d.String("tagging_mode")
+    if tagging == nil || *tagging == "" {
+        m.Tagging = "untagged"
+    } else if *tagging != "untagged" && *tagging != "tagged" {
+        d.Invalid("tagging_mode", CONSTRAINT_ENUM, "Invalid tagging_mode, must be tagged/untagged")
+    } else {
+        m.Tagging = *tagging
+    }
+}
+
+func (m *VlanModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *VlanModel) validate(d *objectDecoder) {
+    if vnet_id := d.String("vnet_id"); vnet_id != nil {
+        m.Vnet_id = *vnet_id
+    }
+
+    m.IPPrefix = ""
+    if ip_prefix := d.String("ip_prefix"); ip_prefix != nil && *ip_prefix != "" {
+        if _, _, err := ParseIPBothPrefix(*ip_prefix); err != nil {
+            d.Invalid("ip_prefix", CONSTRAINT_FORMAT, "Invalid IP prefix")
+        }
+        m.IPPrefix = *ip_prefix
+    }
+}
+
+func (m *TunnelDecapModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *TunnelDecapModel) validate(d *objectDecoder) {
+    ip_addr := d.String("ip_addr")
+    if !d.Has("ip_addr") {
+        d.Missing("ip_addr")
+    }
+    if ip_addr != nil {
+        if !IsValidIPBoth(*ip_addr) {
+            d.Invalid("ip_addr", CONSTRAINT_FORMAT, "Invalid IPv4 address")
+        }
+        m.IPAddr = *ip_addr
+    }
+}
+
+func (m *VnetModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *VnetModel) validate(d *objectDecoder) {
+    vnid := d.Int("vnid")
+    adv_prefix := d.String("advertise_prefix")
+    overlay_dmac := d.String("overlay_dmac")
+
+    if !d.Has("vnid") {
+        d.Missing("vnid")
+    }
+    if vnid != nil {
+        if *vnid >= 0x1000000 {
+            d.Invalid("vnid", CONSTRAINT_RANGE, "vnid must be < 2^24")
+        }
+        m.Vnid = *vnid
+    }
+
+    if adv_prefix != nil {
+        if *adv_prefix != "true" && *adv_prefix != "false" {
+            d.Invalid("advertise_prefix", CONSTRAINT_ENUM, "advertise_prefix must be either true or false")
+        }
+        m.AdvPrefix = *adv_prefix
+    }
+
+    if overlay_dmac != nil {
+        if _, err := net.ParseMAC(*overlay_dmac); err != nil {
+            d.Invalid("overlay_dmac", CONSTRAINT_FORMAT, "Invalid MAC address")
+        }
+        m.OverlayDmac = *overlay_dmac
+    }
+}
+
+func (m *ArpIPModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *ArpIPModel) validate(d *objectDecoder) {
+    ip_addr := d.String("ip_addr")
+    if !d.Has("ip_addr") {
+        d.Missing("ip_addr")
+    }
+    if ip_addr != nil {
+        if !IsValidIP(*ip_addr) {
+            d.Invalid("ip_addr", CONSTRAINT_FORMAT, "Invalid IPv4 address")
+        }
+        m.IPAddr = *ip_addr
+    }
+}
+
+func (m *ArpInterfaceTagModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *ArpInterfaceTagModel) validate(d *objectDecoder) {
+    if_name := d.String("if_name")
+    stag := d.Int("stag")
+    ctag := d.Int("ctag")
+
+    if if_name != nil && *if_name != "" {
+        m.IfName = *if_name
+    } else if !d.Has("if_name") || if_name != nil {
+        d.Missing("if_name")
+    }
+
+    if stag != nil {
+        if *stag < 0 || *stag > MAX_VLAN_TAG {
+            d.Invalid("stag", CONSTRAINT_RANGE, "stag must be between 0 and 4095")
+        }
+        m.Stag = *stag
+    }
+
+    if ctag != nil {
+        if *ctag < 0 || *ctag > MAX_VLAN_TAG {
+            d.Invalid("ctag", CONSTRAINT_RANGE, "ctag must be between 0 and 4095")
+        }
+        m.Ctag = *ctag
+    }
+}
+
+func (m *ArpResolveRequestModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *ArpResolveRequestModel) validate(d *objectDecoder) {
+    ip_addr := d.String("ip_addr")
+    if !d.Has("ip_addr") {
+        d.Missing("ip_addr")
+    }
+    if ip_addr != nil {
+        if !IsValidIP(*ip_addr) {
+            d.Invalid("ip_addr", CONSTRAINT_FORMAT, "Invalid IPv4 address")
+        }
+        m.IPAddr = *ip_addr
+    }
+
+    if !d.Has("interfaces") {
+        d.Missing("interfaces")
+    } else {
+        n := len(d.errs.Violations)
+        d.Decode("interfaces", &m.Interfaces)
+        if len(d.errs.Violations) == n && len(m.Interfaces) == 0 {
+            d.Missing("interfaces")
+        }
+    }
+}
+
+func (m *RouteExpiryTimeModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *RouteExpiryTimeModel) validate(d *objectDecoder) {
+    if time := d.Int("time"); time != nil {
+        if *time < 0 || *time > 172800 {
+            d.Invalid("time", CONSTRAINT_RANGE, "time must be greater than 0 and lesser than or equal to 172800")
+        }
+        m.Time = *time
+    }
+}
+
+func (m *MaintenanceModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *MaintenanceModel) validate(d *objectDecoder) {
+    mode := d.String("mode")
+    reason := d.String("reason")
+    retry_after := d.Int("retry_after")
+    trigger := d.String("trigger")
+
+    if !d.Has("mode") {
+        d.Missing("mode")
+    } else if mode != nil {
+        if *mode != MAINTENANCE_MODE_ON && *mode != MAINTENANCE_MODE_OFF {
+            d.Invalid("mode", CONSTRAINT_ENUM, "mode must be either maintenance or normal")
+        }
+        m.Mode = *mode
+    }
+    if reason != nil {
+        m.Reason = *reason
+    }
+    if retry_after != nil {
+        if *retry_after < 0 {
+            d.Invalid("retry_after", CONSTRAINT_RANGE, "retry_after must not be negative")
+        }
+        m.RetryAfter = *retry_after
+    }
+    // Set by the server, read back by clients
+    if trigger != nil {
+        m.Trigger = *trigger
+    }
+}
+
+func (m *PingRequestModel) UnmarshalJSON(data []byte) error {
+    return DecodeJSON(data, m)
+}
+
+func (m *PingRequestModel) validate(d *objectDecoder) {
+    ip_addr := d.String("ip_addr")
+    vnet_id := d.String("vnet_id")
+    count := d.String("count")
+
+    if !d.Has("ip_addr") {
+        d.Missing("ip_addr")
+    }
+    if ip_addr != nil {
+        if !IsValidIPBoth(*ip_addr) {
+            d.Invalid("ip_addr", CONSTRAINT_FORMAT, "Invalid IPv4 address")
+        }
+        m.IpAddress = *ip_addr
+    }
+
+    if vnet_id != nil {
+        m.VnetId = *vnet_id
+    }
+
+    if count != nil && *count != "" {
+        if _, err := strconv.Atoi(*count); err != nil {
+            d.Invalid("count", CONSTRAINT_FORMAT, "count should be an integer")
+        }
+        m.Count = *count
+    }
+}
+
+func IsValidIP(ipstr string) bool {
+    ip := net.ParseIP(ipstr)
+    return (ip != nil) && (ip.To4() != nil)
+}
+
+func IsValidIPBoth(ipstr string) bool {
+    ip := net.ParseIP(ipstr)
+    return ip != nil
+}
+
+func ParseIPBothPrefix(ipprefix string) (ipstr string, length int, err error) {
+    ip, net, err := net.ParseCIDR(ipprefix)
+    if err != nil {
+        return
+    }
+
+    ipstr = ip.String()
+    length, _ = net.Mask.Size()
+
+    return
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+    "bytes"
+    "encoding/json"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Request bodies are decoded field by field so that every problem in them
+// is reported at once. Models check their own fields with a validate method
+// reading them from an objectDecoder, plain structs, slices and pointers are
+// walked through, and each violation is recorded with its JSON pointer.
+
+const (
+    CONSTRAINT_REQUIRED string = "required"
+    CONSTRAINT_TYPE string     = "type"
+    CONSTRAINT_FORMAT string   = "format"
+    CONSTRAINT_ENUM string     = "enum"
+    CONSTRAINT_RANGE string    = "range"
+    CONSTRAINT_UNKNOWN string  = "unknown"
+)
+
+// StrictJSON rejects fields the API does not know. The server points it at
+// its -strictjson flag.
+var StrictJSON = new(bool)
+
+// validatedModel is a model that checks the fields of its JSON object itself
+type validatedModel interface {
+    validate(d *objectDecoder)
+}
+
+// ValidationError holds every violation found in a request body
+type ValidationError struct {
+    Violations []ViolationModel
+}
+
+func (e *ValidationError) Error() string {
+    if len(e.Violations) == 1 {
+        return e.Violations[0].Message
+    }
+    msgs := make([]string, len(e.Violations))
+    for i, v := range e.Violations {
+        msgs[i] = v.Path + ": " + v.Message
+    }
+    return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(path string, constraint string, value json.RawMessage, message string) {
+    v := ViolationModel{Path: path, Constraint: constraint, Message: message}
+    if len(value) > 0 {
+        json.Unmarshal(value, &v.Value)
+    }
+    e.Violations = append(e.Violations, v)
+}
+
+// Fields gives the names of the invalid fields, once each and in order
+func (e *ValidationError) Fields() []string {
+    fields := []string{}
+    seen := make(map[string]bool)
+    for _, v := range e.Violations {
+        field := v.Path[strings.LastIndex(v.Path, "/") + 1:]
+        field = strings.NewReplacer("~1", "/", "~0", "~").Replace(field)
+        if !seen[field] {
+            seen[field] = true
+            fields = append(fields, field)
+        }
+    }
+    return fields
+}
+
+func pointerTo(path string, name string) string {
+    return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func isJSONNull(data []byte) bool {
+    return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+// objectDecoder hands out the fields of a JSON object, remembering which
+// were read so that the others can be reported as unknown
+type objectDecoder struct {
+    path   string
+    fields map[string]json.RawMessage
+    read   map[string]bool
+    errs   *ValidationError
+}
+
+// newObjectDecoder returns nil, after recording the violation, if data is
+// no JSON object. null reads as an object without fields.
+func newObjectDecoder(data []byte, path string, errs *ValidationError) *objectDecoder {
+    d := &objectDecoder{
+        path:   path,
+        fields: make(map[string]json.RawMessage),
+        read:   make(map[string]bool),
+        errs:   errs,
+    }
+    if isJSONNull(data) {
+        return d
+    }
+    if err := json.Unmarshal(data, &d.fields); err != nil {
+        errs.add(path, CONSTRAINT_TYPE, data, "JSON value must be an object")
+        return nil
+    }
+    return d
+}
+
+// raw gives the value of a field, a null one reads as absent
+func (d *objectDecoder) raw(name string) (json.RawMessage, bool) {
+    d.read[name] = true
+    value, ok := d.fields[name]
+    if !ok || isJSONNull(value) {
+        return nil, false
+    }
+    return value, true
+}
+
+func (d *objectDecoder) typeMismatch(name string) {
+    d.errs.add(pointerTo(d.path, name), CONSTRAINT_TYPE, d.fields[name], "JSON field does not match required type")
+}
+
+// String gives a string field, nil if it is absent or of another type
+func (d *objectDecoder) String(name string) *string {
+    value, ok := d.raw(name)
+    if !ok {
+        return nil
+    }
+    var s string
+    if err := json.Unmarshal(value, &s); err != nil {
+        d.typeMismatch(name)
+        return nil
+    }
+    return &s
+}
+
+// Int gives an integer field, nil if it is absent or of another type
+func (d *objectDecoder) Int(name string) *int {
+    value, ok := d.raw(name)
+    if !ok {
+        return nil
+    }
+    var n int
+    if err := json.Unmarshal(value, &n); err != nil {
+        d.typeMismatch(name)
+        return nil
+    }
+    return &n
+}
+
+// Decode decodes a field of any type into v, checking what is inside. It
+// tells whether the field was there.
+func (d *objectDecoder) Decode(name string, v interface{}) bool {
+    value, ok := d.raw(name)
+    if ok {
+        decodeValue(value, reflect.ValueOf(v).Elem(), pointerTo(d.path, name), d.errs)
+    }
+    return ok
+}
+
+// Has tells whether a field was given and not null, even if it did not
+// decode
+func (d *objectDecoder) Has(name string) bool {
+    value, ok := d.fields[name]
+    return ok && !isJSONNull(value)
+}
+
+func (d *objectDecoder) Missing(name string) {
+    d.errs.add(pointerTo(d.path, name), CONSTRAINT_REQUIRED, nil, "Missing JSON field")
+}
+
+func (d *objectDecoder) Invalid(name string, constraint string, message string) {
+    d.errs.add(pointerTo(d.path, name), constraint, d.fields[name], message)
+}
+
+// finish reports the fields nobody read, with StrictJSON
+func (d *objectDecoder) finish() {
+    if !*StrictJSON {
+        return
+    }
+    names := make([]string, 0, len(d.fields))
+    for name := range d.fields {
+        if !d.read[name] {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        d.Invalid(name, CONSTRAINT_UNKNOWN, "Unknown JSON field")
+    }
+}
+
+func jsonFieldName(f reflect.StructField) string {
+    tag := f.Tag.Get("json")
+    if tag == "-" {
+        return ""
+    }
+    if name := strings.Split(tag, ",")[0]; name != "" {
+        return name
+    }
+    return f.Name
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func decodeValue(data []byte, v reflect.Value, path string, errs *ValidationError) {
+    if m, ok := v.Addr().Interface().(validatedModel); ok {
+        if d := newObjectDecoder(data, path, errs); d != nil {
+            m.validate(d)
+            d.finish()
+        }
+        return
+    }
+
+    switch {
+    case v.Kind() == reflect.Ptr:
+        if isJSONNull(data) {
+            v.Set(reflect.Zero(v.Type()))
+            return
+        }
+        if v.IsNil() {
+            v.Set(reflect.New(v.Type().Elem()))
+        }
+        decodeValue(data, v.Elem(), path, errs)
+        return
+
+    case v.Addr().Type().Implements(jsonUnmarshalerType):
+        // Decoded as a whole below
+
+    case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+        if isJSONNull(data) {
+            v.Set(reflect.Zero(v.Type()))
+            return
+        }
+        var items []json.RawMessage
+        if err := json.Unmarshal(data, &items); err != nil {
+            errs.add(path, CONSTRAINT_TYPE, data, "JSON value must be an array")
+            return
+        }
+        s := reflect.MakeSlice(v.Type(), len(items), len(items))
+        for i, item := range items {
+            decodeValue(item, s.Index(i), path + "/" + strconv.Itoa(i), errs)
+        }
+        v.Set(s)
+        return
+
+    case v.Kind() == reflect.Struct:
+        d := newObjectDecoder(data, path, errs)
+        if d == nil {
+            return
+        }
+        t := v.Type()
+        for i := 0; i < t.NumField(); i++ {
+            f := t.Field(i)
+            name := jsonFieldName(f)
+            if f.PkgPath != "" || name == "" {
+                continue
+            }
+            d.Decode(name, v.Field(i).Addr().Interface())
+        }
+        d.finish()
+        return
+    }
+
+    if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+        errs.add(path, CONSTRAINT_TYPE, data, "JSON field does not match required type")
+    }
+}
+
+// DecodeJSON decodes a request body into attr, which must be a pointer,
+// checking every field on the way. It returns a *ValidationError listing all
+// the violations, or the error of encoding/json if data is no JSON at all.
+func DecodeJSON(data []byte, attr interface{}) error {
+    if !json.Valid(data) {
+        return json.Unmarshal(data, attr)
+    }
+    errs := &ValidationError{}
+    decodeValue(data, reflect.ValueOf(attr).Elem(), "", errs)
+    if len(errs.Violations) > 0 {
+        return errs
+    }
+    return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+    "testing"
+)
+
+func TestDecodeJSONViolations(t *testing.T) {
+    tests := []struct {
+        body   string
+        strict bool
+        paths  []string
+    }{
+        {`[{"cmd": "add", "ip_prefix": "10.1.1.0/24", "nexthop": "1.1.1.1"}]`, false, nil},
+        {`[{"cmd": "update", "ip_prefix": "10.1.1.0/24", "nexthop": "1.1.1.1"}, {"cmd": "add", "ip_prefix": "10.1.1.0/33", "nexthop": "x"}]`, false, []string{"/0/cmd", "/1/ip_prefix", "/1/nexthop"}},
+        {`[{"ip_prefix": "10.1.1.0/24", "cmd": null}]`, false, []string{"/0/cmd", "/0/nexthop"}},
+        {`[{"cmd": "add", "ip_prefix": "10.1.1.0/24", "nexthop": "1.1.1.1", "bogus": 1}]`, false, nil},
+        {`[{"cmd": "add", "ip_prefix": "10.1.1.0/24", "nexthop": "1.1.1.1", "bogus": 1}]`, true, []string{"/0/bogus"}},
+        {`{"cmd": "add"}`, false, []string{""}},
+        {`[{"cmd": "add", "ip_prefix": "10.1.1.0/24", "nexthop": "1.1.1.1", "vnid": "1"}]`, false, []string{"/0/vnid"}},
+    }
+
+    defer func(strict bool) { *StrictJSON = strict }(*StrictJSON)
+    for _, test := range tests {
+        *StrictJSON = test.strict
+        var routes []RouteModel
+        err := DecodeJSON([]byte(test.body), &routes)
+        if test.paths == nil {
+            if err != nil {
+                t.Errorf("%s: got %v, want no error", test.body, err)
+            }
+            continue
+        }
+        e, ok := err.(*ValidationError)
+        if !ok {
+            t.Errorf("%s: got %v, want a *ValidationError", test.body, err)
+            continue
+        }
+        if len(e.Violations) != len(test.paths) {
+            t.Errorf("%s: got violations %+v, want paths %v", test.body, e.Violations, test.paths)
+            continue
+        }
+        for i, v := range e.Violations {
+            if v.Path != test.paths[i] {
+                t.Errorf("%s: got violations %+v, want paths %v", test.body, e.Violations, test.paths)
+                break
+            }
+        }
+    }
+}
+
+func TestDecodeJSONInvalid(t *testing.T) {
+    var m VnetModel
+    err := DecodeJSON([]byte(`{"vnid": `), &m)
+    if _, ok := err.(*ValidationError); ok || err == nil {
+        t.Errorf("got %v, want the encoding/json error", err)
+    }
+}
+
+func TestValidationErrorFields(t *testing.T) {
+    e := &ValidationError{}
+    e.add("/0/cmd", CONSTRAINT_ENUM, nil, "Must be add/delete/append/delete")
+    e.add("/1/cmd", CONSTRAINT_REQUIRED, nil, "Missing JSON field")
+    e.add("/1/a~1b", CONSTRAINT_UNKNOWN, nil, "Unknown JSON field")
+    fields := e.Fields()
+    if len(fields) != 2 || fields[0] != "cmd" || fields[1] != "a/b" {
+        t.Errorf("got fields %v", fields)
+    }
+    if e.Error() != "/0/cmd: Must be add/delete/append/delete; /1/cmd: Missing JSON field; /1/a~1b: Unknown JSON field" {
+        t.Errorf("got %q", e.Error())
+    }
+}
@@ -0,0 +1,35 @@
+#ifndef _C_CONSUMERSTATETABLE_H
+#define _C_CONSUMERSTATETABLE_H
+
+#include <hiredis/hiredis.h>
+#include <stdbool.h>
+
+#include "producertable.h"
+#include "selectable.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef void *db_connector_t2;
+typedef void *consumer_state_table_t;
+
+// ConsumerStateTable::ConsumerStateTable(DBConnector *db, const std::string &tableName, int popBatchSize = DEFAULT_POP_BATCH_SIZE, int pri = 0)
+consumer_state_table_t consumer_state_table_new(db_connector_t2 db, const char *tableName, int popBatchSize, int pri);
+
+// ConsumerStateTable::~ConsumerStateTable()
+void consumer_state_table_delete(consumer_state_table_t ct);
+
+// The table as a Selectable, to add it to a Select
+selectable_t consumer_state_table_get_selectable(consumer_state_table_t ct);
+
+// void ConsumerStateTable::pops(std::deque<KeyOpFieldsValuesTuple> &vkco, const std::string &prefix = EMPTY_PREFIX)
+// Returns how many entries it stored in *kcos, which the caller frees with
+// key_op_field_values_array_free
+size_t consumer_state_table_pops(consumer_state_table_t ct, key_op_field_values_t **kcos, const char *prefix);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -25,11 +25,14 @@ void producer_state_table_delete(producer_state_table_t pt);
 // void ProducerStateTable::setBuffered(bool buffered)
 void producer_state_table_set_buffered(producer_state_table_t pt, bool buffered);
 
+// Functions returning char * return NULL on success, else the message of the
+// exception swsscommon threw, which the caller frees
+
 // void ProducerStateTable::set(std::string key,
 //                         std::vector<FieldValueTuple> &values,
 //                         std::string op = SET_COMMAND,
 //                         std::string prefix = EMPTY_PREFIX)
-void producer_state_table_set(producer_state_table_t pt,
+char *producer_state_table_set(producer_state_table_t pt,
                         const char *key,
                         const field_value_tuple_t *values,
                         size_t count,
@@ -39,13 +42,13 @@ void producer_state_table_set(producer_state_table_t pt,
 // void ProducerStateTable::del(std::string key,
 //                         std::string op = DEL_COMMAND,
 //                         std::string prefix = EMPTY_PREFIX)
-void producer_state_table_del(producer_state_table_t pt,
+char *producer_state_table_del(producer_state_table_t pt,
                         const char *key,
                         const char *op,
                         const char *prefix);
 
 // void ProducerStateTable::flush()
-void producer_state_table_flush(producer_state_table_t pt);
+char *producer_state_table_flush(producer_state_table_t pt);
 
 #ifdef __cplusplus
 }
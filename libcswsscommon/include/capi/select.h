@@ -0,0 +1,37 @@
+#ifndef _C_SELECT_H
+#define _C_SELECT_H
+
+#include "selectable.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef void *select_t;
+
+// Results of select_select, as in Select
+#define SELECT_OBJECT  0
+#define SELECT_ERROR   1
+#define SELECT_TIMEOUT 2
+
+// Select::Select()
+select_t select_new(void);
+
+// Select::~Select()
+void select_delete(select_t s);
+
+// void Select::addSelectable(Selectable *selectable)
+void select_add_selectable(select_t s, selectable_t selectable);
+
+// void Select::removeSelectable(Selectable *selectable)
+void select_remove_selectable(select_t s, selectable_t selectable);
+
+// int Select::select(Selectable **c, int timeout = -1)
+// timeout is in milliseconds, -1 to wait for ever
+int select_select(select_t s, selectable_t *selected, int timeout);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
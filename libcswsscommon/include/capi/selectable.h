@@ -0,0 +1,30 @@
+#ifndef _C_SELECTABLE_H
+#define _C_SELECTABLE_H
+
+#include <stddef.h>
+
+#include "producertable.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef void *selectable_t;
+
+// KeyOpFieldsValuesTuple, as popped from a consumer or subscriber table
+typedef struct key_op_field_values
+{
+    const char *key;
+    const char *op;
+    field_value_tuple_t *fieldValues;
+    size_t count;
+} key_op_field_values_t;
+
+// Frees what pops handed out, strings included
+void key_op_field_values_array_free(key_op_field_values_t *kcos, size_t count);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -0,0 +1,35 @@
+#ifndef _C_SUBSCRIBERSTATETABLE_H
+#define _C_SUBSCRIBERSTATETABLE_H
+
+#include <hiredis/hiredis.h>
+#include <stdbool.h>
+
+#include "producertable.h"
+#include "selectable.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef void *db_connector_t2;
+typedef void *subscriber_state_table_t;
+
+// SubscriberStateTable::SubscriberStateTable(DBConnector *db, const std::string &tableName, int popBatchSize = DEFAULT_POP_BATCH_SIZE, int pri = 0)
+subscriber_state_table_t subscriber_state_table_new(db_connector_t2 db, const char *tableName, int popBatchSize, int pri);
+
+// SubscriberStateTable::~SubscriberStateTable()
+void subscriber_state_table_delete(subscriber_state_table_t st);
+
+// The table as a Selectable, to add it to a Select
+selectable_t subscriber_state_table_get_selectable(subscriber_state_table_t st);
+
+// void SubscriberStateTable::pops(std::deque<KeyOpFieldsValuesTuple> &vkco, const std::string &prefix = EMPTY_PREFIX)
+// Returns how many entries it stored in *kcos, which the caller frees with
+// key_op_field_values_array_free
+size_t subscriber_state_table_pops(subscriber_state_table_t st, key_op_field_values_t **kcos, const char *prefix);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -25,11 +25,14 @@ void table_delete(table_t pt);
 // void ProducerStateTable::setBuffered(bool buffered)
 void table_set_buffered(table_t pt, bool buffered);
 
+// Functions returning char * return NULL on success, else the message of the
+// exception swsscommon threw, which the caller frees
+
 // void ProducerStateTable::set(std::string key,
 //                         std::vector<FieldValueTuple> &values,
 //                         std::string op = SET_COMMAND,
 //                         std::string prefix = EMPTY_PREFIX)
-void table_set(table_t pt,
+char *table_set(table_t pt,
                         const char *key,
                         const field_value_tuple_t *values,
                         size_t count,
@@ -39,13 +42,28 @@ void table_set(table_t pt,
 // void ProducerStateTable::del(std::string key,
 //                         std::string op = DEL_COMMAND,
 //                         std::string prefix = EMPTY_PREFIX)
-void table_del(table_t pt,
+char *table_del(table_t pt,
                         const char *key,
                         const char *op,
                         const char *prefix);
 
-// void ProducerStateTable::flush()
-void table_flush(table_t pt);
+// void Table::flush()
+char *table_flush(table_t pt);
+
+// bool Table::get(const std::string &key, std::vector<FieldValueTuple> &values)
+// The values are freed with field_value_tuple_array_free
+char *table_get(table_t pt, const char *key, field_value_tuple_t **values, size_t *count, bool *found);
+
+// void Table::getKeys(std::vector<std::string> &keys)
+// The keys are freed with string_array_free
+char *table_get_keys(table_t pt, char ***keys, size_t *count);
+
+// bool Table::hget(const std::string &key, const std::string &field, std::string &value)
+// The value is freed with free
+char *table_hget(table_t pt, const char *key, const char *field, char **value, bool *found);
+
+void field_value_tuple_array_free(field_value_tuple_t *values, size_t count);
+void string_array_free(char **strs, size_t count);
 
 #ifdef __cplusplus
 }
@@ -0,0 +1,31 @@
+#include <capi/consumerstatetable.h>
+#include <consumerstatetable.h>
+#include <dbconnector.h>
+
+#include "kco.h"
+
+#include <string>
+#include <deque>
+
+consumer_state_table_t consumer_state_table_new(db_connector_t2 db, const char *tableName, int popBatchSize, int pri)
+{
+    auto ct = new swss::ConsumerStateTable(static_cast<swss::DBConnector*>(db), std::string(tableName), popBatchSize, pri);
+    return static_cast<consumer_state_table_t>(ct);
+}
+
+void consumer_state_table_delete(consumer_state_table_t ct)
+{
+    delete static_cast<swss::ConsumerStateTable*>(ct);
+}
+
+selectable_t consumer_state_table_get_selectable(consumer_state_table_t ct)
+{
+    return static_cast<selectable_t>(static_cast<swss::Selectable*>(static_cast<swss::ConsumerStateTable*>(ct)));
+}
+
+size_t consumer_state_table_pops(consumer_state_table_t ct, key_op_field_values_t **kcos, const char *prefix)
+{
+    std::deque<swss::KeyOpFieldsValuesTuple> vkco;
+    static_cast<swss::ConsumerStateTable*>(ct)->pops(vkco, std::string(prefix));
+    return kcos_to_c(vkco, kcos);
+}
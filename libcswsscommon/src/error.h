@@ -0,0 +1,27 @@
+#ifndef _C_ERROR_H
+#define _C_ERROR_H
+
+#include <cstring>
+#include <exception>
+
+// Runs fn, turning an exception it throws into a message for the C caller,
+// which frees it. NULL means success.
+template <typename F>
+char *capi_try(F fn)
+{
+    try
+    {
+        fn();
+        return nullptr;
+    }
+    catch (const std::exception &e)
+    {
+        return strdup(e.what());
+    }
+    catch (...)
+    {
+        return strdup("unknown exception");
+    }
+}
+
+#endif
@@ -0,0 +1,12 @@
+#ifndef _C_KCO_H
+#define _C_KCO_H
+
+#include <capi/selectable.h>
+#include <table.h>
+
+#include <deque>
+
+// Copies popped entries into a C array for key_op_field_values_array_free
+size_t kcos_to_c(const std::deque<swss::KeyOpFieldsValuesTuple> &vkco, key_op_field_values_t **kcos);
+
+#endif
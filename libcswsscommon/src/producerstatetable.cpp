@@ -7,6 +7,8 @@
 #include <vector>
 #include <tuple>
 
+#include "error.h"
+
 producer_state_table_t producer_state_table_new(db_connector_t db, const char *tableName)
 {
     auto pt = new swss::ProducerStateTable(static_cast<swss::DBConnector*>(db), std::string(tableName));
@@ -29,7 +31,7 @@ void producer_state_table_set_buffered(producer_state_table_t pt, bool buffered)
     static_cast<swss::ProducerStateTable*>(pt)->setBuffered(buffered);
 }
 
-void producer_state_table_set(producer_state_table_t pt,
+char *producer_state_table_set(producer_state_table_t pt,
                         const char *key,
                         const field_value_tuple_t *values,
                         size_t count,
@@ -42,18 +44,24 @@ void producer_state_table_set(producer_state_table_t pt,
         auto tuple = std::make_pair(std::string(values[i].field), std::string(values[i].value));
         tuples.push_back(tuple);
     }
-    static_cast<swss::ProducerStateTable*>(pt)->set(std::string(key), tuples, std::string(op), std::string(prefix));
+    return capi_try([&] {
+        static_cast<swss::ProducerStateTable*>(pt)->set(std::string(key), tuples, std::string(op), std::string(prefix));
+    });
 }
 
-void producer_state_table_del(producer_state_table_t pt,
+char *producer_state_table_del(producer_state_table_t pt,
                         const char *key,
                         const char *op,
                         const char *prefix)
 {
-    static_cast<swss::ProducerStateTable*>(pt)->del(std::string(key), std::string(op), std::string(prefix));
+    return capi_try([&] {
+        static_cast<swss::ProducerStateTable*>(pt)->del(std::string(key), std::string(op), std::string(prefix));
+    });
 }
 
-void producer_state_table_flush(producer_state_table_t pt)
+char *producer_state_table_flush(producer_state_table_t pt)
 {
-    static_cast<swss::ProducerStateTable*>(pt)->flush();
+    return capi_try([&] {
+        static_cast<swss::ProducerStateTable*>(pt)->flush();
+    });
 }
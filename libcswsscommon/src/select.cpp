@@ -0,0 +1,39 @@
+#include <capi/select.h>
+#include <select.h>
+#include <selectable.h>
+
+select_t select_new(void)
+{
+    return static_cast<select_t>(new swss::Select());
+}
+
+void select_delete(select_t s)
+{
+    delete static_cast<swss::Select*>(s);
+}
+
+void select_add_selectable(select_t s, selectable_t selectable)
+{
+    static_cast<swss::Select*>(s)->addSelectable(static_cast<swss::Selectable*>(selectable));
+}
+
+void select_remove_selectable(select_t s, selectable_t selectable)
+{
+    static_cast<swss::Select*>(s)->removeSelectable(static_cast<swss::Selectable*>(selectable));
+}
+
+int select_select(select_t s, selectable_t *selected, int timeout)
+{
+    swss::Selectable *c = nullptr;
+    int ret = static_cast<swss::Select*>(s)->select(&c, timeout);
+    *selected = static_cast<selectable_t>(c);
+    switch (ret)
+    {
+    case swss::Select::OBJECT:
+        return SELECT_OBJECT;
+    case swss::Select::TIMEOUT:
+        return SELECT_TIMEOUT;
+    default:
+        return SELECT_ERROR;
+    }
+}
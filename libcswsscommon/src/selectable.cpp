@@ -0,0 +1,44 @@
+#include "kco.h"
+
+#include <cstdlib>
+#include <cstring>
+#include <string>
+
+size_t kcos_to_c(const std::deque<swss::KeyOpFieldsValuesTuple> &vkco, key_op_field_values_t **kcos)
+{
+    size_t count = vkco.size();
+    *kcos = static_cast<key_op_field_values_t*>(calloc(count, sizeof(key_op_field_values_t)));
+
+    size_t i = 0;
+    for (const auto &kco : vkco)
+    {
+        const auto &values = kfvFieldsValues(kco);
+        auto &out = (*kcos)[i++];
+        out.key = strdup(kfvKey(kco).c_str());
+        out.op = strdup(kfvOp(kco).c_str());
+        out.count = values.size();
+        out.fieldValues = static_cast<field_value_tuple_t*>(calloc(values.size(), sizeof(field_value_tuple_t)));
+        for (size_t j = 0; j < values.size(); j++)
+        {
+            out.fieldValues[j].field = strdup(fvField(values[j]).c_str());
+            out.fieldValues[j].value = strdup(fvValue(values[j]).c_str());
+        }
+    }
+    return count;
+}
+
+void key_op_field_values_array_free(key_op_field_values_t *kcos, size_t count)
+{
+    for (size_t i = 0; i < count; i++)
+    {
+        for (size_t j = 0; j < kcos[i].count; j++)
+        {
+            free(const_cast<char*>(kcos[i].fieldValues[j].field));
+            free(const_cast<char*>(kcos[i].fieldValues[j].value));
+        }
+        free(kcos[i].fieldValues);
+        free(const_cast<char*>(kcos[i].key));
+        free(const_cast<char*>(kcos[i].op));
+    }
+    free(kcos);
+}
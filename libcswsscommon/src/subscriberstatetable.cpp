@@ -0,0 +1,31 @@
+#include <capi/subscriberstatetable.h>
+#include <subscriberstatetable.h>
+#include <dbconnector.h>
+
+#include "kco.h"
+
+#include <string>
+#include <deque>
+
+subscriber_state_table_t subscriber_state_table_new(db_connector_t2 db, const char *tableName, int popBatchSize, int pri)
+{
+    auto st = new swss::SubscriberStateTable(static_cast<swss::DBConnector*>(db), std::string(tableName), popBatchSize, pri);
+    return static_cast<subscriber_state_table_t>(st);
+}
+
+void subscriber_state_table_delete(subscriber_state_table_t st)
+{
+    delete static_cast<swss::SubscriberStateTable*>(st);
+}
+
+selectable_t subscriber_state_table_get_selectable(subscriber_state_table_t st)
+{
+    return static_cast<selectable_t>(static_cast<swss::Selectable*>(static_cast<swss::SubscriberStateTable*>(st)));
+}
+
+size_t subscriber_state_table_pops(subscriber_state_table_t st, key_op_field_values_t **kcos, const char *prefix)
+{
+    std::deque<swss::KeyOpFieldsValuesTuple> vkco;
+    static_cast<swss::SubscriberStateTable*>(st)->pops(vkco, std::string(prefix));
+    return kcos_to_c(vkco, kcos);
+}
@@ -3,10 +3,14 @@
 #include <dbconnector.h>
 #include <redispipeline.h>
 
+#include <cstdlib>
+#include <cstring>
 #include <string>
 #include <vector>
 #include <tuple>
 
+#include "error.h"
+
 table_t table_new(db_connector_t db, const char *tableName)
 {
     auto pt = new swss::Table(static_cast<swss::DBConnector*>(db), std::string(tableName));
@@ -29,7 +33,7 @@ void table_set_buffered(table_t pt, bool buffered)
     static_cast<swss::Table*>(pt)->setBuffered(buffered);
 }
 
-void table_set(table_t pt,
+char *table_set(table_t pt,
                         const char *key,
                         const field_value_tuple_t *values,
                         size_t count,
@@ -42,18 +46,103 @@ void table_set(table_t pt,
         auto tuple = std::make_pair(std::string(values[i].field), std::string(values[i].value));
         tuples.push_back(tuple);
     }
-    static_cast<swss::Table*>(pt)->set(std::string(key), tuples, std::string(op), std::string(prefix));
+    return capi_try([&] {
+        static_cast<swss::Table*>(pt)->set(std::string(key), tuples, std::string(op), std::string(prefix));
+    });
 }
 
-void table_del(table_t pt,
+char *table_del(table_t pt,
                         const char *key,
                         const char *op,
                         const char *prefix)
 {
-    static_cast<swss::Table*>(pt)->del(std::string(key), std::string(op), std::string(prefix));
+    return capi_try([&] {
+        static_cast<swss::Table*>(pt)->del(std::string(key), std::string(op), std::string(prefix));
+    });
+}
+
+char *table_flush(table_t pt)
+{
+    return capi_try([&] {
+        static_cast<swss::Table*>(pt)->flush();
+    });
+}
+
+char *table_get(table_t pt, const char *key, field_value_tuple_t **values, size_t *count, bool *found)
+{
+    *values = nullptr;
+    *count = 0;
+    *found = false;
+    std::vector<swss::FieldValueTuple> tuples;
+    char *err = capi_try([&] {
+        *found = static_cast<swss::Table*>(pt)->get(std::string(key), tuples);
+    });
+    if (err != nullptr || !*found)
+    {
+        return err;
+    }
+    *count = tuples.size();
+    *values = static_cast<field_value_tuple_t*>(calloc(tuples.size(), sizeof(field_value_tuple_t)));
+    for (size_t i = 0; i < tuples.size(); i++)
+    {
+        (*values)[i].field = strdup(fvField(tuples[i]).c_str());
+        (*values)[i].value = strdup(fvValue(tuples[i]).c_str());
+    }
+    return nullptr;
 }
 
-void table_flush(table_t pt)
+char *table_get_keys(table_t pt, char ***keys, size_t *count)
 {
-    static_cast<swss::Table*>(pt)->flush();
+    *keys = nullptr;
+    *count = 0;
+    std::vector<std::string> v;
+    char *err = capi_try([&] {
+        static_cast<swss::Table*>(pt)->getKeys(v);
+    });
+    if (err != nullptr)
+    {
+        return err;
+    }
+    *count = v.size();
+    *keys = static_cast<char**>(calloc(v.size(), sizeof(char*)));
+    for (size_t i = 0; i < v.size(); i++)
+    {
+        (*keys)[i] = strdup(v[i].c_str());
+    }
+    return nullptr;
+}
+
+char *table_hget(table_t pt, const char *key, const char *field, char **value, bool *found)
+{
+    *value = nullptr;
+    *found = false;
+    std::string v;
+    char *err = capi_try([&] {
+        *found = static_cast<swss::Table*>(pt)->hget(std::string(key), std::string(field), v);
+    });
+    if (err != nullptr || !*found)
+    {
+        return err;
+    }
+    *value = strdup(v.c_str());
+    return nullptr;
+}
+
+void field_value_tuple_array_free(field_value_tuple_t *values, size_t count)
+{
+    for (size_t i = 0; i < count; i++)
+    {
+        free(const_cast<char*>(values[i].field));
+        free(const_cast<char*>(values[i].value));
+    }
+    free(values);
+}
+
+void string_array_free(char **strs, size_t count)
+{
+    for (size_t i = 0; i < count; i++)
+    {
+        free(strs[i]);
+    }
+    free(strs);
 }
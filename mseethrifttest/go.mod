@@ -0,0 +1,12 @@
+module mseethrifttest
+
+go 1.15
+
+require (
+	git.apache.org/thrift.git v0.10.0
+	mseethrift v0.0.0
+)
+
+replace mseethrift v0.0.0 => ../mseethrift
+
+replace git.apache.org/thrift.git v0.10.0 => github.com/apache/thrift v0.10.0
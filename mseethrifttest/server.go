@@ -1,13 +1,66 @@
 package main
 
 import (
+    "encoding/binary"
+    "encoding/json"
+    "flag"
     "fmt"
+    "io/ioutil"
     "mseethrift"
+    "net"
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
     "git.apache.org/thrift.git/lib/go/thrift"
 )
 
+// The mock keeps the state a real MSEE would: VNI to VRF maps, ports bound
+// to VRFs and encap and decap routes. It answers with the ResultT a real
+// MSEE gives, e.g. NOT_FOUND for a route into a VRF no VNI maps to.
+//
+// Its state comes from a JSON fixture, see Fixture, and every call is logged.
+// An HTTP endpoint lets tests look at and reset both:
+//
+//   GET    /calls    calls since the last reset
+//   DELETE /calls    clear the call log
+//   GET    /state    current state, in fixture format
+//   PUT    /fixture  load the fixture in the body and clear the call log
+//   POST   /reset    reload the startup fixture and clear the call log
+
+var addrFlag = flag.String("addr", "localhost:9090", "Thrift listen address")
+var httpAddrFlag = flag.String("http", ":9190", "Listen address of the call log and fixture endpoint")
+var fixtureFlag = flag.String("fixture", "", "JSON fixture to start from, the built-in one if empty")
+
 func main() {
-    transport, err := thrift.NewTServerSocket("localhost:9090")
+    flag.Parse()
+
+    fixture := defaultFixture()
+    if *fixtureFlag != "" {
+        data, err := ioutil.ReadFile(*fixtureFlag)
+        if err == nil {
+            fixture, err = parseFixture(data)
+        }
+        if err != nil {
+            fmt.Println("Error loading fixture:", err)
+            return
+        }
+    }
+
+    handler := NewMSEEHandler(fixture)
+    if err := handler.load(fixture); err != nil {
+        fmt.Println("Error loading fixture:", err)
+        return
+    }
+
+    go func() {
+        fmt.Println("Serving call log on", *httpAddrFlag)
+        if err := http.ListenAndServe(*httpAddrFlag, handler); err != nil {
+            fmt.Println("Error serving call log:", err)
+        }
+    }()
+
+    transport, err := thrift.NewTServerSocket(*addrFlag)
 
     if err != nil {
         fmt.Println("Error opening socket:", err)
@@ -20,7 +73,6 @@ func main() {
         return
     }
 
-    handler := NewMSEEHandler()
     processor := msee.NewMSEEProcessor(handler)
     server := thrift.NewTSimpleServer2(processor, transport)
 
@@ -28,89 +80,557 @@ func main() {
     server.Serve()
 }
 
+// Fixture is the mock state in JSON. Addresses and prefixes are strings and
+// MACs are colon separated. Results scripts the next results of a method by
+// its Thrift name; they are returned in order, without touching the state,
+// before the mock answers on its own again. E.g.
+//
+//   {"vrfs": [{"vrf_id": 1, "vni": 1001}],
+//    "results": {"add_encap_route": ["NO_MEMORY"]}}
+type Fixture struct {
+    Vrfs        []FixtureVrf                  `json:"vrfs"`
+    Ports       []FixturePort                 `json:"ports"`
+    EncapRoutes []FixtureEncapRoute           `json:"encap_routes"`
+    DecapRoutes []FixtureDecapRoute           `json:"decap_routes"`
+    Counters    map[string]map[string]int64   `json:"counters"`
+    Statistics  map[string]map[string]int64   `json:"statistics"`
+    Histogram   map[string]map[string]float64 `json:"histogram"`
+    Results     map[string][]msee.ResultT     `json:"results,omitempty"`
+}
+
+type FixtureVrf struct {
+    VrfID int32 `json:"vrf_id"`
+    Vni   int32 `json:"vni"`
+}
+
+type FixturePort struct {
+    VrfID     int32 `json:"vrf_id"`
+    Port      int8  `json:"port"`
+    OuterVlan int16 `json:"outer_vlan"`
+    InnerVlan int16 `json:"inner_vlan"`
+}
+
+type FixtureEncapRoute struct {
+    VrfID   int32  `json:"vrf_id"`
+    Prefix  string `json:"prefix"`
+    HostIP  string `json:"host_ip"`
+    Mac     string `json:"mac"`
+    Vni     int32  `json:"vni"`
+    UDPPort uint16 `json:"udp_port"`
+}
+
+type FixtureDecapRoute struct {
+    VrfID     int32  `json:"vrf_id"`
+    Prefix    string `json:"prefix"`
+    Mac       string `json:"mac"`
+    Port      int8   `json:"port"`
+    OuterVlan int16  `json:"outer_vlan"`
+    InnerVlan int16  `json:"inner_vlan"`
+}
+
+// defaultFixture has no VRFs and the readouts the REST tests expect
+func defaultFixture() *Fixture {
+    return &Fixture{
+        Counters: map[string]map[string]int64{
+            "dpdk.switch_ports": {"0.decap_ok": 1, "0.encap_lpm_not_found": 2},
+            "dpdk.total":        {"0.foo": 3, "bar": 4},
+        },
+        Statistics: map[string]map[string]int64{
+            "rings":    {"foo": 1, "bar": 2},
+            "mempools": {"foo": 3, "bar": 4},
+        },
+        Histogram: map[string]map[string]float64{
+            "0": {"1": 1.1, "2": 2.1},
+            "1": {"1": 1.1, "2": 2.1},
+        },
+    }
+}
+
+func parseFixture(data []byte) (*Fixture, error) {
+    var fixture Fixture
+    if err := json.Unmarshal(data, &fixture); err != nil {
+        return nil, err
+    }
+    return &fixture, nil
+}
+
+// Call is one entry of the call log
+type Call struct {
+    Method string                 `json:"method"`
+    Args   map[string]interface{} `json:"args"`
+    Result string                 `json:"result,omitempty"`
+}
+
+type portKey struct {
+    port  int8
+    outer int16
+    inner int16
+}
+
+type routeKey struct {
+    vrf    int32
+    prefix string
+}
+
 type MSEEHandler struct {
+    mu      sync.Mutex
+    startup *Fixture
+    vrfs    map[int32]int32
+    ports   map[portKey]int32
+    encap   map[routeKey]FixtureEncapRoute
+    decap   map[routeKey]FixtureDecapRoute
+    fixture *Fixture
+    results map[string][]msee.ResultT
+    calls   []Call
+}
+
+func NewMSEEHandler(startup *Fixture) *MSEEHandler {
+    return &MSEEHandler{startup: startup}
 }
 
-func NewMSEEHandler() *MSEEHandler {
-    return &MSEEHandler{}
+// load replaces the state with the fixture and clears the call log
+func (p *MSEEHandler) load(fixture *Fixture) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    p.vrfs = make(map[int32]int32)
+    p.ports = make(map[portKey]int32)
+    p.encap = make(map[routeKey]FixtureEncapRoute)
+    p.decap = make(map[routeKey]FixtureDecapRoute)
+    for _, v := range fixture.Vrfs {
+        p.vrfs[v.VrfID] = v.Vni
+    }
+    for _, port := range fixture.Ports {
+        p.ports[portKey{port.Port, port.OuterVlan, port.InnerVlan}] = port.VrfID
+    }
+    for _, route := range fixture.EncapRoutes {
+        prefix, err := normalisePrefix(route.Prefix)
+        if err != nil {
+            return err
+        }
+        route.Prefix = prefix
+        p.encap[routeKey{route.VrfID, prefix}] = route
+    }
+    for _, route := range fixture.DecapRoutes {
+        prefix, err := normalisePrefix(route.Prefix)
+        if err != nil {
+            return err
+        }
+        route.Prefix = prefix
+        p.decap[routeKey{route.VrfID, prefix}] = route
+    }
+    p.fixture = fixture
+    p.results = make(map[string][]msee.ResultT)
+    for method, results := range fixture.Results {
+        p.results[method] = append([]msee.ResultT{}, results...)
+    }
+    p.calls = nil
+    return nil
+}
+
+// record logs a call. A scripted result, if any, replaces the one the state
+// gives; apply then does not run.
+func (p *MSEEHandler) record(method string, args map[string]interface{}, apply func() msee.ResultT) msee.ResultT {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    var r msee.ResultT
+    if scripted := p.results[method]; len(scripted) > 0 {
+        r = scripted[0]
+        p.results[method] = scripted[1:]
+    } else {
+        r = apply()
+    }
+    fmt.Printf("%s(%v) = %v\n", method, args, r)
+    p.calls = append(p.calls, Call{Method: method, Args: args, Result: r.String()})
+    return r
+}
+
+func (p *MSEEHandler) recordRead(method string, args map[string]interface{}) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    fmt.Printf("%s(%v)\n", method, args)
+    p.calls = append(p.calls, Call{Method: method, Args: args})
 }
 
 func (p *MSEEHandler) InitDpdkPort(nb_customer_ports msee.MseePortCountT, mac_addr msee.MseeMacT, ipv4_loaddr msee.MseeIp4T, ipv6_loaddr *msee.MseeIp6T) (r msee.ResultT, err error) {
-    fmt.Printf("init_dpdk_port(%v, %v, %+v, %+v)\n", nb_customer_ports, mac_addr, ipv4_loaddr, ipv6_loaddr)
-    r = msee.ResultT_OK
+    args := map[string]interface{}{
+        "nb_customer_ports": nb_customer_ports,
+        "mac_addr":          macString(mac_addr),
+        "ipv4_loaddr":       ip4String(ipv4_loaddr),
+        "ipv6_loaddr":       ip6String(ipv6_loaddr),
+    }
+    r = p.record("init_dpdk_port", args, func() msee.ResultT {
+        return msee.ResultT_OK
+    })
     return
 }
 
 func (p *MSEEHandler) AddPortToVrf(vrf_id msee.MseeVrfIDT, port msee.MseePortT, outer_vlan msee.MseeVlanT, inner_vlan msee.MseeVlanT) (r msee.ResultT, err error) {
-    fmt.Printf("add_port_to_vrf(%v, %v, %v, %v)\n", vrf_id, port, outer_vlan, inner_vlan)
-    r = msee.ResultT_ADDED
+    args := map[string]interface{}{"vrf_id": vrf_id, "port": port, "outer_vlan": outer_vlan, "inner_vlan": inner_vlan}
+    r = p.record("add_port_to_vrf", args, func() msee.ResultT {
+        if _, ok := p.vrfs[int32(vrf_id)]; !ok {
+            return msee.ResultT_NOT_FOUND
+        }
+        key := portKey{int8(port), int16(outer_vlan), int16(inner_vlan)}
+        if _, ok := p.ports[key]; ok {
+            return msee.ResultT_ALREADY_EXISTS
+        }
+        p.ports[key] = int32(vrf_id)
+        return msee.ResultT_ADDED
+    })
     return
 }
 
 func (p *MSEEHandler) DeletePortFromVrf(port msee.MseePortT, outer_vlan msee.MseeVlanT, inner_vlan msee.MseeVlanT) (r msee.ResultT, err error) {
-    fmt.Printf("delete_port_from_vrf(%v, %v, %v)\n", port, outer_vlan, inner_vlan)
-    r = msee.ResultT_REMOVED
+    args := map[string]interface{}{"port": port, "outer_vlan": outer_vlan, "inner_vlan": inner_vlan}
+    r = p.record("delete_port_from_vrf", args, func() msee.ResultT {
+        key := portKey{int8(port), int16(outer_vlan), int16(inner_vlan)}
+        if _, ok := p.ports[key]; !ok {
+            return msee.ResultT_NOT_FOUND
+        }
+        delete(p.ports, key)
+        return msee.ResultT_REMOVED
+    })
     return
 }
 
-func (p *MSEEHandler)MapVniToVrf(vni msee.MseeVniT, vrf_id msee.MseeVrfIDT) (r msee.ResultT, err error) {
-    fmt.Printf("map_vni_to_vrf(%v, %v)\n", vni, vrf_id)
-    r = msee.ResultT_ADDED
+// Several VRFs may share a VNI, as several VNETs may in SONiC, but a VRF has
+// a single VNI
+func (p *MSEEHandler) MapVniToVrf(vni msee.MseeVniT, vrf_id msee.MseeVrfIDT) (r msee.ResultT, err error) {
+    args := map[string]interface{}{"vni": vni, "vrf_id": vrf_id}
+    r = p.record("map_vni_to_vrf", args, func() msee.ResultT {
+        if _, ok := p.vrfs[int32(vrf_id)]; ok {
+            return msee.ResultT_ALREADY_EXISTS
+        }
+        p.vrfs[int32(vrf_id)] = int32(vni)
+        return msee.ResultT_ADDED
+    })
     return
 }
 
+// UnmapVniToVrf removes every VRF the VNI maps to
 func (p *MSEEHandler) UnmapVniToVrf(vni msee.MseeVniT) (r msee.ResultT, err error) {
-    fmt.Printf("unmap_vni_to_vrf(%v)\n", vni)
-    r = msee.ResultT_REMOVED
+    args := map[string]interface{}{"vni": vni}
+    r = p.record("unmap_vni_to_vrf", args, func() msee.ResultT {
+        r := msee.ResultT_NOT_FOUND
+        for vrf, v := range p.vrfs {
+            if v == int32(vni) {
+                delete(p.vrfs, vrf)
+                r = msee.ResultT_REMOVED
+            }
+        }
+        return r
+    })
     return
 }
 
 func (p *MSEEHandler) AddEncapRoute(vrf_id msee.MseeVrfIDT, dst_vm_ip_prefix *msee.MseeIPPrefixT, dst_host_ip *msee.MseeIPAddressT, dst_mac_address msee.MseeMacT, vni msee.MseeVniT, port msee.MseeUDPPortT) (r msee.ResultT, err error) {
-    fmt.Printf("add_encap_route(%v, %v, %v, %v, %v, %v)\n", vrf_id, dst_vm_ip_prefix, dst_host_ip, dst_mac_address, vni, uint16(port))
-    r = msee.ResultT_ADDED
+    route := FixtureEncapRoute{
+        VrfID:   int32(vrf_id),
+        Prefix:  prefixString(dst_vm_ip_prefix),
+        HostIP:  ipString(dst_host_ip),
+        Mac:     macString(dst_mac_address),
+        Vni:     int32(vni),
+        UDPPort: uint16(port),
+    }
+    args := map[string]interface{}{
+        "vrf_id":           route.VrfID,
+        "dst_vm_ip_prefix": route.Prefix,
+        "dst_host_ip":      route.HostIP,
+        "dst_mac_address":  route.Mac,
+        "vni":              route.Vni,
+        "port":             route.UDPPort,
+    }
+    r = p.record("add_encap_route", args, func() msee.ResultT {
+        if route.Prefix == "" || route.HostIP == "" {
+            return msee.ResultT_INVALID_PARAMETERS
+        }
+        if _, ok := p.vrfs[route.VrfID]; !ok {
+            return msee.ResultT_NOT_FOUND
+        }
+        key := routeKey{route.VrfID, route.Prefix}
+        _, exists := p.encap[key]
+        p.encap[key] = route
+        if exists {
+            return msee.ResultT_UPDATED
+        }
+        return msee.ResultT_ADDED
+    })
     return
 }
 
 func (p *MSEEHandler) DeleteEncapRoute(vrf_id msee.MseeVrfIDT, dst_vm_ip_prefix *msee.MseeIPPrefixT) (r msee.ResultT, err error) {
-    fmt.Printf("delete_encap_route(%v, %v)\n", vrf_id, dst_vm_ip_prefix)
-    r = msee.ResultT_REMOVED
+    key := routeKey{int32(vrf_id), prefixString(dst_vm_ip_prefix)}
+    args := map[string]interface{}{"vrf_id": key.vrf, "dst_vm_ip_prefix": key.prefix}
+    r = p.record("delete_encap_route", args, func() msee.ResultT {
+        if _, ok := p.encap[key]; !ok {
+            return msee.ResultT_NOT_FOUND
+        }
+        delete(p.encap, key)
+        return msee.ResultT_REMOVED
+    })
     return
 }
 
 func (p *MSEEHandler) AddDecapRoute(vrf_id msee.MseeVrfIDT, dst_ip_prefix *msee.MseeIPPrefixT, mac msee.MseeMacT, port msee.MseePortT, outer_vlan msee.MseeVlanT, inner_vlan msee.MseeVlanT) (r msee.ResultT, err error) {
-    fmt.Printf("add_decap_route(%v, %v, %v, %v, %v, %v)\n", vrf_id, dst_ip_prefix, mac, port, outer_vlan, inner_vlan)
-    r = msee.ResultT_ADDED
+    route := FixtureDecapRoute{
+        VrfID:     int32(vrf_id),
+        Prefix:    prefixString(dst_ip_prefix),
+        Mac:       macString(mac),
+        Port:      int8(port),
+        OuterVlan: int16(outer_vlan),
+        InnerVlan: int16(inner_vlan),
+    }
+    args := map[string]interface{}{
+        "vrf_id":        route.VrfID,
+        "dst_ip_prefix": route.Prefix,
+        "mac":           route.Mac,
+        "port":          route.Port,
+        "outer_vlan":    route.OuterVlan,
+        "inner_vlan":    route.InnerVlan,
+    }
+    r = p.record("add_decap_route", args, func() msee.ResultT {
+        if route.Prefix == "" {
+            return msee.ResultT_INVALID_PARAMETERS
+        }
+        if _, ok := p.vrfs[route.VrfID]; !ok {
+            return msee.ResultT_NOT_FOUND
+        }
+        key := routeKey{route.VrfID, route.Prefix}
+        _, exists := p.decap[key]
+        p.decap[key] = route
+        if exists {
+            return msee.ResultT_UPDATED
+        }
+        return msee.ResultT_ADDED
+    })
     return
 }
 
 func (p *MSEEHandler) DeleteDecapRoute(vrf_id msee.MseeVrfIDT, dst_ip_prefix *msee.MseeIPPrefixT) (r msee.ResultT, err error) {
-    fmt.Printf("delete_decap_route(%v, %v)\n", vrf_id, dst_ip_prefix)
-    r = msee.ResultT_REMOVED
+    key := routeKey{int32(vrf_id), prefixString(dst_ip_prefix)}
+    args := map[string]interface{}{"vrf_id": key.vrf, "dst_ip_prefix": key.prefix}
+    r = p.record("delete_decap_route", args, func() msee.ResultT {
+        if _, ok := p.decap[key]; !ok {
+            return msee.ResultT_NOT_FOUND
+        }
+        delete(p.decap, key)
+        return msee.ResultT_REMOVED
+    })
     return
 }
 
+// GetCounters answers the given group, or all of them if group is empty
 func (p *MSEEHandler) GetCounters(group msee.MseeGroupT) (r msee.CountersT, err error) {
-    fmt.Printf("get_counters(%v)\n", group)
-    if len(group) > 0 {
-        r = msee.CountersT{group: map[msee.MseeCounterName]int64{"0.decap_ok": 1, "0.encap_lpm_not_found": 2}}
-    } else {
-        r = msee.CountersT{"dpdk.switch_ports": map[msee.MseeCounterName]int64{"0.decap_ok": 1, "0.encap_lpm_not_found": 2, "100.encap_lpm_not_found": 2, "foo": 2}, "dpdk.total": map[msee.MseeCounterName]int64{"0.foo": 3, "bar": 4}}
+    p.recordRead("get_counters", map[string]interface{}{"group": group})
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    r = msee.CountersT{}
+    for g, values := range p.fixture.Counters {
+        if group != "" && msee.MseeGroupT(g) != group {
+            continue
+        }
+        m := make(map[msee.MseeCounterName]int64, len(values))
+        for name, v := range values {
+            m[msee.MseeCounterName(name)] = v
+        }
+        r[msee.MseeGroupT(g)] = m
     }
     return
 }
 
 func (p *MSEEHandler) GetStatistics(group msee.MseeGroupT) (r msee.StatisticsT, err error) {
-    fmt.Printf("get_statistics(%v)\n", group)
-    if len(group) > 0 {
-        r = msee.StatisticsT{group: map[msee.MseeStatisticsName]int64{"foo": 1, "bar": 2}}
-    } else {
-        r = msee.StatisticsT{"rings": map[msee.MseeStatisticsName]int64{"foo": 1, "bar": 2}, "mempools": map[msee.MseeStatisticsName]int64{"foo": 3, "bar": 4}}
+    p.recordRead("get_statistics", map[string]interface{}{"group": group})
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    r = msee.StatisticsT{}
+    for g, values := range p.fixture.Statistics {
+        if group != "" && msee.MseeGroupT(g) != group {
+            continue
+        }
+        m := make(map[msee.MseeStatisticsName]int64, len(values))
+        for name, v := range values {
+            m[msee.MseeStatisticsName(name)] = v
+        }
+        r[msee.MseeGroupT(g)] = m
     }
     return
 }
 
 func (p *MSEEHandler) GetHist() (r msee.HistT, err error) {
-    fmt.Printf("get_hist()\n")
-    r = msee.HistT{0: map[int8]float64{1: 1.1, 2: 2.1}, 1: map[int8]float64{1: 1.1, 2: 2.1}}
+    p.recordRead("get_hist", map[string]interface{}{})
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    r = msee.HistT{}
+    for queue, buckets := range p.fixture.Histogram {
+        q, err := strconv.ParseInt(queue, 10, 8)
+        if err != nil {
+            continue
+        }
+        m := make(map[int8]float64, len(buckets))
+        for bucket, v := range buckets {
+            if b, err := strconv.ParseInt(bucket, 10, 8); err == nil {
+                m[int8(b)] = v
+            }
+        }
+        r[msee.MseeQueueIDT(q)] = m
+    }
     return
-}
\ No newline at end of file
+}
+
+// state gives the current state in fixture format, sorted so it compares
+// stably
+func (p *MSEEHandler) state() *Fixture {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    s := &Fixture{
+        Vrfs:        []FixtureVrf{},
+        Ports:       []FixturePort{},
+        EncapRoutes: []FixtureEncapRoute{},
+        DecapRoutes: []FixtureDecapRoute{},
+        Counters:    p.fixture.Counters,
+        Statistics:  p.fixture.Statistics,
+        Histogram:   p.fixture.Histogram,
+    }
+    for vrf, vni := range p.vrfs {
+        s.Vrfs = append(s.Vrfs, FixtureVrf{VrfID: vrf, Vni: vni})
+    }
+    sort.Slice(s.Vrfs, func(i, j int) bool { return s.Vrfs[i].VrfID < s.Vrfs[j].VrfID })
+    for key, vrf := range p.ports {
+        s.Ports = append(s.Ports, FixturePort{VrfID: vrf, Port: key.port, OuterVlan: key.outer, InnerVlan: key.inner})
+    }
+    sort.Slice(s.Ports, func(i, j int) bool {
+        a, b := s.Ports[i], s.Ports[j]
+        if a.Port != b.Port {
+            return a.Port < b.Port
+        }
+        if a.OuterVlan != b.OuterVlan {
+            return a.OuterVlan < b.OuterVlan
+        }
+        return a.InnerVlan < b.InnerVlan
+    })
+    for _, route := range p.encap {
+        s.EncapRoutes = append(s.EncapRoutes, route)
+    }
+    sort.Slice(s.EncapRoutes, func(i, j int) bool {
+        a, b := s.EncapRoutes[i], s.EncapRoutes[j]
+        return a.VrfID < b.VrfID || a.VrfID == b.VrfID && a.Prefix < b.Prefix
+    })
+    for _, route := range p.decap {
+        s.DecapRoutes = append(s.DecapRoutes, route)
+    }
+    sort.Slice(s.DecapRoutes, func(i, j int) bool {
+        a, b := s.DecapRoutes[i], s.DecapRoutes[j]
+        return a.VrfID < b.VrfID || a.VrfID == b.VrfID && a.Prefix < b.Prefix
+    })
+    return s
+}
+
+func (p *MSEEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    switch {
+    case r.URL.Path == "/calls" && r.Method == http.MethodGet:
+        p.mu.Lock()
+        calls := append([]Call{}, p.calls...)
+        p.mu.Unlock()
+        writeJSON(w, calls)
+    case r.URL.Path == "/calls" && r.Method == http.MethodDelete:
+        p.mu.Lock()
+        p.calls = nil
+        p.mu.Unlock()
+        w.WriteHeader(http.StatusNoContent)
+    case r.URL.Path == "/state" && r.Method == http.MethodGet:
+        writeJSON(w, p.state())
+    case r.URL.Path == "/fixture" && r.Method == http.MethodPut:
+        data, err := ioutil.ReadAll(r.Body)
+        var fixture *Fixture
+        if err == nil {
+            fixture, err = parseFixture(data)
+        }
+        if err == nil {
+            err = p.load(fixture)
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    case r.URL.Path == "/reset" && r.Method == http.MethodPost:
+        if err := p.load(p.startup); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    json.NewEncoder(w).Encode(v)
+}
+
+func ip4String(ip msee.MseeIp4T) string {
+    b := make(net.IP, net.IPv4len)
+    binary.BigEndian.PutUint32(b, uint32(ip))
+    return b.String()
+}
+
+func ip6String(ip *msee.MseeIp6T) string {
+    if ip == nil {
+        return ""
+    }
+    b := make(net.IP, net.IPv6len)
+    binary.BigEndian.PutUint64(b[:8], uint64(ip.High))
+    binary.BigEndian.PutUint64(b[8:], uint64(ip.Low))
+    return b.String()
+}
+
+func ipString(ip *msee.MseeIPAddressT) string {
+    if ip == nil || ip.IP == nil {
+        return ""
+    }
+    switch {
+    case ip.Type == msee.IPTypeT_v4 && ip.IP.Ip4 != nil:
+        return ip4String(*ip.IP.Ip4)
+    case ip.Type == msee.IPTypeT_v6 && ip.IP.Ip6 != nil:
+        return ip6String(ip.IP.Ip6)
+    }
+    return ""
+}
+
+// prefixString gives the prefix in CIDR notation, "" if it is malformed
+func prefixString(prefix *msee.MseeIPPrefixT) string {
+    if prefix == nil {
+        return ""
+    }
+    ip := ipString(prefix.IP)
+    if ip == "" {
+        return ""
+    }
+    s, err := normalisePrefix(ip + "/" + strconv.Itoa(int(prefix.MaskLength)))
+    if err != nil {
+        return ""
+    }
+    return s
+}
+
+func normalisePrefix(s string) (string, error) {
+    _, network, err := net.ParseCIDR(s)
+    if err != nil {
+        return "", err
+    }
+    return network.String(), nil
+}
+
+func macString(mac msee.MseeMacT) string {
+    b := make([]byte, 8)
+    binary.BigEndian.PutUint64(b, uint64(mac))
+    return net.HardwareAddr(b[2:]).String()
+}
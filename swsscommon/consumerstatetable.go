@@ -0,0 +1,53 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
+package swsscommon
+
+// #cgo LDFLAGS: -lcswsscommon -lswsscommon -lstdc++
+// #include <capi/consumerstatetable.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+    "unsafe"
+)
+
+// Batch size and priority ConsumerStateTable and SubscriberStateTable
+// default to in swsscommon
+const DefaultPopBatchSize int = 128
+const DefaultPriority int = 0
+
+type ConsumerStateTable struct {
+    ptr   unsafe.Pointer
+    table string
+}
+
+func NewConsumerStateTable(db DBConnector, tableName string, popBatchSize int, pri int) ConsumerStateTable {
+    tableNameC := C.CString(tableName)
+    defer C.free(unsafe.Pointer(tableNameC))
+
+    ct := C.consumer_state_table_new(C.db_connector_t2(db.ptr), tableNameC, C.int(popBatchSize), C.int(pri))
+    return ConsumerStateTable{ptr: unsafe.Pointer(ct), table: tableName}
+}
+
+func (ct ConsumerStateTable) Delete() {
+    C.consumer_state_table_delete(C.consumer_state_table_t(ct.ptr))
+}
+
+func (ct ConsumerStateTable) TableName() string {
+    return ct.table
+}
+
+// Pops takes up to the pop batch size of pending entries off the table
+func (ct ConsumerStateTable) Pops() []KeyOpFieldsValues {
+    prefixC := C.CString("")
+    defer C.free(unsafe.Pointer(prefixC))
+
+    var kcosC *C.key_op_field_values_t
+    count := C.consumer_state_table_pops(C.consumer_state_table_t(ct.ptr), &kcosC, prefixC)
+    return kcosFromC(ct.table, kcosC, count)
+}
+
+func (ct ConsumerStateTable) selectable() unsafe.Pointer {
+    return unsafe.Pointer(C.consumer_state_table_get_selectable(C.consumer_state_table_t(ct.ptr)))
+}
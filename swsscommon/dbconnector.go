@@ -1,3 +1,6 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
 package swsscommon
 
 // #cgo LDFLAGS: -lcswsscommon -lswsscommon -lstdc++
@@ -43,3 +46,9 @@ func (db DBConnector) NewConnector(timeout uint) DBConnector {
     dbc := C.db_connector_new_connector(C.db_connector_t(db.ptr), C.uint(timeout))
     return DBConnector{ptr: unsafe.Pointer(dbc)};
 }
+
+// WithSeparator gives the connector unchanged, libswsscommon reads the
+// separators of the DBs from database_config.json itself
+func (db DBConnector) WithSeparator(separator string) DBConnector {
+    return db
+}
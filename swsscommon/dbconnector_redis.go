@@ -0,0 +1,89 @@
+//go:build !cgo || swsscommon_redis
+// +build !cgo swsscommon_redis
+
+package swsscommon
+
+import (
+    "strconv"
+    "time"
+
+    "github.com/go-redis/redis/v7"
+)
+
+// The pure Go implementation talks to Redis directly instead of going
+// through libswsscommon. It is built when cgo is disabled or with the
+// swsscommon_redis build tag, and follows the Redis layout swsscommon uses
+// so that SONiC daemons see the same keys either way.
+
+type DBConnector struct {
+    client    *redis.Client
+    opts      *redis.Options
+    db        int
+    separator string
+}
+
+func dbConnectorOptions(network string, addr string, db int, timeout uint) *redis.Options {
+    opts := &redis.Options{
+        Network: network,
+        Addr:    addr,
+        DB:      db,
+    }
+    // swsscommon takes the timeout in milliseconds, 0 blocking forever
+    if timeout > 0 {
+        d := time.Duration(timeout) * time.Millisecond
+        opts.DialTimeout = d
+        opts.ReadTimeout = d
+        opts.WriteTimeout = d
+    }
+    return opts
+}
+
+func NewDBConnector(db int, hostname string, port int, timeout uint) DBConnector {
+    opts := dbConnectorOptions("tcp", hostname + ":" + strconv.Itoa(port), db, timeout)
+    return DBConnector{client: redis.NewClient(opts), opts: opts, db: db}
+}
+
+func NewDBConnector2(db int, unixPath string, timeout uint) DBConnector {
+    opts := dbConnectorOptions("unix", unixPath, db, timeout)
+    return DBConnector{client: redis.NewClient(opts), opts: opts, db: db}
+}
+
+func (db DBConnector) Delete() {
+    db.client.Close()
+}
+
+func (db DBConnector) GetDB() int {
+    return db.db
+}
+
+// DBConnectorSelect is a no-op, every connection of the client selects the
+// DB when it is opened
+func DBConnectorSelect(db DBConnector) {
+}
+
+func (db DBConnector) NewConnector(timeout uint) DBConnector {
+    opts := *db.opts
+    if timeout > 0 {
+        d := time.Duration(timeout) * time.Millisecond
+        opts.DialTimeout = d
+        opts.ReadTimeout = d
+        opts.WriteTimeout = d
+    }
+    return DBConnector{client: redis.NewClient(&opts), opts: &opts, db: db.db, separator: db.separator}
+}
+
+// WithSeparator gives the connector with the separator its tables put
+// between the table name and the key, that of the DB in
+// database_config.json. Without one the default of swsscommon for the DB
+// number is used.
+func (db DBConnector) WithSeparator(separator string) DBConnector {
+    db.separator = separator
+    return db
+}
+
+func (db DBConnector) tableSeparator() string {
+    if db.separator != "" {
+        return db.separator
+    }
+    return defaultTableSeparator(db.db)
+}
@@ -0,0 +1,17 @@
+package swsscommon
+
+import (
+    "fmt"
+)
+
+// SwssError is an error a table operation failed with: the exception
+// swsscommon threw, or the Redis error in the pure Go implementation
+type SwssError struct {
+    Op      string
+    Table   string
+    Message string
+}
+
+func (e *SwssError) Error() string {
+    return fmt.Sprintf("swss: %s %s: %s", e.Op, e.Table, e.Message)
+}
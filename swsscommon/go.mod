@@ -1,3 +1,5 @@
 module swsscommon
 
 go 1.15
+
+require github.com/go-redis/redis/v7 v7.3.0
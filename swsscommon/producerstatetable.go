@@ -1,3 +1,6 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
 package swsscommon
 
 // #cgo LDFLAGS: -lcswsscommon -lswsscommon -lstdc++
@@ -32,7 +35,7 @@ func (pt ProducerStateTable) SetBuffered(buffered bool) {
     C.producer_state_table_set_buffered(C.producer_state_table_t(pt.ptr), C._Bool(buffered))
 }
 
-func (pt ProducerStateTable) Set(key string, values map[string]string, op string, prefix string) {
+func (pt ProducerStateTable) Set(key string, values map[string]string, op string, prefix string) error {
     log.Printf(
         "trace: swss: %s %s:%s %s",
         op,
@@ -67,10 +70,10 @@ func (pt ProducerStateTable) Set(key string, values map[string]string, op string
         idx = idx + 1
     }
 
-    C.producer_state_table_set(C.producer_state_table_t(pt.ptr), keyC, tuplePtr, C.size_t(count), opC, prefixC)
+    return errorFromC(op, pt.table, C.producer_state_table_set(C.producer_state_table_t(pt.ptr), keyC, tuplePtr, C.size_t(count), opC, prefixC))
 }
 
-func (pt ProducerStateTable) Del(key string, op string, prefix string) {
+func (pt ProducerStateTable) Del(key string, op string, prefix string) error {
     log.Printf(
         "trace: swss: %s %s:%s",
         op,
@@ -85,9 +88,9 @@ func (pt ProducerStateTable) Del(key string, op string, prefix string) {
     prefixC := C.CString(prefix)
     defer C.free(unsafe.Pointer(prefixC))
 
-    C.producer_state_table_del(C.producer_state_table_t(pt.ptr), keyC, opC, prefixC)
+    return errorFromC(op, pt.table, C.producer_state_table_del(C.producer_state_table_t(pt.ptr), keyC, opC, prefixC))
 }
 
-func (pt ProducerStateTable) Flush() {
-    C.producer_state_table_flush(C.producer_state_table_t(pt.ptr))
+func (pt ProducerStateTable) Flush() error {
+    return errorFromC("FLUSH", pt.table, C.producer_state_table_flush(C.producer_state_table_t(pt.ptr)))
 }
@@ -0,0 +1,154 @@
+//go:build !cgo || swsscommon_redis
+// +build !cgo swsscommon_redis
+
+package swsscommon
+
+import (
+    "log"
+
+    "github.com/go-redis/redis/v7"
+)
+
+// What ProducerStateTable publishes on the channel of the table, the same
+// for every change
+const producerStateNotification string = "G"
+
+// producerStateBuffer holds the commands of a buffered table until Flush,
+// with the SADDs whose result tells whether to publish
+type producerStateBuffer struct {
+    pipe  redis.Pipeliner
+    added []*redis.IntCmd
+}
+
+// ProducerStateTable writes a change to key as swsscommon does, so that a
+// ConsumerStateTable such as the one of orchagent picks it up:
+//  - key is added to the <table>_KEY_SET set
+//  - the fields go to the _<table><separator><key> hash, or for a delete key
+//    is added to the <table>_DEL_SET set and the hash is removed
+//  - "G" is published on <table>_CHANNEL if key was not pending already
+// swsscommon does this in a Lua script. Here the writes are a MULTI/EXEC
+// transaction and the publish follows it.
+type ProducerStateTable struct {
+    db     DBConnector
+    table  string
+    sep    string
+    buffer *producerStateBuffer
+}
+
+func NewProducerStateTable(db DBConnector, tableName string) ProducerStateTable {
+    return ProducerStateTable{
+        db:     db,
+        table:  tableName,
+        sep:    db.tableSeparator(),
+        buffer: &producerStateBuffer{},
+    }
+}
+
+func (pt ProducerStateTable) Delete() {
+    if err := pt.Flush(); err != nil {
+        log.Printf("error: swss: %v", err)
+    }
+}
+
+func (pt ProducerStateTable) SetBuffered(buffered bool) {
+    if buffered && pt.buffer.pipe == nil {
+        pt.buffer.pipe = pt.db.client.TxPipeline()
+    } else if !buffered && pt.buffer.pipe != nil {
+        if err := pt.Flush(); err != nil {
+            log.Printf("error: swss: %v", err)
+        }
+        pt.buffer.pipe = nil
+    }
+}
+
+func (pt ProducerStateTable) keySet() string {
+    return pt.table + "_KEY_SET"
+}
+
+func (pt ProducerStateTable) delSet() string {
+    return pt.table + "_DEL_SET"
+}
+
+func (pt ProducerStateTable) channel() string {
+    return pt.table + "_CHANNEL"
+}
+
+func (pt ProducerStateTable) stateKey(key string) string {
+    return "_" + pt.table + pt.sep + key
+}
+
+func (pt ProducerStateTable) Set(key string, values map[string]string, op string, prefix string) error {
+    log.Printf(
+        "trace: swss: %s %s:%s %s",
+        op,
+        pt.table,
+        key,
+        values,
+    )
+
+    return pt.write(op, func(pipe redis.Pipeliner) {
+        if len(values) > 0 {
+            pipe.HSet(pt.stateKey(key), fieldValues(values)...)
+        }
+    }, key)
+}
+
+func (pt ProducerStateTable) Del(key string, op string, prefix string) error {
+    log.Printf(
+        "trace: swss: %s %s:%s",
+        op,
+        pt.table,
+        key,
+    )
+
+    return pt.write(op, func(pipe redis.Pipeliner) {
+        pipe.SAdd(pt.delSet(), key)
+        pipe.Del(pt.stateKey(key))
+    }, key)
+}
+
+// write queues the change of key, and unless the table is buffered runs it
+// and publishes
+func (pt ProducerStateTable) write(op string, change func(pipe redis.Pipeliner), key string) error {
+    pipe := pt.buffer.pipe
+    buffered := pipe != nil
+    if !buffered {
+        pipe = pt.db.client.TxPipeline()
+    }
+
+    added := pipe.SAdd(pt.keySet(), key)
+    change(pipe)
+    if buffered {
+        pt.buffer.added = append(pt.buffer.added, added)
+        return nil
+    }
+
+    if _, err := pipe.Exec(); err != nil {
+        return redisError(op, pt.table, err)
+    }
+    return pt.publish(op, []*redis.IntCmd{added})
+}
+
+func (pt ProducerStateTable) publish(op string, added []*redis.IntCmd) error {
+    for _, a := range added {
+        if a.Val() == 0 {
+            continue
+        }
+        if err := pt.db.client.Publish(pt.channel(), producerStateNotification).Err(); err != nil {
+            return redisError(op, pt.table, err)
+        }
+    }
+    return nil
+}
+
+func (pt ProducerStateTable) Flush() error {
+    if pt.buffer.pipe == nil {
+        return nil
+    }
+    added := pt.buffer.added
+    pt.buffer.added = nil
+    if _, err := pt.buffer.pipe.Exec(); err != nil {
+        return redisError("FLUSH", pt.table, err)
+    }
+    return pt.publish("FLUSH", added)
+}
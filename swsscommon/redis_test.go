@@ -0,0 +1,256 @@
+//go:build !cgo || swsscommon_redis
+// +build !cgo swsscommon_redis
+
+package swsscommon
+
+import (
+    "os"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/go-redis/redis/v7"
+)
+
+// The tests need a redis-server, at SWSS_TEST_REDIS or localhost:6379, and
+// are skipped without one. They only touch keys of SWSSTEST_ tables.
+
+const testTablePrefix string = "SWSSTEST_"
+
+func testRedisAddr() (string, int) {
+    addr := os.Getenv("SWSS_TEST_REDIS")
+    if addr == "" {
+        addr = "localhost:6379"
+    }
+    i := strings.LastIndex(addr, ":")
+    port, _ := strconv.Atoi(addr[i+1:])
+    return addr[:i], port
+}
+
+// testDB connects to db and removes the test keys before and after the test
+func testDB(t *testing.T, db int) DBConnector {
+    host, port := testRedisAddr()
+    dbc := NewDBConnector(db, host, port, 1000)
+    if err := dbc.client.Ping().Err(); err != nil {
+        dbc.Delete()
+        t.Skipf("no redis-server at %s:%d: %v", host, port, err)
+    }
+    clean := func() {
+        for _, pattern := range []string{testTablePrefix + "*", "_" + testTablePrefix + "*"} {
+            keys, err := dbc.client.Keys(pattern).Result()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(keys) > 0 {
+                dbc.client.Del(keys...)
+            }
+        }
+    }
+    clean()
+    t.Cleanup(func() {
+        clean()
+        dbc.Delete()
+    })
+    return dbc
+}
+
+func TestTableSeparator(t *testing.T) {
+    for db, sep := range map[int]string{0: ":", 2: ":", 4: "|", 6: "|"} {
+        if got := (DBConnector{db: db}).tableSeparator(); got != sep {
+            t.Errorf("separator of DB %d is %q, want %q", db, got, sep)
+        }
+    }
+    // That of database_config.json wins over the default of the DB number
+    if got := (DBConnector{db: 0}).WithSeparator("|").tableSeparator(); got != "|" {
+        t.Errorf("got separator %q, want that of the config", got)
+    }
+
+    db := testDB(t, 0).WithSeparator("|")
+    tb := NewTable(db, testTablePrefix + "VNET")
+    defer tb.Delete()
+    if err := tb.Set("Vnet1", map[string]string{"vni": "2000"}, "SET", ""); err != nil {
+        t.Fatal(err)
+    }
+    if n, _ := db.client.Exists(testTablePrefix + "VNET|Vnet1").Result(); n != 1 {
+        t.Errorf("no %sVNET|Vnet1 in DB 0", testTablePrefix)
+    }
+}
+
+func TestTable(t *testing.T) {
+    db := testDB(t, configDB)
+    tb := NewTable(db, testTablePrefix + "VNET")
+    defer tb.Delete()
+
+    values := map[string]string{"vni": "2000", "vxlan_tunnel": "default_vxlan_tunnel"}
+    if err := tb.Set("Vnet1", values, "SET", ""); err != nil {
+        t.Fatal(err)
+    }
+    if err := tb.Set("Vnet2", map[string]string{"vni": "3000"}, "SET", ""); err != nil {
+        t.Fatal(err)
+    }
+
+    raw, err := db.client.HGetAll(testTablePrefix + "VNET|Vnet1").Result()
+    if err != nil || !reflect.DeepEqual(raw, values) {
+        t.Fatalf("hash of Vnet1 is %v, %v, want %v", raw, err, values)
+    }
+
+    got, found, err := tb.Get("Vnet1")
+    if err != nil || !found || !reflect.DeepEqual(got, values) {
+        t.Errorf("Get(Vnet1) = %v, %v, %v", got, found, err)
+    }
+    vni, found, err := tb.HGet("Vnet2", "vni")
+    if err != nil || !found || vni != "3000" {
+        t.Errorf("HGet(Vnet2, vni) = %q, %v, %v", vni, found, err)
+    }
+    if _, found, err = tb.HGet("Vnet2", "scope"); err != nil || found {
+        t.Errorf("HGet of a missing field found %v, %v", found, err)
+    }
+
+    keys, err := tb.GetKeys()
+    sort.Strings(keys)
+    if err != nil || !reflect.DeepEqual(keys, []string{"Vnet1", "Vnet2"}) {
+        t.Errorf("GetKeys() = %v, %v", keys, err)
+    }
+
+    if err := tb.Del("Vnet1", "DEL", ""); err != nil {
+        t.Fatal(err)
+    }
+    if got, found, err = tb.Get("Vnet1"); err != nil || found || got != nil {
+        t.Errorf("Get of a deleted key = %v, %v, %v", got, found, err)
+    }
+}
+
+func TestTableBuffered(t *testing.T) {
+    db := testDB(t, configDB)
+    tb := NewTable(db, testTablePrefix + "VLAN")
+    defer tb.Delete()
+
+    tb.SetBuffered(true)
+    if err := tb.Set("Vlan2", map[string]string{"vlanid": "2"}, "SET", ""); err != nil {
+        t.Fatal(err)
+    }
+    if n := db.client.Exists(testTablePrefix + "VLAN|Vlan2").Val(); n != 0 {
+        t.Fatal("buffered write reached redis before Flush")
+    }
+    if err := tb.Flush(); err != nil {
+        t.Fatal(err)
+    }
+    if _, found, _ := tb.Get("Vlan2"); !found {
+        t.Fatal("buffered write missing after Flush")
+    }
+}
+
+// receive waits for the notifications published on the channel of the
+// subscription, up to n of them
+func receive(t *testing.T, sub *redis.PubSub, n int) []string {
+    var payloads []string
+    for len(payloads) < n {
+        msg, err := sub.ReceiveTimeout(200 * time.Millisecond)
+        if err != nil {
+            break
+        }
+        if m, ok := msg.(*redis.Message); ok {
+            payloads = append(payloads, m.Payload)
+        }
+    }
+    return payloads
+}
+
+func TestProducerStateTable(t *testing.T) {
+    db := testDB(t, 0)
+    table := testTablePrefix + "ROUTE_TABLE"
+    pt := NewProducerStateTable(db, table)
+    defer pt.Delete()
+
+    sub := db.client.Subscribe(table + "_CHANNEL")
+    defer sub.Close()
+    if _, err := sub.Receive(); err != nil {
+        t.Fatal(err)
+    }
+
+    values := map[string]string{"nexthop": "10.0.0.1", "ifname": "Ethernet0"}
+    if err := pt.Set("10.1.0.0/16", values, "SET", ""); err != nil {
+        t.Fatal(err)
+    }
+    // The key is still pending, the consumer needs no second notification
+    if err := pt.Set("10.1.0.0/16", map[string]string{"weight": "1"}, "SET", ""); err != nil {
+        t.Fatal(err)
+    }
+    if got := receive(t, sub, 2); !reflect.DeepEqual(got, []string{"G"}) {
+        t.Errorf("published %v, want [G]", got)
+    }
+
+    members := db.client.SMembers(table + "_KEY_SET").Val()
+    if !reflect.DeepEqual(members, []string{"10.1.0.0/16"}) {
+        t.Errorf("key set is %v", members)
+    }
+    values["weight"] = "1"
+    if raw := db.client.HGetAll("_" + table + ":10.1.0.0/16").Val(); !reflect.DeepEqual(raw, values) {
+        t.Errorf("state hash is %v, want %v", raw, values)
+    }
+
+    // Once the consumer took the key, the next change notifies again
+    db.client.Del(table + "_KEY_SET")
+    if err := pt.Del("10.1.0.0/16", "DEL", ""); err != nil {
+        t.Fatal(err)
+    }
+    if got := receive(t, sub, 1); !reflect.DeepEqual(got, []string{"G"}) {
+        t.Errorf("published %v on delete, want [G]", got)
+    }
+    if !db.client.SIsMember(table + "_DEL_SET", "10.1.0.0/16").Val() {
+        t.Error("deleted key missing from the del set")
+    }
+    if n := db.client.Exists("_" + table + ":10.1.0.0/16").Val(); n != 0 {
+        t.Error("state hash of a deleted key still exists")
+    }
+}
+
+func TestProducerStateTableBuffered(t *testing.T) {
+    db := testDB(t, 0)
+    table := testTablePrefix + "VNET_ROUTE_TABLE"
+    pt := NewProducerStateTable(db, table)
+    defer pt.Delete()
+
+    sub := db.client.Subscribe(table + "_CHANNEL")
+    defer sub.Close()
+    if _, err := sub.Receive(); err != nil {
+        t.Fatal(err)
+    }
+
+    pt.SetBuffered(true)
+    for _, prefix := range []string{"10.1.0.0/24", "10.1.1.0/24", "10.1.2.0/24"} {
+        if err := pt.Set("Vnet1:" + prefix, map[string]string{"endpoint": "1.1.1.1"}, "SET", ""); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if got := receive(t, sub, 1); len(got) != 0 {
+        t.Fatalf("published %v before Flush", got)
+    }
+    if err := pt.Flush(); err != nil {
+        t.Fatal(err)
+    }
+    if got := receive(t, sub, 3); len(got) != 3 {
+        t.Errorf("published %v after Flush, want one per key", got)
+    }
+    if n := db.client.SCard(table + "_KEY_SET").Val(); n != 3 {
+        t.Errorf("key set has %d keys, want 3", n)
+    }
+}
+
+func TestTableError(t *testing.T) {
+    // Nothing listens on port 1
+    db := NewDBConnector(configDB, "127.0.0.1", 1, 100)
+    defer db.Delete()
+    tb := NewTable(db, testTablePrefix + "VNET")
+
+    err := tb.Set("Vnet1", map[string]string{"vni": "2000"}, "SET", "")
+    if serr, ok := err.(*SwssError); !ok || serr.Op != "SET" || serr.Table != testTablePrefix + "VNET" {
+        t.Errorf("Set without redis returned %#v", err)
+    }
+    if _, _, err = tb.Get("Vnet1"); err == nil {
+        t.Error("Get without redis returned no error")
+    }
+}
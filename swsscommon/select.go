@@ -0,0 +1,107 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
+package swsscommon
+
+// #cgo LDFLAGS: -lcswsscommon -lswsscommon -lstdc++
+// #include <capi/select.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+    "time"
+    "unsafe"
+)
+
+// Results of Select.Select
+const (
+    SelectObject  int = C.SELECT_OBJECT
+    SelectError   int = C.SELECT_ERROR
+    SelectTimeout int = C.SELECT_TIMEOUT
+)
+
+// KeyOpFieldsValues is one entry popped from a ConsumerStateTable or a
+// SubscriberStateTable
+type KeyOpFieldsValues struct {
+    Table       string
+    Key         string
+    Op          string
+    FieldValues map[string]string
+}
+
+// Selectable is a table a Select can wait on
+type Selectable interface {
+    TableName() string
+    Pops() []KeyOpFieldsValues
+    selectable() unsafe.Pointer
+}
+
+// kcosFromC copies what pops handed out and frees it
+func kcosFromC(table string, kcosC *C.key_op_field_values_t, count C.size_t) []KeyOpFieldsValues {
+    if count == 0 {
+        return nil
+    }
+    defer C.key_op_field_values_array_free(kcosC, count)
+
+    kcos := (*[(1 << 28) - 1]C.key_op_field_values_t)(unsafe.Pointer(kcosC))[:count:count]
+    entries := make([]KeyOpFieldsValues, count)
+    for i, kco := range kcos {
+        values := make(map[string]string, int(kco.count))
+        if kco.count > 0 {
+            tuples := (*[(1 << 28) - 1]C.field_value_tuple_t)(unsafe.Pointer(kco.fieldValues))[:kco.count:kco.count]
+            for _, t := range tuples {
+                values[C.GoString(t.field)] = C.GoString(t.value)
+            }
+        }
+        entries[i] = KeyOpFieldsValues{
+            Table:       table,
+            Key:         C.GoString(kco.key),
+            Op:          C.GoString(kco.op),
+            FieldValues: values,
+        }
+    }
+    return entries
+}
+
+// Select waits for any of its tables to have entries to pop. Like the
+// tables, it is not safe for concurrent use.
+type Select struct {
+    ptr         unsafe.Pointer
+    selectables map[unsafe.Pointer]Selectable
+}
+
+func NewSelect() *Select {
+    return &Select{
+        ptr:         unsafe.Pointer(C.select_new()),
+        selectables: make(map[unsafe.Pointer]Selectable),
+    }
+}
+
+func (s *Select) Delete() {
+    C.select_delete(C.select_t(s.ptr))
+}
+
+func (s *Select) AddSelectable(t Selectable) {
+    s.selectables[t.selectable()] = t
+    C.select_add_selectable(C.select_t(s.ptr), C.selectable_t(t.selectable()))
+}
+
+func (s *Select) RemoveSelectable(t Selectable) {
+    delete(s.selectables, t.selectable())
+    C.select_remove_selectable(C.select_t(s.ptr), C.selectable_t(t.selectable()))
+}
+
+// Select waits up to timeout, for ever if it is negative. With SelectObject
+// it also gives the table that is ready.
+func (s *Select) Select(timeout time.Duration) (Selectable, int) {
+    timeoutMs := -1
+    if timeout >= 0 {
+        timeoutMs = int(timeout / time.Millisecond)
+    }
+    var selected C.selectable_t
+    ret := int(C.select_select(C.select_t(s.ptr), &selected, C.int(timeoutMs)))
+    if ret != SelectObject {
+        return nil, ret
+    }
+    return s.selectables[unsafe.Pointer(selected)], ret
+}
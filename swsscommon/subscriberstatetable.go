@@ -0,0 +1,50 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
+package swsscommon
+
+// #cgo LDFLAGS: -lcswsscommon -lswsscommon -lstdc++
+// #include <capi/subscriberstatetable.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+    "unsafe"
+)
+
+// SubscriberStateTable follows the changes to a table through keyspace
+// notifications, without taking them off the table like a
+// ConsumerStateTable does. The first Pops gives the entries already there.
+type SubscriberStateTable struct {
+    ptr   unsafe.Pointer
+    table string
+}
+
+func NewSubscriberStateTable(db DBConnector, tableName string, popBatchSize int, pri int) SubscriberStateTable {
+    tableNameC := C.CString(tableName)
+    defer C.free(unsafe.Pointer(tableNameC))
+
+    st := C.subscriber_state_table_new(C.db_connector_t2(db.ptr), tableNameC, C.int(popBatchSize), C.int(pri))
+    return SubscriberStateTable{ptr: unsafe.Pointer(st), table: tableName}
+}
+
+func (st SubscriberStateTable) Delete() {
+    C.subscriber_state_table_delete(C.subscriber_state_table_t(st.ptr))
+}
+
+func (st SubscriberStateTable) TableName() string {
+    return st.table
+}
+
+func (st SubscriberStateTable) Pops() []KeyOpFieldsValues {
+    prefixC := C.CString("")
+    defer C.free(unsafe.Pointer(prefixC))
+
+    var kcosC *C.key_op_field_values_t
+    count := C.subscriber_state_table_pops(C.subscriber_state_table_t(st.ptr), &kcosC, prefixC)
+    return kcosFromC(st.table, kcosC, count)
+}
+
+func (st SubscriberStateTable) selectable() unsafe.Pointer {
+    return unsafe.Pointer(C.subscriber_state_table_get_selectable(C.subscriber_state_table_t(st.ptr)))
+}
@@ -1,3 +1,6 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
 package swsscommon
 
 // #cgo LDFLAGS: -lcswsscommon -lswsscommon -lstdc++
@@ -32,7 +35,7 @@ func (pt Table) SetBuffered(buffered bool) {
     C.table_set_buffered(C.table_t(pt.ptr), C._Bool(buffered))
 }
 
-func (pt Table) Set(key string, values map[string]string, op string, prefix string) {
+func (pt Table) Set(key string, values map[string]string, op string, prefix string) error {
     log.Printf(
         "trace: swss: %s %s:%s %s",
         op,
@@ -67,10 +70,10 @@ func (pt Table) Set(key string, values map[string]string, op string, prefix stri
         idx = idx + 1
     }
 
-    C.table_set(C.table_t(pt.ptr), keyC, tuplePtr, C.size_t(count), opC, prefixC)
+    return errorFromC(op, pt.table, C.table_set(C.table_t(pt.ptr), keyC, tuplePtr, C.size_t(count), opC, prefixC))
 }
 
-func (pt Table) Del(key string, op string, prefix string) {
+func (pt Table) Del(key string, op string, prefix string) error {
     log.Printf(
         "trace: swss: %s %s:%s",
         op,
@@ -85,9 +88,78 @@ func (pt Table) Del(key string, op string, prefix string) {
     prefixC := C.CString(prefix)
     defer C.free(unsafe.Pointer(prefixC))
 
-    C.table_del(C.table_t(pt.ptr), keyC, opC, prefixC)
+    return errorFromC(op, pt.table, C.table_del(C.table_t(pt.ptr), keyC, opC, prefixC))
+}
+
+func (pt Table) Flush() error {
+    return errorFromC("FLUSH", pt.table, C.table_flush(C.table_t(pt.ptr)))
+}
+
+// Get reads the fields of key. found is false when the key does not exist.
+func (pt Table) Get(key string) (values map[string]string, found bool, err error) {
+    keyC := C.CString(key)
+    defer C.free(unsafe.Pointer(keyC))
+
+    var tuplesC *C.field_value_tuple_t
+    var count C.size_t
+    var foundC C._Bool
+    err = errorFromC("GET", pt.table, C.table_get(C.table_t(pt.ptr), keyC, &tuplesC, &count, &foundC))
+    if err != nil || !bool(foundC) {
+        return nil, false, err
+    }
+    defer C.field_value_tuple_array_free(tuplesC, count)
+
+    values = make(map[string]string, int(count))
+    if count > 0 {
+        tuples := (*[(1 << 28) - 1]C.field_value_tuple_t)(unsafe.Pointer(tuplesC))[:count:count]
+        for _, t := range tuples {
+            values[C.GoString(t.field)] = C.GoString(t.value)
+        }
+    }
+    return values, true, nil
 }
 
-func (pt Table) Flush() {
-    C.table_flush(C.table_t(pt.ptr))
+// GetKeys lists the keys of the table, without the table name
+func (pt Table) GetKeys() ([]string, error) {
+    var keysC **C.char
+    var count C.size_t
+    err := errorFromC("KEYS", pt.table, C.table_get_keys(C.table_t(pt.ptr), &keysC, &count))
+    if err != nil || count == 0 {
+        return nil, err
+    }
+    defer C.string_array_free(keysC, count)
+
+    keys := make([]string, count)
+    for i, k := range (*[(1 << 28) - 1]*C.char)(unsafe.Pointer(keysC))[:count:count] {
+        keys[i] = C.GoString(k)
+    }
+    return keys, nil
+}
+
+// HGet reads one field of key. found is false when the key or the field does
+// not exist.
+func (pt Table) HGet(key string, field string) (value string, found bool, err error) {
+    keyC := C.CString(key)
+    defer C.free(unsafe.Pointer(keyC))
+    fieldC := C.CString(field)
+    defer C.free(unsafe.Pointer(fieldC))
+
+    var valueC *C.char
+    var foundC C._Bool
+    err = errorFromC("HGET", pt.table, C.table_hget(C.table_t(pt.ptr), keyC, fieldC, &valueC, &foundC))
+    if err != nil || !bool(foundC) {
+        return "", false, err
+    }
+    defer C.free(unsafe.Pointer(valueC))
+    return C.GoString(valueC), true, nil
+}
+
+// errorFromC turns the message a C function returned into an error and frees
+// it. A NULL message is success.
+func errorFromC(op string, table string, msg *C.char) error {
+    if msg == nil {
+        return nil
+    }
+    defer C.free(unsafe.Pointer(msg))
+    return &SwssError{Op: op, Table: table, Message: C.GoString(msg)}
 }
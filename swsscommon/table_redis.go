@@ -0,0 +1,183 @@
+//go:build !cgo || swsscommon_redis
+// +build !cgo swsscommon_redis
+
+package swsscommon
+
+import (
+    "log"
+    "strings"
+
+    "github.com/go-redis/redis/v7"
+)
+
+// DBs whose tables use "|" between the table name and the key in the default
+// layout of swsscommon. Every other DB uses ":".
+const (
+    configDB int = 4
+    stateDB  int = 6
+)
+
+// defaultTableSeparator is the separator of a DB connected to without the
+// separator of its database_config.json
+func defaultTableSeparator(db int) string {
+    if db == configDB || db == stateDB {
+        return "|"
+    }
+    return ":"
+}
+
+// fieldValues flattens values into the arguments of HSET
+func fieldValues(values map[string]string) []interface{} {
+    args := make([]interface{}, 0, 2 * len(values))
+    for k, v := range values {
+        args = append(args, k, v)
+    }
+    return args
+}
+
+// tableBuffer holds the commands of a buffered table until Flush. It is
+// shared by the copies of the table value.
+type tableBuffer struct {
+    pipe redis.Pipeliner
+}
+
+// Table is a plain hash per key, named <table><separator><key>
+type Table struct {
+    db     DBConnector
+    table  string
+    sep    string
+    buffer *tableBuffer
+}
+
+func NewTable(db DBConnector, tableName string) Table {
+    return Table{
+        db:     db,
+        table:  tableName,
+        sep:    db.tableSeparator(),
+        buffer: &tableBuffer{},
+    }
+}
+
+// Delete flushes what is still buffered, like the destructor of the
+// swsscommon pipeline
+func (pt Table) Delete() {
+    if err := pt.Flush(); err != nil {
+        log.Printf("error: swss: %v", err)
+    }
+}
+
+func (pt Table) SetBuffered(buffered bool) {
+    if buffered && pt.buffer.pipe == nil {
+        pt.buffer.pipe = pt.db.client.TxPipeline()
+    } else if !buffered && pt.buffer.pipe != nil {
+        if err := pt.Flush(); err != nil {
+            log.Printf("error: swss: %v", err)
+        }
+        pt.buffer.pipe = nil
+    }
+}
+
+func (pt Table) key(key string) string {
+    return pt.table + pt.sep + key
+}
+
+// pipeliner gives the pipeline of a buffered table, or a new one to be run
+// right away
+func (pt Table) pipeliner() (pipe redis.Pipeliner, buffered bool) {
+    if pt.buffer.pipe != nil {
+        return pt.buffer.pipe, true
+    }
+    return pt.db.client.TxPipeline(), false
+}
+
+func (pt Table) Set(key string, values map[string]string, op string, prefix string) error {
+    log.Printf(
+        "trace: swss: %s %s:%s %s",
+        op,
+        pt.table,
+        key,
+        values,
+    )
+
+    if len(values) == 0 {
+        return nil
+    }
+    pipe, buffered := pt.pipeliner()
+    pipe.HSet(pt.key(key), fieldValues(values)...)
+    if buffered {
+        return nil
+    }
+    _, err := pipe.Exec()
+    return redisError(op, pt.table, err)
+}
+
+func (pt Table) Del(key string, op string, prefix string) error {
+    log.Printf(
+        "trace: swss: %s %s:%s",
+        op,
+        pt.table,
+        key,
+    )
+
+    pipe, buffered := pt.pipeliner()
+    pipe.Del(pt.key(key))
+    if buffered {
+        return nil
+    }
+    _, err := pipe.Exec()
+    return redisError(op, pt.table, err)
+}
+
+func (pt Table) Flush() error {
+    if pt.buffer.pipe == nil {
+        return nil
+    }
+    _, err := pt.buffer.pipe.Exec()
+    return redisError("FLUSH", pt.table, err)
+}
+
+// Get reads the fields of key. found is false when the key does not exist.
+func (pt Table) Get(key string) (values map[string]string, found bool, err error) {
+    values, err = pt.db.client.HGetAll(pt.key(key)).Result()
+    if err != nil {
+        return nil, false, redisError("GET", pt.table, err)
+    }
+    if len(values) == 0 {
+        return nil, false, nil
+    }
+    return values, true, nil
+}
+
+// GetKeys lists the keys of the table, without the table name
+func (pt Table) GetKeys() ([]string, error) {
+    prefix := pt.table + pt.sep
+    found, err := pt.db.client.Keys(prefix + "*").Result()
+    if err != nil {
+        return nil, redisError("KEYS", pt.table, err)
+    }
+    keys := make([]string, len(found))
+    for i, k := range found {
+        keys[i] = strings.TrimPrefix(k, prefix)
+    }
+    return keys, nil
+}
+
+// HGet reads one field of key. found is false when the key or the field does
+// not exist.
+func (pt Table) HGet(key string, field string) (value string, found bool, err error) {
+    value, err = pt.db.client.HGet(pt.key(key), field).Result()
+    if err == redis.Nil {
+        return "", false, nil
+    }
+    if err != nil {
+        return "", false, redisError("HGET", pt.table, err)
+    }
+    return value, true, nil
+}
+
+func redisError(op string, table string, err error) error {
+    if err == nil || err == redis.Nil {
+        return nil
+    }
+    return &SwssError{Op: op, Table: table, Message: err.Error()}
+}
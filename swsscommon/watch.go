@@ -0,0 +1,84 @@
+//go:build cgo && !swsscommon_redis
+// +build cgo,!swsscommon_redis
+
+package swsscommon
+
+import (
+    "log"
+    "time"
+)
+
+// How long a Watcher waits in Select before it checks whether it was stopped
+const WatchPollInterval time.Duration = 100 * time.Millisecond
+
+// Watcher pops its tables in a goroutine of its own and sends the entries
+// on Entries, in the order popped. The tables belong to that goroutine until
+// Stop returns; the caller still deletes them afterwards.
+type Watcher struct {
+    Entries <-chan KeyOpFieldsValues
+
+    entries chan KeyOpFieldsValues
+    stop    chan struct{}
+    done    chan struct{}
+}
+
+// Watch starts watching the tables. Entries is buffered for size entries;
+// when the reader falls behind, popping waits for it.
+func Watch(size int, tables ...Selectable) *Watcher {
+    w := &Watcher{
+        entries: make(chan KeyOpFieldsValues, size),
+        stop:    make(chan struct{}),
+        done:    make(chan struct{}),
+    }
+    w.Entries = w.entries
+    go w.run(tables)
+    return w
+}
+
+func (w *Watcher) run(tables []Selectable) {
+    defer close(w.done)
+    defer close(w.entries)
+
+    s := NewSelect()
+    defer s.Delete()
+    for _, t := range tables {
+        s.AddSelectable(t)
+    }
+
+    for {
+        select {
+        case <-w.stop:
+            return
+        default:
+        }
+
+        t, ret := s.Select(WatchPollInterval)
+        switch ret {
+        case SelectTimeout:
+            continue
+        case SelectError:
+            log.Printf("error: swss: select failed while watching tables")
+            continue
+        }
+        if t == nil {
+            continue
+        }
+        for _, entry := range t.Pops() {
+            select {
+            case w.entries <- entry:
+            case <-w.stop:
+                return
+            }
+        }
+    }
+}
+
+// Stop ends the watch and closes Entries. Entries not read yet are dropped.
+func (w *Watcher) Stop() {
+    select {
+    case <-w.stop:
+    default:
+        close(w.stop)
+    }
+    <-w.done
+}
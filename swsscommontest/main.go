@@ -1,15 +1,22 @@
 package main
 
-import "swsscommon"
+import (
+    "log"
+    "swsscommon"
+)
 
 func main() {
     db := swsscommon.NewDBConnector(0, "localhost", 6379, 0);
     defer db.Delete()
     pt := swsscommon.NewProducerStateTable(db, "QOS_TABLE")
     defer pt.Delete()
-    pt.Del("PORT_TABLE:ETHERNET4", "DEL", "")
-    pt.Set("SCHEDULER_TABLE:SCAVENGER", map[string]string{
+    if err := pt.Del("PORT_TABLE:ETHERNET4", "DEL", ""); err != nil {
+        log.Fatal(err)
+    }
+    if err := pt.Set("SCHEDULER_TABLE:SCAVENGER", map[string]string{
         "algorithm": "DWRR",
         "weight": "35",
-    }, "SET", "")
+    }, "SET", ""); err != nil {
+        log.Fatal(err)
+    }
 }
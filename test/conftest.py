@@ -1,7 +1,8 @@
+import os
 import pytest
 import redis
 
-from restapi_client import RESTAPI_client
+from restapi_client import RESTAPI_client, MockDataPlane, MOCK_MSEE_HOST, MOCK_ARP_HOST
 
 @pytest.fixture()
 def setup_restapi_client():
@@ -12,6 +13,11 @@ def setup_restapi_client():
     configdb = redis.StrictRedis('localhost', 6379, 4)
     configdb.flushdb()
 
+    # The msee backend programs the mock on every test, so start each one
+    # from the same data plane state as the flushed DBs
+    if os.environ.get('RESTAPI_BACKEND') == 'msee':
+        MockDataPlane(MOCK_MSEE_HOST).reset()
+
     restapi_client = RESTAPI_client(db)
     restapi_client.post_config_restart_in_mem_db()
 
@@ -26,3 +32,15 @@ def setup_restapi_client():
     assert keys == []
 
     yield db, cache, configdb, restapi_client
+
+@pytest.fixture()
+def msee_mock():
+    mock = MockDataPlane(MOCK_MSEE_HOST)
+    mock.reset()
+    yield mock
+
+@pytest.fixture()
+def arp_mock():
+    mock = MockDataPlane(MOCK_ARP_HOST)
+    mock.reset()
+    yield mock
@@ -12,6 +12,8 @@ VLAN_NAME_PREF    = "Vlan"
 
 TEST_HOST = 'http://localhost:8090/'
 TEST_HOST_HTTPS = "https://localhost:8081/"
+MOCK_MSEE_HOST = 'http://localhost:9190/'
+MOCK_ARP_HOST = 'http://localhost:9191/'
 
 class RESTAPI_client:
 
@@ -20,33 +22,39 @@ class RESTAPI_client:
 
     maxDiff = None
 
-    def post(self, url, body = []):
+    def post(self, url, body = [], headers = {}):
         if body == None:
             data = None
         else:
             data = json.dumps(body)
 
+        req_headers = {'Content-Type': 'application/json'}
+        req_headers.update(headers)
+
         logging.info("Request POST: %s" % url)
         logging.info("JSON Body: %s" % data)
-        r = requests.post(TEST_HOST + url, data=data, headers={'Content-Type': 'application/json'})
+        r = requests.post(TEST_HOST + url, data=data, headers=req_headers)
         logging.info('Response Code: %s' % r.status_code)
         logging.info('Response Body: %s' % r.text)
         return r
 
-    def patch(self, url, body = []):
+    def patch(self, url, body = [], headers = {}):
         if body == None:
             data = None
         else:
             data = json.dumps(body)
 
+        req_headers = {'Content-Type': 'application/json'}
+        req_headers.update(headers)
+
         logging.info("Request PATCH: %s" % url)
         logging.info("JSON Body: %s" % data)
-        r = requests.patch(TEST_HOST + url, data=data, headers={'Content-Type': 'application/json'})
+        r = requests.patch(TEST_HOST + url, data=data, headers=req_headers)
         logging.info('Response Code: %s' % r.status_code)
         logging.info('Response Body: %s' % r.text)
         return r
 
-    def get(self, url, body = [], params = {}, client_cert=None):
+    def get(self, url, body = [], params = {}, client_cert=None, headers = {}):
         """
         :param client_cert: tuple of (cert_file, key_file) for client certificate authentication
         """
@@ -55,25 +63,31 @@ class RESTAPI_client:
         else:
             data = json.dumps(body)
 
+        req_headers = {'Content-Type': 'application/json'}
+        req_headers.update(headers)
+
         logging.info("Request GET: %s" % url)
         logging.info("JSON Body: %s" % data)
         if client_cert:
-            r = requests.get(TEST_HOST_HTTPS + url, data=data, params=params, headers={'Content-Type': 'application/json'}, cert=client_cert, verify=False)
+            r = requests.get(TEST_HOST_HTTPS + url, data=data, params=params, headers=req_headers, cert=client_cert, verify=False)
         else:
-            r = requests.get(TEST_HOST + url, data=data, params=params, headers={'Content-Type': 'application/json'})
+            r = requests.get(TEST_HOST + url, data=data, params=params, headers=req_headers)
         logging.info('Response Code: %s' % r.status_code)
         logging.info('Response Body: %s' % r.text)
         return r
 
-    def delete(self, url, body = [], params = {}):
+    def delete(self, url, body = [], params = {}, headers = {}):
         if body == None:
             data = None
         else:
             data = json.dumps(body)
 
+        req_headers = {'Content-Type': 'application/json'}
+        req_headers.update(headers)
+
         logging.info("Request DELETE: %s" % url)
         logging.info("JSON Body: %s" % data)
-        r = requests.delete(TEST_HOST + url, data=data, params=params, headers={'Content-Type': 'application/json'})
+        r = requests.delete(TEST_HOST + url, data=data, params=params, headers=req_headers)
         logging.info('Response Code: %s' % r.status_code)
         logging.info('Response Body: %s' % r.text)
         return r
@@ -81,6 +95,39 @@ class RESTAPI_client:
     def get_heartbeat(self, client_cert=None):
         return self.get("v1/state/heartbeat", client_cert=client_cert)
 
+    def get_healthz(self):
+        return self.get('healthz')
+
+    def get_readyz(self):
+        return self.get('readyz')
+
+    def get_openapi_spec(self):
+        return self.get('v1/openapi.yaml')
+
+    def get_msee_counters(self, group):
+        return self.get('v1/state/msee/counters/' + group)
+
+    def get_msee_statistics(self, group):
+        return self.get('v1/state/msee/statistics/' + group)
+
+    def get_msee_histogram(self):
+        return self.get('v1/state/msee/histogram')
+
+    def post_config_arp_interface(self, if_name, dry_run=False):
+        return self.post('v1/config/arp/interface/' + if_name + ('?dry_run=true' if dry_run else ''), None)
+
+    def delete_config_arp_interface(self, if_name):
+        return self.delete('v1/config/arp/interface/' + if_name)
+
+    def post_config_arp_interface_ip(self, if_name, stag, ctag, value, dry_run=False):
+        return self.post('v1/config/arp/interface/' + if_name + '/' + str(stag) + '/' + str(ctag) + ('?dry_run=true' if dry_run else ''), value)
+
+    def delete_config_arp_interface_ip(self, if_name, stag, ctag):
+        return self.delete('v1/config/arp/interface/' + if_name + '/' + str(stag) + '/' + str(ctag))
+
+    def post_arp_resolve(self, value):
+        return self.post('v1/state/arp/resolve', value)
+
     def get_config_reset_status(self):
         return self.get('v1/config/resetstatus')
 
@@ -88,24 +135,24 @@ class RESTAPI_client:
         return self.post('v1/config/resetstatus', value)
 
     # BGP Community String
-    def get_bgp_community_string(self, profile_name):
-        return self.get('v1/config/bgp/profile/{profile_name}'.format(profile_name=profile_name))
+    def get_bgp_community_string(self, profile_name, headers = {}):
+        return self.get('v1/config/bgp/profile/{profile_name}'.format(profile_name=profile_name), headers=headers)
 
-    def post_bgp_community_string(self, profile_name, value):
-        return self.post('v1/config/bgp/profile/{profile_name}'.format(profile_name=profile_name), value)
+    def post_bgp_community_string(self, profile_name, value, headers = {}):
+        return self.post('v1/config/bgp/profile/{profile_name}'.format(profile_name=profile_name), value, headers=headers)
 
-    def delete_bgp_community_string(self, profile_name):
-        return self.delete('v1/config/bgp/profile/{profile_name}'.format(profile_name=profile_name))
+    def delete_bgp_community_string(self, profile_name, headers = {}):
+        return self.delete('v1/config/bgp/profile/{profile_name}'.format(profile_name=profile_name), headers=headers)
 
     # VRF/VNET
-    def post_config_vrouter_vrf_id(self, vrf_id, value):
-        return self.post('v1/config/vrouter/{vrf_id}'.format(vrf_id=vrf_id), value)
+    def post_config_vrouter_vrf_id(self, vrf_id, value, headers = {}):
+        return self.post('v1/config/vrouter/{vrf_id}'.format(vrf_id=vrf_id), value, headers=headers)
 
-    def get_config_vrouter_vrf_id(self, vrf_id):
-        return self.get('v1/config/vrouter/{vrf_id}'.format(vrf_id=vrf_id))
+    def get_config_vrouter_vrf_id(self, vrf_id, headers = {}):
+        return self.get('v1/config/vrouter/{vrf_id}'.format(vrf_id=vrf_id), headers=headers)
 
-    def delete_config_vrouter_vrf_id(self, vrf_id):
-        return self.delete('v1/config/vrouter/{vrf_id}'.format(vrf_id=vrf_id))
+    def delete_config_vrouter_vrf_id(self, vrf_id, headers = {}):
+        return self.delete('v1/config/vrouter/{vrf_id}'.format(vrf_id=vrf_id), headers=headers)
 
     # Encap
     def post_config_tunnel_encap_vxlan_vnid(self, vnid, value):
@@ -128,14 +175,14 @@ class RESTAPI_client:
         return self.delete('v1/config/tunnel/decap/{tunnel_type}'.format(tunnel_type=tunnel_type))
 
     # Vlan
-    def post_config_vlan(self, vlan_id, value):
-        return self.post('v1/config/interface/vlan/{vlan_id}'.format(vlan_id=vlan_id), value) 
+    def post_config_vlan(self, vlan_id, value, headers = {}):
+        return self.post('v1/config/interface/vlan/{vlan_id}'.format(vlan_id=vlan_id), value, headers=headers)
 
-    def get_config_vlan(self, vlan_id):
-        return self.get('v1/config/interface/vlan/{vlan_id}'.format(vlan_id=vlan_id)) 
+    def get_config_vlan(self, vlan_id, headers = {}):
+        return self.get('v1/config/interface/vlan/{vlan_id}'.format(vlan_id=vlan_id), headers=headers)
 
-    def delete_config_vlan(self, vlan_id):
-        return self.delete('v1/config/interface/vlan/{vlan_id}'.format(vlan_id=vlan_id)) 
+    def delete_config_vlan(self, vlan_id, headers = {}):
+        return self.delete('v1/config/interface/vlan/{vlan_id}'.format(vlan_id=vlan_id), headers=headers)
 
     def get_config_interface_vlans(self, vnet_id=None):
         params = {}
@@ -188,19 +235,25 @@ class RESTAPI_client:
             params['vnid'] = vnid
         return self.delete('v1/config/vrouter/{vrf_id}/routes'.format(vrf_id=vrf_id), value, params=params)
 
-    def get_config_vrouter_vrf_id_routes(self, vrf_id, vnid=None, ip_prefix=None):
+    def get_config_vrouter_vrf_id_routes(self, vrf_id, vnid=None, ip_prefix=None, ndjson=False):
         params = {}
         if vnid != None:
             params['vnid'] = vnid
         if ip_prefix != None:
             params['ip_prefix'] = ip_prefix
-        return self.get('v1/config/vrouter/{vrf_id}/routes'.format(vrf_id=vrf_id), params=params)
+        headers = {}
+        if ndjson:
+            headers['Accept'] = 'application/x-ndjson'
+        return self.get('v1/config/vrouter/{vrf_id}/routes'.format(vrf_id=vrf_id), params=params, headers=headers)
 
-    def get_config_vrf_vrf_id_routes(self, vrf_id, ip_prefix=None):
+    def get_config_vrf_vrf_id_routes(self, vrf_id, ip_prefix=None, ndjson=False):
         params = {}
         if ip_prefix != None:
             params['ip_prefix'] = ip_prefix
-        return self.get('v1/config/vrf/{vrf_id}/routes'.format(vrf_id=vrf_id), params=params)
+        headers = {}
+        if ndjson:
+            headers['Accept'] = 'application/x-ndjson'
+        return self.get('v1/config/vrf/{vrf_id}/routes'.format(vrf_id=vrf_id), params=params, headers=headers)
 
     # Static Route Expiry Timer
     def get_rt_expiry_timer(self):
@@ -209,7 +262,29 @@ class RESTAPI_client:
     def post_rt_expiry_timer(self, value):
         return self.post('v1/config/vrf/route_expiry', value)
 
+    # Maintenance mode
+    def get_config_maintenance(self):
+        return self.get('v1/config/maintenance')
+
+    def post_config_maintenance(self, value):
+        return self.post('v1/config/maintenance', value)
+
     # In memory DB restart
+    # Snapshot
+    def get_config_snapshot(self):
+        return self.get('v1/config/snapshot')
+
+    def post_config_snapshot(self, value, mode=None, dry_run=False):
+        params = []
+        if mode != None:
+            params.append('mode=' + mode)
+        if dry_run:
+            params.append('dry_run=true')
+        url = 'v1/config/snapshot'
+        if params:
+            url += '?' + '&'.join(params)
+        return self.post(url, value)
+
     def post_config_restart_in_mem_db(self):
         return self.post('v1/config/restartdb')
 
@@ -301,3 +376,33 @@ class RESTAPI_client:
        for route in routes_arr:
            route_table = self.db.hgetall(LOCAL_ROUTE_TB + ':' + VNET_NAME_PREF +str(vnet_num_mapped)+':'+route['ip_prefix'])
            assert route_table == {}
+
+
+class MockDataPlane:
+    """ Call log and fixture endpoint of thrift_mock_server or arp_mock_server """
+
+    def __init__(self, host):
+        self.host = host
+
+    def reset(self):
+        r = requests.post(self.host + 'reset')
+        assert r.status_code == 204
+
+    def load(self, fixture):
+        r = requests.put(self.host + 'fixture', data=json.dumps(fixture))
+        assert r.status_code == 204
+
+    def calls(self, *methods):
+        """ Logged calls, only those to the given methods if any """
+        r = requests.get(self.host + 'calls')
+        assert r.status_code == 200
+        return [c for c in r.json() if not methods or c['method'] in methods]
+
+    def clear_calls(self):
+        r = requests.delete(self.host + 'calls')
+        assert r.status_code == 204
+
+    def state(self):
+        r = requests.get(self.host + 'state')
+        assert r.status_code == 200
+        return r.json()
@@ -3,6 +3,8 @@
 import logging
 import json
 import os
+import pytest
+import uuid
 
 # DB Names
 VXLAN_TUNNEL_TB   = "VXLAN_TUNNEL"
@@ -1693,6 +1695,49 @@ class TestRestApiPositive:
         j = json.loads(r.text)
         assert sorted(j) == sorted(routes_cleaned)
 
+    def test_routes_get_ndjson(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        routes = []
+        for i in range (1,300):
+             routes.append({'cmd':'add',
+                            'ip_prefix':'10.3.'+str(i)+'.0/24',
+                            'nexthop':'192.168.3.'+str(i%250),
+                            'vnid': 1 + i%5,
+                            'mac_address':'00:08:aa:bb:cd:'+hex(16+i%200)[2:]})
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", routes)
+        assert r.status_code == 204
+        for route in routes:
+             del route['cmd']
+
+        # Default is a single JSON array
+        r = restapi_client.get_config_vrouter_vrf_id_routes("vnet-guid-1")
+        assert r.status_code == 200
+        assert r.headers['Content-Type'] == 'application/json; charset=UTF-8'
+        assert sorted(json.loads(r.text)) == sorted(routes)
+
+        # One route per line when asked for NDJSON
+        r = restapi_client.get_config_vrouter_vrf_id_routes("vnet-guid-1", ndjson=True)
+        assert r.status_code == 200
+        assert r.headers['Content-Type'] == 'application/x-ndjson'
+        lines = r.text.splitlines()
+        assert len(lines) == len(routes)
+        assert sorted([json.loads(l) for l in lines]) == sorted(routes)
+
+        # Empty result is an empty body
+        r = restapi_client.get_config_vrouter_vrf_id_routes("vnet-guid-1", vnid=4242, ndjson=True)
+        assert r.status_code == 200
+        assert r.text == ''
+
+        # Static routes stream the same way
+        static_routes = [{'cmd':'add', 'ip_prefix':'20.3.'+str(i)+'.0/24', 'nexthop':'192.168.4.'+str(i)} for i in range(1,50)]
+        r = restapi_client.patch_config_vrf_vrf_id_routes(DEFAULT_VRF, static_routes)
+        assert r.status_code == 204
+        r = restapi_client.get_config_vrf_vrf_id_routes(DEFAULT_VRF, ndjson=True)
+        assert r.status_code == 200
+        assert r.headers['Content-Type'] == 'application/x-ndjson'
+        assert len(r.text.splitlines()) == len(static_routes)
+
     def test_vrf_static_routes_patch(self, setup_restapi_client):
         _, _, _, restapi_client = setup_restapi_client
         routes = []
@@ -2018,6 +2063,338 @@ class TestRestApiPositive:
         r = restapi_client.post_ping({"ip_addr" : "8.8.8.8"})
         assert r.status_code == 200
         
+    # Optimistic concurrency
+    def test_etag_bgp_profile(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_bgp_community_string("profile1", {"community_id": "1234:1235"})
+        assert r.status_code == 204
+        etag = r.headers['ETag']
+
+        r = restapi_client.get_bgp_community_string("profile1")
+        assert r.status_code == 200
+        assert r.headers['ETag'] == etag
+
+        r = restapi_client.get_bgp_community_string("profile1", headers={'If-None-Match': etag})
+        assert r.status_code == 304
+
+        # First writer wins, second one holds a stale ETag
+        r = restapi_client.post_bgp_community_string("profile1", {"community_id": "1234:1236"}, headers={'If-Match': etag})
+        assert r.status_code == 204
+        new_etag = r.headers['ETag']
+        assert new_etag != etag
+
+        r = restapi_client.post_bgp_community_string("profile1", {"community_id": "1234:1237"}, headers={'If-Match': etag})
+        assert r.status_code == 412
+        r = restapi_client.delete_bgp_community_string("profile1", headers={'If-Match': etag})
+        assert r.status_code == 412
+        r = restapi_client.get_bgp_community_string("profile1")
+        assert r.json() == {"community_id": "1234:1236"}
+
+        r = restapi_client.delete_bgp_community_string("profile1", headers={'If-Match': new_etag})
+        assert r.status_code == 204
+
+    def test_etag_vrouter_create_only(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vxlan_tunnel()
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001}, headers={'If-None-Match': '*'})
+        assert r.status_code == 204
+        etag = r.headers['ETag']
+
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001}, headers={'If-None-Match': '*'})
+        assert r.status_code == 412
+
+        r = restapi_client.get_config_vrouter_vrf_id("vnet-guid-1")
+        assert r.status_code == 200
+        assert r.headers['ETag'] == etag
+
+        r = restapi_client.delete_config_vrouter_vrf_id("vnet-guid-1", headers={'If-Match': '"stale"'})
+        assert r.status_code == 412
+        r = restapi_client.delete_config_vrouter_vrf_id("vnet-guid-1", headers={'If-Match': etag})
+        assert r.status_code == 204
+
+    def test_etag_vlan(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        r = restapi_client.post_config_vlan(2, {'vnet_id': 'vnet-guid-1'})
+        assert r.status_code == 204
+        etag = r.headers['ETag']
+
+        r = restapi_client.get_config_vlan(2)
+        assert r.status_code == 200
+        assert r.headers['ETag'] == etag
+
+        r = restapi_client.delete_config_vlan(2, headers={'If-Match': '"stale", ' + etag})
+        assert r.status_code == 204
+
+        r = restapi_client.delete_config_vlan(2, headers={'If-Match': etag})
+        assert r.status_code == 404
+
+    # Idempotency keys
+    def test_idempotency_key_vrouter_post(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vxlan_tunnel()
+        key = {'Idempotency-Key': str(uuid.uuid4())}
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001}, headers=key)
+        assert r.status_code == 204
+        assert 'Idempotent-Replayed' not in r.headers
+
+        # A retry gets the original answer instead of RESRC_EXISTS
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001}, headers=key)
+        assert r.status_code == 204
+        assert r.headers['Idempotent-Replayed'] == 'true'
+
+        # Same key for another request is refused
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1002}, headers=key)
+        assert r.status_code == 422
+
+        # Without a key the duplicate is still a conflict
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001})
+        assert r.status_code == 409
+        j = json.loads(r.text)
+        assert RESRC_EXISTS == j['error']['sub-code']
+
+    def test_idempotency_key_routes_patch(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        key = {'Idempotency-Key': str(uuid.uuid4())}
+        routes = [{'cmd':'add', 'ip_prefix':'10.2.1.0/24', 'nexthop':'192.168.2.1'},
+                  {'cmd':'delete', 'ip_prefix':'10.2.2.0/24', 'nexthop':'192.168.2.2'}]
+        r = restapi_client.patch('v1/config/vrouter/vnet-guid-1/routes', routes, headers=key)
+        assert r.status_code == 207
+        first = r.json()
+
+        r = restapi_client.patch('v1/config/vrouter/vnet-guid-1/routes', routes, headers=key)
+        assert r.status_code == 207
+        assert r.headers['Idempotent-Replayed'] == 'true'
+        assert r.json() == first
+
+    def test_dry_run_vrouter_post(self, setup_restapi_client):
+        _, _, configdb, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vxlan_tunnel()
+        r = restapi_client.post('v1/config/vrouter/vnet-guid-1?dry_run=true', {'vnid': 1001})
+        assert r.status_code == 200
+        j = r.json()
+        assert j['dry_run'] == True
+        assert j['status'] == 204
+        assert j['writes'] == [{
+            'db': 'CONFIG_DB',
+            'table': VNET_TB,
+            'key': VNET_NAME_PREF + '1',
+            'op': 'SET',
+            'values': {
+                'vxlan_tunnel': 'default_vxlan_tunnel',
+                'vni': '1001',
+                'guid': 'vnet-guid-1',
+            },
+        }]
+        assert configdb.keys(VNET_TB + '|*') == []
+
+        # Nothing was claimed, the real request gets the same VNET id
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001})
+        assert r.status_code == 204
+        assert configdb.hgetall(VNET_TB + '|' + VNET_NAME_PREF + '1') == {
+            b'vxlan_tunnel': b'default_vxlan_tunnel',
+            b'vni': b'1001',
+            b'guid': b'vnet-guid-1',
+        }
+
+        # Failures are reported exactly like the real request would
+        r = restapi_client.post('v1/config/vrouter/vnet-guid-1?dry_run=true', {'vnid': 1001})
+        assert r.status_code == 409
+        j = json.loads(r.text)
+        assert RESRC_EXISTS == j['error']['sub-code']
+
+    def test_dry_run_vlan_delete(self, setup_restapi_client):
+        _, _, configdb, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        r = restapi_client.post_config_vlan(2, {'vnet_id': 'vnet-guid-1', 'ip_prefix': '10.1.1.0/24'})
+        assert r.status_code == 204
+        keys = sorted(configdb.keys())
+
+        r = restapi_client.delete('v1/config/interface/vlan/2?dry_run=1')
+        assert r.status_code == 200
+        j = r.json()
+        assert j['status'] == 204
+        assert [(w['db'], w['table'], w['key'], w['op']) for w in j['writes']] == [
+            ('APPL_DB', 'VNET_ROUTE_TABLE', VNET_NAME_PREF + '1:10.1.1.0/24', 'DEL'),
+            ('CONFIG_DB', VLAN_INTF_TB, VLAN_NAME_PREF + '2|10.1.1.0/24', 'DEL'),
+            ('CONFIG_DB', VLAN_INTF_TB, VLAN_NAME_PREF + '2', 'DEL'),
+            ('CONFIG_DB', VLAN_TB, VLAN_NAME_PREF + '2', 'DEL'),
+        ]
+        assert sorted(configdb.keys()) == keys
+
+    def test_dry_run_routes_patch(self, setup_restapi_client):
+        db, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        routes = [{'cmd':'add', 'ip_prefix':'10.2.1.0/24', 'nexthop':'192.168.2.1'},
+                  {'cmd':'delete', 'ip_prefix':'10.2.2.0/24', 'nexthop':'192.168.2.2'}]
+        r = restapi_client.patch('v1/config/vrouter/vnet-guid-1/routes?dry_run=true', routes)
+        assert r.status_code == 200
+        j = r.json()
+        assert j['status'] == 207
+        assert len(j['response']['failed']) == 1
+        assert [(w['table'], w['key'], w['op']) for w in j['writes']] == [
+            ('VNET_ROUTE_TUNNEL_TABLE', VNET_NAME_PREF + '1:10.2.1.0/24', 'SET'),
+        ]
+        assert db.keys(ROUTE_TUN_TB + ':*') == []
+
+    def test_dry_run_invalid(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vxlan_tunnel()
+        r = restapi_client.post('v1/config/vrouter/vnet-guid-1?dry_run=maybe', {'vnid': 1001})
+        assert r.status_code == 400
+        r = restapi_client.post('v1/config/vrouter/vnet-guid-1?dry_run=false', {'vnid': 1001})
+        assert r.status_code == 204
+
+    def post_snapshot_config(self, restapi_client):
+        restapi_client.post_generic_vrouter_and_deps()
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-2", {'vnid': 1002, 'advertise_prefix': 'true'})
+        assert r.status_code == 204
+        r = restapi_client.post_bgp_community_string("bgp-profile", {'community_id': '1234:1235'})
+        assert r.status_code == 204
+        r = restapi_client.post_config_vlan(2, {'vnet_id': 'vnet-guid-1', 'ip_prefix': '10.1.1.1/24'})
+        assert r.status_code == 204
+        r = restapi_client.post_config_vlan_member(2, 'Ethernet0', {'tagging_mode': 'tagged'})
+        assert r.status_code == 204
+        r = restapi_client.post_config_vlan_neighbor(2, '10.1.1.5')
+        assert r.status_code == 204
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", [
+            {'cmd': 'add', 'ip_prefix': '10.2.1.0/24', 'nexthop': '192.168.2.1', 'vnid': 7000},
+        ])
+        assert r.status_code == 204
+        r = restapi_client.patch_config_vrf_vrf_id_routes("default", [
+            {'cmd': 'add', 'ip_prefix': '10.3.1.0/24', 'nexthop': '192.168.3.1'},
+            {'cmd': 'add', 'ip_prefix': '10.3.2.0/24', 'nexthop': '192.168.3.2', 'persistent': 'true'},
+        ])
+        assert r.status_code == 204
+        r = restapi_client.post_rt_expiry_timer({'time': 60})
+        assert r.status_code == 204
+
+    def reset_snapshot_dbs(self, db, cache, configdb, restapi_client):
+        db.flushdb()
+        cache.flushdb()
+        configdb.flushdb()
+        restapi_client.post_config_restart_in_mem_db()
+
+    def test_snapshot_get(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        self.post_snapshot_config(restapi_client)
+
+        r = restapi_client.get_config_snapshot()
+        assert r.status_code == 200
+        j = r.json()
+        assert j['version'] == 1
+        assert j['tunnels'] == [{'tunnel_type': 'vxlan', 'attr': {'ip_addr': '34.53.1.0'}}]
+        assert j['vnets'] == [
+            {'vnet_id': 'vnet-guid-1', 'attr': {'vnid': 1001}},
+            {'vnet_id': 'vnet-guid-2', 'attr': {'vnid': 1002, 'advertise_prefix': 'true'}},
+        ]
+        assert j['bgp_profiles'] == [{'profile_name': 'bgp-profile', 'attr': {'community_id': '1234:1235'}}]
+        assert j['vlans'] == [{'vlan_id': 2, 'ip_prefix': '10.1.1.1/24', 'vnet_id': 'vnet-guid-1'}]
+        assert j['vlan_members'] == [{'vlan_id': 2, 'if_name': 'Ethernet0', 'attr': {'tagging_mode': 'tagged'}}]
+        assert j['vlan_neighbors'] == [{'vlan_id': 2, 'ip_addr': '10.1.1.5'}]
+        # The subnet route of the VLAN is not listed, restoring the VLAN brings it back
+        assert len(j['vnet_routes']) == 1
+        assert j['vnet_routes'][0]['vnet_id'] == 'vnet-guid-1'
+        assert [rt['ip_prefix'] for rt in j['vnet_routes'][0]['routes']] == ['10.2.1.0/24']
+        assert len(j['static_routes']) == 1
+        assert j['static_routes'][0]['vrf_id'] == 'default'
+        assert [rt['ip_prefix'] for rt in j['static_routes'][0]['routes']] == ['10.3.1.0/24', '10.3.2.0/24']
+        assert j['route_expiry'] == {'time': 60}
+
+    def test_snapshot_restore(self, setup_restapi_client):
+        db, cache, configdb, restapi_client = setup_restapi_client
+        self.post_snapshot_config(restapi_client)
+        snapshot = restapi_client.get_config_snapshot().json()
+
+        self.reset_snapshot_dbs(db, cache, configdb, restapi_client)
+        r = restapi_client.post_config_snapshot(snapshot, dry_run=True)
+        assert r.status_code == 200
+        j = r.json()
+        assert j['dry_run'] == True
+        assert [(op['method'], op['path']) for op in j['planned']] == [
+            ('POST', '/v1/config/tunnel/decap/vxlan'),
+            ('POST', '/v1/config/vrouter/vnet-guid-1'),
+            ('POST', '/v1/config/vrouter/vnet-guid-2'),
+            ('POST', '/v1/config/bgp/profile/bgp-profile'),
+            ('POST', '/v1/config/interface/vlan/2'),
+            ('POST', '/v1/config/interface/vlan/2/member/Ethernet0'),
+            ('POST', '/v1/config/interface/vlan/2/neighbor/10.1.1.5'),
+            ('PATCH', '/v1/config/vrouter/vnet-guid-1/routes'),
+            ('PATCH', '/v1/config/vrf/default/routes'),
+            ('POST', '/v1/config/vrf/route_expiry'),
+        ]
+        assert configdb.keys() == []
+
+        r = restapi_client.post_config_snapshot(snapshot)
+        assert r.status_code == 200
+        assert len(r.json()['applied']) == 10
+        assert restapi_client.get_config_snapshot().json() == snapshot
+        vrouter_table = configdb.hgetall(VNET_TB + '|' + VNET_NAME_PREF + '2')
+        assert vrouter_table[b'guid'] == b'vnet-guid-2'
+
+        # Restoring the same snapshot again has nothing to do
+        r = restapi_client.post_config_snapshot(snapshot, dry_run=True)
+        assert r.status_code == 200
+        assert 'planned' not in r.json()
+
+    def test_snapshot_restore_replace(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        self.post_snapshot_config(restapi_client)
+        snapshot = restapi_client.get_config_snapshot().json()
+        snapshot['vnets'] = snapshot['vnets'][:1]
+        snapshot['vlan_neighbors'] = []
+        snapshot['bgp_profiles'] = []
+
+        # Merge never deletes
+        r = restapi_client.post_config_snapshot(snapshot, mode='merge', dry_run=True)
+        assert r.status_code == 200
+        assert 'planned' not in r.json()
+
+        r = restapi_client.post_config_snapshot(snapshot, mode='replace', dry_run=True)
+        assert r.status_code == 200
+        assert [(op['method'], op['path']) for op in r.json()['planned']] == [
+            ('DELETE', '/v1/config/interface/vlan/2/neighbor/10.1.1.5'),
+            ('DELETE', '/v1/config/bgp/profile/bgp-profile'),
+            ('DELETE', '/v1/config/vrouter/vnet-guid-2'),
+        ]
+
+        r = restapi_client.post_config_snapshot(snapshot, mode='replace')
+        assert r.status_code == 200
+        assert restapi_client.get_config_snapshot().json() == snapshot
+        r = restapi_client.get_config_vrouter_vrf_id("vnet-guid-2")
+        assert r.status_code == 404
+
+    def test_snapshot_restore_conflict(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        self.post_snapshot_config(restapi_client)
+        snapshot = restapi_client.get_config_snapshot().json()
+        snapshot['vnets'][1]['attr']['vnid'] = 1003
+        snapshot['bgp_profiles'][0]['attr']['community_id'] = '1234:9999'
+
+        # The VNET handler refuses to redefine an existing VNET
+        r = restapi_client.post_config_snapshot(snapshot, mode='merge')
+        assert r.status_code == 207
+        j = r.json()
+        assert [(op['method'], op['path']) for op in j['failed']] == [('POST', '/v1/config/vrouter/vnet-guid-2')]
+        assert j['failed'][0]['error_code'] == 409
+        assert RESRC_EXISTS == j['failed'][0]['error']['error']['sub-code']
+        assert [(op['method'], op['path']) for op in j['skipped']] == [('POST', '/v1/config/bgp/profile/bgp-profile')]
+
+    def test_snapshot_invalid(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_config_snapshot()
+        snapshot = r.json()
+        r = restapi_client.post_config_snapshot(snapshot, mode='overwrite')
+        assert r.status_code == 400
+        snapshot['version'] = 2
+        r = restapi_client.post_config_snapshot(snapshot)
+        assert r.status_code == 400
+        snapshot['version'] = 1
+        snapshot['vnets'] = [{'vnet_id': 'vnet-guid-1', 'attr': {}}]
+        r = restapi_client.post_config_snapshot(snapshot)
+        assert r.status_code == 400
+
 class TestRestApiNegative():
     """Invalid input tests"""
     # BGP Community
@@ -2642,3 +3019,537 @@ class TestRestApiNegative():
         # vnet_id not found 404 error
         r = restapi_client.post_ping({'vnet_id' : 'vnet-1', 'ip_addr' : '8.8.8.8'})
         assert r.status_code == 404
+
+    # Validation errors
+    def test_patch_routes_all_violations(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", [
+            {'cmd':'update', 'ip_prefix':'10.1.2.0/24', 'nexthop':'192.168.2.1'},
+            {'cmd':'add', 'ip_prefix':'10.1.2.0/33', 'nexthop':'x'},
+            {'ip_prefix':'10.1.3.0/24'}
+        ])
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['cmd', 'ip_prefix', 'nexthop']
+        assert [(v['path'], v['constraint']) for v in j['error']['violations']] == [
+            ('/0/cmd', 'enum'),
+            ('/1/ip_prefix', 'format'),
+            ('/1/nexthop', 'format'),
+            ('/2/cmd', 'required'),
+            ('/2/nexthop', 'required'),
+        ]
+        assert j['error']['violations'][1]['value'] == '10.1.2.0/33'
+
+    # Limits
+    def test_post_vrouter_body_too_large(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-2", {
+            'vnid': 1002,
+            'guid': 'x' * (2 << 20)
+        })
+        assert r.status_code == 413
+        j = json.loads(r.text)
+        assert j['error']['message'] == "Request body too large"
+        r = restapi_client.get_config_vrouter_vrf_id("vnet-guid-2")
+        assert r.status_code == 404
+
+    def test_post_vrouter_all_violations(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-2", {
+            'vnid': 'x',
+            'advertise_prefix': 'maybe',
+            'overlay_dmac': 'zz'
+        })
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['vnid', 'advertise_prefix', 'overlay_dmac']
+        assert [v['path'] for v in j['error']['violations']] == ['/vnid', '/advertise_prefix', '/overlay_dmac']
+
+
+# Needs the mock Thrift server, which the test docker starts
+class TestRestApiNamespace:
+    """Single ASIC, only the default namespace"""
+    def test_default_namespace(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get('v1/state/heartbeat', params={'namespace': ''})
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert 'namespaces' not in j
+
+    def test_unknown_namespace(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        for params in [{'namespace': 'asic7'}, {'asic': '7'}, {'asic': 'x'}, {'asic': '0', 'namespace': 'asic0'}]:
+            r = restapi_client.get('v1/state/heartbeat', params=params)
+            assert r.status_code == 400
+            j = json.loads(r.text)
+            assert j['error']['fields'] == ['namespace']
+
+        r = restapi_client.get('v1/config/vrouter/Vnet1', params={'namespace': 'asic7'})
+        assert r.status_code == 400
+
+
+class TestRestApiOpenAPI:
+    def test_openapi_spec(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_openapi_spec()
+        assert r.status_code == 200
+        assert r.headers['Content-Type'] == 'application/yaml'
+        assert "swagger: '2.0'" in r.text
+        assert 'operationId: StateHeartbeatGet' in r.text
+
+    def test_heartbeat_routes_available(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_heartbeat()
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        # There is no CRM in the test docker
+        assert j['routes_available'] == -1
+
+
+class TestRestApiV2:
+    def test_error_catalog(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get('v2/errors')
+        assert r.status_code == 200
+        codes = {e['reason']: e for e in json.loads(r.text)}
+        assert codes['RESOURCE_EXISTS']['sub-code'] == RESRC_EXISTS
+        assert codes['DEPENDENCY_MISSING']['sub-code'] == DEP_MISSING
+        assert codes['DELETE_DEPENDENCY']['sub-code'] == DELETE_DEP
+        assert codes['NOT_FOUND']['status'] == 404
+        assert codes['INVALID_ARGUMENT']['status'] == 422
+
+    def test_typed_vnet(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vxlan_tunnel()
+        r = restapi_client.post('v2/config/vrouter/vnet-guid-1', {'vnid': 1001, 'advertise_prefix': True})
+        assert r.status_code == 204
+        r = restapi_client.get('v2/config/vrouter/vnet-guid-1')
+        assert r.status_code == 200
+        assert json.loads(r.text) == {'vnet_id': 'vnet-guid-1', 'attr': {'vnid': 1001, 'advertise_prefix': True}}
+        # v1 still sees the string
+        r = restapi_client.get_config_vrouter_vrf_id('vnet-guid-1')
+        assert json.loads(r.text)['attr']['advertise_prefix'] == 'true'
+
+        r = restapi_client.post('v2/config/vrouter/vnet-guid-1', {'vnid': 1001})
+        assert r.status_code == 409
+        j = json.loads(r.text)
+        assert j['error']['sub-code'] == RESRC_EXISTS
+        assert j['error']['reason'] == 'RESOURCE_EXISTS'
+
+        r = restapi_client.post('v2/config/vrouter/vnet-guid-2', {'vnid': 1002, 'advertise_prefix': 'true'})
+        assert r.status_code == 422
+        j = json.loads(r.text)
+        assert j['error']['reason'] == 'INVALID_ARGUMENT'
+        assert j['error']['fields'] == ['advertise_prefix']
+
+    def test_typed_routes(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        r = restapi_client.patch('v2/config/vrouter/vnet-guid-1/routes', [{
+            'cmd': 'add',
+            'ip_prefix': '10.2.1.0/24',
+            'nexthop': ['192.168.2.1', '192.168.2.2'],
+            'weight': [1, 2],
+            'vnid': 7036001,
+        }])
+        assert r.status_code == 204
+        r = restapi_client.get('v2/config/vrouter/vnet-guid-1/routes')
+        assert r.status_code == 200
+        assert json.loads(r.text) == [{
+            'ip_prefix': '10.2.1.0/24',
+            'nexthop': ['192.168.2.1', '192.168.2.2'],
+            'weight': [1, 2],
+            'vnid': 7036001,
+        }]
+        r = restapi_client.get_config_vrouter_vrf_id_routes('vnet-guid-1')
+        assert json.loads(r.text)[0]['nexthop'] == '192.168.2.1,192.168.2.2'
+
+        r = restapi_client.patch('v2/config/vrouter/vnet-guid-1/routes', [{
+            'cmd': 'add',
+            'ip_prefix': '10.2.2.0/24',
+            'nexthop': '192.168.2.1',
+            'persistent': 'true',
+        }])
+        assert r.status_code == 422
+        assert sorted(json.loads(r.text)['error']['fields']) == ['[0].nexthop', '[0].persistent']
+
+    def test_not_found(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        for url in ['v2/config/bgp/profile/profile1', 'v2/config/vrf/route_expiry', 'v2/config/vrouter/vnet-guid-9']:
+            r = restapi_client.get(url)
+            assert r.status_code == 404
+            j = json.loads(r.text)
+            assert j['error']['sub-code'] == 3
+            assert j['error']['reason'] == 'NOT_FOUND'
+        r = restapi_client.delete('v2/config/bgp/profile/profile1')
+        assert r.status_code == 404
+        r = restapi_client.patch('v2/config/vrf/vrf1/routes', [])
+        assert r.status_code == 404
+        assert json.loads(r.text)['error']['fields'] == ['vrf_id']
+
+        # v1 keeps its answers
+        r = restapi_client.get_bgp_community_string('profile1')
+        assert r.status_code == 400
+
+class TestRestApiMaintenance:
+    def test_maintenance_mode(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_maintenance({'mode': 'maintenance', 'reason': 'upgrade', 'retry_after': 30})
+        assert r.status_code == 200
+        assert json.loads(r.text) == {'mode': 'maintenance', 'reason': 'upgrade', 'retry_after': 30, 'trigger': 'api'}
+        try:
+            r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001})
+            assert r.status_code == 503
+            assert r.headers['Retry-After'] == '30'
+            j = json.loads(r.text)
+            assert j['error']['message'] == "Maintenance mode"
+            assert j['error']['details'] == "upgrade"
+
+            # Reads keep working
+            r = restapi_client.get_config_vrouter_vrf_id("vnet-guid-1")
+            assert r.status_code == 404
+            r = restapi_client.get_heartbeat()
+            assert r.status_code == 200
+            j = json.loads(r.text)
+            assert j['maintenance'] == {'mode': 'maintenance', 'reason': 'upgrade', 'retry_after': 30, 'trigger': 'api'}
+        finally:
+            r = restapi_client.post_config_maintenance({'mode': 'normal'})
+            assert r.status_code == 200
+            assert json.loads(r.text) == {'mode': 'normal'}
+
+        r = restapi_client.get_config_maintenance()
+        assert json.loads(r.text) == {'mode': 'normal'}
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001})
+        assert r.status_code == 204
+
+    def test_maintenance_mode_invalid(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_maintenance({'mode': 'off'})
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['mode']
+
+        r = restapi_client.post_config_maintenance({'mode': 'maintenance', 'retry_after': -5})
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['retry_after']
+        r = restapi_client.get_config_maintenance()
+        assert json.loads(r.text) == {'mode': 'normal'}
+
+class TestRestApiHealth:
+    def test_healthz(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_healthz()
+        assert r.status_code == 200
+        assert json.loads(r.text) == {'status': 'pass'}
+
+    def test_readyz(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001})
+        assert r.status_code == 204
+        r = restapi_client.get_readyz()
+        j = json.loads(r.text)
+        for db in ['APPL_DB', 'COUNTER_DB', 'CONFIG_DB', 'RESTAPI_DB']:
+            assert j['checks']['redis:' + db]['status'] == 'pass'
+            assert j['checks']['swss:' + db]['status'] == 'pass'
+        assert j['checks']['cache:vnet'] == {'status': 'pass', 'message': '1 VNETs'}
+        assert j['checks']['maintenance'] == {'status': 'pass'}
+        failed = [name for name, check in j['checks'].items() if check['status'] == 'fail']
+        assert r.status_code == (503 if failed else 200)
+
+    def test_readyz_cache_out_of_sync(self, setup_restapi_client):
+        _, _, configdb, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_vrouter_vrf_id("vnet-guid-1", {'vnid': 1001})
+        assert r.status_code == 204
+        # The VNET cached by the server is gone from CONFIG_DB
+        configdb.flushdb()
+        r = restapi_client.get_readyz()
+        assert r.status_code == 503
+        j = json.loads(r.text)
+        assert j['status'] == 'fail'
+        assert j['checks']['cache:vnet']['status'] == 'fail'
+
+        restapi_client.post_config_restart_in_mem_db()
+        r = restapi_client.get_readyz()
+        j = json.loads(r.text)
+        assert j['checks']['cache:vnet'] == {'status': 'pass', 'message': '0 VNETs'}
+
+    def test_readyz_maintenance(self, setup_restapi_client):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_maintenance({'mode': 'maintenance', 'reason': 'upgrade'})
+        assert r.status_code == 200
+        try:
+            r = restapi_client.get_readyz()
+            j = json.loads(r.text)
+            assert j['checks']['maintenance'] == {'status': 'warn', 'message': 'maintenance mode, upgrade'}
+        finally:
+            restapi_client.post_config_maintenance({'mode': 'normal'})
+
+class TestRestApiMseeState:
+    def test_msee_counters(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_msee_counters('dpdk.switch_ports')
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j == {
+            'group': 'dpdk.switch_ports',
+            'counters': {'dpdk.switch_ports': {'0.decap_ok': 1, '0.encap_lpm_not_found': 2}}
+        }
+
+    def test_msee_counters_not_found(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_msee_counters('dpdk.unknown')
+        assert r.status_code == 404
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['group']
+        assert msee_mock.calls() == [{'method': 'get_counters', 'args': {'group': 'dpdk.unknown'}}]
+
+    def test_msee_statistics(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_msee_statistics('rings')
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j == {
+            'group': 'rings',
+            'statistics': {'rings': {'foo': 1, 'bar': 2}}
+        }
+
+    def test_msee_histogram(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_msee_histogram()
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j == {
+            'histogram': {
+                '0': {'1': 1.1, '2': 2.1},
+                '1': {'1': 1.1, '2': 2.1}
+            }
+        }
+
+    def test_msee_counters_cached(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        # A group of its own, so no earlier test has it cached
+        group = 'cached.' + str(uuid.uuid4())
+        msee_mock.load({'counters': {group: {'foo': 1}}})
+        r1 = restapi_client.get_msee_counters(group)
+        r2 = restapi_client.get_msee_counters(group)
+        assert r1.status_code == 200
+        assert r2.status_code == 200
+        assert json.loads(r1.text) == json.loads(r2.text)
+        assert msee_mock.calls() == [{'method': 'get_counters', 'args': {'group': group}}]
+
+
+# Needs the mock ARP responder, which the test docker starts
+class TestRestApiArpResponder:
+    def test_arp_interface(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_arp_interface('Ethernet0')
+        assert r.status_code == 204
+        r = restapi_client.post_config_arp_interface_ip('Ethernet0', 100, 200, {'ip_addr': '10.1.1.1'})
+        assert r.status_code == 204
+        assert arp_mock.state()['ips'] == [{'iface_name': 'Ethernet0', 'stag': 100, 'ctag': 200, 'ip': '10.1.1.1'}]
+        r = restapi_client.delete_config_arp_interface_ip('Ethernet0', 100, 200)
+        assert r.status_code == 204
+        r = restapi_client.delete_config_arp_interface('Ethernet0')
+        assert r.status_code == 204
+        assert arp_mock.calls() == [
+            {'method': 'add_interface', 'args': {'iface_name': 'Ethernet0'}, 'result': True},
+            {'method': 'add_ip', 'args': {'iface_name': 'Ethernet0', 'stag': 100, 'ctag': 200, 'ip': '10.1.1.1'}, 'result': True},
+            {'method': 'del_ip', 'args': {'iface_name': 'Ethernet0', 'stag': 100, 'ctag': 200}, 'result': True},
+            {'method': 'del_interface', 'args': {'iface_name': 'Ethernet0'}, 'result': True},
+        ]
+        assert arp_mock.state()['interfaces'] == []
+
+    def test_arp_heartbeat(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_arp_interface('Ethernet0')
+        assert r.status_code == 204
+        r = restapi_client.get_heartbeat()
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j['backends']['arp_responder'] == {'address': 'localhost:9091', 'reachable': True, 'circuit': 'closed'}
+
+    def test_arp_interface_refused(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_arp_interface_ip('Ethernet0', 100, 200, {'ip_addr': '10.1.1.1'})
+        assert r.status_code == 500
+        j = json.loads(r.text)
+        assert j['error']['message'] == "ARP responder error: add_ip failed"
+
+        arp_mock.load({'interfaces': ['Ethernet0'], 'results': {'add_interface': [False]}})
+        r = restapi_client.post_config_arp_interface('Ethernet4')
+        assert r.status_code == 500
+        assert arp_mock.state()['interfaces'] == ['Ethernet0']
+
+    def test_arp_interface_ip_invalid(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_arp_interface_ip('Ethernet0', 4096, 200, {'ip_addr': '10.1.1.1'})
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['stag']
+        r = restapi_client.post_config_arp_interface_ip('Ethernet0', 100, 200, {'ip_addr': '2001:db8::1'})
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['ip_addr']
+        assert arp_mock.calls() == []
+
+    def test_arp_interface_dry_run(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_config_arp_interface_ip('Ethernet0', 100, 200, {'ip_addr': '10.1.1.1'}, dry_run=True)
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j['dry_run'] == True
+        assert j['status'] == 204
+        assert j['writes'] == [{
+            'db': 'ARP_RESPONDER',
+            'table': 'IP',
+            'key': 'Ethernet0|100|200',
+            'op': 'SET',
+            'values': {'ip_addr': '10.1.1.1'}
+        }]
+        assert arp_mock.calls() == []
+
+    def test_arp_resolve(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        # Neighbors are only reachable over interfaces with an IP
+        arp_mock.load({
+            'interfaces': ['Ethernet0', 'Ethernet4'],
+            'ips': [{'iface_name': 'Ethernet4', 'stag': 300, 'ctag': 400, 'ip': '10.1.1.1'}],
+            'neighbors': [{'ip': '12.34.56.78', 'mac': '12:34:56:78:90:12'},
+                          {'ip': '90.12.34.56', 'mac': '34:56:78:90:12:34'}]
+        })
+        r = restapi_client.post_arp_resolve([
+            {'ip_addr': '12.34.56.78', 'interfaces': [{'if_name': 'Ethernet0', 'stag': 100, 'ctag': 200}]},
+            {'ip_addr': '1.2.3.4', 'interfaces': [{'if_name': 'Ethernet4', 'stag': 300, 'ctag': 400}]},
+            {'ip_addr': '90.12.34.56', 'interfaces': [{'if_name': 'Ethernet0', 'stag': 100, 'ctag': 200},
+                                                      {'if_name': 'Ethernet4', 'stag': 300, 'ctag': 400}]},
+        ])
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j == [
+            {'ip_addr': '12.34.56.78', 'found': False},
+            {'ip_addr': '1.2.3.4', 'found': False},
+            {'ip_addr': '90.12.34.56', 'found': True, 'mac_address': '34:56:78:90:12:34',
+             'interface': {'if_name': 'Ethernet4', 'stag': 300, 'ctag': 400}},
+        ]
+        calls = arp_mock.calls()
+        assert len(calls) == 1
+        assert calls[0]['method'] == 'request_mac'
+        assert [req['index'] for req in calls[0]['args']['requests']] == [0, 1, 2]
+        assert [req['ip'] for req in calls[0]['args']['requests']] == ['12.34.56.78', '1.2.3.4', '90.12.34.56']
+
+    def test_arp_resolve_invalid(self, setup_restapi_client, arp_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.post_arp_resolve([{'ip_addr': '12.34.56.78', 'interfaces': []}])
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['fields'] == ['interfaces']
+        assert arp_mock.calls() == []
+
+
+# Run against go-server-server started with -backend=msee and the mock Thrift
+# server, e.g. supervisorctl stop rest-api && supervisorctl start rest-api-msee
+@pytest.mark.skipif(os.environ.get('RESTAPI_BACKEND') != 'msee', reason="needs the msee backend")
+class TestRestApiMseeBackend:
+    def test_vnet_vlan_member_routes(self, setup_restapi_client, msee_mock):
+        db, _, configdb, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vlan_and_deps()
+        assert msee_mock.calls() == [
+            {'method': 'map_vni_to_vrf', 'args': {'vni': 1001, 'vrf_id': 1}, 'result': 'ADDED'},
+        ]
+        msee_mock.clear_calls()
+
+        r = restapi_client.post_config_vlan_member(2, 'Ethernet4', {'tagging_mode' : 'untagged'})
+        assert r.status_code == 204
+        assert configdb.hgetall(VLAN_MEMB_TB + '|' + VLAN_NAME_PREF + '2|Ethernet4') == {b'tagging_mode' : b'untagged'}
+        assert msee_mock.calls() == [
+            {'method': 'add_port_to_vrf', 'args': {'vrf_id': 1, 'port': 4, 'outer_vlan': 0, 'inner_vlan': 0}, 'result': 'ADDED'},
+        ]
+        msee_mock.clear_calls()
+
+        routes = [{'cmd':'add', 'ip_prefix':'10.2.1.0/24', 'nexthop':'34.53.1.0', 'vnid':1001, 'mac_address':'00:08:aa:bb:cd:01'},
+                  {'cmd':'add', 'ip_prefix':'2001:db8::/64', 'nexthop':'34.53.1.1', 'vnid':1001, 'mac_address':'00:08:aa:bb:cd:02'}]
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", routes)
+        assert r.status_code == 204
+        for route in routes:
+            del route['cmd']
+        restapi_client.check_routes_exist_in_tun_tb(1, routes)
+        assert msee_mock.calls() == [
+            {'method': 'add_encap_route', 'args': {'vrf_id': 1, 'dst_vm_ip_prefix': '10.2.1.0/24', 'dst_host_ip': '34.53.1.0',
+                                                   'dst_mac_address': '00:08:aa:bb:cd:01', 'vni': 1001, 'port': 4789}, 'result': 'ADDED'},
+            {'method': 'add_encap_route', 'args': {'vrf_id': 1, 'dst_vm_ip_prefix': '2001:db8::/64', 'dst_host_ip': '34.53.1.1',
+                                                   'dst_mac_address': '00:08:aa:bb:cd:02', 'vni': 1001, 'port': 4789}, 'result': 'ADDED'},
+        ]
+        msee_mock.clear_calls()
+
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", [{'cmd':'add', 'ip_prefix':'10.3.1.0/24', 'nexthop':'', 'ifname':'Ethernet8'}])
+        assert r.status_code == 204
+        assert db.hgetall(LOCAL_ROUTE_TB + ':' + VNET_NAME_PREF + '1:10.3.1.0/24') == {b'ifname' : b'Ethernet8'}
+        assert msee_mock.calls() == [
+            {'method': 'add_decap_route', 'args': {'vrf_id': 1, 'dst_ip_prefix': '10.3.1.0/24', 'mac': '00:00:00:00:00:00',
+                                                   'port': 8, 'outer_vlan': 0, 'inner_vlan': 0}, 'result': 'ADDED'},
+        ]
+
+        r = restapi_client.delete_config_vrouter_vrf_id_routes("vnet-guid-1")
+        assert r.status_code == 204
+        restapi_client.check_routes_dont_exist_in_tun_tb(1, routes)
+        state = msee_mock.state()
+        assert state['encap_routes'] == []
+        assert state['decap_routes'] == []
+
+        r = restapi_client.delete_config_vlan_member(2, 'Ethernet4')
+        assert r.status_code == 204
+        assert configdb.hgetall(VLAN_MEMB_TB + '|' + VLAN_NAME_PREF + '2|Ethernet4') == {}
+        assert msee_mock.calls('delete_port_from_vrf') == [
+            {'method': 'delete_port_from_vrf', 'args': {'port': 4, 'outer_vlan': 0, 'inner_vlan': 0}, 'result': 'REMOVED'},
+        ]
+        assert msee_mock.state()['ports'] == []
+
+    def test_heartbeat(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        r = restapi_client.get_heartbeat()
+        assert r.status_code == 200
+        j = json.loads(r.text)
+        assert j['backends']['msee'] == {'address': 'localhost:9090', 'reachable': True, 'circuit': 'closed'}
+
+    def test_data_plane_error(self, setup_restapi_client, msee_mock):
+        _, _, configdb, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vlan_and_deps()
+        # The port is already bound in the data plane, but not in CONFIG_DB
+        msee_mock.load({'vrfs': [{'vrf_id': 1, 'vni': 1001}],
+                        'ports': [{'vrf_id': 1, 'port': 4, 'outer_vlan': 0, 'inner_vlan': 0}]})
+        r = restapi_client.post_config_vlan_member(2, 'Ethernet4', {'tagging_mode' : 'untagged'})
+        assert r.status_code == 409
+        assert configdb.hgetall(VLAN_MEMB_TB + '|' + VLAN_NAME_PREF + '2|Ethernet4') == {}
+
+        msee_mock.load({'vrfs': [{'vrf_id': 1, 'vni': 1001}], 'results': {'add_encap_route': ['NO_MEMORY']}})
+        routes = [{'cmd':'add', 'ip_prefix':'10.2.1.0/24', 'nexthop':'34.53.1.0', 'vnid':1001, 'mac_address':'00:08:aa:bb:cd:01'}]
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", routes)
+        assert r.status_code == 207
+        j = json.loads(r.text)
+        assert j['failed'][0]['error_code'] == 500
+        assert j['failed'][0]['error_msg'] == "Data plane error: add_encap_route returned NO_MEMORY"
+        restapi_client.check_routes_dont_exist_in_tun_tb(1, routes)
+
+    def test_ecmp_route_rejected(self, setup_restapi_client, msee_mock):
+        _, _, _, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vrouter_and_deps()
+        routes = [{'cmd':'add', 'ip_prefix':'10.2.1.0/24', 'nexthop':'34.53.1.0,34.53.1.1', 'vnid':1001}]
+        r = restapi_client.patch_config_vrouter_vrf_id_routes("vnet-guid-1", routes)
+        assert r.status_code == 207
+        j = json.loads(r.text)
+        assert j['failed'][0]['error_code'] == 400
+        assert j['failed'][0]['error_msg'] == "Data plane does not support multiple nexthops"
+        restapi_client.check_routes_dont_exist_in_tun_tb(1, routes)
+        assert msee_mock.calls('add_encap_route') == []
+
+    def test_unsupported_member_rejected(self, setup_restapi_client, msee_mock):
+        _, _, configdb, restapi_client = setup_restapi_client
+        restapi_client.post_generic_vlan_and_deps()
+        r = restapi_client.post_config_vlan_member(2, 'PortChannel1', {'tagging_mode' : 'untagged'})
+        assert r.status_code == 400
+        j = json.loads(r.text)
+        assert j['error']['message'] == "Interface not supported by data plane: PortChannel1"
+        assert configdb.hgetall(VLAN_MEMB_TB + '|' + VLAN_NAME_PREF + '2|PortChannel1') == {}
+        assert msee_mock.calls('add_port_to_vrf') == []